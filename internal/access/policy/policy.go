@@ -0,0 +1,315 @@
+// Package policy enforces per-client API key policies: allowed models, request rates,
+// concurrency caps and token budgets. Token consumption is fed from coreusage records.
+package policy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// Violation describes why a request was rejected by a key policy.
+type Violation struct {
+	// StatusCode is the HTTP status returned to the client.
+	StatusCode int
+	// Message is a human readable explanation.
+	Message string
+	// RetryAfter, when positive, tells the client when the limit resets.
+	RetryAfter time.Duration
+}
+
+// keyState holds the runtime counters of a single client API key.
+type keyState struct {
+	inFlight    int
+	recent      []time.Time
+	day         string
+	dayTokens   int64
+	month       string
+	monthTokens int64
+}
+
+// Enforcer evaluates API key policies and tracks the counters they depend on.
+type Enforcer struct {
+	mu       sync.Mutex
+	policies map[string]config.APIKeyPolicy
+	states   map[string]*keyState
+	now      func() time.Time
+	// tokensSince reports the persisted tokens of a key since a time; see SetUsageSource.
+	tokensSince func(apiKey string, since time.Time) int64
+}
+
+// NewEnforcer creates an enforcer without policies; every request is allowed until SetPolicies is called.
+func NewEnforcer() *Enforcer {
+	return &Enforcer{
+		policies: make(map[string]config.APIKeyPolicy),
+		states:   make(map[string]*keyState),
+		now:      time.Now,
+	}
+}
+
+// SetPolicies replaces the active policies. Counters of keys that keep a policy are preserved;
+// keys that gain one start from the usage source, if any.
+func (e *Enforcer) SetPolicies(policies []config.APIKeyPolicy) {
+	if e == nil {
+		return
+	}
+	next := make(map[string]config.APIKeyPolicy, len(policies))
+	for _, p := range policies {
+		key := strings.TrimSpace(p.APIKey)
+		if key == "" {
+			continue
+		}
+		next[key] = p
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	previous := e.policies
+	e.policies = next
+	for key := range next {
+		if _, kept := previous[key]; !kept {
+			e.seedLocked(key)
+		}
+	}
+}
+
+// SetUsageSource makes token budgets start from the usage already recorded for a key, so
+// that they survive restarts and apply to keys that gain a policy later. tokensSince
+// returns the tokens charged to apiKey since the given time, e.g. from persisted usage.
+func (e *Enforcer) SetUsageSource(tokensSince func(apiKey string, since time.Time) int64) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.tokensSince = tokensSince
+	for key := range e.policies {
+		e.seedLocked(key)
+	}
+}
+
+// seedLocked resets the budget counters of apiKey to the usage reported by the source.
+func (e *Enforcer) seedLocked(apiKey string) {
+	if e.tokensSince == nil {
+		return
+	}
+	now := e.now()
+	state := e.stateLocked(apiKey, now)
+	y, m, d := now.Date()
+	state.dayTokens = e.tokensSince(apiKey, time.Date(y, m, d, 0, 0, 0, 0, now.Location()))
+	state.monthTokens = e.tokensSince(apiKey, time.Date(y, m, 1, 0, 0, 0, 0, now.Location()))
+}
+
+// Acquire checks whether apiKey may start a request for model. On success it returns a
+// release function that must be called once the request finished; on rejection it returns
+// the violation and a nil release function.
+func (e *Enforcer) Acquire(apiKey, model string) (func(), *Violation) {
+	noop := func() {}
+	if e == nil || apiKey == "" {
+		return noop, nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	p, ok := e.policies[apiKey]
+	if !ok {
+		return noop, nil
+	}
+	if violation := modelViolation(p, model); violation != nil {
+		return nil, violation
+	}
+
+	now := e.now()
+	state := e.stateLocked(apiKey, now)
+	if p.DailyTokenBudget > 0 && state.dayTokens >= p.DailyTokenBudget {
+		return nil, &Violation{StatusCode: http.StatusTooManyRequests, Message: "daily token budget exhausted for this API key", RetryAfter: nextDay(now).Sub(now)}
+	}
+	if p.MonthlyTokenBudget > 0 && state.monthTokens >= p.MonthlyTokenBudget {
+		return nil, &Violation{StatusCode: http.StatusTooManyRequests, Message: "monthly token budget exhausted for this API key", RetryAfter: nextMonth(now).Sub(now)}
+	}
+	if p.MaxConcurrentRequests > 0 && state.inFlight >= p.MaxConcurrentRequests {
+		return nil, &Violation{StatusCode: http.StatusTooManyRequests, Message: "too many concurrent requests for this API key", RetryAfter: time.Second}
+	}
+	if p.RequestsPerMinute > 0 {
+		cutoff := now.Add(-time.Minute)
+		kept := state.recent[:0]
+		for _, ts := range state.recent {
+			if ts.After(cutoff) {
+				kept = append(kept, ts)
+			}
+		}
+		state.recent = kept
+		if len(state.recent) >= p.RequestsPerMinute {
+			return nil, &Violation{StatusCode: http.StatusTooManyRequests, Message: "requests per minute limit exceeded for this API key", RetryAfter: state.recent[0].Add(time.Minute).Sub(now)}
+		}
+		state.recent = append(state.recent, now)
+	}
+
+	state.inFlight++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			e.mu.Lock()
+			if state.inFlight > 0 {
+				state.inFlight--
+			}
+			e.mu.Unlock()
+		})
+	}, nil
+}
+
+// CheckModel reports the violation when the policy of apiKey does not allow model. Unlike
+// Acquire it neither starts a request nor checks rate or budget limits, so it suits
+// validating work that is executed later.
+func (e *Enforcer) CheckModel(apiKey, model string) *Violation {
+	if e == nil || apiKey == "" {
+		return nil
+	}
+	e.mu.Lock()
+	p, ok := e.policies[apiKey]
+	e.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return modelViolation(p, model)
+}
+
+// Keys returns the API keys that currently have a policy.
+func (e *Enforcer) Keys() []string {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	keys := make([]string, 0, len(e.policies))
+	for key := range e.policies {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// HandleUsage implements coreusage.Plugin and charges consumed tokens to the client key.
+func (e *Enforcer) HandleUsage(_ context.Context, record coreusage.Record) {
+	if e == nil || record.APIKey == "" {
+		return
+	}
+	tokens := record.Detail.TotalTokens
+	if tokens == 0 {
+		tokens = record.Detail.InputTokens + record.Detail.OutputTokens + record.Detail.ReasoningTokens
+	}
+	if tokens <= 0 {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.policies[record.APIKey]; !ok {
+		return
+	}
+	ts := record.RequestedAt
+	if ts.IsZero() {
+		ts = e.now()
+	}
+	state := e.stateLocked(record.APIKey, e.now())
+	if ts.Format("2006-01-02") == state.day {
+		state.dayTokens += tokens
+	}
+	if ts.Format("2006-01") == state.month {
+		state.monthTokens += tokens
+	}
+}
+
+// Usage reports the tokens charged to apiKey in the current day and month.
+func (e *Enforcer) Usage(apiKey string) (daily, monthly int64) {
+	if e == nil {
+		return 0, 0
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	state := e.stateLocked(apiKey, e.now())
+	return state.dayTokens, state.monthTokens
+}
+
+// stateLocked returns the counters for apiKey, rolling the budget periods over when needed.
+func (e *Enforcer) stateLocked(apiKey string, now time.Time) *keyState {
+	state, ok := e.states[apiKey]
+	if !ok {
+		state = &keyState{}
+		e.states[apiKey] = state
+	}
+	if day := now.Format("2006-01-02"); state.day != day {
+		state.day = day
+		state.dayTokens = 0
+	}
+	if month := now.Format("2006-01"); state.month != month {
+		state.month = month
+		state.monthTokens = 0
+	}
+	return state
+}
+
+func modelViolation(p config.APIKeyPolicy, model string) *Violation {
+	if model == "" || modelAllowed(p, model) {
+		return nil
+	}
+	return &Violation{StatusCode: http.StatusForbidden, Message: fmt.Sprintf("model %s is not allowed for this API key", model)}
+}
+
+func modelAllowed(p config.APIKeyPolicy, model string) bool {
+	for _, pattern := range p.DeniedModels {
+		if matchGlob(pattern, model) {
+			return false
+		}
+	}
+	if len(p.AllowedModels) == 0 {
+		return true
+	}
+	for _, pattern := range p.AllowedModels {
+		if matchGlob(pattern, model) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches value against a pattern where '*' matches any run of characters
+// (including '/') and '?' matches a single character. Matching is case-insensitive.
+func matchGlob(pattern, value string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	value = strings.ToLower(value)
+	p, v := 0, 0
+	star, match := -1, 0
+	for v < len(value) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == value[v]):
+			p++
+			v++
+		case p < len(pattern) && pattern[p] == '*':
+			star, match = p, v
+			p++
+		case star >= 0:
+			p = star + 1
+			match++
+			v = match
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+func nextDay(now time.Time) time.Time {
+	y, m, d := now.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+}
+
+func nextMonth(now time.Time) time.Time {
+	y, m, _ := now.Date()
+	return time.Date(y, m+1, 1, 0, 0, 0, 0, now.Location())
+}
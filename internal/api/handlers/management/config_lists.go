@@ -119,6 +119,88 @@ func (h *Handler) DeleteAPIKeys(c *gin.Context) {
 	h.deleteFromStringList(c, &h.cfg.APIKeys, func() { h.cfg.Access.Providers = nil })
 }
 
+// api-key-policies: []APIKeyPolicy
+func (h *Handler) GetAPIKeyPolicies(c *gin.Context) {
+	c.JSON(200, gin.H{"api-key-policies": h.cfg.APIKeyPolicies})
+}
+func (h *Handler) PutAPIKeyPolicies(c *gin.Context) {
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(400, gin.H{"error": "failed to read body"})
+		return
+	}
+	var arr []config.APIKeyPolicy
+	if err = json.Unmarshal(data, &arr); err != nil {
+		var obj struct {
+			Items []config.APIKeyPolicy `json:"items"`
+		}
+		if err2 := json.Unmarshal(data, &obj); err2 != nil || len(obj.Items) == 0 {
+			c.JSON(400, gin.H{"error": "invalid body"})
+			return
+		}
+		arr = obj.Items
+	}
+	h.cfg.APIKeyPolicies = arr
+	h.persist(c)
+}
+
+// PatchAPIKeyPolicy replaces the policy at index or the one whose api-key equals match.
+// A match that does not exist yet adds the policy.
+func (h *Handler) PatchAPIKeyPolicy(c *gin.Context) {
+	var body struct {
+		Index *int                 `json:"index"`
+		Match *string              `json:"match"`
+		Value *config.APIKeyPolicy `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Value == nil {
+		c.JSON(400, gin.H{"error": "invalid body"})
+		return
+	}
+	if body.Index != nil && *body.Index >= 0 && *body.Index < len(h.cfg.APIKeyPolicies) {
+		h.cfg.APIKeyPolicies[*body.Index] = *body.Value
+		h.persist(c)
+		return
+	}
+	if body.Match != nil {
+		for i := range h.cfg.APIKeyPolicies {
+			if h.cfg.APIKeyPolicies[i].APIKey == *body.Match {
+				h.cfg.APIKeyPolicies[i] = *body.Value
+				h.persist(c)
+				return
+			}
+		}
+		if strings.TrimSpace(body.Value.APIKey) != "" {
+			h.cfg.APIKeyPolicies = append(h.cfg.APIKeyPolicies, *body.Value)
+			h.persist(c)
+			return
+		}
+	}
+	c.JSON(404, gin.H{"error": "item not found"})
+}
+func (h *Handler) DeleteAPIKeyPolicy(c *gin.Context) {
+	if val := c.Query("api-key"); val != "" {
+		out := make([]config.APIKeyPolicy, 0, len(h.cfg.APIKeyPolicies))
+		for _, v := range h.cfg.APIKeyPolicies {
+			if v.APIKey != val {
+				out = append(out, v)
+			}
+		}
+		h.cfg.APIKeyPolicies = out
+		h.persist(c)
+		return
+	}
+	if idxStr := c.Query("index"); idxStr != "" {
+		var idx int
+		_, err := fmt.Sscanf(idxStr, "%d", &idx)
+		if err == nil && idx >= 0 && idx < len(h.cfg.APIKeyPolicies) {
+			h.cfg.APIKeyPolicies = append(h.cfg.APIKeyPolicies[:idx], h.cfg.APIKeyPolicies[idx+1:]...)
+			h.persist(c)
+			return
+		}
+	}
+	c.JSON(400, gin.H{"error": "missing api-key or index"})
+}
+
 // generative-language-api-key
 func (h *Handler) GetGlKeys(c *gin.Context) {
 	c.JSON(200, gin.H{"generative-language-api-key": h.cfg.GlAPIKey})
@@ -0,0 +1,312 @@
+package management
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// Credential health values, from the most to the least severe.
+const (
+	healthRemoved       = "removed"
+	healthDisabled      = "disabled"
+	healthError         = "error"
+	healthQuotaExceeded = "quota_exceeded"
+	healthCoolingDown   = "cooling_down"
+	healthOK            = "ok"
+)
+
+// credentialHealth joins the runtime state of a credential with its usage.
+type credentialHealth struct {
+	ID              string                `json:"id"`
+	Provider        string                `json:"provider"`
+	Label           string                `json:"label,omitempty"`
+	Account         string                `json:"account,omitempty"`
+	File            string                `json:"file,omitempty"`
+	Health          string                `json:"health"`
+	Status          coreauth.Status       `json:"status,omitempty"`
+	StatusMessage   string                `json:"status_message,omitempty"`
+	Disabled        bool                  `json:"disabled"`
+	Unavailable     bool                  `json:"unavailable"`
+	Quota           coreauth.QuotaState   `json:"quota"`
+	LastError       *coreauth.Error       `json:"last_error,omitempty"`
+	NextRetryAfter  time.Time             `json:"next_retry_after"`
+	LastRefreshedAt time.Time             `json:"last_refreshed_at"`
+	NextRefreshAt   time.Time             `json:"next_refresh_at"`
+	Cooldowns       []modelCooldown       `json:"cooldowns"`
+	TotalRequests   int64                 `json:"total_requests"`
+	SuccessCount    int64                 `json:"success_count"`
+	FailureCount    int64                 `json:"failure_count"`
+	SuccessRate     *float64              `json:"success_rate"`
+	TotalTokens     int64                 `json:"total_tokens"`
+	TotalCost       float64               `json:"total_cost"`
+	Errors          map[string]int64      `json:"errors,omitempty"`
+	Latency         *usage.LatencySummary `json:"latency,omitempty"`
+	LastSuccessAt   time.Time             `json:"last_success_at"`
+	LastFailureAt   time.Time             `json:"last_failure_at"`
+}
+
+// modelCooldown is a model of a credential that is currently blocked or failing.
+type modelCooldown struct {
+	Model string `json:"model"`
+	*coreauth.ModelState
+}
+
+// providerHealth rolls up the credentials of one provider.
+type providerHealth struct {
+	Provider      string         `json:"provider"`
+	Credentials   int            `json:"credentials"`
+	Health        map[string]int `json:"health"`
+	TotalRequests int64          `json:"total_requests"`
+	FailureCount  int64          `json:"failure_count"`
+	SuccessRate   *float64       `json:"success_rate"`
+	TotalTokens   int64          `json:"total_tokens"`
+	TotalCost     float64        `json:"total_cost"`
+}
+
+// credentialSortKeys maps the sort query parameter to a comparison and its default order.
+var credentialSortKeys = map[string]struct {
+	less func(a, b *credentialHealth) bool
+	desc bool
+}{
+	"id":           {less: func(a, b *credentialHealth) bool { return a.ID < b.ID }},
+	"provider":     {less: func(a, b *credentialHealth) bool { return a.Provider < b.Provider }},
+	"health":       {less: func(a, b *credentialHealth) bool { return healthRank(a.Health) < healthRank(b.Health) }},
+	"requests":     {less: func(a, b *credentialHealth) bool { return a.TotalRequests < b.TotalRequests }, desc: true},
+	"failures":     {less: func(a, b *credentialHealth) bool { return a.FailureCount < b.FailureCount }, desc: true},
+	"success_rate": {less: func(a, b *credentialHealth) bool { return rateValue(a.SuccessRate) < rateValue(b.SuccessRate) }},
+	"tokens":       {less: func(a, b *credentialHealth) bool { return a.TotalTokens < b.TotalTokens }, desc: true},
+	"cost":         {less: func(a, b *credentialHealth) bool { return a.TotalCost < b.TotalCost }, desc: true},
+	"cooldowns":    {less: func(a, b *credentialHealth) bool { return len(a.Cooldowns) < len(b.Cooldowns) }, desc: true},
+	"last_success": {less: func(a, b *credentialHealth) bool { return a.LastSuccessAt.Before(b.LastSuccessAt) }},
+	"last_failure": {less: func(a, b *credentialHealth) bool { return a.LastFailureAt.Before(b.LastFailureAt) }, desc: true},
+	"next_refresh": {less: func(a, b *credentialHealth) bool { return a.NextRefreshAt.Before(b.NextRefreshAt) }},
+}
+
+// GetCredentials lists every credential with its runtime state and usage, plus a rollup
+// per provider. Query parameters: provider and health filter the list; from and to limit
+// the usage window; sort (id, provider, health, requests, failures, success_rate, tokens,
+// cost, cooldowns, last_success, last_failure, next_refresh), order (asc or desc) and
+// limit shape it. Provider rollups cover every filtered credential regardless of limit.
+func (h *Handler) GetCredentials(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	var window usage.Filter
+	var err error
+	if window.From, err = parseUsageTime(c.Query("from")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid from: %v", err)})
+		return
+	}
+	if window.To, err = parseUsageTime(c.Query("to")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid to: %v", err)})
+		return
+	}
+	sortKey := strings.ToLower(strings.TrimSpace(c.DefaultQuery("sort", "id")))
+	key, ok := credentialSortKeys[sortKey]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid sort %q", sortKey)})
+		return
+	}
+	desc := key.desc
+	switch strings.ToLower(strings.TrimSpace(c.Query("order"))) {
+	case "":
+	case "asc":
+		desc = false
+	case "desc":
+		desc = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order, expected asc or desc"})
+		return
+	}
+	limit := 0
+	if raw := strings.TrimSpace(c.Query("limit")); raw != "" {
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+	}
+	providerFilter := strings.ToLower(strings.TrimSpace(c.Query("provider")))
+	healthFilter := strings.ToLower(strings.TrimSpace(c.Query("health")))
+
+	var stats map[string]usage.CredentialSnapshot
+	if h.usageStats != nil {
+		stats = h.usageStats.Query(window).Credentials
+	}
+	now := time.Now()
+	rows := make([]*credentialHealth, 0, len(stats))
+	seen := make(map[string]struct{})
+	for _, auth := range h.authManager.List() {
+		seen[auth.ID] = struct{}{}
+		rows = append(rows, newCredentialHealth(auth, stats[auth.ID], now))
+	}
+	// Credentials deleted since their requests were recorded still show up in usage.
+	for id, snapshot := range stats {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		row := &credentialHealth{ID: id, Provider: snapshot.Provider, Health: healthRemoved, Cooldowns: []modelCooldown{}}
+		row.applyUsage(snapshot)
+		rows = append(rows, row)
+	}
+
+	filtered := rows[:0]
+	for _, row := range rows {
+		if providerFilter != "" && strings.ToLower(row.Provider) != providerFilter {
+			continue
+		}
+		if healthFilter != "" && row.Health != healthFilter {
+			continue
+		}
+		filtered = append(filtered, row)
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		a, b := filtered[i], filtered[j]
+		if key.less(a, b) {
+			return !desc
+		}
+		if key.less(b, a) {
+			return desc
+		}
+		return a.ID < b.ID
+	})
+
+	providers := rollupCredentialHealth(filtered)
+	total := len(filtered)
+	if limit > 0 && limit < len(filtered) {
+		filtered = filtered[:limit]
+	}
+	c.JSON(http.StatusOK, gin.H{"total": total, "credentials": filtered, "providers": providers})
+}
+
+func newCredentialHealth(auth *coreauth.Auth, snapshot usage.CredentialSnapshot, now time.Time) *credentialHealth {
+	row := &credentialHealth{
+		ID:              auth.ID,
+		Provider:        auth.Provider,
+		Label:           auth.Label,
+		Status:          auth.Status,
+		StatusMessage:   auth.StatusMessage,
+		Disabled:        auth.Disabled,
+		Unavailable:     auth.Unavailable,
+		Quota:           auth.Quota,
+		LastError:       auth.LastError,
+		NextRetryAfter:  auth.NextRetryAfter,
+		LastRefreshedAt: auth.LastRefreshedAt,
+		NextRefreshAt:   coreauth.NextRefreshTime(auth, now),
+		Cooldowns:       []modelCooldown{},
+	}
+	if _, account := auth.AccountInfo(); account != "" {
+		row.Account = account
+	}
+	if auth.FileName != "" {
+		row.File = filepath.Base(auth.FileName)
+	}
+	quotaExceeded := auth.Quota.Exceeded
+	for model, state := range auth.ModelStates {
+		if state == nil {
+			continue
+		}
+		blocked := state.Unavailable && state.NextRetryAfter.After(now)
+		if !blocked && !state.Quota.Exceeded && state.Status != coreauth.StatusError {
+			continue
+		}
+		quotaExceeded = quotaExceeded || state.Quota.Exceeded
+		row.Cooldowns = append(row.Cooldowns, modelCooldown{Model: model, ModelState: state})
+	}
+	sort.Slice(row.Cooldowns, func(i, j int) bool { return row.Cooldowns[i].Model < row.Cooldowns[j].Model })
+
+	switch {
+	case auth.Disabled || auth.Status == coreauth.StatusDisabled:
+		row.Health = healthDisabled
+	case auth.Status == coreauth.StatusError:
+		row.Health = healthError
+	case quotaExceeded:
+		row.Health = healthQuotaExceeded
+	case len(row.Cooldowns) > 0 || auth.NextRetryAfter.After(now):
+		row.Health = healthCoolingDown
+	default:
+		row.Health = healthOK
+	}
+	row.applyUsage(snapshot)
+	return row
+}
+
+func (row *credentialHealth) applyUsage(snapshot usage.CredentialSnapshot) {
+	row.TotalRequests = snapshot.TotalRequests
+	row.FailureCount = snapshot.FailureCount
+	row.SuccessCount = snapshot.TotalRequests - snapshot.FailureCount
+	row.SuccessRate = successRate(row.SuccessCount, row.TotalRequests)
+	row.TotalTokens = snapshot.TotalTokens
+	row.TotalCost = snapshot.TotalCost
+	row.Errors = snapshot.Errors
+	row.Latency = snapshot.Latency
+	row.LastSuccessAt = snapshot.LastSuccessAt
+	row.LastFailureAt = snapshot.LastFailureAt
+}
+
+func rollupCredentialHealth(rows []*credentialHealth) []providerHealth {
+	byProvider := make(map[string]*providerHealth)
+	for _, row := range rows {
+		rollup, ok := byProvider[row.Provider]
+		if !ok {
+			rollup = &providerHealth{Provider: row.Provider, Health: make(map[string]int)}
+			byProvider[row.Provider] = rollup
+		}
+		rollup.Credentials++
+		rollup.Health[row.Health]++
+		rollup.TotalRequests += row.TotalRequests
+		rollup.FailureCount += row.FailureCount
+		rollup.TotalTokens += row.TotalTokens
+		rollup.TotalCost += row.TotalCost
+	}
+	out := make([]providerHealth, 0, len(byProvider))
+	for _, rollup := range byProvider {
+		rollup.SuccessRate = successRate(rollup.TotalRequests-rollup.FailureCount, rollup.TotalRequests)
+		out = append(out, *rollup)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Provider < out[j].Provider })
+	return out
+}
+
+// successRate returns nil when there were no requests.
+func successRate(successes, total int64) *float64 {
+	if total <= 0 {
+		return nil
+	}
+	rate := float64(successes) / float64(total)
+	return &rate
+}
+
+// rateValue sorts credentials without requests below every measured rate.
+func rateValue(rate *float64) float64 {
+	if rate == nil {
+		return -1
+	}
+	return *rate
+}
+
+func healthRank(health string) int {
+	switch health {
+	case healthRemoved:
+		return 0
+	case healthDisabled:
+		return 1
+	case healthError:
+		return 2
+	case healthQuotaExceeded:
+		return 3
+	case healthCoolingDown:
+		return 4
+	default:
+		return 5
+	}
+}
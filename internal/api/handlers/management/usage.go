@@ -1,17 +1,223 @@
 package management
 
 import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 )
 
-// GetUsageStatistics returns the in-memory request statistics snapshot.
+// GetUsageStatistics returns the request statistics snapshot. Optional query parameters
+// from, to (RFC3339 or unix seconds), api, model, provider and auth-id narrow the snapshot;
+// granularity (minute, hour or day) additionally returns the matching rollup buckets.
 func (h *Handler) GetUsageStatistics(c *gin.Context) {
+	filter, err := usageFilterFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var granularity usage.Granularity
+	if raw := strings.TrimSpace(c.Query("granularity")); raw != "" {
+		g, ok := usage.ParseGranularity(raw)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid granularity, expected minute, hour or day"})
+			return
+		}
+		granularity = g
+	}
+
 	var snapshot usage.StatisticsSnapshot
+	var rollups []usage.Bucket
 	if h != nil && h.usageStats != nil {
-		snapshot = h.usageStats.Snapshot()
+		snapshot = h.usageStats.Query(filter)
+		if granularity != "" {
+			rollups = h.usageStats.Rollups(granularity, filter)
+		}
+	}
+	if granularity != "" {
+		c.JSON(http.StatusOK, gin.H{"usage": snapshot, "rollups": rollups})
+		return
 	}
 	c.JSON(http.StatusOK, gin.H{"usage": snapshot})
 }
+
+// GetUsageCost returns a cost report for the usage matching the same filters as
+// GetUsageStatistics. granularity splits the report into periods and format=csv returns
+// the rows as a CSV attachment instead of JSON. Ranges starting before the retention of
+// the rollups are rejected rather than reported incompletely.
+func (h *Handler) GetUsageCost(c *gin.Context) {
+	filter, err := usageFilterFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var granularity usage.Granularity
+	if raw := strings.TrimSpace(c.Query("granularity")); raw != "" {
+		g, ok := usage.ParseGranularity(raw)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid granularity, expected minute, hour or day"})
+			return
+		}
+		granularity = g
+	}
+	format := strings.ToLower(strings.TrimSpace(c.DefaultQuery("format", "json")))
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid format, expected json or csv"})
+		return
+	}
+
+	var report usage.CostReport
+	if h != nil && h.usageStats != nil {
+		report = h.usageStats.CostReport(filter, granularity)
+	}
+	if report.Truncated {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("from is outside the usage retention; usage is only available from %s", report.RetainedFrom.Format(time.RFC3339))})
+		return
+	}
+	if format == "json" {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+	data, err := costReportCSV(report)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="usage-cost.csv"`)
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", data)
+}
+
+// GetUsageExport returns the recorded usage events as a versioned dump. The same filters
+// as GetUsageStatistics apply; format=ndjson streams one event per line after a header.
+func (h *Handler) GetUsageExport(c *gin.Context) {
+	filter, err := usageFilterFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format := strings.ToLower(strings.TrimSpace(c.DefaultQuery("format", "json")))
+	if format != "json" && format != "ndjson" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid format, expected json or ndjson"})
+		return
+	}
+	dump, err := usage.ExportUsage(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(usageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	filename := "usage-export-" + dump.ExportedAt.Format("20060102-150405") + "." + format
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if format == "json" {
+		c.JSON(http.StatusOK, dump)
+		return
+	}
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	if err = dump.WriteNDJSON(c.Writer); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+// PostUsageImport merges a dump produced by GetUsageExport, in either format. Events that
+// were already recorded are skipped, so importing the same dump twice is harmless.
+func (h *Handler) PostUsageImport(c *gin.Context) {
+	dump, err := usage.DecodeDump(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	imported, skipped, err := usage.ImportUsage(c.Request.Context(), dump)
+	if err != nil {
+		c.JSON(usageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"imported": imported, "skipped": skipped})
+}
+
+// DeleteUsage resets the usage statistics. The same filters as GetUsageStatistics limit
+// the reset to matching requests, e.g. a single client key or a date range.
+func (h *Handler) DeleteUsage(c *gin.Context) {
+	filter, err := usageFilterFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	removed, err := usage.DeleteUsage(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(usageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"removed": removed})
+}
+
+func usageErrorStatus(err error) int {
+	if errors.Is(err, usage.ErrPersistenceInactive) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+func costReportCSV(report usage.CostReport) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	records := [][]string{{
+		"period", "api", "model", "provider", "auth_id", "requests", "failures",
+		"input_tokens", "output_tokens", "reasoning_tokens", "cached_tokens", "total_tokens", "cost",
+	}}
+	for _, row := range report.Rows {
+		records = append(records, []string{
+			row.Period, row.APIKey, row.Model, row.Provider, row.AuthID,
+			strconv.FormatInt(row.Requests, 10),
+			strconv.FormatInt(row.Failures, 10),
+			strconv.FormatInt(row.Tokens.InputTokens, 10),
+			strconv.FormatInt(row.Tokens.OutputTokens, 10),
+			strconv.FormatInt(row.Tokens.ReasoningTokens, 10),
+			strconv.FormatInt(row.Tokens.CachedTokens, 10),
+			strconv.FormatInt(row.Tokens.TotalTokens, 10),
+			strconv.FormatFloat(row.Cost, 'f', 6, 64),
+		})
+	}
+	if err := w.WriteAll(records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func usageFilterFromQuery(c *gin.Context) (usage.Filter, error) {
+	filter := usage.Filter{
+		APIKey:   strings.TrimSpace(c.Query("api")),
+		Model:    strings.TrimSpace(c.Query("model")),
+		Provider: strings.TrimSpace(c.Query("provider")),
+		AuthID:   strings.TrimSpace(c.Query("auth-id")),
+	}
+	var err error
+	if filter.From, err = parseUsageTime(c.Query("from")); err != nil {
+		return filter, fmt.Errorf("invalid from: %w", err)
+	}
+	if filter.To, err = parseUsageTime(c.Query("to")); err != nil {
+		return filter, fmt.Errorf("invalid to: %w", err)
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return filter, fmt.Errorf("from must be before to")
+	}
+	return filter, nil
+}
+
+// parseUsageTime accepts RFC3339 timestamps or unix seconds; empty input yields the zero time.
+func parseUsageTime(raw string) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if seconds, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
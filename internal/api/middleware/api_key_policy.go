@@ -0,0 +1,113 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains the middleware enforcing per-client API key policies.
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/access/policy"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+)
+
+// APIKeyPolicyMiddleware rejects requests that violate the policy of the authenticated
+// client API key. It must run after the authentication middleware, which stores the key
+// under "apiKey". Rejections use the error format of the API the client is talking to.
+func APIKeyPolicyMiddleware(enforcer *policy.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if enforcer == nil {
+			c.Next()
+			return
+		}
+		apiKey, _ := c.Get("apiKey")
+		key, _ := apiKey.(string)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		release, violation := enforcer.Acquire(key, requestModel(c))
+		if violation != nil {
+			msg := &interfaces.ErrorMessage{StatusCode: violation.StatusCode, Error: errors.New(violation.Message)}
+			if violation.RetryAfter > 0 {
+				seconds := int(math.Ceil(violation.RetryAfter.Seconds()))
+				c.Header("Retry-After", strconv.Itoa(seconds))
+			}
+			c.Data(violation.StatusCode, "application/json", handlers.BuildErrorResponseBody(dialectForPath(c.Request.URL.Path), msg))
+			c.Abort()
+			return
+		}
+		defer release()
+		// Fallback models configured on the server must not route around the policy.
+		c.Set(handlers.ModelFilterKey, func(model string) bool {
+			return enforcer.CheckModel(key, model) == nil
+		})
+		c.Next()
+	}
+}
+
+// requestModel extracts the requested model from the Gemini style path action or the JSON body.
+// The body is restored so downstream handlers can read it again.
+func requestModel(c *gin.Context) string {
+	if action := c.Param("action"); action != "" {
+		if idx := strings.Index(action, ":"); idx > 0 {
+			return action[:idx]
+		}
+		return action
+	}
+	if c.Request.Method != http.MethodPost || c.Request.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	_ = c.Request.Body.Close()
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	if mediaType, params, errParse := mime.ParseMediaType(c.GetHeader("Content-Type")); errParse == nil && mediaType == "multipart/form-data" {
+		return multipartModel(body, params["boundary"])
+	}
+	return gjson.GetBytes(body, "model").String()
+}
+
+// multipartModel reads the model field of a multipart form body.
+func multipartModel(body []byte, boundary string) string {
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return ""
+		}
+		if part.FormName() == "model" && part.FileName() == "" {
+			value, _ := io.ReadAll(io.LimitReader(part, 1024))
+			return strings.TrimSpace(string(value))
+		}
+	}
+}
+
+// dialectForPath maps a request path to the handler type whose error format the client expects.
+func dialectForPath(path string) string {
+	switch {
+	case strings.HasPrefix(path, "/v1/messages"):
+		return constant.Claude
+	case strings.HasPrefix(path, "/v1beta"):
+		return constant.Gemini
+	case strings.HasPrefix(path, "/v1internal"):
+		return constant.GeminiCLI
+	case strings.HasPrefix(path, "/api/"):
+		return constant.Ollama
+	default:
+		return constant.OpenAI
+	}
+}
@@ -20,7 +20,7 @@ func RequestLoggingMiddleware(logger logging.RequestLogger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		path := c.Request.URL.Path
 		shouldLog := false
-		if strings.HasPrefix(path, "/v1") {
+		if strings.HasPrefix(path, "/v1") || strings.HasPrefix(path, "/api/") {
 			shouldLog = true
 		}
 
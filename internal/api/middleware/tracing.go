@@ -0,0 +1,47 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains the middleware that opens the server span of a traced request.
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
+)
+
+// TracingMiddleware starts the server span of every request, continuing the trace of an
+// incoming W3C traceparent header when present. The span is stored in the request context
+// so handlers, credential attempts and upstream calls are recorded as its children.
+func TracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !tracing.Enabled() {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+		if parent, ok := tracing.ParseTraceparent(c.GetHeader(tracing.TraceparentHeader)); ok {
+			ctx = tracing.ContextWithRemoteParent(ctx, parent)
+		}
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		ctx, span := tracing.StartSpan(ctx, c.Request.Method+" "+route, tracing.SpanKindServer)
+		defer span.End()
+		span.SetAttribute("http.request.method", c.Request.Method)
+		span.SetAttribute("http.route", route)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttribute("http.response.status_code", status)
+		if handlerType, ok := c.Get("API_HANDLER_TYPE"); ok {
+			span.SetAttribute("handler", handlerType)
+		}
+		if status >= http.StatusInternalServerError {
+			span.SetError(fmt.Errorf("request failed with status %d", status))
+		}
+	}
+}
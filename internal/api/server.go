@@ -12,25 +12,31 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/access"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/access/policy"
 	managementHandlers "github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/management"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/middleware"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/managementasset"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/metrics"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/batches"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/claude"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/gemini"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/ollama"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/openai"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 )
@@ -114,6 +120,9 @@ type Server struct {
 	// handlers contains the API handlers for processing requests.
 	handlers *handlers.BaseAPIHandler
 
+	// authManager is the core auth manager, used for credential health metrics.
+	authManager *auth.Manager
+
 	// cfg holds the current server configuration.
 	cfg *config.Config
 
@@ -124,6 +133,12 @@ type Server struct {
 	// accessManager handles request authentication providers.
 	accessManager *sdkaccess.Manager
 
+	// keyPolicies enforces per-client API key policies on authenticated routes.
+	keyPolicies *policy.Enforcer
+
+	// batchHandlers runs message batches in the background until the server stops.
+	batchHandlers *batches.BatchAPIHandler
+
 	// requestLogger is the request logger instance for dynamic configuration updates.
 	requestLogger logging.RequestLogger
 	loggerToggle  func(bool)
@@ -217,8 +232,10 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	s := &Server{
 		engine:              engine,
 		handlers:            handlers.NewBaseAPIHandlers(&cfg.SDKConfig, authManager),
+		authManager:         authManager,
 		cfg:                 cfg,
 		accessManager:       accessManager,
+		keyPolicies:         policy.NewEnforcer(),
 		requestLogger:       requestLogger,
 		loggerToggle:        toggle,
 		configFilePath:      configFilePath,
@@ -228,6 +245,11 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	// Save initial YAML snapshot
 	s.oldConfigYaml, _ = yaml.Marshal(cfg)
 	s.applyAccessConfig(nil, cfg)
+	s.keyPolicies.SetPolicies(cfg.APIKeyPolicies)
+	// Persisted usage has been loaded by now; budgets continue from it after a restart.
+	s.keyPolicies.SetUsageSource(usage.GetRequestStatistics().TokensSince)
+	coreusage.RegisterPlugin(s.keyPolicies)
+	s.applyMetrics(cfg.Metrics.Enable)
 	// Initialize management handler
 	s.mgmt = managementHandlers.NewHandler(cfg, configFilePath, authManager)
 	if optionState.localPassword != "" {
@@ -271,28 +293,60 @@ func (s *Server) setupRoutes() {
 	geminiCLIHandlers := gemini.NewGeminiCLIAPIHandler(s.handlers)
 	claudeCodeHandlers := claude.NewClaudeCodeAPIHandler(s.handlers)
 	openaiResponsesHandlers := openai.NewOpenAIResponsesAPIHandler(s.handlers)
+	ollamaHandlers := ollama.NewOllamaAPIHandler(s.handlers)
+	s.batchHandlers = batches.NewBatchAPIHandler(s.handlers, s.keyPolicies)
 
 	// OpenAI compatible API routes
 	v1 := s.engine.Group("/v1")
-	v1.Use(AuthMiddleware(s.accessManager))
+	v1.Use(middleware.TracingMiddleware(), AuthMiddleware(s.accessManager), middleware.APIKeyPolicyMiddleware(s.keyPolicies))
 	{
 		v1.GET("/models", s.unifiedModelsHandler(openaiHandlers, claudeCodeHandlers))
 		v1.POST("/chat/completions", openaiHandlers.ChatCompletions)
 		v1.POST("/completions", openaiHandlers.Completions)
+		v1.POST("/embeddings", openaiHandlers.Embeddings)
+		v1.POST("/images/generations", openaiHandlers.ImageGenerations)
+		v1.POST("/images/edits", openaiHandlers.ImageEdits)
 		v1.POST("/messages", claudeCodeHandlers.ClaudeMessages)
 		v1.POST("/messages/count_tokens", claudeCodeHandlers.ClaudeCountTokens)
 		v1.POST("/responses", openaiResponsesHandlers.Responses)
+		v1.GET("/responses/:id", openaiResponsesHandlers.GetResponse)
+		v1.DELETE("/responses/:id", openaiResponsesHandlers.DeleteResponse)
+		v1.POST("/messages/batches", s.batchHandlers.CreateMessageBatch)
+		v1.GET("/messages/batches", s.batchHandlers.ListMessageBatches)
+		v1.GET("/messages/batches/:id", s.batchHandlers.GetMessageBatch)
+		v1.DELETE("/messages/batches/:id", s.batchHandlers.DeleteMessageBatch)
+		v1.POST("/messages/batches/:id/cancel", s.batchHandlers.CancelMessageBatch)
+		v1.GET("/messages/batches/:id/results", s.batchHandlers.MessageBatchResults)
+		v1.POST("/batches", s.batchHandlers.CreateBatch)
+		v1.GET("/batches", s.batchHandlers.ListBatches)
+		v1.GET("/batches/:id", s.batchHandlers.GetBatch)
+		v1.POST("/batches/:id/cancel", s.batchHandlers.CancelBatch)
+		v1.POST("/files", s.batchHandlers.UploadFile)
+		v1.GET("/files", s.batchHandlers.ListFiles)
+		v1.GET("/files/:id", s.batchHandlers.GetFile)
+		v1.DELETE("/files/:id", s.batchHandlers.DeleteFile)
+		v1.GET("/files/:id/content", s.batchHandlers.GetFileContent)
 	}
 
 	// Gemini compatible API routes
 	v1beta := s.engine.Group("/v1beta")
-	v1beta.Use(AuthMiddleware(s.accessManager))
+	v1beta.Use(middleware.TracingMiddleware(), AuthMiddleware(s.accessManager), middleware.APIKeyPolicyMiddleware(s.keyPolicies))
 	{
 		v1beta.GET("/models", geminiHandlers.GeminiModels)
 		v1beta.POST("/models/:action", geminiHandlers.GeminiHandler)
 		v1beta.GET("/models/:action", geminiHandlers.GeminiGetHandler)
 	}
 
+	// Ollama compatible API routes
+	ollamaAPI := s.engine.Group("/api")
+	ollamaAPI.Use(middleware.TracingMiddleware(), AuthMiddleware(s.accessManager), middleware.APIKeyPolicyMiddleware(s.keyPolicies))
+	{
+		ollamaAPI.POST("/chat", ollamaHandlers.Chat)
+		ollamaAPI.POST("/generate", ollamaHandlers.Generate)
+		ollamaAPI.GET("/tags", ollamaHandlers.Tags)
+		ollamaAPI.POST("/show", ollamaHandlers.Show)
+	}
+
 	// Root endpoint
 	s.engine.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -300,11 +354,22 @@ func (s *Server) setupRoutes() {
 			"endpoints": []string{
 				"POST /v1/chat/completions",
 				"POST /v1/completions",
+				"POST /v1/embeddings",
+				"POST /v1/images/generations",
+				"POST /v1/images/edits",
+				"POST /v1/batches",
+				"POST /v1/messages/batches",
 				"GET /v1/models",
+				"POST /api/chat",
+				"POST /api/generate",
+				"GET /api/tags",
 			},
 		})
 	})
-	s.engine.POST("/v1internal:method", geminiCLIHandlers.CLIHandler)
+	s.engine.POST("/v1internal:method", middleware.TracingMiddleware(), geminiCLIHandlers.CLIHandler)
+	s.engine.GET("/metrics", s.serveMetrics)
+	// Temporary image URLs are fetched without credentials, like signed upstream URLs.
+	s.engine.GET("/v1/images/files/:id", openaiHandlers.ImageFile)
 
 	// OAuth callback endpoints (reuse main server port)
 	// These endpoints receive provider redirects and persist
@@ -375,6 +440,10 @@ func (s *Server) registerManagementRoutes() {
 	mgmt.Use(s.managementAvailabilityMiddleware(), s.mgmt.Middleware())
 	{
 		mgmt.GET("/usage", s.mgmt.GetUsageStatistics)
+		mgmt.DELETE("/usage", s.mgmt.DeleteUsage)
+		mgmt.GET("/usage/cost", s.mgmt.GetUsageCost)
+		mgmt.GET("/usage/export", s.mgmt.GetUsageExport)
+		mgmt.POST("/usage/import", s.mgmt.PostUsageImport)
 		mgmt.GET("/config", s.mgmt.GetConfig)
 
 		mgmt.GET("/debug", s.mgmt.GetDebug)
@@ -407,6 +476,11 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.PATCH("/api-keys", s.mgmt.PatchAPIKeys)
 		mgmt.DELETE("/api-keys", s.mgmt.DeleteAPIKeys)
 
+		mgmt.GET("/api-key-policies", s.mgmt.GetAPIKeyPolicies)
+		mgmt.PUT("/api-key-policies", s.mgmt.PutAPIKeyPolicies)
+		mgmt.PATCH("/api-key-policies", s.mgmt.PatchAPIKeyPolicy)
+		mgmt.DELETE("/api-key-policies", s.mgmt.DeleteAPIKeyPolicy)
+
 		mgmt.GET("/generative-language-api-key", s.mgmt.GetGlKeys)
 		mgmt.PUT("/generative-language-api-key", s.mgmt.PutGlKeys)
 		mgmt.PATCH("/generative-language-api-key", s.mgmt.PatchGlKeys)
@@ -441,6 +515,7 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.GET("/auth-files/download", s.mgmt.DownloadAuthFile)
 		mgmt.POST("/auth-files", s.mgmt.UploadAuthFile)
 		mgmt.DELETE("/auth-files", s.mgmt.DeleteAuthFile)
+		mgmt.GET("/credentials", s.mgmt.GetCredentials)
 
 		mgmt.GET("/anthropic-auth-url", s.mgmt.RequestAnthropicToken)
 		mgmt.GET("/codex-auth-url", s.mgmt.RequestCodexToken)
@@ -451,6 +526,34 @@ func (s *Server) registerManagementRoutes() {
 	}
 }
 
+// serveMetrics renders Prometheus metrics when enabled, optionally requiring a client API key.
+func (s *Server) serveMetrics(c *gin.Context) {
+	cfg := s.cfg
+	if cfg == nil || !cfg.Metrics.Enable {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	if cfg.Metrics.RequireAuth {
+		AuthMiddleware(s.accessManager)(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	metrics.Default().Write(c.Writer, s.authManager)
+}
+
+// applyMetrics attaches the metrics collector to the usage pipeline and the auth manager
+// while metrics are enabled, and detaches it otherwise.
+func (s *Server) applyMetrics(enable bool) {
+	if enable {
+		metrics.Attach(s.authManager)
+		return
+	}
+	metrics.Detach()
+}
+
 func (s *Server) managementAvailabilityMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if !s.managementRoutesEnabled.Load() {
@@ -624,6 +727,11 @@ func (s *Server) Stop(ctx context.Context) error {
 		return fmt.Errorf("failed to shutdown HTTP server: %v", err)
 	}
 
+	// Stop dispatching batch requests; unfinished batches resume on the next start.
+	if err := s.batchHandlers.Stop(ctx); err != nil {
+		return fmt.Errorf("failed to stop batch processing: %v", err)
+	}
+
 	log.Debug("API server stopped")
 	return nil
 }
@@ -705,6 +813,25 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		}
 	}
 
+	if oldCfg == nil || oldCfg.Metrics.Enable != cfg.Metrics.Enable {
+		s.applyMetrics(cfg.Metrics.Enable)
+		if oldCfg != nil {
+			log.Debugf("metrics.enable updated from %t to %t", oldCfg.Metrics.Enable, cfg.Metrics.Enable)
+		}
+	}
+
+	if oldCfg == nil || oldCfg.UsageRetention != cfg.UsageRetention {
+		usage.GetRequestStatistics().SetRetention(usage.RetentionFromConfig(cfg.UsageRetention))
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.Pricing, cfg.Pricing) {
+		usage.SetPricing(cfg.Pricing)
+	}
+
+	if oldCfg == nil || oldCfg.PromptCaching != cfg.PromptCaching {
+		util.SetPromptCaching(cfg.PromptCaching)
+	}
+
 	// Update log level dynamically when debug flag changes
 	if oldCfg == nil || oldCfg.Debug != cfg.Debug {
 		util.SetLogLevel(cfg)
@@ -748,6 +875,7 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 	}
 
 	s.applyAccessConfig(oldCfg, cfg)
+	s.keyPolicies.SetPolicies(cfg.APIKeyPolicies)
 	s.cfg = cfg
 	// Save YAML snapshot for next comparison
 	s.oldConfigYaml, _ = yaml.Marshal(cfg)
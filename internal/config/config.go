@@ -34,15 +34,55 @@ type Config struct {
 	// UsageStatisticsEnabled toggles in-memory usage aggregation; when false, usage data is discarded.
 	UsageStatisticsEnabled bool `yaml:"usage-statistics-enabled" json:"usage-statistics-enabled"`
 
+	// UsageStatisticsFile is the append-only file persisting usage statistics across restarts.
+	// Relative paths resolve against the config file directory. Unused when PGSTORE_DSN is set,
+	// in which case usage is stored in Postgres. Changes take effect after a restart.
+	UsageStatisticsFile string `yaml:"usage-statistics-file" json:"usage-statistics-file"`
+
+	// UsageRetention bounds the usage history kept in memory.
+	UsageRetention UsageRetention `yaml:"usage-retention" json:"usage-retention"`
+
+	// Pricing configures the per-model token prices used for cost accounting.
+	Pricing PricingConfig `yaml:"pricing" json:"pricing"`
+
+	// UsageSinks ship every usage record to files or HTTP webhooks.
+	UsageSinks []UsageSink `yaml:"usage-sinks,omitempty" json:"usage-sinks,omitempty"`
+
+	// Metrics configures the Prometheus /metrics endpoint.
+	Metrics MetricsConfig `yaml:"metrics" json:"metrics"`
+
+	// Tracing configures distributed tracing with OTLP/HTTP span export.
+	Tracing TracingConfig `yaml:"tracing" json:"tracing"`
+
 	// QuotaExceeded defines the behavior when a quota is exceeded.
 	QuotaExceeded QuotaExceeded `yaml:"quota-exceeded" json:"quota-exceeded"`
 
+	// APIKeyPolicies restricts what individual client API keys (see api-keys) may do.
+	APIKeyPolicies []APIKeyPolicy `yaml:"api-key-policies" json:"api-key-policies"`
+
 	// GlAPIKey is the API key for the generative language API.
 	GlAPIKey []string `yaml:"generative-language-api-key" json:"generative-language-api-key"`
 
 	// RequestRetry defines the retry times when the request failed.
 	RequestRetry int `yaml:"request-retry" json:"request-retry"`
 
+	// RequestRetryBackoff tunes the delay applied between request retry rounds.
+	RequestRetryBackoff RequestRetryBackoff `yaml:"request-retry-backoff" json:"request-retry-backoff"`
+
+	// Cooldowns sets how long a credential is skipped after a failure when the upstream
+	// does not report a reset time itself.
+	Cooldowns CooldownConfig `yaml:"cooldowns" json:"cooldowns"`
+
+	// ModelFallbacks maps a requested model to an ordered list of models tried when every
+	// credential for the requested model is unavailable or failing.
+	ModelFallbacks map[string][]string `yaml:"model-fallbacks" json:"model-fallbacks"`
+
+	// Routing configures how credentials are selected for each request.
+	Routing RoutingConfig `yaml:"routing" json:"routing"`
+
+	// PromptCaching controls the cache breakpoints added to requests translated for Claude.
+	PromptCaching PromptCachingConfig `yaml:"prompt-caching" json:"prompt-caching"`
+
 	// ClaudeKey defines a list of Claude API key configurations as specified in the YAML configuration file.
 	ClaudeKey []ClaudeKey `yaml:"claude-api-key" json:"claude-api-key"`
 
@@ -66,6 +106,125 @@ type RemoteManagement struct {
 	DisableControlPanel bool `yaml:"disable-control-panel"`
 }
 
+// UsageRetention bounds the in-memory usage rollups and request details.
+// Zero values fall back to the defaults.
+type UsageRetention struct {
+	// MinuteRollupHours is how many hours of per-minute buckets are kept (default 24).
+	MinuteRollupHours int `yaml:"minute-rollup-hours" json:"minute-rollup-hours"`
+
+	// HourRollupDays is how many days of per-hour buckets are kept (default 31).
+	HourRollupDays int `yaml:"hour-rollup-days" json:"hour-rollup-days"`
+
+	// DayRollupDays is how many days of per-day buckets and daily totals are kept (default 400).
+	DayRollupDays int `yaml:"day-rollup-days" json:"day-rollup-days"`
+
+	// MaxDetails is how many of the most recent per-request details are kept (default 1000).
+	MaxDetails int `yaml:"max-details" json:"max-details"`
+}
+
+// PricingConfig holds token prices used to compute request costs.
+type PricingConfig struct {
+	// Models overrides or extends the built-in price table. Keys are model names; a key
+	// ending in "*" matches every model starting with the text before it.
+	Models map[string]ModelPrice `yaml:"models" json:"models"`
+
+	// DisableDefaults ignores the built-in prices so only Models is used.
+	DisableDefaults bool `yaml:"disable-defaults" json:"disable-defaults"`
+}
+
+// ModelPrice holds token prices in USD per one million tokens.
+type ModelPrice struct {
+	// Input is the price of uncached input tokens.
+	Input float64 `yaml:"input" json:"input"`
+
+	// Output is the price of output tokens.
+	Output float64 `yaml:"output" json:"output"`
+
+	// CachedInput is the price of cached input tokens; unset uses Input.
+	CachedInput *float64 `yaml:"cached-input,omitempty" json:"cached-input,omitempty"`
+
+	// Reasoning is the price of reasoning tokens; unset uses Output.
+	Reasoning *float64 `yaml:"reasoning,omitempty" json:"reasoning,omitempty"`
+}
+
+// UsageSink configures a destination receiving every usage record as a JSON object.
+type UsageSink struct {
+	// Type selects the sink: "file" writes rotating NDJSON files, "webhook" POSTs batches.
+	Type string `yaml:"type" json:"type"`
+
+	// Fields limits the record fields written; empty writes every field.
+	Fields []string `yaml:"fields,omitempty" json:"fields,omitempty"`
+
+	// RawAPIKeys writes client API keys unmasked.
+	RawAPIKeys bool `yaml:"raw-api-keys,omitempty" json:"raw-api-keys,omitempty"`
+
+	// Path is the file written by file sinks. Relative paths resolve against the config file directory.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// MaxSizeMB rotates the file once it reaches this size (default 100).
+	MaxSizeMB int `yaml:"max-size-mb,omitempty" json:"max-size-mb,omitempty"`
+
+	// MaxBackups is the number of rotated files kept; zero keeps all.
+	MaxBackups int `yaml:"max-backups,omitempty" json:"max-backups,omitempty"`
+
+	// MaxAgeDays removes rotated files older than this many days; zero keeps them.
+	MaxAgeDays int `yaml:"max-age-days,omitempty" json:"max-age-days,omitempty"`
+
+	// Compress gzips rotated files.
+	Compress bool `yaml:"compress,omitempty" json:"compress,omitempty"`
+
+	// URL receives batches of records as a JSON array for webhook sinks.
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+
+	// Headers are added to every webhook request, e.g. for authentication.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// BatchSize is the maximum number of records per webhook request (default 100).
+	BatchSize int `yaml:"batch-size,omitempty" json:"batch-size,omitempty"`
+
+	// FlushIntervalSeconds bounds how long records wait before being sent (default 5).
+	FlushIntervalSeconds int `yaml:"flush-interval-seconds,omitempty" json:"flush-interval-seconds,omitempty"`
+
+	// TimeoutSeconds limits a single webhook request (default 10).
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+
+	// MaxRetries is the number of retries for failed webhook requests (default 3).
+	MaxRetries int `yaml:"max-retries,omitempty" json:"max-retries,omitempty"`
+
+	// SpillDir holds batches that could not be delivered until the webhook recovers.
+	// Relative paths resolve against the config file directory.
+	SpillDir string `yaml:"spill-dir,omitempty" json:"spill-dir,omitempty"`
+}
+
+// MetricsConfig controls the Prometheus metrics endpoint.
+type MetricsConfig struct {
+	// Enable serves metrics in the Prometheus text format at /metrics.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// RequireAuth protects /metrics with the same client API keys as the proxy endpoints.
+	// It defaults to true since the metrics are labelled with credential IDs.
+	RequireAuth bool `yaml:"require-auth" json:"require-auth"`
+}
+
+// TracingConfig controls distributed tracing.
+type TracingConfig struct {
+	// Enable records spans for handled requests and exports them to Endpoint.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Endpoint is the OTLP/HTTP collector URL. A URL without a path gets /v1/traces appended.
+	Endpoint string `yaml:"endpoint" json:"endpoint"`
+
+	// ServiceName is reported as the service.name resource attribute (default "cli-proxy-api").
+	ServiceName string `yaml:"service-name" json:"service-name"`
+
+	// SampleRatio is the fraction of new traces recorded, between 0 and 1 (default 1).
+	// Traces started by a client traceparent follow the client's sampling decision.
+	SampleRatio float64 `yaml:"sample-ratio" json:"sample-ratio"`
+
+	// Headers are sent with every export request, e.g. collector authentication.
+	Headers map[string]string `yaml:"headers" json:"headers"`
+}
+
 // QuotaExceeded defines the behavior when API quota limits are exceeded.
 // It provides configuration options for automatic failover mechanisms.
 type QuotaExceeded struct {
@@ -76,6 +235,82 @@ type QuotaExceeded struct {
 	SwitchPreviewModel bool `yaml:"switch-preview-model" json:"switch-preview-model"`
 }
 
+// RequestRetryBackoff configures the exponential backoff used between retry rounds.
+// Zero values fall back to the defaults of the core auth manager.
+type RequestRetryBackoff struct {
+	// InitialMS is the delay before the first retry round, in milliseconds.
+	InitialMS int `yaml:"initial-ms" json:"initial-ms"`
+
+	// MaxMS caps a single retry delay, including waits for credential cooldowns, in milliseconds.
+	MaxMS int `yaml:"max-ms" json:"max-ms"`
+
+	// Multiplier grows the delay after every retry round.
+	Multiplier float64 `yaml:"multiplier" json:"multiplier"`
+
+	// Jitter randomises each delay by up to the given fraction (0-1).
+	Jitter float64 `yaml:"jitter" json:"jitter"`
+}
+
+// APIKeyPolicy limits the models, request rate and token consumption of one client API key.
+// Zero limits are not enforced.
+type APIKeyPolicy struct {
+	// APIKey is the client key the policy applies to.
+	APIKey string `yaml:"api-key" json:"api-key"`
+
+	// AllowedModels lists model globs (e.g. "claude-*") the key may use. Empty allows all models.
+	AllowedModels []string `yaml:"allowed-models,omitempty" json:"allowed-models,omitempty"`
+
+	// DeniedModels lists model globs the key may never use; it takes precedence over AllowedModels.
+	DeniedModels []string `yaml:"denied-models,omitempty" json:"denied-models,omitempty"`
+
+	// RequestsPerMinute caps the number of requests started within any 60 second window.
+	RequestsPerMinute int `yaml:"requests-per-minute,omitempty" json:"requests-per-minute,omitempty"`
+
+	// MaxConcurrentRequests caps the number of requests in flight at the same time.
+	MaxConcurrentRequests int `yaml:"max-concurrent-requests,omitempty" json:"max-concurrent-requests,omitempty"`
+
+	// DailyTokenBudget caps the total tokens consumed per calendar day.
+	DailyTokenBudget int64 `yaml:"daily-token-budget,omitempty" json:"daily-token-budget,omitempty"`
+
+	// MonthlyTokenBudget caps the total tokens consumed per calendar month.
+	MonthlyTokenBudget int64 `yaml:"monthly-token-budget,omitempty" json:"monthly-token-budget,omitempty"`
+}
+
+// CooldownConfig holds the default credential cooldowns per upstream status class, in seconds.
+// Zero values fall back to the defaults of the core auth manager.
+type CooldownConfig struct {
+	// UnauthorizedSeconds applies to 401 responses (default 1800).
+	UnauthorizedSeconds int `yaml:"unauthorized-seconds" json:"unauthorized-seconds"`
+
+	// PaymentRequiredSeconds applies to 402 and 403 responses (default 1800).
+	PaymentRequiredSeconds int `yaml:"payment-required-seconds" json:"payment-required-seconds"`
+
+	// RateLimitedSeconds applies to 429 responses without a reset hint (default 1800).
+	RateLimitedSeconds int `yaml:"rate-limited-seconds" json:"rate-limited-seconds"`
+
+	// TransientSeconds applies to 408 and 5xx responses (default 60).
+	TransientSeconds int `yaml:"transient-seconds" json:"transient-seconds"`
+}
+
+// RoutingConfig groups credential selection options.
+type RoutingConfig struct {
+	// Strategy selects the credential selection strategy: round-robin (default),
+	// fill-first, least-recently-used, weighted or least-in-flight.
+	Strategy string `yaml:"strategy" json:"strategy"`
+}
+
+// PromptCachingConfig controls automatic Claude cache breakpoints. Requests that already
+// carry cache_control blocks are left as they are.
+type PromptCachingConfig struct {
+	// DisableAutoBreakpoints stops adding cache_control breakpoints to OpenAI-format
+	// requests sent to Claude.
+	DisableAutoBreakpoints bool `yaml:"disable-auto-breakpoints" json:"disable-auto-breakpoints"`
+
+	// MinTokens is the estimated size a system prompt or tool list must reach before it
+	// gets a breakpoint (default 1024, Claude's minimum cacheable prompt).
+	MinTokens int `yaml:"min-tokens" json:"min-tokens"`
+}
+
 // ClaudeKey represents the configuration for a Claude API key,
 // including the API key itself and an optional base URL for the API endpoint.
 type ClaudeKey struct {
@@ -88,6 +323,12 @@ type ClaudeKey struct {
 
 	// ProxyURL overrides the global proxy setting for this API key if provided.
 	ProxyURL string `yaml:"proxy-url" json:"proxy-url"`
+
+	// Priority ranks this key for the weighted routing strategy; higher tiers are used first.
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+
+	// Weight sets the share of traffic this key receives within its priority tier.
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
 }
 
 // CodexKey represents the configuration for a Codex API key,
@@ -102,6 +343,12 @@ type CodexKey struct {
 
 	// ProxyURL overrides the global proxy setting for this API key if provided.
 	ProxyURL string `yaml:"proxy-url" json:"proxy-url"`
+
+	// Priority ranks this key for the weighted routing strategy; higher tiers are used first.
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+
+	// Weight sets the share of traffic this key receives within its priority tier.
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
 }
 
 // OpenAICompatibility represents the configuration for OpenAI API compatibility
@@ -131,6 +378,12 @@ type OpenAICompatibilityAPIKey struct {
 
 	// ProxyURL overrides the global proxy setting for this API key if provided.
 	ProxyURL string `yaml:"proxy-url,omitempty" json:"proxy-url,omitempty"`
+
+	// Priority ranks this key for the weighted routing strategy; higher tiers are used first.
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+
+	// Weight sets the share of traffic this key receives within its priority tier.
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
 }
 
 // OpenAICompatibilityModel represents a model configuration for OpenAI compatibility,
@@ -183,6 +436,7 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	// Set defaults before unmarshal so that absent keys keep defaults.
 	cfg.LoggingToFile = false
 	cfg.UsageStatisticsEnabled = false
+	cfg.Metrics.RequireAuth = true
 	// Defaults for /v1/responses behavior
 	cfg.SDKConfig.Responses.InferEffortFromModelSuffix = true
 	cfg.SDKConfig.Responses.Defaults.Verbosity = "medium"
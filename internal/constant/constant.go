@@ -21,4 +21,7 @@ const (
 
 	// OpenaiResponse represents the OpenAI response format identifier.
 	OpenaiResponse = "openai-response"
+
+	// Ollama represents the Ollama API format identifier.
+	Ollama = "ollama"
 )
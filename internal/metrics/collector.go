@@ -0,0 +1,209 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+var (
+	latencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+	ttftBuckets    = []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30}
+)
+
+var (
+	defaultCollector = NewCollector()
+
+	attachMu sync.Mutex
+	attached *coreauth.Manager
+	enabled  bool
+)
+
+// Default returns the process wide collector fed by usage records.
+func Default() *Collector { return defaultCollector }
+
+// Attach feeds the default collector from usage records and, when manager is not nil,
+// from the execution hooks of manager. Until then the collector costs nothing per request.
+func Attach(manager *coreauth.Manager) {
+	attachMu.Lock()
+	defer attachMu.Unlock()
+	if !enabled {
+		coreusage.RegisterPlugin(defaultCollector)
+		enabled = true
+	}
+	if manager != attached {
+		if attached != nil {
+			attached.UnregisterExecutionHook(defaultCollector)
+		}
+		if manager != nil {
+			manager.RegisterExecutionHook(defaultCollector)
+		}
+		attached = manager
+	}
+}
+
+// Detach stops feeding the default collector. Metrics collected so far are kept.
+func Detach() {
+	attachMu.Lock()
+	defer attachMu.Unlock()
+	if enabled {
+		coreusage.UnregisterPlugin(defaultCollector)
+		enabled = false
+	}
+	if attached != nil {
+		attached.UnregisterExecutionHook(defaultCollector)
+		attached = nil
+	}
+}
+
+// Collector aggregates request, latency and token metrics. It implements
+// coreauth.ExecutionHook for upstream calls and coreusage.Plugin for token usage.
+type Collector struct {
+	requests *counterFamily
+	latency  *histogramFamily
+	ttft     *histogramFamily
+	tokens   *counterFamily
+
+	attempts sync.Map // *coreauth.ExecutionContext -> *attempt
+}
+
+// attempt tracks the timing of a single executor call.
+type attempt struct {
+	start      time.Time
+	firstChunk sync.Once
+}
+
+// NewCollector creates an empty collector.
+func NewCollector() *Collector {
+	return &Collector{
+		requests: newCounterFamily("cliproxy_requests_total", "Upstream executor calls by inbound handler, model, provider and status.", "handler", "model", "provider", "status"),
+		latency:  newHistogramFamily("cliproxy_upstream_latency_seconds", "Duration of upstream executor calls, until the last chunk for streams.", latencyBuckets, "provider", "model"),
+		ttft:     newHistogramFamily("cliproxy_upstream_time_to_first_token_seconds", "Time until the first streamed payload chunk arrived from upstream.", ttftBuckets, "provider", "model"),
+		tokens:   newCounterFamily("cliproxy_tokens_total", "Tokens consumed by provider, model and token type.", "provider", "model", "type"),
+	}
+}
+
+// BeforeExecute implements coreauth.ExecutionHook.
+func (c *Collector) BeforeExecute(_ context.Context, execCtx *coreauth.ExecutionContext) {
+	if c == nil || execCtx == nil {
+		return
+	}
+	c.attempts.Store(execCtx, &attempt{start: time.Now()})
+}
+
+// OnStreamChunk implements coreauth.ExecutionHook and records the time to first token.
+func (c *Collector) OnStreamChunk(_ context.Context, execCtx *coreauth.ExecutionContext, chunk cliproxyexecutor.StreamChunk) {
+	if c == nil || execCtx == nil || len(chunk.Payload) == 0 {
+		return
+	}
+	value, ok := c.attempts.Load(execCtx)
+	if !ok {
+		return
+	}
+	a := value.(*attempt)
+	a.firstChunk.Do(func() {
+		c.ttft.observe(time.Since(a.start).Seconds(), providerOf(execCtx), execCtx.Request.Model)
+	})
+}
+
+// AfterExecute implements coreauth.ExecutionHook.
+func (c *Collector) AfterExecute(_ context.Context, execCtx *coreauth.ExecutionContext, _ cliproxyexecutor.Response, err error) {
+	if c == nil || execCtx == nil {
+		return
+	}
+	provider := providerOf(execCtx)
+	model := execCtx.Request.Model
+	if value, ok := c.attempts.LoadAndDelete(execCtx); ok {
+		c.latency.observe(time.Since(value.(*attempt).start).Seconds(), provider, model)
+	}
+	c.requests.add(1, execCtx.Options.SourceFormat.String(), model, provider, statusLabel(err))
+}
+
+// HandleUsage implements coreusage.Plugin.
+func (c *Collector) HandleUsage(_ context.Context, record coreusage.Record) {
+	if c == nil {
+		return
+	}
+	detail := record.Detail
+	c.tokens.add(float64(detail.InputTokens), record.Provider, record.Model, "input")
+	c.tokens.add(float64(detail.OutputTokens), record.Provider, record.Model, "output")
+	c.tokens.add(float64(detail.ReasoningTokens), record.Provider, record.Model, "reasoning")
+	c.tokens.add(float64(detail.CachedTokens), record.Provider, record.Model, "cached")
+}
+
+// Write renders all metrics in the Prometheus text format. Credential gauges are
+// derived from manager when it is not nil.
+func (c *Collector) Write(w io.Writer, manager *coreauth.Manager) {
+	if c == nil {
+		return
+	}
+	c.requests.write(w)
+	c.latency.write(w)
+	c.ttft.write(w)
+	c.tokens.write(w)
+	if manager != nil {
+		writeAuthGauges(w, manager.List(), time.Now())
+	}
+}
+
+// writeAuthGauges exposes auth counts per status and the remaining per-model cooldowns.
+func writeAuthGauges(w io.Writer, auths []*coreauth.Auth, now time.Time) {
+	counts := make(map[[2]string]float64)
+	var cooldowns []gaugeSample
+	for _, auth := range auths {
+		if auth == nil {
+			continue
+		}
+		status := string(auth.Status)
+		if auth.Disabled {
+			status = string(coreauth.StatusDisabled)
+		}
+		if status == "" {
+			status = string(coreauth.StatusUnknown)
+		}
+		counts[[2]string{auth.Provider, status}]++
+		for model, state := range auth.ModelStates {
+			if state == nil || !state.NextRetryAfter.After(now) {
+				continue
+			}
+			cooldowns = append(cooldowns, gaugeSample{
+				labels: labelSet{auth.Provider, auth.ID, model},
+				value:  state.NextRetryAfter.Sub(now).Seconds(),
+			})
+		}
+	}
+	statusSamples := make([]gaugeSample, 0, len(counts))
+	for key, count := range counts {
+		statusSamples = append(statusSamples, gaugeSample{labels: labelSet{key[0], key[1]}, value: count})
+	}
+	writeGauge(w, "cliproxy_auths", "Registered credentials by provider and status.", []string{"provider", "status"}, statusSamples)
+	writeGauge(w, "cliproxy_auth_model_cooldown_seconds", "Remaining cooldown of credentials blocked for a model.", []string{"provider", "auth_id", "model"}, cooldowns)
+}
+
+func providerOf(execCtx *coreauth.ExecutionContext) string {
+	if execCtx == nil || execCtx.Auth == nil {
+		return ""
+	}
+	return execCtx.Auth.Provider
+}
+
+func statusLabel(err error) string {
+	if err == nil {
+		return "200"
+	}
+	var se cliproxyexecutor.StatusError
+	if errors.As(err, &se) && se != nil && se.StatusCode() > 0 {
+		return strconv.Itoa(se.StatusCode())
+	}
+	if errors.Is(err, context.Canceled) {
+		return "canceled"
+	}
+	return "error"
+}
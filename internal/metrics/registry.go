@@ -0,0 +1,205 @@
+// Package metrics exposes proxy runtime metrics in the Prometheus text exposition format.
+// It collects request, latency and token metrics from execution hooks and usage records,
+// and derives credential health gauges from the core auth manager at scrape time.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// labelSet is an ordered list of label values matching a family's label names.
+type labelSet []string
+
+func (l labelSet) key() string { return strings.Join(l, "\xff") }
+
+// counterFamily is a monotonically increasing metric partitioned by labels.
+type counterFamily struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]*counterValue
+}
+
+type counterValue struct {
+	labels labelSet
+	value  float64
+}
+
+func newCounterFamily(name, help string, labels ...string) *counterFamily {
+	return &counterFamily{name: name, help: help, labels: labels, values: make(map[string]*counterValue)}
+}
+
+// add increases the counter identified by values by delta.
+func (f *counterFamily) add(delta float64, values ...string) {
+	if delta < 0 {
+		return
+	}
+	set := labelSet(values)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.values[set.key()]
+	if !ok {
+		v = &counterValue{labels: append(labelSet(nil), set...)}
+		f.values[set.key()] = v
+	}
+	v.value += delta
+}
+
+func (f *counterFamily) write(w io.Writer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	writeHeader(w, f.name, f.help, "counter")
+	for _, key := range sortedKeys(f.values) {
+		v := f.values[key]
+		writeSample(w, f.name, f.labels, v.labels, nil, v.value)
+	}
+}
+
+// histogramFamily records observations into cumulative buckets partitioned by labels.
+type histogramFamily struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labels labelSet
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogramFamily(name, help string, buckets []float64, labels ...string) *histogramFamily {
+	return &histogramFamily{name: name, help: help, labels: labels, buckets: buckets, values: make(map[string]*histogramValue)}
+}
+
+// observe records value for the histogram identified by values.
+func (f *histogramFamily) observe(value float64, values ...string) {
+	set := labelSet(values)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.values[set.key()]
+	if !ok {
+		v = &histogramValue{labels: append(labelSet(nil), set...), counts: make([]uint64, len(f.buckets))}
+		f.values[set.key()] = v
+	}
+	for i, upper := range f.buckets {
+		if value <= upper {
+			v.counts[i]++
+		}
+	}
+	v.count++
+	v.sum += value
+}
+
+func (f *histogramFamily) write(w io.Writer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	writeHeader(w, f.name, f.help, "histogram")
+	for _, key := range sortedKeys(f.values) {
+		v := f.values[key]
+		for i, upper := range f.buckets {
+			writeSample(w, f.name+"_bucket", f.labels, v.labels, []string{"le", formatFloat(upper)}, float64(v.counts[i]))
+		}
+		writeSample(w, f.name+"_bucket", f.labels, v.labels, []string{"le", "+Inf"}, float64(v.count))
+		writeSample(w, f.name+"_sum", f.labels, v.labels, nil, v.sum)
+		writeSample(w, f.name+"_count", f.labels, v.labels, nil, float64(v.count))
+	}
+}
+
+// gaugeSample is a single gauge value computed at scrape time.
+type gaugeSample struct {
+	labels labelSet
+	value  float64
+}
+
+// writeGauge renders a gauge family from samples computed at scrape time.
+func writeGauge(w io.Writer, name, help string, labels []string, samples []gaugeSample) {
+	writeHeader(w, name, help, "gauge")
+	sort.Slice(samples, func(i, j int) bool { return samples[i].labels.key() < samples[j].labels.key() })
+	for _, sample := range samples {
+		writeSample(w, name, labels, sample.labels, nil, sample.value)
+	}
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeSample(w io.Writer, name string, names []string, values labelSet, extra []string, value float64) {
+	var b strings.Builder
+	b.WriteString(name)
+	pairs := len(names)
+	if len(extra) == 2 {
+		pairs++
+	}
+	if pairs > 0 {
+		b.WriteByte('{')
+		first := true
+		for i, label := range names {
+			if !first {
+				b.WriteByte(',')
+			}
+			first = false
+			val := ""
+			if i < len(values) {
+				val = values[i]
+			}
+			b.WriteString(label)
+			b.WriteString(`="`)
+			b.WriteString(escapeLabel(val))
+			b.WriteByte('"')
+		}
+		if len(extra) == 2 {
+			if !first {
+				b.WriteByte(',')
+			}
+			b.WriteString(extra[0])
+			b.WriteString(`="`)
+			b.WriteString(escapeLabel(extra[1]))
+			b.WriteByte('"')
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(formatFloat(value))
+	b.WriteByte('\n')
+	_, _ = io.WriteString(w, b.String())
+}
+
+func escapeLabel(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return strings.ReplaceAll(value, `"`, `\"`)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func sortedKeys[T any](values map[string]T) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
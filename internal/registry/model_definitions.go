@@ -144,6 +144,41 @@ func GetGeminiModels() []*ModelInfo {
 	}
 }
 
+// GetGeminiEmbeddingModels returns the Gemini embedding model definitions served through
+// the embeddings endpoints by Gemini API credentials.
+func GetGeminiEmbeddingModels() []*ModelInfo {
+	return []*ModelInfo{
+		{
+			ID:                         "gemini-embedding-001",
+			Object:                     "model",
+			Created:                    time.Now().Unix(),
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/gemini-embedding-001",
+			Version:                    "001",
+			DisplayName:                "Gemini Embedding 001",
+			Description:                "Obtain a distributed representation of a text.",
+			InputTokenLimit:            2048,
+			OutputTokenLimit:           1,
+			SupportedGenerationMethods: []string{"embedContent", "countTextTokens", "countTokens", "asyncBatchEmbedContent"},
+		},
+		{
+			ID:                         "text-embedding-004",
+			Object:                     "model",
+			Created:                    time.Now().Unix(),
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/text-embedding-004",
+			Version:                    "004",
+			DisplayName:                "Text Embedding 004",
+			Description:                "Obtain a distributed representation of a text.",
+			InputTokenLimit:            2048,
+			OutputTokenLimit:           1,
+			SupportedGenerationMethods: []string{"embedContent"},
+		},
+	}
+}
+
 // GetGeminiCLIModels returns the standard Gemini model definitions
 func GetGeminiCLIModels() []*ModelInfo {
 	return []*ModelInfo{
@@ -0,0 +1,50 @@
+// Package registry provides model definitions for various AI service providers.
+// This file contains the default token prices used for cost accounting.
+package registry
+
+// ModelPrice holds token prices in USD per one million tokens.
+type ModelPrice struct {
+	// Input is the price of uncached input tokens.
+	Input float64
+	// Output is the price of output tokens.
+	Output float64
+	// CachedInput is the price of input tokens served from the prompt cache.
+	CachedInput float64
+	// Reasoning is the price of reasoning (thinking) tokens.
+	Reasoning float64
+}
+
+// DefaultModelPrices returns list prices for the models defined in this package. They are
+// approximations of the providers' published standard-tier prices and can be overridden
+// through the pricing config section. Models served for free (iFlow) are not listed.
+func DefaultModelPrices() map[string]ModelPrice {
+	prices := make(map[string]ModelPrice)
+	set := func(price ModelPrice, ids ...string) {
+		for _, id := range ids {
+			prices[id] = price
+		}
+	}
+
+	set(ModelPrice{Input: 1, Output: 5, CachedInput: 0.1, Reasoning: 5}, "claude-haiku-4-5-20251001")
+	set(ModelPrice{Input: 3, Output: 15, CachedInput: 0.3, Reasoning: 15},
+		"claude-sonnet-4-5-20250929", "claude-sonnet-4-20250514", "claude-3-7-sonnet-20250219")
+	set(ModelPrice{Input: 15, Output: 75, CachedInput: 1.5, Reasoning: 75},
+		"claude-opus-4-1-20250805", "claude-opus-4-20250514")
+	set(ModelPrice{Input: 0.8, Output: 4, CachedInput: 0.08, Reasoning: 4}, "claude-3-5-haiku-20241022")
+
+	set(ModelPrice{Input: 1.25, Output: 10, CachedInput: 0.125, Reasoning: 10}, "gemini-2.5-pro")
+	set(ModelPrice{Input: 0.3, Output: 2.5, CachedInput: 0.03, Reasoning: 2.5}, "gemini-2.5-flash")
+	set(ModelPrice{Input: 0.1, Output: 0.4, CachedInput: 0.01, Reasoning: 0.4}, "gemini-2.5-flash-lite")
+	set(ModelPrice{Input: 0.3, Output: 30, CachedInput: 0.03, Reasoning: 30},
+		"gemini-2.5-flash-image-preview", "gemini-2.5-flash-image")
+	set(ModelPrice{Input: 0.15}, "gemini-embedding-001")
+
+	set(ModelPrice{Input: 1.25, Output: 10, CachedInput: 0.125, Reasoning: 10},
+		"gpt-5", "gpt-5-minimal", "gpt-5-low", "gpt-5-medium", "gpt-5-high",
+		"gpt-5-codex", "gpt-5-codex-low", "gpt-5-codex-medium", "gpt-5-codex-high")
+	set(ModelPrice{Input: 1.5, Output: 6, CachedInput: 0.375, Reasoning: 6}, "codex-mini-latest")
+
+	set(ModelPrice{Input: 1, Output: 5, CachedInput: 0.2, Reasoning: 5}, "qwen3-coder-plus")
+	set(ModelPrice{Input: 0.3, Output: 1.5, CachedInput: 0.06, Reasoning: 1.5}, "qwen3-coder-flash")
+	return prices
+}
@@ -4,6 +4,8 @@
 package registry
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"sort"
 	"strings"
 	"sync"
@@ -727,6 +729,37 @@ func (r *ModelRegistry) convertModelToMap(model *ModelInfo, handlerType string)
 		}
 		return result
 
+	case "ollama":
+		family := model.Type
+		if family == "" {
+			family = model.OwnedBy
+		}
+		modified := time.Unix(model.Created, 0).UTC()
+		digest := sha256.Sum256([]byte(model.ID))
+		capabilities := []string{"completion", "tools", "vision"}
+		for _, method := range model.SupportedGenerationMethods {
+			if method == "embedContent" {
+				capabilities = []string{"embedding"}
+				break
+			}
+		}
+		return map[string]any{
+			"name":        model.ID,
+			"model":       model.ID,
+			"modified_at": modified.Format(time.RFC3339),
+			"size":        0,
+			"digest":      hex.EncodeToString(digest[:]),
+			"details": map[string]any{
+				"parent_model":       "",
+				"format":             "",
+				"family":             family,
+				"families":           []string{family},
+				"parameter_size":     "",
+				"quantization_level": "",
+			},
+			"capabilities": capabilities,
+		}
+
 	default:
 		// Generic format
 		result := map[string]any{
@@ -14,6 +14,7 @@ import (
 	claudeauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/claude"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
@@ -34,6 +35,13 @@ func NewClaudeExecutor(cfg *config.Config) *ClaudeExecutor { return &ClaudeExecu
 
 func (e *ClaudeExecutor) Identifier() string { return "claude" }
 
+// RequestFormat implements cliproxyauth.RequestFormatter. Non-streaming requests from other
+// formats use the streaming translation, which preserves function calling.
+func (e *ClaudeExecutor) RequestFormat(opts cliproxyexecutor.Options) (sdktranslator.Format, bool) {
+	to := sdktranslator.FromString("claude")
+	return to, opts.Stream || opts.SourceFormat != to
+}
+
 func (e *ClaudeExecutor) PrepareRequest(_ *http.Request, _ *cliproxyauth.Auth) error { return nil }
 
 func (e *ClaudeExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
@@ -47,10 +55,10 @@ func (e *ClaudeExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 	to := sdktranslator.FromString("claude")
 	// Use streaming translation to preserve function calling, except for claude.
 	stream := from != to
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), stream)
+	body := translateRequest(ctx, from, to, req.Model, bytes.Clone(req.Payload), stream)
 
 	if !strings.HasPrefix(req.Model, "claude-3-5-haiku") {
-		body, _ = sjson.SetRawBytes(body, "system", []byte(misc.ClaudeCodeInstructions))
+		body = applyClaudeCodeSystem(body)
 	}
 
 	url := fmt.Sprintf("%s/v1/messages?beta=true", baseURL)
@@ -93,7 +101,7 @@ func (e *ClaudeExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 		b, _ := io.ReadAll(resp.Body)
 		appendAPIResponseChunk(ctx, e.cfg, b)
 		log.Debugf("request error, error status: %d, error body: %s", resp.StatusCode, string(b))
-		return cliproxyexecutor.Response{}, statusErr{code: resp.StatusCode, msg: string(b)}
+		return cliproxyexecutor.Response{}, newUpstreamStatusErr(e.cfg, resp.StatusCode, resp.Header, b)
 	}
 	reader := io.Reader(resp.Body)
 	var decoder *zstd.Decoder
@@ -136,8 +144,8 @@ func (e *ClaudeExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("claude")
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
-	body, _ = sjson.SetRawBytes(body, "system", []byte(misc.ClaudeCodeInstructions))
+	body := translateRequest(ctx, from, to, req.Model, bytes.Clone(req.Payload), true)
+	body = applyClaudeCodeSystem(body)
 
 	url := fmt.Sprintf("%s/v1/messages?beta=true", baseURL)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
@@ -175,7 +183,7 @@ func (e *ClaudeExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 		b, _ := io.ReadAll(resp.Body)
 		appendAPIResponseChunk(ctx, e.cfg, b)
 		log.Debugf("request error, error status: %d, error body: %s", resp.StatusCode, string(b))
-		return nil, statusErr{code: resp.StatusCode, msg: string(b)}
+		return nil, newUpstreamStatusErr(e.cfg, resp.StatusCode, resp.Header, b)
 	}
 	out := make(chan cliproxyexecutor.StreamChunk)
 	go func() {
@@ -241,10 +249,10 @@ func (e *ClaudeExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Aut
 	to := sdktranslator.FromString("claude")
 	// Use streaming translation to preserve function calling, except for claude.
 	stream := from != to
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), stream)
+	body := translateRequest(ctx, from, to, req.Model, bytes.Clone(req.Payload), stream)
 
 	if !strings.HasPrefix(req.Model, "claude-3-5-haiku") {
-		body, _ = sjson.SetRawBytes(body, "system", []byte(misc.ClaudeCodeInstructions))
+		body = applyClaudeCodeSystem(body)
 	}
 
 	url := fmt.Sprintf("%s/v1/messages/count_tokens?beta=true", baseURL)
@@ -286,7 +294,7 @@ func (e *ClaudeExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Aut
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		b, _ := io.ReadAll(resp.Body)
 		appendAPIResponseChunk(ctx, e.cfg, b)
-		return cliproxyexecutor.Response{}, statusErr{code: resp.StatusCode, msg: string(b)}
+		return cliproxyexecutor.Response{}, newUpstreamStatusErr(e.cfg, resp.StatusCode, resp.Header, b)
 	}
 	reader := io.Reader(resp.Body)
 	var decoder *zstd.Decoder
@@ -389,6 +397,29 @@ func applyClaudeHeaders(r *http.Request, apiKey string, stream bool) {
 	r.Header.Set("Accept", "application/json")
 }
 
+// applyClaudeCodeSystem puts the Claude Code instructions first in the system prompt. The
+// client's own system prompt is no longer discarded: its blocks follow the instructions with
+// their cache breakpoints, trimmed to the four breakpoints Claude accepts.
+func applyClaudeCodeSystem(body []byte) []byte {
+	instructions := gjson.Parse(misc.ClaudeCodeInstructions)
+	system := gjson.GetBytes(body, "system")
+	if system.IsArray() && system.Get("0.text").String() == instructions.Get("0.text").String() {
+		return util.LimitClaudeCacheBreakpoints(body)
+	}
+	merged := instructions.Raw
+	switch {
+	case system.IsArray():
+		for _, block := range system.Array() {
+			merged, _ = sjson.SetRaw(merged, "-1", block.Raw)
+		}
+	case system.Type == gjson.String && system.String() != "":
+		block, _ := sjson.Set(`{"type":"text","text":""}`, "text", system.String())
+		merged, _ = sjson.SetRaw(merged, "-1", block)
+	}
+	body, _ = sjson.SetRawBytes(body, "system", []byte(merged))
+	return util.LimitClaudeCacheBreakpoints(body)
+}
+
 func claudeCreds(a *cliproxyauth.Auth) (apiKey, baseURL string) {
 	if a == nil {
 		return "", ""
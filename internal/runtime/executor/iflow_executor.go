@@ -36,6 +36,11 @@ func NewIFlowExecutor(cfg *config.Config) *IFlowExecutor { return &IFlowExecutor
 // Identifier returns the provider key.
 func (e *IFlowExecutor) Identifier() string { return "iflow" }
 
+// RequestFormat implements cliproxyauth.RequestFormatter.
+func (e *IFlowExecutor) RequestFormat(opts cliproxyexecutor.Options) (sdktranslator.Format, bool) {
+	return sdktranslator.FromString("openai"), opts.Stream
+}
+
 // PrepareRequest implements ProviderExecutor but requires no preprocessing.
 func (e *IFlowExecutor) PrepareRequest(_ *http.Request, _ *cliproxyauth.Auth) error { return nil }
 
@@ -53,7 +58,7 @@ func (e *IFlowExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, re
 
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("openai")
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), false)
+	body := translateRequest(ctx, from, to, req.Model, bytes.Clone(req.Payload), false)
 
 	endpoint := strings.TrimSuffix(baseURL, "/") + iflowDefaultEndpoint
 
@@ -93,7 +98,7 @@ func (e *IFlowExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, re
 		b, _ := io.ReadAll(resp.Body)
 		appendAPIResponseChunk(ctx, e.cfg, b)
 		log.Debugf("iflow request error: status %d body %s", resp.StatusCode, string(b))
-		return cliproxyexecutor.Response{}, statusErr{code: resp.StatusCode, msg: string(b)}
+		return cliproxyexecutor.Response{}, newUpstreamStatusErr(e.cfg, resp.StatusCode, resp.Header, b)
 	}
 
 	data, err := io.ReadAll(resp.Body)
@@ -123,7 +128,7 @@ func (e *IFlowExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Au
 
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("openai")
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
+	body := translateRequest(ctx, from, to, req.Model, bytes.Clone(req.Payload), true)
 
 	// Ensure tools array exists to avoid provider quirks similar to Qwen's behaviour.
 	toolsResult := gjson.GetBytes(body, "tools")
@@ -169,7 +174,7 @@ func (e *IFlowExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Au
 		b, _ := io.ReadAll(resp.Body)
 		appendAPIResponseChunk(ctx, e.cfg, b)
 		log.Debugf("iflow streaming error: status %d body %s", resp.StatusCode, string(b))
-		return nil, statusErr{code: resp.StatusCode, msg: string(b)}
+		return nil, newUpstreamStatusErr(e.cfg, resp.StatusCode, resp.Header, b)
 	}
 
 	out := make(chan cliproxyexecutor.StreamChunk)
@@ -202,8 +207,9 @@ func (e *IFlowExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Au
 }
 
 // CountTokens is not implemented for iFlow.
-func (e *IFlowExecutor) CountTokens(context.Context, *cliproxyauth.Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
-	return cliproxyexecutor.Response{Payload: nil}, fmt.Errorf("not implemented")
+// CountTokens estimates the input tokens offline; iFlow has no counting endpoint.
+func (e *IFlowExecutor) CountTokens(ctx context.Context, _ *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return countTokensLocally(ctx, "openai", req.Model, req, opts)
 }
 
 // Refresh refreshes OAuth tokens and updates the stored API key.
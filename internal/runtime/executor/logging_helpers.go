@@ -12,6 +12,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 )
 
 const (
@@ -72,6 +73,9 @@ func recordAPIRequest(ctx context.Context, cfg *config.Config, info upstreamRequ
 	if auth := formatAuthInfo(info); auth != "" {
 		builder.WriteString(fmt.Sprintf("Auth: %s\n", auth))
 	}
+	if retry := cliproxyauth.RetryAttemptFromContext(ctx); retry > 0 {
+		builder.WriteString(fmt.Sprintf("Retry: %d\n", retry))
+	}
 	builder.WriteString("\nHeaders:\n")
 	writeHeaders(builder, info.Headers)
 	builder.WriteString("\nBody:\n")
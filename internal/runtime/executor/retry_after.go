@@ -0,0 +1,176 @@
+package executor
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/tidwall/gjson"
+)
+
+// anthropicRateLimitKinds lists the Anthropic rate limit families that report their
+// remaining budget and reset time via anthropic-ratelimit-<kind>-remaining/-reset headers.
+var anthropicRateLimitKinds = []string{"requests", "tokens", "input-tokens", "output-tokens"}
+
+// openAIRateLimitKinds lists the OpenAI-style rate limit families reported through
+// x-ratelimit-remaining-<kind>/x-ratelimit-reset-<kind> headers.
+var openAIRateLimitKinds = []string{"requests", "tokens"}
+
+// newUpstreamStatusErr builds the error for a failed upstream response, capturing any
+// hint about when the provider will accept the next request.
+func newUpstreamStatusErr(cfg *config.Config, code int, header http.Header, body []byte) statusErr {
+	retryAfter := parseRetryAfter(code, header, body, time.Now(), maxRetryAfter(cfg))
+	return statusErr{code: code, msg: string(body), retryAfter: retryAfter}
+}
+
+// maxRetryAfter returns the longest configured credential cooldown, which bounds any
+// upstream reset hint.
+func maxRetryAfter(cfg *config.Config) time.Duration {
+	var cooldowns config.CooldownConfig
+	if cfg != nil {
+		cooldowns = cfg.Cooldowns
+	}
+	return cliproxyauth.CooldownPolicy{
+		Unauthorized:    time.Duration(cooldowns.UnauthorizedSeconds) * time.Second,
+		PaymentRequired: time.Duration(cooldowns.PaymentRequiredSeconds) * time.Second,
+		RateLimited:     time.Duration(cooldowns.RateLimitedSeconds) * time.Second,
+		Transient:       time.Duration(cooldowns.TransientSeconds) * time.Second,
+	}.Max()
+}
+
+// isThrottlingStatus reports whether code signals that the upstream is rate limiting or
+// overloaded (429, Anthropic's 529 and 503).
+func isThrottlingStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable || code == 529
+}
+
+// parseRetryAfter extracts the delay before the next request may succeed from upstream
+// response headers and error bodies. It understands the standard Retry-After header,
+// Anthropic and OpenAI rate limit reset headers, Codex usage-limit reset fields and
+// Gemini RetryInfo details. Rate limit reset headers accompany successful responses
+// too, so they only count for throttling statuses. The hint is capped at limit when
+// limit is positive; nil is returned when no hint is present.
+func parseRetryAfter(code int, header http.Header, body []byte, now time.Time, limit time.Duration) *time.Duration {
+	candidates := []func() (time.Duration, bool){
+		func() (time.Duration, bool) { return retryAfterHeader(header, now) },
+		func() (time.Duration, bool) {
+			if !isThrottlingStatus(code) {
+				return 0, false
+			}
+			return anthropicRateLimitReset(header, now)
+		},
+		func() (time.Duration, bool) {
+			if !isThrottlingStatus(code) {
+				return 0, false
+			}
+			return openAIRateLimitReset(header)
+		},
+		func() (time.Duration, bool) { return codexUsageLimitReset(body, now) },
+		func() (time.Duration, bool) { return geminiRetryInfo(body) },
+	}
+	for _, candidate := range candidates {
+		if wait, ok := candidate(); ok && wait > 0 {
+			if limit > 0 && wait > limit {
+				wait = limit
+			}
+			return &wait
+		}
+	}
+	return nil
+}
+
+func retryAfterHeader(header http.Header, now time.Time) (time.Duration, bool) {
+	raw := strings.TrimSpace(header.Get("Retry-After"))
+	if raw == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseFloat(raw, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	if at, err := http.ParseTime(raw); err == nil {
+		return at.Sub(now), true
+	}
+	return 0, false
+}
+
+// anthropicRateLimitReset returns the latest reset among the exhausted Anthropic limits.
+func anthropicRateLimitReset(header http.Header, now time.Time) (time.Duration, bool) {
+	var latest time.Time
+	for _, kind := range anthropicRateLimitKinds {
+		if strings.TrimSpace(header.Get("anthropic-ratelimit-"+kind+"-remaining")) != "0" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, strings.TrimSpace(header.Get("anthropic-ratelimit-"+kind+"-reset")))
+		if err != nil {
+			continue
+		}
+		if at.After(latest) {
+			latest = at
+		}
+	}
+	if latest.IsZero() {
+		return 0, false
+	}
+	return latest.Sub(now), true
+}
+
+// openAIRateLimitReset returns the longest reset among the exhausted OpenAI-style limits.
+func openAIRateLimitReset(header http.Header) (time.Duration, bool) {
+	var longest time.Duration
+	for _, kind := range openAIRateLimitKinds {
+		if strings.TrimSpace(header.Get("x-ratelimit-remaining-"+kind)) != "0" {
+			continue
+		}
+		wait, err := time.ParseDuration(strings.TrimSpace(header.Get("x-ratelimit-reset-" + kind)))
+		if err != nil {
+			continue
+		}
+		if wait > longest {
+			longest = wait
+		}
+	}
+	return longest, longest > 0
+}
+
+// codexUsageLimitReset reads resets_in_seconds or resets_at from a Codex usage-limit error.
+func codexUsageLimitReset(body []byte, now time.Time) (time.Duration, bool) {
+	if len(body) == 0 || !gjson.ValidBytes(body) {
+		return 0, false
+	}
+	errNode := gjson.GetBytes(body, "error")
+	if seconds := errNode.Get("resets_in_seconds"); seconds.Exists() {
+		return time.Duration(seconds.Float() * float64(time.Second)), true
+	}
+	if at := errNode.Get("resets_at"); at.Exists() && at.Int() > 0 {
+		return time.Unix(at.Int(), 0).Sub(now), true
+	}
+	return 0, false
+}
+
+// geminiRetryInfo reads the retryDelay of a google.rpc.RetryInfo error detail.
+func geminiRetryInfo(body []byte) (time.Duration, bool) {
+	if len(body) == 0 || !gjson.ValidBytes(body) {
+		return 0, false
+	}
+	root := gjson.ParseBytes(body)
+	if root.IsArray() {
+		root = root.Get("0")
+	}
+	var wait time.Duration
+	found := false
+	root.Get("error.details").ForEach(func(_, detail gjson.Result) bool {
+		if !strings.HasSuffix(detail.Get("@type").String(), "google.rpc.RetryInfo") {
+			return true
+		}
+		parsed, err := time.ParseDuration(strings.TrimSpace(detail.Get("retryDelay").String()))
+		if err != nil {
+			return true
+		}
+		wait, found = parsed, true
+		return false
+	})
+	return wait, found
+}
@@ -0,0 +1,24 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tokenizer"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+// countTokensLocally answers CountTokens for providers without a token counting endpoint.
+// The request is translated to the provider format like a real request, counted with the
+// offline tokenizer for model, and the count is returned through the TranslateTokenCount
+// transform of the client format.
+func countTokensLocally(ctx context.Context, provider, model string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	from := opts.SourceFormat
+	to := sdktranslator.FromString(provider)
+	body := translateRequest(ctx, from, to, req.Model, bytes.Clone(req.Payload), false)
+	count := tokenizer.CountPayload(provider, model, body)
+	translated := sdktranslator.TranslateTokenCount(ctx, to, from, count, []byte(fmt.Sprintf(`{"total_tokens":%d}`, count)))
+	return cliproxyexecutor.Response{Payload: []byte(translated)}, nil
+}
@@ -0,0 +1,27 @@
+package executor
+
+import (
+	"context"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+// translateRequest converts payload from the client format to the provider format,
+// recording the conversion as a span of the current attempt and the formats on its
+// usage record. The payload already translated for execution hooks is used when the
+// manager provided one for the same model and stream flag.
+func translateRequest(ctx context.Context, from, to sdktranslator.Format, model string, payload []byte, stream bool) []byte {
+	usage.AttemptFromContext(ctx).SetFormats(from.String(), to.String())
+	if translated, ok := cliproxyauth.TranslatedRequestFromContext(ctx, to, model, stream); ok {
+		return translated
+	}
+	_, span := tracing.StartSpan(ctx, "translate request", tracing.SpanKindInternal)
+	defer span.End()
+	span.SetAttribute("translator.from", from.String())
+	span.SetAttribute("translator.to", to.String())
+	span.SetAttribute("model", model)
+	return sdktranslator.TranslateRequest(from, to, model, payload, stream)
+}
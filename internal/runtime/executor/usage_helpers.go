@@ -18,6 +18,7 @@ import (
 type usageReporter struct {
 	provider    string
 	model       string
+	requested   string
 	authID      string
 	apiKey      string
 	source      string
@@ -37,6 +38,9 @@ func newUsageReporter(ctx context.Context, provider, model string, auth *cliprox
 	if auth != nil {
 		reporter.authID = auth.ID
 	}
+	if fallback, ok := cliproxyauth.ModelFallbackFromContext(ctx); ok {
+		reporter.requested = fallback.Requested
+	}
 	return reporter
 }
 
@@ -54,15 +58,23 @@ func (r *usageReporter) publish(ctx context.Context, detail usage.Detail) {
 		return
 	}
 	r.once.Do(func() {
-		usage.PublishRecord(ctx, usage.Record{
-			Provider:    r.provider,
-			Model:       r.model,
-			Source:      r.source,
-			APIKey:      r.apiKey,
-			AuthID:      r.authID,
-			RequestedAt: r.requestedAt,
-			Detail:      detail,
-		})
+		record := usage.Record{
+			Provider:       r.provider,
+			Model:          r.model,
+			RequestedModel: r.requested,
+			Source:         r.source,
+			APIKey:         r.apiKey,
+			AuthID:         r.authID,
+			RequestedAt:    r.requestedAt,
+			Detail:         detail,
+		}
+		// Attempts run by the auth manager publish the record, with the outcome, once
+		// they finish; direct executor calls publish right away.
+		if attempt := usage.AttemptFromContext(ctx); attempt != nil {
+			attempt.Report(record)
+			return
+		}
+		usage.PublishRecord(ctx, record)
 	})
 }
 
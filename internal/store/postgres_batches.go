@@ -0,0 +1,443 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/batches"
+)
+
+// Message batches use three tables named after BatchTable: the batch records with the
+// lease of the replica processing them, their requests and results (<table>_items, at most
+// one result per custom ID) and uploaded input files (<table>_files).
+const (
+	batchItemRequest = "request"
+	batchItemResult  = "result"
+)
+
+func (s *PostgresStore) batchTables() (batchTable, itemTable, fileTable string) {
+	return s.fullTableName(s.cfg.BatchTable), s.fullTableName(s.cfg.BatchTable + "_items"), s.fullTableName(s.cfg.BatchTable + "_files")
+}
+
+// ensureBatchSchema creates the message batch tables.
+func (s *PostgresStore) ensureBatchSchema(ctx context.Context) error {
+	batchTable, itemTable, fileTable := s.batchTables()
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id TEXT PRIMARY KEY,
+			content JSONB NOT NULL,
+			lease_owner TEXT,
+			lease_expires TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`, batchTable)); err != nil {
+		return fmt.Errorf("postgres store: create batch table: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS lease_owner TEXT, ADD COLUMN IF NOT EXISTS lease_expires TIMESTAMPTZ", batchTable)); err != nil {
+		return fmt.Errorf("postgres store: add batch lease columns: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			seq BIGSERIAL PRIMARY KEY,
+			batch_id TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			custom_id TEXT,
+			content TEXT NOT NULL
+		)
+	`, itemTable)); err != nil {
+		return fmt.Errorf("postgres store: create batch item table: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS custom_id TEXT", itemTable)); err != nil {
+		return fmt.Errorf("postgres store: add batch item custom_id column: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (batch_id, kind, seq)", quoteIdentifier(s.cfg.BatchTable+"_items_batch_idx"), itemTable)); err != nil {
+		return fmt.Errorf("postgres store: create batch item index: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s (batch_id, custom_id) WHERE kind = '%s'", quoteIdentifier(s.cfg.BatchTable+"_items_result_idx"), itemTable, batchItemResult)); err != nil {
+		return fmt.Errorf("postgres store: create batch result index: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id TEXT PRIMARY KEY,
+			meta JSONB NOT NULL,
+			content BYTEA NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`, fileTable)); err != nil {
+		return fmt.Errorf("postgres store: create batch file table: %w", err)
+	}
+	return nil
+}
+
+// PutBatch implements batches.Store. A cancel request already stored is kept, so the
+// replica processing the batch cannot overwrite a cancel requested through another one.
+func (s *PostgresStore) PutBatch(ctx context.Context, batch *batches.Batch) error {
+	if s == nil || s.db == nil {
+		return fmt.Errorf("postgres store: not initialized")
+	}
+	content, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("postgres store: marshal batch: %w", err)
+	}
+	batchTable, _, _ := s.batchTables()
+	query := fmt.Sprintf(`
+		INSERT INTO %s AS stored (id, content, created_at, updated_at)
+		VALUES ($1, $2, NOW(), NOW())
+		ON CONFLICT (id)
+		DO UPDATE SET content = CASE
+			WHEN stored.content ? 'cancel_requested_at' AND NOT EXCLUDED.content ? 'cancel_requested_at'
+			THEN EXCLUDED.content || jsonb_build_object(
+				'cancel_requested_at', stored.content->'cancel_requested_at',
+				'status', CASE WHEN EXCLUDED.content->>'status' = '%s' THEN '%s' ELSE EXCLUDED.content->>'status' END)
+			ELSE EXCLUDED.content
+		END, updated_at = NOW()
+	`, batchTable, batches.StatusInProgress, batches.StatusCanceling)
+	if _, err = s.db.ExecContext(ctx, query, batch.ID, content); err != nil {
+		return fmt.Errorf("postgres store: upsert batch %s: %w", batch.ID, err)
+	}
+	return nil
+}
+
+// ClaimBatch implements batches.SharedStore.
+func (s *PostgresStore) ClaimBatch(ctx context.Context, id, owner string, ttl time.Duration) (bool, error) {
+	if s == nil || s.db == nil {
+		return false, fmt.Errorf("postgres store: not initialized")
+	}
+	batchTable, _, _ := s.batchTables()
+	query := fmt.Sprintf(`
+		UPDATE %s SET lease_owner = $2, lease_expires = NOW() + $3::BIGINT * INTERVAL '1 millisecond'
+		WHERE id = $1 AND (lease_owner IS NULL OR lease_owner = $2 OR lease_expires < NOW())
+		RETURNING id
+	`, batchTable)
+	var claimed string
+	err := s.db.QueryRowContext(ctx, query, id, owner, ttl.Milliseconds()).Scan(&claimed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("postgres store: claim batch %s: %w", id, err)
+	}
+	return true, nil
+}
+
+// ReleaseBatch implements batches.SharedStore.
+func (s *PostgresStore) ReleaseBatch(ctx context.Context, id, owner string) error {
+	if s == nil || s.db == nil {
+		return fmt.Errorf("postgres store: not initialized")
+	}
+	batchTable, _, _ := s.batchTables()
+	query := fmt.Sprintf("UPDATE %s SET lease_owner = NULL, lease_expires = NULL WHERE id = $1 AND lease_owner = $2", batchTable)
+	if _, err := s.db.ExecContext(ctx, query, id, owner); err != nil {
+		return fmt.Errorf("postgres store: release batch %s: %w", id, err)
+	}
+	return nil
+}
+
+// GetBatch implements batches.Store.
+func (s *PostgresStore) GetBatch(ctx context.Context, id string) (*batches.Batch, error) {
+	if s == nil || s.db == nil {
+		return nil, fmt.Errorf("postgres store: not initialized")
+	}
+	batchTable, _, _ := s.batchTables()
+	var content []byte
+	err := s.db.QueryRowContext(ctx, fmt.Sprintf("SELECT content FROM %s WHERE id = $1", batchTable), id).Scan(&content)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, batches.ErrNotFound
+		}
+		return nil, fmt.Errorf("postgres store: load batch %s: %w", id, err)
+	}
+	var batch batches.Batch
+	if err = json.Unmarshal(content, &batch); err != nil {
+		return nil, fmt.Errorf("postgres store: decode batch %s: %w", id, err)
+	}
+	return &batch, nil
+}
+
+// ListBatches implements batches.Store.
+func (s *PostgresStore) ListBatches(ctx context.Context) ([]*batches.Batch, error) {
+	if s == nil || s.db == nil {
+		return nil, fmt.Errorf("postgres store: not initialized")
+	}
+	batchTable, _, _ := s.batchTables()
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("SELECT content FROM %s ORDER BY created_at DESC", batchTable))
+	if err != nil {
+		return nil, fmt.Errorf("postgres store: list batches: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var list []*batches.Batch
+	for rows.Next() {
+		var content []byte
+		if err = rows.Scan(&content); err != nil {
+			return nil, fmt.Errorf("postgres store: scan batch: %w", err)
+		}
+		var batch batches.Batch
+		if err = json.Unmarshal(content, &batch); err != nil {
+			return nil, fmt.Errorf("postgres store: decode batch: %w", err)
+		}
+		list = append(list, &batch)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres store: iterate batches: %w", err)
+	}
+	return list, nil
+}
+
+// DeleteBatch implements batches.Store.
+func (s *PostgresStore) DeleteBatch(ctx context.Context, id string) error {
+	if s == nil || s.db == nil {
+		return fmt.Errorf("postgres store: not initialized")
+	}
+	batchTable, itemTable, _ := s.batchTables()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("postgres store: begin batch delete: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if _, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE batch_id = $1", itemTable), id); err != nil {
+		return fmt.Errorf("postgres store: delete batch items %s: %w", id, err)
+	}
+	result, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = $1", batchTable), id)
+	if err != nil {
+		return fmt.Errorf("postgres store: delete batch %s: %w", id, err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("postgres store: commit batch delete: %w", err)
+	}
+	if removed, errRows := result.RowsAffected(); errRows == nil && removed == 0 {
+		return batches.ErrNotFound
+	}
+	return nil
+}
+
+// PutRequests implements batches.Store, replacing any requests stored for the batch.
+func (s *PostgresStore) PutRequests(ctx context.Context, batchID string, requests []batches.Request) error {
+	if s == nil || s.db == nil {
+		return fmt.Errorf("postgres store: not initialized")
+	}
+	_, itemTable, _ := s.batchTables()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("postgres store: begin batch requests transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if _, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE batch_id = $1 AND kind = $2", itemTable), batchID, batchItemRequest); err != nil {
+		return fmt.Errorf("postgres store: clear batch requests: %w", err)
+	}
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s (batch_id, kind, content) VALUES ($1, $2, $3)", itemTable))
+	if err != nil {
+		return fmt.Errorf("postgres store: prepare batch request insert: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+	for i := range requests {
+		content, errMarshal := json.Marshal(&requests[i])
+		if errMarshal != nil {
+			return fmt.Errorf("postgres store: marshal batch request: %w", errMarshal)
+		}
+		if _, err = stmt.ExecContext(ctx, batchID, batchItemRequest, string(content)); err != nil {
+			return fmt.Errorf("postgres store: insert batch request: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("postgres store: commit batch requests: %w", err)
+	}
+	return nil
+}
+
+// LoadRequests implements batches.Store.
+func (s *PostgresStore) LoadRequests(ctx context.Context, batchID string) ([]batches.Request, error) {
+	var requests []batches.Request
+	err := s.loadBatchItems(ctx, batchID, batchItemRequest, func(content []byte) error {
+		var request batches.Request
+		if err := json.Unmarshal(content, &request); err != nil {
+			return err
+		}
+		requests = append(requests, request)
+		return nil
+	})
+	return requests, err
+}
+
+// AppendResult implements batches.Store, ignoring results for custom IDs that already
+// have one.
+func (s *PostgresStore) AppendResult(ctx context.Context, batchID string, result batches.Result) error {
+	if s == nil || s.db == nil {
+		return fmt.Errorf("postgres store: not initialized")
+	}
+	content, err := json.Marshal(&result)
+	if err != nil {
+		return fmt.Errorf("postgres store: marshal batch result: %w", err)
+	}
+	_, itemTable, _ := s.batchTables()
+	query := fmt.Sprintf(`
+		INSERT INTO %s (batch_id, kind, custom_id, content)
+		VALUES ($1, '%s', $2, $3)
+		ON CONFLICT (batch_id, custom_id) WHERE kind = '%s'
+		DO NOTHING
+	`, itemTable, batchItemResult, batchItemResult)
+	if _, err = s.db.ExecContext(ctx, query, batchID, result.CustomID, string(content)); err != nil {
+		return fmt.Errorf("postgres store: insert batch result: %w", err)
+	}
+	return nil
+}
+
+// LoadResults implements batches.Store.
+func (s *PostgresStore) LoadResults(ctx context.Context, batchID string) ([]batches.Result, error) {
+	var results []batches.Result
+	err := s.loadBatchItems(ctx, batchID, batchItemResult, func(content []byte) error {
+		var result batches.Result
+		if err := json.Unmarshal(content, &result); err != nil {
+			return err
+		}
+		results = append(results, result)
+		return nil
+	})
+	return results, err
+}
+
+func (s *PostgresStore) loadBatchItems(ctx context.Context, batchID, kind string, fn func(content []byte) error) error {
+	if s == nil || s.db == nil {
+		return fmt.Errorf("postgres store: not initialized")
+	}
+	_, itemTable, _ := s.batchTables()
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("SELECT content FROM %s WHERE batch_id = $1 AND kind = $2 ORDER BY seq", itemTable), batchID, kind)
+	if err != nil {
+		return fmt.Errorf("postgres store: query batch %ss: %w", kind, err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		var content []byte
+		if err = rows.Scan(&content); err != nil {
+			return fmt.Errorf("postgres store: scan batch %s: %w", kind, err)
+		}
+		if err = fn(content); err != nil {
+			return fmt.Errorf("postgres store: decode batch %s: %w", kind, err)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("postgres store: iterate batch %ss: %w", kind, err)
+	}
+	return nil
+}
+
+// PutFile implements batches.Store.
+func (s *PostgresStore) PutFile(ctx context.Context, file *batches.File, content []byte) error {
+	if s == nil || s.db == nil {
+		return fmt.Errorf("postgres store: not initialized")
+	}
+	meta, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("postgres store: marshal batch file: %w", err)
+	}
+	_, _, fileTable := s.batchTables()
+	query := fmt.Sprintf(`
+		INSERT INTO %s (id, meta, content, created_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (id)
+		DO UPDATE SET meta = EXCLUDED.meta, content = EXCLUDED.content
+	`, fileTable)
+	if _, err = s.db.ExecContext(ctx, query, file.ID, meta, content); err != nil {
+		return fmt.Errorf("postgres store: upsert batch file %s: %w", file.ID, err)
+	}
+	return nil
+}
+
+// GetFile implements batches.Store.
+func (s *PostgresStore) GetFile(ctx context.Context, id string) (*batches.File, error) {
+	if s == nil || s.db == nil {
+		return nil, fmt.Errorf("postgres store: not initialized")
+	}
+	_, _, fileTable := s.batchTables()
+	var meta []byte
+	err := s.db.QueryRowContext(ctx, fmt.Sprintf("SELECT meta FROM %s WHERE id = $1", fileTable), id).Scan(&meta)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, batches.ErrNotFound
+		}
+		return nil, fmt.Errorf("postgres store: load batch file %s: %w", id, err)
+	}
+	var file batches.File
+	if err = json.Unmarshal(meta, &file); err != nil {
+		return nil, fmt.Errorf("postgres store: decode batch file %s: %w", id, err)
+	}
+	return &file, nil
+}
+
+// ReadFile implements batches.Store.
+func (s *PostgresStore) ReadFile(ctx context.Context, id string) ([]byte, error) {
+	if s == nil || s.db == nil {
+		return nil, fmt.Errorf("postgres store: not initialized")
+	}
+	_, _, fileTable := s.batchTables()
+	var content []byte
+	err := s.db.QueryRowContext(ctx, fmt.Sprintf("SELECT content FROM %s WHERE id = $1", fileTable), id).Scan(&content)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, batches.ErrNotFound
+		}
+		return nil, fmt.Errorf("postgres store: read batch file %s: %w", id, err)
+	}
+	return content, nil
+}
+
+// ListFiles implements batches.Store.
+func (s *PostgresStore) ListFiles(ctx context.Context) ([]*batches.File, error) {
+	if s == nil || s.db == nil {
+		return nil, fmt.Errorf("postgres store: not initialized")
+	}
+	_, _, fileTable := s.batchTables()
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("SELECT meta FROM %s ORDER BY created_at DESC", fileTable))
+	if err != nil {
+		return nil, fmt.Errorf("postgres store: list batch files: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var list []*batches.File
+	for rows.Next() {
+		var meta []byte
+		if err = rows.Scan(&meta); err != nil {
+			return nil, fmt.Errorf("postgres store: scan batch file: %w", err)
+		}
+		var file batches.File
+		if err = json.Unmarshal(meta, &file); err != nil {
+			return nil, fmt.Errorf("postgres store: decode batch file: %w", err)
+		}
+		list = append(list, &file)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres store: iterate batch files: %w", err)
+	}
+	return list, nil
+}
+
+// DeleteFile implements batches.Store.
+func (s *PostgresStore) DeleteFile(ctx context.Context, id string) error {
+	if s == nil || s.db == nil {
+		return fmt.Errorf("postgres store: not initialized")
+	}
+	_, _, fileTable := s.batchTables()
+	result, err := s.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = $1", fileTable), id)
+	if err != nil {
+		return fmt.Errorf("postgres store: delete batch file %s: %w", id, err)
+	}
+	if removed, errRows := result.RowsAffected(); errRows == nil && removed == 0 {
+		return batches.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+// usageLockKey derives the advisory lock serialising usage writers of a table, so row
+// sequence numbers become visible in commit order and readers can page by sequence.
+func (s *PostgresStore) usageLockKey() int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s.fullTableName(s.cfg.UsageTable)))
+	return int64(h.Sum64())
+}
+
+// usageGenerationTable holds the single row counting deletions from the usage table.
+func (s *PostgresStore) usageGenerationTable() string {
+	return s.fullTableName(s.cfg.UsageTable + "_generation")
+}
+
+// AppendUsage implements usage.Store. Events are written in a single transaction; events
+// already stored by an earlier attempt are skipped.
+func (s *PostgresStore) AppendUsage(ctx context.Context, events []usage.Event) error {
+	if s == nil || s.db == nil {
+		return fmt.Errorf("postgres store: not initialized")
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("postgres store: begin usage transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if _, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", s.usageLockKey()); err != nil {
+		return fmt.Errorf("postgres store: lock usage table: %w", err)
+	}
+	query := fmt.Sprintf("INSERT INTO %s (id, instance, content) VALUES ($1, $2, $3) ON CONFLICT (id) DO NOTHING", s.fullTableName(s.cfg.UsageTable))
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("postgres store: prepare usage insert: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+	for _, event := range events {
+		content, errMarshal := json.Marshal(event)
+		if errMarshal != nil {
+			return fmt.Errorf("postgres store: marshal usage event: %w", errMarshal)
+		}
+		if _, err = stmt.ExecContext(ctx, event.ID, event.Instance, content); err != nil {
+			return fmt.Errorf("postgres store: insert usage event: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("postgres store: commit usage events: %w", err)
+	}
+	return nil
+}
+
+// LoadUsage implements usage.Store using the row sequence as cursor.
+func (s *PostgresStore) LoadUsage(ctx context.Context, cursor int64) ([]usage.Event, int64, error) {
+	if s == nil || s.db == nil {
+		return nil, cursor, fmt.Errorf("postgres store: not initialized")
+	}
+	start := cursor
+	query := fmt.Sprintf("SELECT seq, content FROM %s WHERE seq > $1 ORDER BY seq", s.fullTableName(s.cfg.UsageTable))
+	rows, err := s.db.QueryContext(ctx, query, cursor)
+	if err != nil {
+		return nil, cursor, fmt.Errorf("postgres store: query usage events: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var events []usage.Event
+	for rows.Next() {
+		var (
+			seq     int64
+			content []byte
+		)
+		if err = rows.Scan(&seq, &content); err != nil {
+			return nil, start, fmt.Errorf("postgres store: scan usage event: %w", err)
+		}
+		var event usage.Event
+		if err = json.Unmarshal(content, &event); err != nil {
+			return nil, start, fmt.Errorf("postgres store: decode usage event %d: %w", seq, err)
+		}
+		events = append(events, event)
+		cursor = seq
+	}
+	if err = rows.Err(); err != nil {
+		return nil, start, fmt.Errorf("postgres store: iterate usage events: %w", err)
+	}
+	return events, cursor, nil
+}
+
+// DeleteUsage implements usage.Store, advancing the generation in the same transaction.
+func (s *PostgresStore) DeleteUsage(ctx context.Context, filter usage.Filter) (int64, error) {
+	if s == nil || s.db == nil {
+		return 0, fmt.Errorf("postgres store: not initialized")
+	}
+	var (
+		conditions []string
+		args       []any
+	)
+	add := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.APIKey != "" {
+		add("content->>'api' = $%d", filter.APIKey)
+	}
+	if filter.Model != "" {
+		add("content->>'model' = $%d", filter.Model)
+	}
+	if filter.Provider != "" {
+		add("content->'detail'->>'provider' = $%d", filter.Provider)
+	}
+	if filter.AuthID != "" {
+		add("content->'detail'->>'auth_id' = $%d", filter.AuthID)
+	}
+	if !filter.From.IsZero() {
+		add("(content->'detail'->>'timestamp')::timestamptz >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		add("(content->'detail'->>'timestamp')::timestamptz < $%d", filter.To)
+	}
+	query := fmt.Sprintf("DELETE FROM %s", s.fullTableName(s.cfg.UsageTable))
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("postgres store: begin usage delete: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("postgres store: delete usage events: %w", err)
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("postgres store: count deleted usage events: %w", err)
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	generationQuery := fmt.Sprintf(`
+		INSERT INTO %s AS state (id, generation) VALUES (1, 1)
+		ON CONFLICT (id) DO UPDATE SET generation = state.generation + 1
+	`, s.usageGenerationTable())
+	if _, err = tx.ExecContext(ctx, generationQuery); err != nil {
+		return 0, fmt.Errorf("postgres store: advance usage generation: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("postgres store: commit usage delete: %w", err)
+	}
+	return removed, nil
+}
+
+// UsageGeneration implements usage.Store.
+func (s *PostgresStore) UsageGeneration(ctx context.Context) (int64, error) {
+	if s == nil || s.db == nil {
+		return 0, fmt.Errorf("postgres store: not initialized")
+	}
+	var generation int64
+	err := s.db.QueryRowContext(ctx, fmt.Sprintf("SELECT generation FROM %s WHERE id = 1", s.usageGenerationTable())).Scan(&generation)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("postgres store: load usage generation: %w", err)
+	}
+	return generation, nil
+}
@@ -22,6 +22,8 @@ import (
 const (
 	defaultConfigTable = "config_store"
 	defaultAuthTable   = "auth_store"
+	defaultUsageTable  = "usage_store"
+	defaultBatchTable  = "batch_store"
 	defaultConfigKey   = "config"
 )
 
@@ -31,6 +33,8 @@ type PostgresStoreConfig struct {
 	Schema      string
 	ConfigTable string
 	AuthTable   string
+	UsageTable  string
+	BatchTable  string
 	SpoolDir    string
 }
 
@@ -58,6 +62,12 @@ func NewPostgresStore(ctx context.Context, cfg PostgresStoreConfig) (*PostgresSt
 	if cfg.AuthTable == "" {
 		cfg.AuthTable = defaultAuthTable
 	}
+	if cfg.UsageTable == "" {
+		cfg.UsageTable = defaultUsageTable
+	}
+	if cfg.BatchTable == "" {
+		cfg.BatchTable = defaultBatchTable
+	}
 
 	spoolRoot := strings.TrimSpace(cfg.SpoolDir)
 	if spoolRoot == "" {
@@ -140,6 +150,29 @@ func (s *PostgresStore) EnsureSchema(ctx context.Context) error {
 	`, authTable)); err != nil {
 		return fmt.Errorf("postgres store: create auth table: %w", err)
 	}
+	usageTable := s.fullTableName(s.cfg.UsageTable)
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			seq BIGSERIAL PRIMARY KEY,
+			id TEXT NOT NULL UNIQUE,
+			instance TEXT NOT NULL,
+			content JSONB NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`, usageTable)); err != nil {
+		return fmt.Errorf("postgres store: create usage table: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id SMALLINT PRIMARY KEY,
+			generation BIGINT NOT NULL
+		)
+	`, s.usageGenerationTable())); err != nil {
+		return fmt.Errorf("postgres store: create usage generation table: %w", err)
+	}
+	if err := s.ensureBatchSchema(ctx); err != nil {
+		return err
+	}
 	return nil
 }
 
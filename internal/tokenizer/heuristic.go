@@ -0,0 +1,52 @@
+package tokenizer
+
+import (
+	"math"
+	"unicode"
+)
+
+// heuristic estimates the tokens of a model family. Text is split with the family's
+// pre-tokenizer pattern; every piece counts at least one token and long pieces one token per
+// charsPerToken characters. Han, kana and Hangul characters are counted separately at
+// tokensPerCJK each, since most vocabularies spend about one token per character on them.
+type heuristic struct {
+	name          string
+	charsPerToken float64
+	tokensPerCJK  float64
+	splitter      splitter
+}
+
+var (
+	o200kEstimator   = &heuristic{name: "o200k_base-estimate", charsPerToken: 4.2, tokensPerCJK: 0.9, splitter: splitter{pattern: o200kPattern}}
+	cl100kEstimator  = &heuristic{name: "cl100k_base-estimate", charsPerToken: 4.0, tokensPerCJK: 1.2, splitter: splitter{pattern: cl100kPattern}}
+	claudeEstimator  = &heuristic{name: "claude-estimate", charsPerToken: 3.5, tokensPerCJK: 1.3, splitter: splitter{pattern: cl100kPattern}}
+	geminiEstimator  = &heuristic{name: "gemini-estimate", charsPerToken: 4.0, tokensPerCJK: 0.8, splitter: splitter{pattern: cl100kPattern}}
+	cjkEstimator     = &heuristic{name: "cjk-estimate", charsPerToken: 4.0, tokensPerCJK: 0.7, splitter: splitter{pattern: cl100kPattern}}
+	genericEstimator = &heuristic{name: "generic-estimate", charsPerToken: 4.0, tokensPerCJK: 1.0, splitter: splitter{pattern: cl100kPattern}}
+)
+
+// Name returns the estimator name.
+func (h *heuristic) Name() string { return h.name }
+
+// Count returns the estimated number of tokens in text.
+func (h *heuristic) Count(text string) int {
+	var tokens, cjk float64
+	h.splitter.split(text, func(piece string) {
+		chars := 0
+		for _, r := range piece {
+			if isCJK(r) {
+				cjk++
+				continue
+			}
+			chars++
+		}
+		if chars > 0 {
+			tokens += math.Max(1, math.Ceil(float64(chars)/h.charsPerToken))
+		}
+	})
+	return int(tokens + math.Ceil(cjk*h.tokensPerCJK))
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
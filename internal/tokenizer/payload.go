@@ -0,0 +1,249 @@
+package tokenizer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// messageOverhead is the number of tokens the chat format adds around every message.
+	messageOverhead = 3
+	// replyOverhead is the number of tokens priming the assistant reply.
+	replyOverhead = 3
+	// toolOverhead is the number of tokens added around every tool definition.
+	toolOverhead = 8
+	// defaultImageSide is the side assumed for images whose size cannot be read.
+	defaultImageSide = 1024
+	// geminiImageTokens is the fixed cost of an image in Gemini requests.
+	geminiImageTokens = 258
+)
+
+// CountPayload counts the input tokens of a request payload in the given upstream format
+// ("openai", "codex", "claude", "gemini" or "gemini-cli") for model. Text, tool calls, tool
+// results, tool definitions and images are counted; other formats count all string values.
+func CountPayload(format, model string, payload []byte) int64 {
+	encoder := ForModel(model)
+	root := gjson.ParseBytes(payload)
+	var total int
+	switch format {
+	case "openai":
+		total = countOpenAIChat(encoder, root)
+	case "codex":
+		total = countResponses(encoder, root)
+	case "claude":
+		total = countClaude(encoder, root)
+	case "gemini":
+		total = countGemini(encoder, root)
+	case "gemini-cli":
+		total = countGemini(encoder, root.Get("request"))
+	default:
+		total = countStrings(encoder, root)
+	}
+	return int64(total)
+}
+
+func countOpenAIChat(encoder Encoder, root gjson.Result) int {
+	total := replyOverhead
+	for _, message := range root.Get("messages").Array() {
+		total += messageOverhead
+		total += encoder.Count(message.Get("role").String())
+		total += encoder.Count(message.Get("name").String())
+		content := message.Get("content")
+		if content.Type == gjson.String {
+			total += encoder.Count(content.String())
+		}
+		for _, part := range content.Array() {
+			switch part.Get("type").String() {
+			case "text":
+				total += encoder.Count(part.Get("text").String())
+			case "image_url":
+				total += openAIImageTokens(part.Get("image_url.url").String(), part.Get("image_url.detail").String())
+			}
+		}
+		for _, call := range message.Get("tool_calls").Array() {
+			total += encoder.Count(call.Get("function.name").String())
+			total += encoder.Count(call.Get("function.arguments").String())
+		}
+	}
+	for _, tool := range root.Get("tools").Array() {
+		total += toolOverhead + encoder.Count(tool.Get("function").Raw)
+	}
+	total += encoder.Count(root.Get("response_format.json_schema").Raw)
+	return total
+}
+
+func countResponses(encoder Encoder, root gjson.Result) int {
+	total := replyOverhead + encoder.Count(root.Get("instructions").String())
+	input := root.Get("input")
+	if input.Type == gjson.String {
+		total += messageOverhead + encoder.Count(input.String())
+	}
+	for _, item := range input.Array() {
+		total += messageOverhead
+		switch item.Get("type").String() {
+		case "function_call":
+			total += encoder.Count(item.Get("name").String())
+			total += encoder.Count(item.Get("arguments").String())
+		case "function_call_output":
+			total += encoder.Count(item.Get("output").String())
+		default:
+			content := item.Get("content")
+			if content.Type == gjson.String {
+				total += encoder.Count(content.String())
+			}
+			for _, part := range content.Array() {
+				switch part.Get("type").String() {
+				case "input_text", "output_text":
+					total += encoder.Count(part.Get("text").String())
+				case "input_image":
+					total += openAIImageTokens(part.Get("image_url").String(), part.Get("detail").String())
+				}
+			}
+		}
+	}
+	for _, tool := range root.Get("tools").Array() {
+		total += toolOverhead + encoder.Count(tool.Raw)
+	}
+	total += encoder.Count(root.Get("text.format.schema").Raw)
+	return total
+}
+
+func countClaude(encoder Encoder, root gjson.Result) int {
+	total := countClaudeContent(encoder, root.Get("system"))
+	for _, message := range root.Get("messages").Array() {
+		total += messageOverhead + countClaudeContent(encoder, message.Get("content"))
+	}
+	for _, tool := range root.Get("tools").Array() {
+		total += toolOverhead + encoder.Count(tool.Raw)
+	}
+	return total
+}
+
+func countClaudeContent(encoder Encoder, content gjson.Result) int {
+	if content.Type == gjson.String {
+		return encoder.Count(content.String())
+	}
+	total := 0
+	for _, block := range content.Array() {
+		switch block.Get("type").String() {
+		case "text":
+			total += encoder.Count(block.Get("text").String())
+		case "thinking":
+			total += encoder.Count(block.Get("thinking").String())
+		case "tool_use":
+			total += encoder.Count(block.Get("name").String())
+			total += encoder.Count(block.Get("input").Raw)
+		case "tool_result":
+			total += countClaudeContent(encoder, block.Get("content"))
+		case "image":
+			width, height := imageSize(block.Get("source.data").String())
+			total += claudeImageTokens(width, height)
+		}
+	}
+	return total
+}
+
+func countGemini(encoder Encoder, root gjson.Result) int {
+	total := 0
+	for _, key := range []string{"systemInstruction", "system_instruction"} {
+		for _, part := range root.Get(key + ".parts").Array() {
+			total += countGeminiPart(encoder, part)
+		}
+	}
+	for _, content := range root.Get("contents").Array() {
+		total += messageOverhead
+		for _, part := range content.Get("parts").Array() {
+			total += countGeminiPart(encoder, part)
+		}
+	}
+	for _, tool := range root.Get("tools").Array() {
+		total += toolOverhead + encoder.Count(tool.Raw)
+	}
+	return total
+}
+
+func countGeminiPart(encoder Encoder, part gjson.Result) int {
+	switch {
+	case part.Get("text").Exists():
+		return encoder.Count(part.Get("text").String())
+	case part.Get("functionCall").Exists():
+		return encoder.Count(part.Get("functionCall").Raw)
+	case part.Get("functionResponse").Exists():
+		return encoder.Count(part.Get("functionResponse").Raw)
+	case part.Get("inlineData").Exists(), part.Get("inline_data").Exists(), part.Get("fileData").Exists():
+		return geminiImageTokens
+	}
+	return 0
+}
+
+// countStrings counts every string value of an unknown payload, treating data URLs as images.
+func countStrings(encoder Encoder, value gjson.Result) int {
+	switch {
+	case value.Type == gjson.String:
+		text := value.String()
+		if strings.HasPrefix(text, "data:image/") {
+			return openAIImageTokens(text, "")
+		}
+		return encoder.Count(text)
+	case value.IsObject(), value.IsArray():
+		total := 0
+		value.ForEach(func(_, item gjson.Result) bool {
+			total += countStrings(encoder, item)
+			return true
+		})
+		return total
+	}
+	return 0
+}
+
+// openAIImageTokens applies OpenAI's image pricing: 85 tokens for low detail, otherwise 85
+// plus 170 per 512px tile after fitting the image in 2048x2048 and its short side in 768.
+func openAIImageTokens(url, detail string) int {
+	if detail == "low" {
+		return 85
+	}
+	width, height := imageSize(url)
+	w, h := float64(width), float64(height)
+	if scale := 2048 / math.Max(w, h); scale < 1 {
+		w, h = w*scale, h*scale
+	}
+	if scale := 768 / math.Min(w, h); scale < 1 {
+		w, h = w*scale, h*scale
+	}
+	tiles := math.Ceil(w/512) * math.Ceil(h/512)
+	return 85 + 170*int(tiles)
+}
+
+// claudeImageTokens applies Anthropic's estimate of width*height/750 after fitting the
+// long side in 1568 pixels.
+func claudeImageTokens(width, height int) int {
+	w, h := float64(width), float64(height)
+	if scale := 1568 / math.Max(w, h); scale < 1 {
+		w, h = w*scale, h*scale
+	}
+	return int(math.Ceil(w * h / 750))
+}
+
+// imageSize reads the dimensions of a base64 image or data URL, falling back to a
+// 1024x1024 image for remote URLs and unknown formats.
+func imageSize(data string) (int, int) {
+	if strings.HasPrefix(data, "data:") {
+		if idx := strings.Index(data, ","); idx >= 0 {
+			data = data[idx+1:]
+		}
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(data); err == nil {
+		if cfg, _, errDecode := image.DecodeConfig(bytes.NewReader(decoded)); errDecode == nil && cfg.Width > 0 && cfg.Height > 0 {
+			return cfg.Width, cfg.Height
+		}
+	}
+	return defaultImageSide, defaultImageSide
+}
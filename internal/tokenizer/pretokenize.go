@@ -0,0 +1,34 @@
+package tokenizer
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+// The tiktoken split patterns end with `\s+(?!\S)|\s+`. RE2 has no lookahead, so the
+// patterns below end with `\s+`; a whitespace run keeps its last character instead of
+// leaving it to the next piece, which changes estimates by at most one token per run.
+var (
+	cl100kPattern = regexp.MustCompile(`^(?:(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+)`)
+	o200kPattern  = regexp.MustCompile(`^(?:[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+)`)
+)
+
+// splitter breaks text into the pieces an estimator counts.
+type splitter struct {
+	pattern *regexp.Regexp
+}
+
+// split calls fn for every piece of text.
+func (s splitter) split(text string, fn func(piece string)) {
+	for len(text) > 0 {
+		loc := s.pattern.FindStringIndex(text)
+		end := 1
+		if loc != nil && loc[1] > 0 {
+			end = loc[1]
+		} else {
+			_, end = utf8.DecodeRuneInString(text)
+		}
+		fn(text[:end])
+		text = text[end:]
+	}
+}
@@ -0,0 +1,47 @@
+// Package tokenizer estimates token counts offline for providers that have no token
+// counting endpoint, using heuristic estimators tuned per model family. OpenAI model
+// families are split like o200k_base and cl100k_base but not BPE encoded, so their counts
+// are estimates too.
+package tokenizer
+
+import (
+	"strings"
+)
+
+// Encoder counts the tokens of a text.
+type Encoder interface {
+	// Name identifies the estimator.
+	Name() string
+	// Count returns the number of tokens in text.
+	Count(text string) int
+}
+
+// family maps model name prefixes to the encoder that counts their tokens.
+type family struct {
+	prefixes []string
+	encoder  func() Encoder
+}
+
+var families = []family{
+	{prefixes: []string{"gpt-4o", "gpt-4.1", "gpt-4.5", "gpt-5", "gpt-oss", "chatgpt-", "codex-", "o1", "o3", "o4"}, encoder: func() Encoder { return o200kEstimator }},
+	{prefixes: []string{"gpt-4", "gpt-3.5", "text-embedding-3", "text-embedding-ada"}, encoder: func() Encoder { return cl100kEstimator }},
+	{prefixes: []string{"claude"}, encoder: func() Encoder { return claudeEstimator }},
+	{prefixes: []string{"gemini", "gemma", "text-embedding-004"}, encoder: func() Encoder { return geminiEstimator }},
+	{prefixes: []string{"qwen", "deepseek", "kimi", "glm", "tstars"}, encoder: func() Encoder { return cjkEstimator }},
+}
+
+// ForModel returns the encoder for a model. Unknown models use a generic estimator.
+func ForModel(model string) Encoder {
+	name := strings.ToLower(strings.TrimSpace(model))
+	if idx := strings.LastIndex(name, "/"); idx >= 0 {
+		name = name[idx+1:]
+	}
+	for _, f := range families {
+		for _, prefix := range f.prefixes {
+			if strings.HasPrefix(name, prefix) {
+				return f.encoder()
+			}
+		}
+	}
+	return genericEstimator
+}
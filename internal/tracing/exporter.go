@@ -0,0 +1,304 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultServiceName = "cli-proxy-api"
+	maxQueuedSpans     = 4096
+	maxBatchSize       = 512
+	flushInterval      = 5 * time.Second
+	exportTimeout      = 10 * time.Second
+)
+
+// exporter batches finished spans and posts them to an OTLP/HTTP endpoint as JSON.
+type exporter struct {
+	endpoint    string
+	headers     map[string]string
+	serviceName string
+	client      *http.Client
+
+	mu      sync.Mutex
+	queue   []*Span
+	closed  bool
+	dropped int
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newExporter(cfg config.TracingConfig) (*exporter, error) {
+	endpoint, err := tracesEndpoint(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	serviceName := strings.TrimSpace(cfg.ServiceName)
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	e := &exporter{
+		endpoint:    endpoint,
+		headers:     cfg.Headers,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: exportTimeout},
+		wake:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go e.run()
+	return e, nil
+}
+
+// tracesEndpoint resolves the OTLP traces URL. A base URL without a path gets the
+// standard /v1/traces path appended; any other path is used verbatim.
+func tracesEndpoint(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", fmt.Errorf("tracing endpoint is empty")
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return "", fmt.Errorf("invalid tracing endpoint %q", raw)
+	}
+	if parsed.Path == "" || parsed.Path == "/" {
+		parsed.Path = "/v1/traces"
+	}
+	return parsed.String(), nil
+}
+
+// enqueue schedules a finished span for export, dropping it when the queue is full.
+func (e *exporter) enqueue(span *Span) {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return
+	}
+	if len(e.queue) >= maxQueuedSpans {
+		e.dropped++
+		e.mu.Unlock()
+		return
+	}
+	e.queue = append(e.queue, span)
+	full := len(e.queue) >= maxBatchSize
+	e.mu.Unlock()
+	if full {
+		select {
+		case e.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+		case <-e.wake:
+		}
+		e.flush(context.Background())
+	}
+}
+
+// shutdown stops the background loop and exports whatever is still queued.
+func (e *exporter) shutdown(ctx context.Context) {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return
+	}
+	e.closed = true
+	e.mu.Unlock()
+	close(e.stop)
+	<-e.done
+	e.flush(ctx)
+}
+
+// flush exports all queued spans in batches.
+func (e *exporter) flush(ctx context.Context) {
+	for {
+		e.mu.Lock()
+		n := len(e.queue)
+		if n > maxBatchSize {
+			n = maxBatchSize
+		}
+		batch := e.queue[:n:n]
+		e.queue = e.queue[n:]
+		if len(e.queue) == 0 {
+			e.queue = nil
+		}
+		dropped := e.dropped
+		e.dropped = 0
+		e.mu.Unlock()
+
+		if dropped > 0 {
+			log.Warnf("tracing: dropped %d spans because the export queue was full", dropped)
+		}
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.export(ctx, batch); err != nil {
+			log.Debugf("tracing: failed to export %d spans: %v", len(batch), err)
+		}
+	}
+}
+
+func (e *exporter) export(ctx context.Context, spans []*Span) error {
+	body, err := json.Marshal(e.encode(spans))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, exportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("collector returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// The types below follow the OTLP/HTTP JSON encoding of ExportTraceServiceRequest.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func (e *exporter) encode(spans []*Span) otlpRequest {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		out = append(out, encodeSpan(span))
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpKeyValue{keyValue("service.name", e.serviceName)}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "github.com/router-for-me/CLIProxyAPI"}, Spans: out}},
+	}}}
+}
+
+func encodeSpan(span *Span) otlpSpan {
+	span.mu.Lock()
+	defer span.mu.Unlock()
+	encoded := otlpSpan{
+		TraceID:           hex.EncodeToString(span.sc.TraceID[:]),
+		SpanID:            hex.EncodeToString(span.sc.SpanID[:]),
+		Name:              span.name,
+		Kind:              int(span.kind),
+		StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+	}
+	if span.parent != (SpanID{}) {
+		encoded.ParentSpanID = hex.EncodeToString(span.parent[:])
+	}
+	for _, attr := range span.attributes {
+		encoded.Attributes = append(encoded.Attributes, keyValue(attr.key, attr.value))
+	}
+	if span.failed {
+		// STATUS_CODE_ERROR
+		encoded.Status = otlpStatus{Code: 2, Message: span.errMessage}
+	}
+	return encoded
+}
+
+func keyValue(key string, value any) otlpKeyValue {
+	var v otlpValue
+	switch typed := value.(type) {
+	case string:
+		v.StringValue = &typed
+	case bool:
+		v.BoolValue = &typed
+	case int:
+		s := strconv.FormatInt(int64(typed), 10)
+		v.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(typed, 10)
+		v.IntValue = &s
+	case float64:
+		v.DoubleValue = &typed
+	default:
+		s := fmt.Sprint(value)
+		v.StringValue = &s
+	}
+	return otlpKeyValue{Key: key, Value: v}
+}
@@ -0,0 +1,290 @@
+// Package tracing provides lightweight distributed tracing for the proxy. Spans follow the
+// W3C Trace Context model: incoming traceparent headers are honored, outgoing upstream
+// requests carry a traceparent of their own, and finished spans are exported over OTLP/HTTP.
+// When tracing is disabled every operation is a cheap no-op on a nil *Span.
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// SpanKind mirrors the OTLP span kinds used by the proxy.
+type SpanKind int
+
+const (
+	// SpanKindInternal marks an operation inside the proxy.
+	SpanKindInternal SpanKind = 1
+	// SpanKindServer marks the handling of an inbound client request.
+	SpanKindServer SpanKind = 2
+	// SpanKindClient marks an outbound request to an upstream provider.
+	SpanKindClient SpanKind = 3
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// SpanContext is the propagated identity of a span.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether both identifiers are non-zero.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Traceparent formats sc as a W3C traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), flags)
+}
+
+// ParseTraceparent parses a W3C traceparent header value.
+func ParseTraceparent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	// Version ff is forbidden; version 00 must have exactly four fields.
+	if parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+	var sc SpanContext
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&0x01 == 0x01
+	return sc, true
+}
+
+// attribute is a single span attribute.
+type attribute struct {
+	key   string
+	value any
+}
+
+// Span is an in-flight operation. A nil *Span is valid and ignores every call.
+type Span struct {
+	tracer *Tracer
+	sc     SpanContext
+	parent SpanID
+	name   string
+	kind   SpanKind
+	start  time.Time
+
+	mu         sync.Mutex
+	end        time.Time
+	attributes []attribute
+	errMessage string
+	failed     bool
+	ended      bool
+}
+
+// SpanContext returns the propagated identity of the span.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttribute records a key/value pair on the span. Supported values are strings,
+// booleans, integers and floats; anything else is stored via fmt.Sprint.
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil || key == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.attributes {
+		if s.attributes[i].key == key {
+			s.attributes[i].value = value
+			return
+		}
+	}
+	s.attributes = append(s.attributes, attribute{key: key, value: value})
+}
+
+// SetError marks the span as failed with err. A nil err is ignored.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.failed = true
+	s.errMessage = err.Error()
+	s.mu.Unlock()
+}
+
+// End finishes the span and hands it to the exporter when sampled. Only the first call counts.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+	if s.sc.Sampled && s.tracer != nil {
+		s.tracer.exporter.enqueue(s)
+	}
+}
+
+// Tracer creates spans and owns the exporter they are sent to.
+type Tracer struct {
+	cfg         config.TracingConfig
+	sampleRatio float64
+	exporter    *exporter
+}
+
+var active atomic.Pointer[Tracer]
+
+// Configure applies cfg, replacing the active tracer unless cfg is unchanged. Spans still
+// queued on the previous tracer are flushed in the background. Disabling tracing turns all
+// spans into no-ops.
+func Configure(cfg config.TracingConfig) {
+	current := active.Load()
+	if current == nil && !cfg.Enable {
+		return
+	}
+	if current != nil && reflect.DeepEqual(current.cfg, cfg) {
+		return
+	}
+	var next *Tracer
+	if cfg.Enable {
+		exp, err := newExporter(cfg)
+		if err != nil {
+			log.Errorf("tracing disabled: %v", err)
+		} else {
+			ratio := cfg.SampleRatio
+			if ratio <= 0 || ratio > 1 {
+				ratio = 1
+			}
+			next = &Tracer{cfg: cfg, sampleRatio: ratio, exporter: exp}
+		}
+	}
+	if next != nil {
+		log.Infof("tracing enabled, exporting spans to %s", next.exporter.endpoint)
+	}
+	if previous := active.Swap(next); previous != nil {
+		go previous.exporter.shutdown(context.Background())
+	}
+}
+
+// Shutdown flushes pending spans and disables tracing.
+func Shutdown(ctx context.Context) {
+	if previous := active.Swap(nil); previous != nil {
+		previous.exporter.shutdown(ctx)
+	}
+}
+
+// Enabled reports whether a tracer is active.
+func Enabled() bool {
+	return active.Load() != nil
+}
+
+// spanContextKey is an unexported context key type to avoid collisions.
+type spanContextKey struct{}
+
+// remoteParentKey is an unexported context key type to avoid collisions.
+type remoteParentKey struct{}
+
+// ContextWithSpan returns a copy of ctx carrying span as the current span.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	if span == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// SpanFromContext returns the current span of ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// ContextWithRemoteParent returns a copy of ctx whose next span continues the trace described by sc.
+func ContextWithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteParentKey{}, sc)
+}
+
+// StartSpan starts a span named name as a child of the span or remote parent in ctx.
+// It returns ctx unchanged and a nil span when tracing is disabled.
+func StartSpan(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	tracer := active.Load()
+	if tracer == nil {
+		return ctx, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	span := &Span{tracer: tracer, name: name, kind: kind, start: time.Now()}
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.sc.TraceID = parent.sc.TraceID
+		span.sc.Sampled = parent.sc.Sampled
+		span.parent = parent.sc.SpanID
+	} else if remote, ok := ctx.Value(remoteParentKey{}).(SpanContext); ok {
+		span.sc.TraceID = remote.TraceID
+		span.sc.Sampled = remote.Sampled
+		span.parent = remote.SpanID
+	} else {
+		span.sc.TraceID = newTraceID()
+		span.sc.Sampled = tracer.sampleRatio >= 1 || rand.Float64() < tracer.sampleRatio
+	}
+	span.sc.SpanID = newSpanID()
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for id == (TraceID{}) {
+		binary.BigEndian.PutUint64(id[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(id[8:], rand.Uint64())
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for id == (SpanID{}) {
+		binary.BigEndian.PutUint64(id[:], rand.Uint64())
+	}
+	return id
+}
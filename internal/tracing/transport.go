@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// TraceparentHeader is the W3C Trace Context request header.
+const TraceparentHeader = "traceparent"
+
+// WrapTransport returns a RoundTripper that records a client span for every request made
+// within a traced context and forwards the trace to the upstream via traceparent. The
+// span ends once the response body is closed, so streamed responses are fully covered.
+// A nil base uses http.DefaultTransport.
+func WrapTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if _, ok := base.(*transport); ok {
+		return base
+	}
+	return &transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if SpanFromContext(ctx) == nil {
+		return t.base.RoundTrip(req)
+	}
+	_, span := StartSpan(ctx, "upstream "+req.Method+" "+req.URL.Host, SpanKindClient)
+	if span == nil {
+		return t.base.RoundTrip(req)
+	}
+	span.SetAttribute("http.request.method", req.Method)
+	span.SetAttribute("server.address", req.URL.Host)
+	span.SetAttribute("url.path", req.URL.Path)
+
+	outgoing := req.Clone(ctx)
+	outgoing.Header.Set(TraceparentHeader, span.SpanContext().Traceparent())
+	resp, err := t.base.RoundTrip(outgoing)
+	if err != nil {
+		span.SetError(err)
+		span.End()
+		return nil, err
+	}
+	span.SetAttribute("http.response.status_code", resp.StatusCode)
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetError(fmt.Errorf("upstream returned status %d", resp.StatusCode))
+	}
+	if resp.Body == nil || resp.Body == http.NoBody {
+		span.End()
+		return resp, nil
+	}
+	resp.Body = &spanBody{ReadCloser: resp.Body, span: span}
+	return resp, nil
+}
+
+// spanBody ends its span when the response body is closed or fully read.
+type spanBody struct {
+	io.ReadCloser
+	span *Span
+	once sync.Once
+}
+
+func (b *spanBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		b.span.SetError(err)
+	}
+	if err != nil {
+		b.once.Do(b.span.End)
+	}
+	return n, err
+}
+
+func (b *spanBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.span.End)
+	return err
+}
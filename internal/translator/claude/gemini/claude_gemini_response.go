@@ -13,6 +13,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -211,24 +212,18 @@ func ConvertClaudeResponseToGemini(_ context.Context, modelName string, original
 		}
 
 		if usage := root.Get("usage"); usage.Exists() {
-			// Basic token counts for prompt and completion
-			inputTokens := usage.Get("input_tokens").Int()
+			// Basic token counts for prompt and completion; the prompt count includes cached tokens
+			cacheUsage := util.ClaudeCacheUsage(usage)
 			outputTokens := usage.Get("output_tokens").Int()
 
 			// Set basic usage metadata according to Gemini API specification
-			template, _ = sjson.Set(template, "usageMetadata.promptTokenCount", inputTokens)
+			template, _ = sjson.Set(template, "usageMetadata.promptTokenCount", cacheUsage.PromptTokens)
 			template, _ = sjson.Set(template, "usageMetadata.candidatesTokenCount", outputTokens)
-			template, _ = sjson.Set(template, "usageMetadata.totalTokenCount", inputTokens+outputTokens)
+			template, _ = sjson.Set(template, "usageMetadata.totalTokenCount", cacheUsage.PromptTokens+outputTokens)
 
-			// Add cache-related token counts if present (Claude Code API cache fields)
-			if cacheCreationTokens := usage.Get("cache_creation_input_tokens"); cacheCreationTokens.Exists() {
-				template, _ = sjson.Set(template, "usageMetadata.cachedContentTokenCount", cacheCreationTokens.Int())
-			}
-			if cacheReadTokens := usage.Get("cache_read_input_tokens"); cacheReadTokens.Exists() {
-				// Add cache read tokens to cached content count
-				existingCacheTokens := usage.Get("cache_creation_input_tokens").Int()
-				totalCacheTokens := existingCacheTokens + cacheReadTokens.Int()
-				template, _ = sjson.Set(template, "usageMetadata.cachedContentTokenCount", totalCacheTokens)
+			// Prompt tokens read from the Claude cache
+			if cacheUsage.CachedTokens > 0 {
+				template, _ = sjson.Set(template, "usageMetadata.cachedContentTokenCount", cacheUsage.CachedTokens)
 			}
 
 			// Add thinking tokens if present (for models with reasoning capabilities)
@@ -473,24 +468,18 @@ func ConvertClaudeResponseToGeminiNonStream(_ context.Context, modelName string,
 			if usage := root.Get("usage"); usage.Exists() {
 				usageJSON := `{}`
 
-				// Basic token counts for prompt and completion
-				inputTokens := usage.Get("input_tokens").Int()
+				// Basic token counts for prompt and completion; the prompt count includes cached tokens
+				cacheUsage := util.ClaudeCacheUsage(usage)
 				outputTokens := usage.Get("output_tokens").Int()
 
 				// Set basic usage metadata according to Gemini API specification
-				usageJSON, _ = sjson.Set(usageJSON, "promptTokenCount", inputTokens)
+				usageJSON, _ = sjson.Set(usageJSON, "promptTokenCount", cacheUsage.PromptTokens)
 				usageJSON, _ = sjson.Set(usageJSON, "candidatesTokenCount", outputTokens)
-				usageJSON, _ = sjson.Set(usageJSON, "totalTokenCount", inputTokens+outputTokens)
+				usageJSON, _ = sjson.Set(usageJSON, "totalTokenCount", cacheUsage.PromptTokens+outputTokens)
 
-				// Add cache-related token counts if present (Claude Code API cache fields)
-				if cacheCreationTokens := usage.Get("cache_creation_input_tokens"); cacheCreationTokens.Exists() {
-					usageJSON, _ = sjson.Set(usageJSON, "cachedContentTokenCount", cacheCreationTokens.Int())
-				}
-				if cacheReadTokens := usage.Get("cache_read_input_tokens"); cacheReadTokens.Exists() {
-					// Add cache read tokens to cached content count
-					existingCacheTokens := usage.Get("cache_creation_input_tokens").Int()
-					totalCacheTokens := existingCacheTokens + cacheReadTokens.Int()
-					usageJSON, _ = sjson.Set(usageJSON, "cachedContentTokenCount", totalCacheTokens)
+				// Prompt tokens read from the Claude cache
+				if cacheUsage.CachedTokens > 0 {
+					usageJSON, _ = sjson.Set(usageJSON, "cachedContentTokenCount", cacheUsage.CachedTokens)
 				}
 
 				// Add thinking tokens if present (for models with reasoning capabilities)
@@ -0,0 +1,22 @@
+// Package ollama provides translation functionality between Ollama and the Claude API.
+// Requests and responses are translated through the OpenAI Chat Completions format,
+// reusing the OpenAI translators of the Claude API.
+package ollama
+
+import (
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/claude/openai/chat-completions"
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/openai/ollama"
+)
+
+// ConvertOllamaRequestToClaude converts an Ollama /api/chat request into a Claude API request.
+//
+// Parameters:
+//   - modelName: The name of the model to use for the request
+//   - inputRawJSON: The raw JSON request data from the Ollama API
+//   - stream: A boolean indicating if the request is for a streaming response
+//
+// Returns:
+//   - []byte: The transformed request data in the Claude API format
+func ConvertOllamaRequestToClaude(modelName string, inputRawJSON []byte, stream bool) []byte {
+	return ConvertOpenAIRequestToClaude(modelName, ConvertOllamaRequestToOpenAI(modelName, inputRawJSON, stream), stream)
+}
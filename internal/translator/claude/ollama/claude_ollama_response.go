@@ -0,0 +1,45 @@
+package ollama
+
+import (
+	"context"
+
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/claude/openai/chat-completions"
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/openai/ollama"
+)
+
+// ConvertClaudeResponseToOllama translates one server-sent event of a Claude Messages stream
+// into Ollama NDJSON lines. The event is first turned into OpenAI chat completion chunks, so
+// text and thinking deltas become content and thinking lines, tool_use blocks become tool
+// calls, and message_delta supplies the done reason and token counts of the final line.
+//
+// Parameters:
+//   - ctx: The context for the request, used for cancellation and timeout handling
+//   - modelName: The name of the model being used for the response
+//   - originalRequestRawJSON: The original Ollama request
+//   - requestRawJSON: The Claude Messages request sent upstream
+//   - rawJSON: One event of the Claude Messages stream
+//   - param: A pointer to a parameter object for maintaining state between calls
+//
+// Returns:
+//   - []string: A slice of Ollama JSON lines
+func ConvertClaudeResponseToOllama(ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
+	return ConvertResponseToOllamaViaOpenAI(ctx, modelName, originalRequestRawJSON, requestRawJSON, rawJSON, param, ConvertClaudeResponseToOpenAI)
+}
+
+// ConvertClaudeResponseToOllamaNonStream converts a Claude Messages response into an Ollama
+// /api/chat response. Text blocks are joined into the message content, thinking blocks fill
+// message.thinking and tool_use blocks become tool calls.
+//
+// Parameters:
+//   - ctx: The context for the request, used for cancellation and timeout handling
+//   - modelName: The name of the model being used for the response
+//   - originalRequestRawJSON: The original Ollama request
+//   - requestRawJSON: The Claude Messages request sent upstream
+//   - rawJSON: The Claude Messages response
+//   - param: A pointer to a parameter object for the conversion
+//
+// Returns:
+//   - string: An Ollama-compatible JSON response
+func ConvertClaudeResponseToOllamaNonStream(ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) string {
+	return ConvertResponseToOllamaViaOpenAINonStream(ctx, modelName, originalRequestRawJSON, requestRawJSON, rawJSON, param, ConvertClaudeResponseToOpenAINonStream)
+}
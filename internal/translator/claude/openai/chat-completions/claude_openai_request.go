@@ -16,6 +16,7 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -133,6 +134,7 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 	// Process messages and transform them to Claude Code format
 	var anthropicMessages []interface{}
 	var toolCallIDs []string // Track tool call IDs for matching with tool results
+	systemMessages := 0      // Number of leading messages converted from system messages
 
 	if messages := root.Get("messages"); messages.Exists() && messages.IsArray() {
 		messages.ForEach(func(_, message gjson.Result) bool {
@@ -168,11 +170,15 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 
 						switch partType {
 						case "text":
-							// Text part conversion
-							contentParts = append(contentParts, map[string]interface{}{
+							// Text part conversion, keeping client cache breakpoints
+							textPart := map[string]interface{}{
 								"type": "text",
 								"text": part.Get("text").String(),
-							})
+							}
+							if cacheControl := part.Get("cache_control"); cacheControl.IsObject() {
+								textPart["cache_control"] = cacheControl.Value()
+							}
+							contentParts = append(contentParts, textPart)
 
 						case "image_url":
 							// Convert OpenAI image format to Claude Code format
@@ -255,6 +261,9 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 				}
 
 				anthropicMessages = append(anthropicMessages, msg)
+				if message.Get("role").String() == "system" && systemMessages == len(anthropicMessages)-1 {
+					systemMessages++
+				}
 
 			case "tool":
 				// Handle tool result messages conversion
@@ -302,6 +311,9 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 				} else if parameters = function.Get("parametersJsonSchema"); parameters.Exists() {
 					anthropicTool["input_schema"] = parameters.Value()
 				}
+				if cacheControl := tool.Get("cache_control"); cacheControl.IsObject() {
+					anthropicTool["cache_control"] = cacheControl.Value()
+				}
 
 				anthropicTools = append(anthropicTools, anthropicTool)
 			}
@@ -321,7 +333,7 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 			choice := toolChoice.String()
 			switch choice {
 			case "none":
-				// Don't set tool_choice, Claude Code will not use tools
+				out, _ = sjson.Set(out, "tool_choice", map[string]interface{}{"type": "none"})
 			case "auto":
 				out, _ = sjson.Set(out, "tool_choice", map[string]interface{}{"type": "auto"})
 			case "required":
@@ -340,5 +352,11 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 		}
 	}
 
+	// response_format -> forced structured output tool
+	out = util.ApplyClaudeStructuredOutput(out, util.ChatCompletionsStructuredOutput(rawJSON))
+
+	// Cache breakpoints after long system prompts and tool definitions
+	out = util.ApplyClaudeAutoCacheBreakpoints(out, systemMessages)
+
 	return []byte(out)
 }
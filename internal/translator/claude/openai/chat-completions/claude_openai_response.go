@@ -12,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -27,6 +28,13 @@ type ConvertAnthropicResponseToOpenAIParams struct {
 	FinishReason string
 	// Tool calls accumulator for streaming
 	ToolCallsAccumulator map[int]*ToolCallAccumulator
+	// Structured output requested through response_format, if any
+	StructuredOutput *util.StructuredOutput
+	// Whether the structured output tool was called and whether any other tool call was emitted
+	StructuredOutputSent bool
+	ToolCallsSent        bool
+	// Prompt token counts reported by message_start, including cached tokens
+	PromptUsage util.CacheUsage
 }
 
 // ToolCallAccumulator holds the state for accumulating tool call data
@@ -34,6 +42,8 @@ type ToolCallAccumulator struct {
 	ID        string
 	Name      string
 	Arguments strings.Builder
+	// Structured marks the structured output tool call, whose input is emitted as content
+	Structured bool
 }
 
 // ConvertClaudeResponseToOpenAI converts Claude Code streaming response format to OpenAI Chat Completions format.
@@ -52,9 +62,10 @@ type ToolCallAccumulator struct {
 func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
 	if *param == nil {
 		*param = &ConvertAnthropicResponseToOpenAIParams{
-			CreatedAt:    0,
-			ResponseID:   "",
-			FinishReason: "",
+			CreatedAt:        0,
+			ResponseID:       "",
+			FinishReason:     "",
+			StructuredOutput: util.ChatCompletionsStructuredOutput(originalRequestRawJSON),
 		}
 	}
 
@@ -88,6 +99,7 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 		if message := root.Get("message"); message.Exists() {
 			(*param).(*ConvertAnthropicResponseToOpenAIParams).ResponseID = message.Get("id").String()
 			(*param).(*ConvertAnthropicResponseToOpenAIParams).CreatedAt = time.Now().Unix()
+			(*param).(*ConvertAnthropicResponseToOpenAIParams).PromptUsage = util.ClaudeCacheUsage(message.Get("usage"))
 
 			template, _ = sjson.Set(template, "id", (*param).(*ConvertAnthropicResponseToOpenAIParams).ResponseID)
 			template, _ = sjson.Set(template, "model", modelName)
@@ -119,8 +131,9 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 				}
 
 				(*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator[index] = &ToolCallAccumulator{
-					ID:   toolCallID,
-					Name: toolName,
+					ID:         toolCallID,
+					Name:       toolName,
+					Structured: toolName == util.StructuredOutputToolName && (*param).(*ConvertAnthropicResponseToOpenAIParams).StructuredOutput != nil,
 				}
 
 				// Don't output anything yet - wait for complete tool call
@@ -155,6 +168,11 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 					if (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator != nil {
 						if accumulator, exists := (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator[index]; exists {
 							accumulator.Arguments.WriteString(partialJSON.String())
+							// Structured output arguments are the message content; stream them as they arrive
+							if accumulator.Structured && partialJSON.String() != "" && (*param).(*ConvertAnthropicResponseToOpenAIParams).StructuredOutput.StreamsStructuredOutput() {
+								template, _ = sjson.Set(template, "choices.0.delta.content", partialJSON.String())
+								return []string{template}
+							}
 						}
 					}
 				}
@@ -175,9 +193,19 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 			if accumulator, exists := (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator[index]; exists {
 				// Build complete tool call with accumulated arguments
 				arguments := accumulator.Arguments.String()
+				if accumulator.Structured {
+					(*param).(*ConvertAnthropicResponseToOpenAIParams).StructuredOutputSent = true
+					delete((*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator, index)
+					if arguments != "" && (*param).(*ConvertAnthropicResponseToOpenAIParams).StructuredOutput.StreamsStructuredOutput() {
+						return []string{}
+					}
+					template, _ = sjson.Set(template, "choices.0.delta.content", (*param).(*ConvertAnthropicResponseToOpenAIParams).StructuredOutput.StructuredOutputContent(arguments))
+					return []string{template}
+				}
 				if arguments == "" {
 					arguments = "{}"
 				}
+				(*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsSent = true
 
 				toolCall := map[string]interface{}{
 					"index": index,
@@ -204,16 +232,28 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 		if delta := root.Get("delta"); delta.Exists() {
 			if stopReason := delta.Get("stop_reason"); stopReason.Exists() {
 				(*param).(*ConvertAnthropicResponseToOpenAIParams).FinishReason = mapAnthropicStopReasonToOpenAI(stopReason.String())
+				// A structured output tool call is the final answer, not a call the client has to run
+				if (*param).(*ConvertAnthropicResponseToOpenAIParams).StructuredOutputSent && !(*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsSent {
+					(*param).(*ConvertAnthropicResponseToOpenAIParams).FinishReason = "stop"
+				}
 				template, _ = sjson.Set(template, "choices.0.finish_reason", (*param).(*ConvertAnthropicResponseToOpenAIParams).FinishReason)
 			}
 		}
 
-		// Handle usage information for token counts
+		// Handle usage information for token counts; prompt counts come from message_start
+		// unless the final usage repeats them
 		if usage := root.Get("usage"); usage.Exists() {
+			promptUsage := (*param).(*ConvertAnthropicResponseToOpenAIParams).PromptUsage
+			if finalUsage := util.ClaudeCacheUsage(usage); finalUsage.PromptTokens > 0 {
+				promptUsage = finalUsage
+			}
 			usageObj := map[string]interface{}{
-				"prompt_tokens":     usage.Get("input_tokens").Int(),
+				"prompt_tokens":     promptUsage.PromptTokens,
 				"completion_tokens": usage.Get("output_tokens").Int(),
-				"total_tokens":      usage.Get("input_tokens").Int() + usage.Get("output_tokens").Int(),
+				"total_tokens":      promptUsage.PromptTokens + usage.Get("output_tokens").Int(),
+				"prompt_tokens_details": map[string]interface{}{
+					"cached_tokens": promptUsage.CachedTokens,
+				},
 			}
 			template, _ = sjson.Set(template, "usage", usageObj)
 		}
@@ -293,7 +333,8 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 	var messageID string
 	var model string
 	var createdAt int64
-	var inputTokens, outputTokens int64
+	var promptUsage util.CacheUsage
+	var outputTokens int64
 	var reasoningTokens int64
 	var stopReason string
 	var contentParts []string
@@ -301,7 +342,10 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 	// Use map to track tool calls by index for proper merging
 	toolCallsMap := make(map[int]map[string]interface{})
 	// Track tool call arguments accumulation
-	toolCallArgsMap := make(map[int]strings.Builder)
+	toolCallArgsMap := make(map[int]*strings.Builder)
+	// The structured output tool call carries the message content instead of a tool call
+	structuredOutput := util.ChatCompletionsStructuredOutput(originalRequestRawJSON)
+	structuredIndex := -1
 
 	for _, chunk := range chunks {
 		root := gjson.ParseBytes(chunk)
@@ -315,7 +359,7 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 				model = message.Get("model").String()
 				createdAt = time.Now().Unix()
 				if usage := message.Get("usage"); usage.Exists() {
-					inputTokens = usage.Get("input_tokens").Int()
+					promptUsage = util.ClaudeCacheUsage(usage)
 				}
 			}
 
@@ -329,6 +373,11 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 				} else if blockType == "tool_use" {
 					// Initialize tool call tracking for this index
 					index := int(root.Get("index").Int())
+					toolCallArgsMap[index] = &strings.Builder{}
+					if structuredOutput != nil && contentBlock.Get("name").String() == util.StructuredOutputToolName {
+						structuredIndex = index
+						continue
+					}
 					toolCallsMap[index] = map[string]interface{}{
 						"id":   contentBlock.Get("id").String(),
 						"type": "function",
@@ -337,8 +386,6 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 							"arguments": "",
 						},
 					}
-					// Initialize arguments builder for this tool call
-					toolCallArgsMap[index] = strings.Builder{}
 				}
 			}
 
@@ -363,7 +410,6 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 						index := int(root.Get("index").Int())
 						if builder, exists := toolCallArgsMap[index]; exists {
 							builder.WriteString(partialJSON.String())
-							toolCallArgsMap[index] = builder
 						}
 					}
 				}
@@ -372,6 +418,12 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 		case "content_block_stop":
 			// Finalize tool call arguments for this index when content block ends
 			index := int(root.Get("index").Int())
+			if index == structuredIndex {
+				if builder, argsExists := toolCallArgsMap[index]; argsExists {
+					contentParts = append(contentParts, structuredOutput.StructuredOutputContent(builder.String()))
+				}
+				continue
+			}
 			if toolCall, exists := toolCallsMap[index]; exists {
 				if builder, argsExists := toolCallArgsMap[index]; argsExists {
 					// Set the accumulated arguments for the tool call
@@ -392,6 +444,9 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 			}
 			if usage := root.Get("usage"); usage.Exists() {
 				outputTokens = usage.Get("output_tokens").Int()
+				if finalUsage := util.ClaudeCacheUsage(usage); finalUsage.PromptTokens > 0 {
+					promptUsage = finalUsage
+				}
 				// Estimate reasoning tokens from accumulated thinking content
 				if len(reasoningParts) > 0 {
 					reasoningTokens = int64(len(strings.Join(reasoningParts, "")) / 4) // Rough estimation
@@ -439,15 +494,18 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 		} else {
 			out, _ = sjson.Set(out, "choices.0.finish_reason", mapAnthropicStopReasonToOpenAI(stopReason))
 		}
+	} else if structuredIndex >= 0 {
+		out, _ = sjson.Set(out, "choices.0.finish_reason", "stop")
 	} else {
 		out, _ = sjson.Set(out, "choices.0.finish_reason", mapAnthropicStopReasonToOpenAI(stopReason))
 	}
 
 	// Set usage information including prompt tokens, completion tokens, and total tokens
-	totalTokens := inputTokens + outputTokens
-	out, _ = sjson.Set(out, "usage.prompt_tokens", inputTokens)
+	totalTokens := promptUsage.PromptTokens + outputTokens
+	out, _ = sjson.Set(out, "usage.prompt_tokens", promptUsage.PromptTokens)
 	out, _ = sjson.Set(out, "usage.completion_tokens", outputTokens)
 	out, _ = sjson.Set(out, "usage.total_tokens", totalTokens)
+	out, _ = sjson.Set(out, "usage.prompt_tokens_details.cached_tokens", promptUsage.CachedTokens)
 
 	// Add reasoning tokens to usage details if any reasoning content was processed
 	if reasoningTokens > 0 {
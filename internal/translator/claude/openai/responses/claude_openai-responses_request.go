@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -307,7 +308,7 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 			case "auto":
 				out, _ = sjson.Set(out, "tool_choice", map[string]interface{}{"type": "auto"})
 			case "none":
-				// Leave unset; implies no tools
+				out, _ = sjson.Set(out, "tool_choice", map[string]interface{}{"type": "none"})
 			case "required":
 				out, _ = sjson.Set(out, "tool_choice", map[string]interface{}{"type": "any"})
 			}
@@ -321,5 +322,15 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 		}
 	}
 
+	// text.format -> forced structured output tool
+	out = util.ApplyClaudeStructuredOutput(out, util.ResponsesStructuredOutput(rawJSON))
+
+	// Cache breakpoints after long instructions and tool definitions
+	systemMessages := 0
+	if instructionsText != "" {
+		systemMessages = 1
+	}
+	out = util.ApplyClaudeAutoCacheBreakpoints(out, systemMessages)
+
 	return []byte(out)
 }
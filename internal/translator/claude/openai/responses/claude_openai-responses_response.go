@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -34,8 +35,13 @@ type claudeToResponsesState struct {
 	ReasoningIndex     int
 	// usage aggregation
 	InputTokens  int64
+	CachedTokens int64
 	OutputTokens int64
 	UsageSeen    bool
+	// structured output requested through text.format; its tool call is emitted as message text
+	StructuredOutput *util.StructuredOutput
+	InStructured     bool
+	StructuredBuf    strings.Builder
 }
 
 var dataTag = []byte("data:")
@@ -47,7 +53,7 @@ func emitEvent(event string, payload string) string {
 // ConvertClaudeResponseToOpenAIResponses converts Claude SSE to OpenAI Responses SSE events.
 func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
 	if *param == nil {
-		*param = &claudeToResponsesState{FuncArgsBuf: make(map[int]*strings.Builder), FuncNames: make(map[int]string), FuncCallIDs: make(map[int]string)}
+		*param = &claudeToResponsesState{FuncArgsBuf: make(map[int]*strings.Builder), FuncNames: make(map[int]string), FuncCallIDs: make(map[int]string), StructuredOutput: util.ResponsesStructuredOutput(originalRequestRawJSON)}
 	}
 	st := (*param).(*claudeToResponsesState)
 
@@ -73,6 +79,7 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 			st.ReasoningActive = false
 			st.InTextBlock = false
 			st.InFuncBlock = false
+			st.InStructured = false
 			st.CurrentMsgID = ""
 			st.CurrentFCID = ""
 			st.ReasoningItemID = ""
@@ -82,11 +89,14 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 			st.FuncNames = make(map[int]string)
 			st.FuncCallIDs = make(map[int]string)
 			st.InputTokens = 0
+			st.CachedTokens = 0
 			st.OutputTokens = 0
 			st.UsageSeen = false
 			if usage := msg.Get("usage"); usage.Exists() {
 				if v := usage.Get("input_tokens"); v.Exists() {
-					st.InputTokens = v.Int()
+					cacheUsage := util.ClaudeCacheUsage(usage)
+					st.InputTokens = cacheUsage.PromptTokens
+					st.CachedTokens = cacheUsage.CachedTokens
 					st.UsageSeen = true
 				}
 				if v := usage.Get("output_tokens"); v.Exists() {
@@ -114,9 +124,12 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 		}
 		idx := int(root.Get("index").Int())
 		typ := cb.Get("type").String()
-		if typ == "text" {
+		structured := typ == "tool_use" && st.StructuredOutput != nil && cb.Get("name").String() == util.StructuredOutputToolName
+		if typ == "text" || structured {
 			// open message item + content part
 			st.InTextBlock = true
+			st.InStructured = structured
+			st.StructuredBuf.Reset()
 			st.CurrentMsgID = fmt.Sprintf("msg_%s_0", st.ResponseID)
 			item := `{"type":"response.output_item.added","sequence_number":0,"output_index":0,"item":{"id":"","type":"message","status":"in_progress","content":[],"role":"assistant"}}`
 			item, _ = sjson.Set(item, "sequence_number", nextSeq())
@@ -179,6 +192,18 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 				// aggregate text for response.output
 				st.TextBuf.WriteString(t.String())
 			}
+		} else if dt == "input_json_delta" && st.InStructured {
+			if pj := d.Get("partial_json"); pj.Exists() {
+				st.StructuredBuf.WriteString(pj.String())
+				if pj.String() != "" && st.StructuredOutput.StreamsStructuredOutput() {
+					msg := `{"type":"response.output_text.delta","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"delta":"","logprobs":[]}`
+					msg, _ = sjson.Set(msg, "sequence_number", nextSeq())
+					msg, _ = sjson.Set(msg, "item_id", st.CurrentMsgID)
+					msg, _ = sjson.Set(msg, "delta", pj.String())
+					out = append(out, emitEvent("response.output_text.delta", msg))
+					st.TextBuf.WriteString(pj.String())
+				}
+			}
 		} else if dt == "input_json_delta" {
 			idx := int(root.Get("index").Int())
 			if pj := d.Get("partial_json"); pj.Exists() {
@@ -209,6 +234,16 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 	case "content_block_stop":
 		idx := int(root.Get("index").Int())
 		if st.InTextBlock {
+			if st.InStructured && (st.StructuredBuf.Len() == 0 || !st.StructuredOutput.StreamsStructuredOutput()) {
+				text := st.StructuredOutput.StructuredOutputContent(st.StructuredBuf.String())
+				msg := `{"type":"response.output_text.delta","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"delta":"","logprobs":[]}`
+				msg, _ = sjson.Set(msg, "sequence_number", nextSeq())
+				msg, _ = sjson.Set(msg, "item_id", st.CurrentMsgID)
+				msg, _ = sjson.Set(msg, "delta", text)
+				out = append(out, emitEvent("response.output_text.delta", msg))
+				st.TextBuf.WriteString(text)
+			}
+			st.InStructured = false
 			done := `{"type":"response.output_text.done","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"text":"","logprobs":[]}`
 			done, _ = sjson.Set(done, "sequence_number", nextSeq())
 			done, _ = sjson.Set(done, "item_id", st.CurrentMsgID)
@@ -266,8 +301,9 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 				st.OutputTokens = v.Int()
 				st.UsageSeen = true
 			}
-			if v := usage.Get("input_tokens"); v.Exists() {
-				st.InputTokens = v.Int()
+			if cacheUsage := util.ClaudeCacheUsage(usage); cacheUsage.PromptTokens > 0 {
+				st.InputTokens = cacheUsage.PromptTokens
+				st.CachedTokens = cacheUsage.CachedTokens
 				st.UsageSeen = true
 			}
 		}
@@ -417,7 +453,7 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 		usagePresent := st.UsageSeen || reasoningTokens > 0
 		if usagePresent {
 			completed, _ = sjson.Set(completed, "response.usage.input_tokens", st.InputTokens)
-			completed, _ = sjson.Set(completed, "response.usage.input_tokens_details.cached_tokens", 0)
+			completed, _ = sjson.Set(completed, "response.usage.input_tokens_details.cached_tokens", st.CachedTokens)
 			completed, _ = sjson.Set(completed, "response.usage.output_tokens", st.OutputTokens)
 			if reasoningTokens > 0 {
 				completed, _ = sjson.Set(completed, "response.usage.output_tokens_details.reasoning_tokens", reasoningTokens)
@@ -470,8 +506,12 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 		reasoningActive bool
 		reasoningItemID string
 		inputTokens     int64
+		cachedTokens    int64
 		outputTokens    int64
+		structuredIdx   = -1
+		structuredBuf   strings.Builder
 	)
+	structuredOutput := util.ResponsesStructuredOutput(originalRequestRawJSON)
 
 	// Per-index tool call aggregation
 	type toolState struct {
@@ -492,7 +532,9 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 				responseID = msg.Get("id").String()
 				createdAt = time.Now().Unix()
 				if usage := msg.Get("usage"); usage.Exists() {
-					inputTokens = usage.Get("input_tokens").Int()
+					cacheUsage := util.ClaudeCacheUsage(usage)
+					inputTokens = cacheUsage.PromptTokens
+					cachedTokens = cacheUsage.CachedTokens
 				}
 			}
 
@@ -507,8 +549,14 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 			case "text":
 				currentMsgID = "msg_" + responseID + "_0"
 			case "tool_use":
-				currentFCID = cb.Get("id").String()
 				name := cb.Get("name").String()
+				if structuredOutput != nil && name == util.StructuredOutputToolName {
+					// The structured output tool call carries the message text
+					currentMsgID = "msg_" + responseID + "_0"
+					structuredIdx = idx
+					continue
+				}
+				currentFCID = cb.Get("id").String()
 				if toolCalls[idx] == nil {
 					toolCalls[idx] = &toolState{id: currentFCID, name: name}
 				} else {
@@ -534,6 +582,10 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 			case "input_json_delta":
 				if pj := d.Get("partial_json"); pj.Exists() {
 					idx := int(root.Get("index").Int())
+					if idx == structuredIdx {
+						structuredBuf.WriteString(pj.String())
+						continue
+					}
 					if toolCalls[idx] == nil {
 						toolCalls[idx] = &toolState{}
 					}
@@ -548,8 +600,10 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 			}
 
 		case "content_block_stop":
-			// Nothing special to finalize for non-stream aggregation
-			_ = root
+			if int(root.Get("index").Int()) == structuredIdx {
+				textBuf.WriteString(structuredOutput.StructuredOutputContent(structuredBuf.String()))
+				structuredIdx = -1
+			}
 
 		case "message_delta":
 			if usage := root.Get("usage"); usage.Exists() {
@@ -686,6 +740,7 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 	// Usage
 	total := inputTokens + outputTokens
 	out, _ = sjson.Set(out, "usage.input_tokens", inputTokens)
+	out, _ = sjson.Set(out, "usage.input_tokens_details.cached_tokens", cachedTokens)
 	out, _ = sjson.Set(out, "usage.output_tokens", outputTokens)
 	out, _ = sjson.Set(out, "usage.total_tokens", total)
 	if reasoningBuf.Len() > 0 {
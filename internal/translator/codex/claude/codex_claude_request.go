@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -220,6 +221,11 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 	template, _ = sjson.Set(template, "store", false)
 	template, _ = sjson.Set(template, "include", []string{"reasoning.encrypted_content"})
 
+	// Claude cache breakpoints -> prompt_cache_key, so requests sharing the cached prefix hit the same cache.
+	if key := util.ClaudeCachePrefixKey(rawJSON); key != "" {
+		template, _ = sjson.Set(template, "prompt_cache_key", key)
+	}
+
 	// Add a first message to ignore system instructions and ensure proper execution.
 	inputResult := gjson.Get(template, "input")
 	if inputResult.Exists() && inputResult.IsArray() {
@@ -14,6 +14,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -108,8 +109,12 @@ func ConvertCodexResponseToClaude(_ context.Context, _ string, originalRequestRa
 		} else {
 			template, _ = sjson.Set(template, "delta.stop_reason", "end_turn")
 		}
-		template, _ = sjson.Set(template, "usage.input_tokens", rootResult.Get("response.usage.input_tokens").Int())
+		promptUsage := util.OpenAICacheUsage(rootResult.Get("response.usage"))
+		template, _ = sjson.Set(template, "usage.input_tokens", promptUsage.UncachedTokens())
 		template, _ = sjson.Set(template, "usage.output_tokens", rootResult.Get("response.usage.output_tokens").Int())
+		if promptUsage.CachedTokens > 0 {
+			template, _ = sjson.Set(template, "usage.cache_read_input_tokens", promptUsage.CachedTokens)
+		}
 
 		output = "event: message_delta\n"
 		output += fmt.Sprintf("data: %s\n\n", template)
@@ -205,6 +210,15 @@ func ConvertCodexResponseToClaudeNonStream(_ context.Context, _ string, original
 			continue
 		}
 
+		promptUsage := util.OpenAICacheUsage(responseData.Get("usage"))
+		usage := map[string]interface{}{
+			"input_tokens":  promptUsage.UncachedTokens(),
+			"output_tokens": responseData.Get("usage.output_tokens").Int(),
+		}
+		if promptUsage.CachedTokens > 0 {
+			usage["cache_read_input_tokens"] = promptUsage.CachedTokens
+		}
+
 		response := map[string]interface{}{
 			"id":            responseData.Get("id").String(),
 			"type":          "message",
@@ -213,10 +227,7 @@ func ConvertCodexResponseToClaudeNonStream(_ context.Context, _ string, original
 			"content":       []interface{}{},
 			"stop_reason":   nil,
 			"stop_sequence": nil,
-			"usage": map[string]interface{}{
-				"input_tokens":  responseData.Get("usage.input_tokens").Int(),
-				"output_tokens": responseData.Get("usage.output_tokens").Int(),
-			},
+			"usage":         usage,
 		}
 
 		var contentBlocks []interface{}
@@ -331,13 +342,6 @@ func ConvertCodexResponseToClaudeNonStream(_ context.Context, _ string, original
 			response["stop_sequence"] = stopSequence.Value()
 		}
 
-		if responseData.Get("usage.input_tokens").Exists() || responseData.Get("usage.output_tokens").Exists() {
-			response["usage"] = map[string]interface{}{
-				"input_tokens":  responseData.Get("usage.input_tokens").Int(),
-				"output_tokens": responseData.Get("usage.output_tokens").Int(),
-			}
-		}
-
 		responseJSON, err := json.Marshal(response)
 		if err != nil {
 			return ""
@@ -371,3 +375,7 @@ func buildReverseMapFromClaudeOriginalShortToOriginal(original []byte) map[strin
 	}
 	return rev
 }
+
+func ClaudeTokenCount(ctx context.Context, count int64) string {
+	return fmt.Sprintf(`{"input_tokens":%d}`, count)
+}
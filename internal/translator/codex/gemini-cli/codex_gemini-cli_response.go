@@ -54,3 +54,7 @@ func ConvertCodexResponseToGeminiCLINonStream(ctx context.Context, modelName str
 	strJSON, _ = sjson.SetRaw(json, "response", strJSON)
 	return strJSON
 }
+
+func GeminiCLITokenCount(ctx context.Context, count int64) string {
+	return GeminiTokenCount(ctx, count)
+}
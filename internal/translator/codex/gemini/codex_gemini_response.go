@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/tidwall/gjson"
@@ -126,6 +127,9 @@ func ConvertCodexResponseToGemini(_ context.Context, modelName string, originalR
 		template, _ = sjson.Set(template, "usageMetadata.candidatesTokenCount", rootResult.Get("response.usage.output_tokens").Int())
 		totalTokens := rootResult.Get("response.usage.input_tokens").Int() + rootResult.Get("response.usage.output_tokens").Int()
 		template, _ = sjson.Set(template, "usageMetadata.totalTokenCount", totalTokens)
+		if cachedTokens := rootResult.Get("response.usage.input_tokens_details.cached_tokens").Int(); cachedTokens > 0 {
+			template, _ = sjson.Set(template, "usageMetadata.cachedContentTokenCount", cachedTokens)
+		}
 	} else {
 		return []string{}
 	}
@@ -198,6 +202,9 @@ func ConvertCodexResponseToGeminiNonStream(_ context.Context, modelName string,
 				template, _ = sjson.Set(template, "usageMetadata.promptTokenCount", inputTokens)
 				template, _ = sjson.Set(template, "usageMetadata.candidatesTokenCount", outputTokens)
 				template, _ = sjson.Set(template, "usageMetadata.totalTokenCount", totalTokens)
+				if cachedTokens := usage.Get("input_tokens_details.cached_tokens").Int(); cachedTokens > 0 {
+					template, _ = sjson.Set(template, "usageMetadata.cachedContentTokenCount", cachedTokens)
+				}
 			}
 
 			// Process output content to build parts array
@@ -344,3 +351,7 @@ func mustMarshalJSON(v interface{}) string {
 	}
 	return string(data)
 }
+
+func GeminiTokenCount(ctx context.Context, count int64) string {
+	return fmt.Sprintf(`{"totalTokens":%d,"promptTokensDetails":[{"modality":"TEXT","tokenCount":%d}]}`, count, count)
+}
@@ -0,0 +1,45 @@
+package ollama
+
+import (
+	"context"
+
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/codex/openai/chat-completions"
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/openai/ollama"
+)
+
+// ConvertCodexResponseToOllama translates one event of a Codex Responses API stream into
+// Ollama NDJSON lines. The event is first turned into OpenAI chat completion chunks, so
+// response.output_text.delta and reasoning summary deltas become content and thinking lines,
+// function calls become tool calls, and response.completed ends the stream with its usage.
+//
+// Parameters:
+//   - ctx: The context for the request, used for cancellation and timeout handling
+//   - modelName: The name of the model being used for the response
+//   - originalRequestRawJSON: The original Ollama request
+//   - requestRawJSON: The Codex Responses request sent upstream
+//   - rawJSON: One event of the Codex Responses stream
+//   - param: A pointer to a parameter object for maintaining state between calls
+//
+// Returns:
+//   - []string: A slice of Ollama JSON lines
+func ConvertCodexResponseToOllama(ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
+	return ConvertResponseToOllamaViaOpenAI(ctx, modelName, originalRequestRawJSON, requestRawJSON, rawJSON, param, ConvertCodexResponseToOpenAI)
+}
+
+// ConvertCodexResponseToOllamaNonStream converts a Codex response into an Ollama /api/chat
+// response. Codex only streams, so rawJSON is the response.completed event of the stream,
+// which carries the output items and usage.
+//
+// Parameters:
+//   - ctx: The context for the request, used for cancellation and timeout handling
+//   - modelName: The name of the model being used for the response
+//   - originalRequestRawJSON: The original Ollama request
+//   - requestRawJSON: The Codex Responses request sent upstream
+//   - rawJSON: The response.completed event of the Codex Responses stream
+//   - param: A pointer to a parameter object for the conversion
+//
+// Returns:
+//   - string: An Ollama-compatible JSON response
+func ConvertCodexResponseToOllamaNonStream(ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) string {
+	return ConvertResponseToOllamaViaOpenAINonStream(ctx, modelName, originalRequestRawJSON, requestRawJSON, rawJSON, param, ConvertCodexResponseToOpenAINonStream)
+}
@@ -14,6 +14,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -234,7 +235,11 @@ func ConvertGeminiCLIResponseToClaude(_ context.Context, _ string, originalReque
 			// Include thinking tokens in output token count if present
 			thoughtsTokenCount := usageResult.Get("thoughtsTokenCount").Int()
 			template, _ = sjson.Set(template, "usage.output_tokens", candidatesTokenCountResult.Int()+thoughtsTokenCount)
-			template, _ = sjson.Set(template, "usage.input_tokens", usageResult.Get("promptTokenCount").Int())
+			promptUsage := util.GeminiCacheUsage(usageResult)
+			template, _ = sjson.Set(template, "usage.input_tokens", promptUsage.UncachedTokens())
+			if promptUsage.CachedTokens > 0 {
+				template, _ = sjson.Set(template, "usage.cache_read_input_tokens", promptUsage.CachedTokens)
+			}
 
 			output = output + template + "\n\n\n"
 		}
@@ -259,6 +264,15 @@ func ConvertGeminiCLIResponseToClaudeNonStream(_ context.Context, _ string, orig
 
 	root := gjson.ParseBytes(rawJSON)
 
+	promptUsage := util.GeminiCacheUsage(root.Get("response.usageMetadata"))
+	usage := map[string]interface{}{
+		"input_tokens":  promptUsage.UncachedTokens(),
+		"output_tokens": root.Get("response.usageMetadata.candidatesTokenCount").Int() + root.Get("response.usageMetadata.thoughtsTokenCount").Int(),
+	}
+	if promptUsage.CachedTokens > 0 {
+		usage["cache_read_input_tokens"] = promptUsage.CachedTokens
+	}
+
 	response := map[string]interface{}{
 		"id":            root.Get("response.responseId").String(),
 		"type":          "message",
@@ -267,10 +281,7 @@ func ConvertGeminiCLIResponseToClaudeNonStream(_ context.Context, _ string, orig
 		"content":       []interface{}{},
 		"stop_reason":   nil,
 		"stop_sequence": nil,
-		"usage": map[string]interface{}{
-			"input_tokens":  root.Get("response.usageMetadata.promptTokenCount").Int(),
-			"output_tokens": root.Get("response.usageMetadata.candidatesTokenCount").Int() + root.Get("response.usageMetadata.thoughtsTokenCount").Int(),
-		},
+		"usage":         usage,
 	}
 
 	parts := root.Get("response.candidates.0.content.parts")
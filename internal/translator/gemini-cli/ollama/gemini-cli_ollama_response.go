@@ -0,0 +1,45 @@
+package ollama
+
+import (
+	"context"
+
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini-cli/openai/chat-completions"
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/openai/ollama"
+)
+
+// ConvertGeminiCLIResponseToOllama translates one chunk of a Gemini CLI (Cloud Code Assist)
+// stream into Ollama NDJSON lines. These chunks wrap a Gemini GenerateContentResponse in a
+// "response" field; the chunk is unwrapped and turned into an OpenAI chat completion chunk
+// before being rendered as content, thinking and tool call lines.
+//
+// Parameters:
+//   - ctx: The context for the request, used for cancellation and timeout handling
+//   - modelName: The name of the model being used for the response
+//   - originalRequestRawJSON: The original Ollama request
+//   - requestRawJSON: The Cloud Code Assist request sent upstream
+//   - rawJSON: One chunk of the Gemini CLI stream
+//   - param: A pointer to a parameter object for maintaining state between calls
+//
+// Returns:
+//   - []string: A slice of Ollama JSON lines
+func ConvertGeminiCLIResponseToOllama(ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
+	return ConvertResponseToOllamaViaOpenAI(ctx, modelName, originalRequestRawJSON, requestRawJSON, rawJSON, param, ConvertCliResponseToOpenAI)
+}
+
+// ConvertGeminiCLIResponseToOllamaNonStream converts a Gemini CLI (Cloud Code Assist)
+// response, a GenerateContentResponse wrapped in a "response" field, into an Ollama
+// /api/chat response.
+//
+// Parameters:
+//   - ctx: The context for the request, used for cancellation and timeout handling
+//   - modelName: The name of the model being used for the response
+//   - originalRequestRawJSON: The original Ollama request
+//   - requestRawJSON: The Cloud Code Assist request sent upstream
+//   - rawJSON: The Gemini CLI response
+//   - param: A pointer to a parameter object for the conversion
+//
+// Returns:
+//   - string: An Ollama-compatible JSON response
+func ConvertGeminiCLIResponseToOllamaNonStream(ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) string {
+	return ConvertResponseToOllamaViaOpenAINonStream(ctx, modelName, originalRequestRawJSON, requestRawJSON, rawJSON, param, ConvertCliResponseToOpenAINonStream)
+}
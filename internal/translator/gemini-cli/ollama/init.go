@@ -0,0 +1,19 @@
+package ollama
+
+import (
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/translator"
+)
+
+func init() {
+	translator.Register(
+		Ollama,
+		GeminiCLI,
+		ConvertOllamaRequestToGeminiCLI,
+		interfaces.TranslateResponse{
+			Stream:    ConvertGeminiCLIResponseToOllama,
+			NonStream: ConvertGeminiCLIResponseToOllamaNonStream,
+		},
+	)
+}
@@ -90,6 +90,9 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 		}
 	}
 
+	// response_format -> request.generationConfig.responseMimeType/responseSchema
+	out = util.ApplyGeminiStructuredOutput(out, "request.", util.ChatCompletionsStructuredOutput(rawJSON))
+
 	// messages -> systemInstruction + contents
 	messages := gjson.GetBytes(rawJSON, "messages")
 	if messages.IsArray() {
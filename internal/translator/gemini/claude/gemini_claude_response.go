@@ -14,6 +14,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -228,7 +229,11 @@ func ConvertGeminiResponseToClaude(_ context.Context, _ string, originalRequestR
 
 			thoughtsTokenCount := usageResult.Get("thoughtsTokenCount").Int()
 			template, _ = sjson.Set(template, "usage.output_tokens", candidatesTokenCountResult.Int()+thoughtsTokenCount)
-			template, _ = sjson.Set(template, "usage.input_tokens", usageResult.Get("promptTokenCount").Int())
+			promptUsage := util.GeminiCacheUsage(usageResult)
+			template, _ = sjson.Set(template, "usage.input_tokens", promptUsage.UncachedTokens())
+			if promptUsage.CachedTokens > 0 {
+				template, _ = sjson.Set(template, "usage.cache_read_input_tokens", promptUsage.CachedTokens)
+			}
 
 			output = output + template + "\n\n\n"
 		}
@@ -253,6 +258,15 @@ func ConvertGeminiResponseToClaudeNonStream(_ context.Context, _ string, origina
 
 	root := gjson.ParseBytes(rawJSON)
 
+	promptUsage := util.GeminiCacheUsage(root.Get("usageMetadata"))
+	usage := map[string]interface{}{
+		"input_tokens":  promptUsage.UncachedTokens(),
+		"output_tokens": root.Get("usageMetadata.candidatesTokenCount").Int() + root.Get("usageMetadata.thoughtsTokenCount").Int(),
+	}
+	if promptUsage.CachedTokens > 0 {
+		usage["cache_read_input_tokens"] = promptUsage.CachedTokens
+	}
+
 	response := map[string]interface{}{
 		"id":            root.Get("responseId").String(),
 		"type":          "message",
@@ -261,10 +275,7 @@ func ConvertGeminiResponseToClaudeNonStream(_ context.Context, _ string, origina
 		"content":       []interface{}{},
 		"stop_reason":   nil,
 		"stop_sequence": nil,
-		"usage": map[string]interface{}{
-			"input_tokens":  root.Get("usageMetadata.promptTokenCount").Int(),
-			"output_tokens": root.Get("usageMetadata.candidatesTokenCount").Int() + root.Get("usageMetadata.thoughtsTokenCount").Int(),
-		},
+		"usage":         usage,
 	}
 
 	parts := root.Get("candidates.0.content.parts")
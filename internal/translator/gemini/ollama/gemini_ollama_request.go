@@ -0,0 +1,22 @@
+// Package ollama provides translation functionality between Ollama and the Gemini API.
+// Requests and responses are translated through the OpenAI Chat Completions format,
+// reusing the OpenAI translators of the Gemini API.
+package ollama
+
+import (
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/openai/chat-completions"
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/openai/ollama"
+)
+
+// ConvertOllamaRequestToGemini converts an Ollama /api/chat request into a Gemini API request.
+//
+// Parameters:
+//   - modelName: The name of the model to use for the request
+//   - inputRawJSON: The raw JSON request data from the Ollama API
+//   - stream: A boolean indicating if the request is for a streaming response
+//
+// Returns:
+//   - []byte: The transformed request data in the Gemini API format
+func ConvertOllamaRequestToGemini(modelName string, inputRawJSON []byte, stream bool) []byte {
+	return ConvertOpenAIRequestToGemini(modelName, ConvertOllamaRequestToOpenAI(modelName, inputRawJSON, stream), stream)
+}
@@ -0,0 +1,45 @@
+package ollama
+
+import (
+	"context"
+
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/openai/chat-completions"
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/openai/ollama"
+)
+
+// ConvertGeminiResponseToOllama translates one chunk of a Gemini streamGenerateContent
+// stream into Ollama NDJSON lines. The chunk is first turned into an OpenAI chat completion
+// chunk, so text parts become content, thought parts become thinking and functionCall parts
+// become tool calls; finishReason and usageMetadata complete the final line.
+//
+// Parameters:
+//   - ctx: The context for the request, used for cancellation and timeout handling
+//   - modelName: The name of the model being used for the response
+//   - originalRequestRawJSON: The original Ollama request
+//   - requestRawJSON: The Gemini generateContent request sent upstream
+//   - rawJSON: One GenerateContentResponse chunk of the Gemini stream
+//   - param: A pointer to a parameter object for maintaining state between calls
+//
+// Returns:
+//   - []string: A slice of Ollama JSON lines
+func ConvertGeminiResponseToOllama(ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
+	return ConvertResponseToOllamaViaOpenAI(ctx, modelName, originalRequestRawJSON, requestRawJSON, rawJSON, param, ConvertGeminiResponseToOpenAI)
+}
+
+// ConvertGeminiResponseToOllamaNonStream converts a Gemini GenerateContentResponse into an
+// Ollama /api/chat response, taking the message from the first candidate and the token
+// counts from usageMetadata.
+//
+// Parameters:
+//   - ctx: The context for the request, used for cancellation and timeout handling
+//   - modelName: The name of the model being used for the response
+//   - originalRequestRawJSON: The original Ollama request
+//   - requestRawJSON: The Gemini generateContent request sent upstream
+//   - rawJSON: The Gemini GenerateContentResponse
+//   - param: A pointer to a parameter object for the conversion
+//
+// Returns:
+//   - string: An Ollama-compatible JSON response
+func ConvertGeminiResponseToOllamaNonStream(ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) string {
+	return ConvertResponseToOllamaViaOpenAINonStream(ctx, modelName, originalRequestRawJSON, requestRawJSON, rawJSON, param, ConvertGeminiResponseToOpenAINonStream)
+}
@@ -90,6 +90,9 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 		}
 	}
 
+	// response_format -> generationConfig.responseMimeType/responseSchema
+	out = util.ApplyGeminiStructuredOutput(out, "", util.ChatCompletionsStructuredOutput(rawJSON))
+
 	// messages -> systemInstruction + contents
 	messages := gjson.GetBytes(rawJSON, "messages")
 	if messages.IsArray() {
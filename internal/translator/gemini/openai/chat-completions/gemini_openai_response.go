@@ -96,6 +96,9 @@ func ConvertGeminiResponseToOpenAI(_ context.Context, _ string, originalRequestR
 		if thoughtsTokenCount > 0 {
 			template, _ = sjson.Set(template, "usage.completion_tokens_details.reasoning_tokens", thoughtsTokenCount)
 		}
+		if cachedTokenCount := usageResult.Get("cachedContentTokenCount").Int(); cachedTokenCount > 0 {
+			template, _ = sjson.Set(template, "usage.prompt_tokens_details.cached_tokens", cachedTokenCount)
+		}
 	}
 
 	// Process the main content part of the response.
@@ -234,6 +237,9 @@ func ConvertGeminiResponseToOpenAINonStream(_ context.Context, _ string, origina
 		if thoughtsTokenCount > 0 {
 			template, _ = sjson.Set(template, "usage.completion_tokens_details.reasoning_tokens", thoughtsTokenCount)
 		}
+		if cachedTokenCount := usageResult.Get("cachedContentTokenCount").Int(); cachedTokenCount > 0 {
+			template, _ = sjson.Set(template, "usage.prompt_tokens_details.cached_tokens", cachedTokenCount)
+		}
 	}
 
 	// Process the main content part of the response.
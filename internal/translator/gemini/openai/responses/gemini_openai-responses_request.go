@@ -263,5 +263,6 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 		}
 	}
 
-	return []byte(out)
+	// text.format -> generationConfig.responseMimeType/responseSchema
+	return util.ApplyGeminiStructuredOutput([]byte(out), "", util.ResponsesStructuredOutput(rawJSON))
 }
@@ -3,29 +3,34 @@ package translator
 import (
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/claude/gemini"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/claude/gemini-cli"
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/claude/ollama"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/claude/openai/chat-completions"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/claude/openai/responses"
 
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/codex/claude"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/codex/gemini"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/codex/gemini-cli"
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/codex/ollama"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/codex/openai/chat-completions"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/codex/openai/responses"
 
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini-cli/claude"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini-cli/gemini"
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini-cli/ollama"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini-cli/openai/chat-completions"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini-cli/openai/responses"
 
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/claude"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/gemini"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/gemini-cli"
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/ollama"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/openai/chat-completions"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/openai/responses"
 
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/openai/claude"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/openai/gemini"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/openai/gemini-cli"
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/openai/ollama"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/openai/openai/chat-completions"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/openai/openai/responses"
 )
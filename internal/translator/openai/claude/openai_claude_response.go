@@ -9,11 +9,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 var (
@@ -296,10 +296,7 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 					"stop_reason":   mapOpenAIFinishReasonToAnthropic(param.FinishReason),
 					"stop_sequence": nil,
 				},
-				"usage": map[string]interface{}{
-					"input_tokens":  promptTokens.Int(),
-					"output_tokens": completionTokens.Int(),
-				},
+				"usage": claudeUsageFromOpenAI(usage),
 			}
 
 			messageDeltaJSON, _ := json.Marshal(messageDelta)
@@ -408,10 +405,7 @@ func convertOpenAINonStreamingToAnthropic(rawJSON []byte) []string {
 
 	// Set usage information
 	if usage := root.Get("usage"); usage.Exists() {
-		response["usage"] = map[string]interface{}{
-			"input_tokens":  usage.Get("prompt_tokens").Int(),
-			"output_tokens": usage.Get("completion_tokens").Int(),
-		}
+		response["usage"] = claudeUsageFromOpenAI(usage)
 	}
 
 	responseJSON, _ := json.Marshal(response)
@@ -595,11 +589,7 @@ func ConvertOpenAIResponseToClaudeNonStream(_ context.Context, _ string, origina
 	response["content"] = contentBlocks
 
 	if respUsage := root.Get("usage"); respUsage.Exists() {
-		usageJSON := `{}`
-		usageJSON, _ = sjson.Set(usageJSON, "input_tokens", respUsage.Get("prompt_tokens").Int())
-		usageJSON, _ = sjson.Set(usageJSON, "output_tokens", respUsage.Get("completion_tokens").Int())
-		parsedUsage := gjson.Parse(usageJSON).Value().(map[string]interface{})
-		response["usage"] = parsedUsage
+		response["usage"] = claudeUsageFromOpenAI(respUsage)
 	}
 
 	if response["stop_reason"] == nil {
@@ -630,3 +620,21 @@ func ConvertOpenAIResponseToClaudeNonStream(_ context.Context, _ string, origina
 	}
 	return string(responseJSON)
 }
+
+// claudeUsageFromOpenAI converts an OpenAI usage block into a Claude one, reporting the
+// prompt tokens read from the cache as cache_read_input_tokens.
+func claudeUsageFromOpenAI(usage gjson.Result) map[string]interface{} {
+	promptUsage := util.OpenAICacheUsage(usage)
+	out := map[string]interface{}{
+		"input_tokens":  promptUsage.UncachedTokens(),
+		"output_tokens": usage.Get("completion_tokens").Int(),
+	}
+	if promptUsage.CachedTokens > 0 {
+		out["cache_read_input_tokens"] = promptUsage.CachedTokens
+	}
+	return out
+}
+
+func ClaudeTokenCount(ctx context.Context, count int64) string {
+	return fmt.Sprintf(`{"input_tokens":%d}`, count)
+}
@@ -51,3 +51,7 @@ func ConvertOpenAIResponseToGeminiCLINonStream(ctx context.Context, modelName st
 	strJSON, _ = sjson.SetRaw(json, "response", strJSON)
 	return strJSON
 }
+
+func GeminiCLITokenCount(ctx context.Context, count int64) string {
+	return GeminiTokenCount(ctx, count)
+}
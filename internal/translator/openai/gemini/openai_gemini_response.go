@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -88,6 +89,9 @@ func ConvertOpenAIResponseToGemini(_ context.Context, _ string, originalRequestR
 					"candidatesTokenCount": usage.Get("completion_tokens").Int(),
 					"totalTokenCount":      usage.Get("total_tokens").Int(),
 				}
+				if cachedTokens := usage.Get("prompt_tokens_details.cached_tokens").Int(); cachedTokens > 0 {
+					usageObj["cachedContentTokenCount"] = cachedTokens
+				}
 				template, _ = sjson.Set(template, "usageMetadata", usageObj)
 				return []string{template}
 			}
@@ -219,6 +223,9 @@ func ConvertOpenAIResponseToGemini(_ context.Context, _ string, originalRequestR
 					"candidatesTokenCount": usage.Get("completion_tokens").Int(),
 					"totalTokenCount":      usage.Get("total_tokens").Int(),
 				}
+				if cachedTokens := usage.Get("prompt_tokens_details.cached_tokens").Int(); cachedTokens > 0 {
+					usageObj["cachedContentTokenCount"] = cachedTokens
+				}
 				template, _ = sjson.Set(template, "usageMetadata", usageObj)
 				results = append(results, template)
 				return true
@@ -593,8 +600,15 @@ func ConvertOpenAIResponseToGeminiNonStream(_ context.Context, _ string, origina
 			"candidatesTokenCount": usage.Get("completion_tokens").Int(),
 			"totalTokenCount":      usage.Get("total_tokens").Int(),
 		}
+		if cachedTokens := usage.Get("prompt_tokens_details.cached_tokens").Int(); cachedTokens > 0 {
+			usageObj["cachedContentTokenCount"] = cachedTokens
+		}
 		out, _ = sjson.Set(out, "usageMetadata", usageObj)
 	}
 
 	return out
 }
+
+func GeminiTokenCount(ctx context.Context, count int64) string {
+	return fmt.Sprintf(`{"totalTokens":%d,"promptTokensDetails":[{"modality":"TEXT","tokenCount":%d}]}`, count, count)
+}
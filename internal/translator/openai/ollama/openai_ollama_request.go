@@ -0,0 +1,160 @@
+// Package ollama provides request translation functionality for Ollama to OpenAI API compatibility.
+// It converts Ollama /api/chat requests into OpenAI Chat Completions requests, which the other
+// upstream translators then build on.
+package ollama
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// pendingToolCall is an assistant tool call still waiting for its tool result message.
+type pendingToolCall struct {
+	id   string
+	name string
+}
+
+// ConvertOllamaRequestToOpenAI converts an Ollama /api/chat request into an OpenAI Chat
+// Completions request. Ollama tool calls carry no IDs, so assistant tool calls get generated
+// IDs which the following tool messages are matched to by tool name, falling back to order.
+//
+// Parameters:
+//   - modelName: The name of the model to use for the request
+//   - inputRawJSON: The raw JSON request data from the Ollama API
+//   - stream: A boolean indicating if the request is for a streaming response
+//
+// Returns:
+//   - []byte: The transformed request data in OpenAI Chat Completions format
+func ConvertOllamaRequestToOpenAI(modelName string, inputRawJSON []byte, stream bool) []byte {
+	root := gjson.ParseBytes(inputRawJSON)
+	out := `{"model":"","messages":[],"stream":false}`
+	out, _ = sjson.Set(out, "model", modelName)
+	out, _ = sjson.Set(out, "stream", stream)
+	if stream {
+		out, _ = sjson.Set(out, "stream_options.include_usage", true)
+	}
+
+	var pending []pendingToolCall
+	for i, message := range root.Get("messages").Array() {
+		role := message.Get("role").String()
+		msg := `{"role":""}`
+		msg, _ = sjson.Set(msg, "role", role)
+
+		switch role {
+		case "tool":
+			name := message.Get("tool_name").String()
+			if name == "" {
+				name = message.Get("name").String()
+			}
+			id := fmt.Sprintf("call_%d", i)
+			for j, call := range pending {
+				if name == "" || call.name == name {
+					id = call.id
+					pending = append(pending[:j], pending[j+1:]...)
+					break
+				}
+			}
+			msg, _ = sjson.Set(msg, "tool_call_id", id)
+			msg, _ = sjson.Set(msg, "content", message.Get("content").String())
+		case "assistant":
+			// Like OpenAI clients, leave the content null on tool call turns without text.
+			if content := message.Get("content").String(); content != "" || len(message.Get("tool_calls").Array()) == 0 {
+				msg, _ = sjson.Set(msg, "content", content)
+			} else {
+				msg, _ = sjson.SetRaw(msg, "content", "null")
+			}
+			for j, call := range message.Get("tool_calls").Array() {
+				id := fmt.Sprintf("call_%d_%d", i, j)
+				name := call.Get("function.name").String()
+				arguments := call.Get("function.arguments")
+				args := arguments.Raw
+				if arguments.Type == gjson.String {
+					args = arguments.String()
+				} else if !arguments.Exists() {
+					args = "{}"
+				}
+				toolCall := `{"id":"","type":"function","function":{"name":"","arguments":""}}`
+				toolCall, _ = sjson.Set(toolCall, "id", id)
+				toolCall, _ = sjson.Set(toolCall, "function.name", name)
+				toolCall, _ = sjson.Set(toolCall, "function.arguments", args)
+				msg, _ = sjson.SetRaw(msg, "tool_calls.-1", toolCall)
+				pending = append(pending, pendingToolCall{id: id, name: name})
+			}
+		default:
+			images := message.Get("images").Array()
+			if len(images) == 0 {
+				msg, _ = sjson.Set(msg, "content", message.Get("content").String())
+				break
+			}
+			msg, _ = sjson.SetRaw(msg, "content", `[]`)
+			if text := message.Get("content").String(); text != "" {
+				part, _ := sjson.Set(`{"type":"text","text":""}`, "text", text)
+				msg, _ = sjson.SetRaw(msg, "content.-1", part)
+			}
+			for _, image := range images {
+				part, _ := sjson.Set(`{"type":"image_url","image_url":{"url":""}}`, "image_url.url", imageDataURL(image.String()))
+				msg, _ = sjson.SetRaw(msg, "content.-1", part)
+			}
+		}
+		out, _ = sjson.SetRaw(out, "messages.-1", msg)
+	}
+
+	if tools := root.Get("tools"); tools.IsArray() && len(tools.Array()) > 0 {
+		out, _ = sjson.SetRaw(out, "tools", tools.Raw)
+	}
+
+	switch format := root.Get("format"); {
+	case format.Type == gjson.String && format.String() == "json":
+		out, _ = sjson.SetRaw(out, "response_format", `{"type":"json_object"}`)
+	case format.IsObject():
+		responseFormat := `{"type":"json_schema","json_schema":{"name":"response","schema":{}}}`
+		responseFormat, _ = sjson.SetRaw(responseFormat, "json_schema.schema", format.Raw)
+		out, _ = sjson.SetRaw(out, "response_format", responseFormat)
+	}
+
+	options := root.Get("options")
+	for _, key := range []string{"temperature", "top_p", "top_k", "seed", "frequency_penalty", "presence_penalty"} {
+		if v := options.Get(key); v.Exists() {
+			out, _ = sjson.SetRaw(out, key, v.Raw)
+		}
+	}
+	if v := options.Get("num_predict"); v.Exists() && v.Int() > 0 {
+		out, _ = sjson.Set(out, "max_tokens", v.Int())
+	}
+	if v := options.Get("stop"); v.Exists() {
+		out, _ = sjson.SetRaw(out, "stop", v.Raw)
+	}
+
+	switch think := root.Get("think"); think.Type {
+	case gjson.True:
+		out, _ = sjson.Set(out, "reasoning_effort", "medium")
+	case gjson.String:
+		out, _ = sjson.Set(out, "reasoning_effort", think.String())
+	}
+
+	return []byte(out)
+}
+
+// imageDataURL turns an Ollama base64 image into a data URL, sniffing its MIME type.
+// Values that already are URLs are returned unchanged.
+func imageDataURL(image string) string {
+	if strings.HasPrefix(image, "data:") || strings.HasPrefix(image, "http://") || strings.HasPrefix(image, "https://") {
+		return image
+	}
+	mimeType := "image/png"
+	head := image
+	if len(head) > 64 {
+		head = head[:64]
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(head[:len(head)/4*4]); err == nil {
+		if detected := http.DetectContentType(decoded); strings.HasPrefix(detected, "image/") {
+			mimeType = detected
+		}
+	}
+	return "data:" + mimeType + ";base64," + image
+}
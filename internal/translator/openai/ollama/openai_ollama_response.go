@@ -0,0 +1,255 @@
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// convertOpenAIResponseToOllamaParams holds the state of a streaming response conversion.
+type convertOpenAIResponseToOllamaParams struct {
+	Start time.Time
+	// OpenAIRequest is the Ollama request converted to OpenAI, for upstream translators.
+	OpenAIRequest []byte
+	// Upstream is the state of the upstream to OpenAI translator.
+	Upstream any
+	// ToolCalls accumulates streamed tool call fragments by index.
+	ToolCalls     map[int]*toolCallAccumulator
+	ToolCallsSent bool
+	FinishReason  string
+	PromptTokens  int64
+	EvalTokens    int64
+	HasUsage      bool
+	Done          bool
+}
+
+// toolCallAccumulator collects the name and argument fragments of one streamed tool call.
+type toolCallAccumulator struct {
+	Name      string
+	Arguments string
+}
+
+// ConvertOpenAIResponseToOllama translates a single chunk of an OpenAI Chat Completions
+// stream into Ollama NDJSON lines. Content and thinking deltas are forwarded as they arrive;
+// tool calls are emitted once complete, and the final "done" line is emitted when both the
+// finish reason and the usage are known or the stream reports [DONE].
+//
+// Parameters:
+//   - ctx: The context for the request, used for cancellation and timeout handling
+//   - modelName: The name of the model being used for the response
+//   - originalRequestRawJSON: The original Ollama request
+//   - requestRawJSON: The translated upstream request
+//   - rawJSON: The raw JSON chunk from the OpenAI API
+//   - param: A pointer to a parameter object for maintaining state between calls
+//
+// Returns:
+//   - []string: A slice of Ollama JSON lines
+func ConvertOpenAIResponseToOllama(_ context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
+	state := ollamaStreamState(param)
+	if bytes.HasPrefix(rawJSON, []byte("data:")) {
+		rawJSON = bytes.TrimSpace(rawJSON[5:])
+	}
+	if bytes.Equal(rawJSON, []byte("[DONE]")) {
+		return state.finish(originalRequestRawJSON, modelName)
+	}
+	return state.chunk(originalRequestRawJSON, modelName, rawJSON)
+}
+
+// ConvertOpenAIResponseToOllamaNonStream converts a non-streaming OpenAI Chat Completions
+// response into an Ollama /api/chat response.
+//
+// Parameters:
+//   - ctx: The context for the request, used for cancellation and timeout handling
+//   - modelName: The name of the model being used for the response
+//   - originalRequestRawJSON: The original Ollama request
+//   - requestRawJSON: The translated upstream request
+//   - rawJSON: The raw JSON response from the OpenAI API
+//   - param: A pointer to a parameter object for the conversion
+//
+// Returns:
+//   - string: An Ollama-compatible JSON response
+func ConvertOpenAIResponseToOllamaNonStream(_ context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
+	root := gjson.ParseBytes(rawJSON)
+	message := root.Get("choices.0.message")
+
+	out := newOllamaLine(originalRequestRawJSON, modelName)
+	out, _ = sjson.Set(out, "message.content", message.Get("content").String())
+	if thinking := message.Get("reasoning_content").String(); thinking != "" && thinkingEnabled(originalRequestRawJSON) {
+		out, _ = sjson.Set(out, "message.thinking", thinking)
+	}
+	for _, call := range message.Get("tool_calls").Array() {
+		out, _ = sjson.SetRaw(out, "message.tool_calls.-1", ollamaToolCall(call.Get("function.name").String(), call.Get("function.arguments").String()))
+	}
+	out, _ = sjson.Set(out, "done", true)
+	out, _ = sjson.Set(out, "done_reason", ollamaDoneReason(root.Get("choices.0.finish_reason").String()))
+	out, _ = sjson.Set(out, "prompt_eval_count", root.Get("usage.prompt_tokens").Int())
+	out, _ = sjson.Set(out, "eval_count", root.Get("usage.completion_tokens").Int())
+	return out
+}
+
+// ConvertResponseToOllamaViaOpenAI translates a streaming upstream chunk into Ollama lines
+// by first translating it to OpenAI Chat Completions with toOpenAI. Upstream translators
+// see the Ollama request converted to OpenAI as their original request.
+func ConvertResponseToOllamaViaOpenAI(ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any, toOpenAI interfaces.TranslateResponseFunc) []string {
+	state := ollamaStreamState(param)
+	if state.OpenAIRequest == nil {
+		state.OpenAIRequest = ConvertOllamaRequestToOpenAI(modelName, originalRequestRawJSON, true)
+	}
+	var out []string
+	for _, chunk := range toOpenAI(ctx, modelName, state.OpenAIRequest, requestRawJSON, rawJSON, &state.Upstream) {
+		out = append(out, state.chunk(originalRequestRawJSON, modelName, []byte(chunk))...)
+	}
+	if bytes.Equal(bytes.TrimSpace(bytes.TrimPrefix(rawJSON, []byte("data:"))), []byte("[DONE]")) {
+		out = append(out, state.finish(originalRequestRawJSON, modelName)...)
+	}
+	return out
+}
+
+// ConvertResponseToOllamaViaOpenAINonStream converts a non-streaming upstream response into
+// an Ollama response by first translating it to OpenAI Chat Completions with toOpenAI.
+func ConvertResponseToOllamaViaOpenAINonStream(ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any, toOpenAI interfaces.TranslateResponseNonStreamFunc) string {
+	openAIRequest := ConvertOllamaRequestToOpenAI(modelName, originalRequestRawJSON, false)
+	openAIResponse := toOpenAI(ctx, modelName, openAIRequest, requestRawJSON, rawJSON, param)
+	return ConvertOpenAIResponseToOllamaNonStream(ctx, modelName, originalRequestRawJSON, requestRawJSON, []byte(openAIResponse), nil)
+}
+
+func ollamaStreamState(param *any) *convertOpenAIResponseToOllamaParams {
+	if *param == nil {
+		*param = &convertOpenAIResponseToOllamaParams{
+			Start:     time.Now(),
+			ToolCalls: make(map[int]*toolCallAccumulator),
+		}
+	}
+	return (*param).(*convertOpenAIResponseToOllamaParams)
+}
+
+// chunk converts one OpenAI stream chunk into Ollama lines.
+func (p *convertOpenAIResponseToOllamaParams) chunk(originalRequestRawJSON []byte, modelName string, rawJSON []byte) []string {
+	if p.Done || !gjson.ValidBytes(rawJSON) {
+		return nil
+	}
+	root := gjson.ParseBytes(rawJSON)
+	if usage := root.Get("usage"); usage.IsObject() {
+		p.PromptTokens = usage.Get("prompt_tokens").Int()
+		p.EvalTokens = usage.Get("completion_tokens").Int()
+		p.HasUsage = true
+	}
+
+	var out []string
+	choice := root.Get("choices.0")
+	delta := choice.Get("delta")
+	content := delta.Get("content").String()
+	thinking := ""
+	if thinkingEnabled(originalRequestRawJSON) {
+		thinking = delta.Get("reasoning_content").String()
+	}
+	if content != "" || thinking != "" {
+		line := newOllamaLine(originalRequestRawJSON, modelName)
+		line, _ = sjson.Set(line, "message.content", content)
+		if thinking != "" {
+			line, _ = sjson.Set(line, "message.thinking", thinking)
+		}
+		out = append(out, line)
+	}
+
+	for i, call := range delta.Get("tool_calls").Array() {
+		index := i
+		if idx := call.Get("index"); idx.Exists() {
+			index = int(idx.Int())
+		}
+		acc, ok := p.ToolCalls[index]
+		if !ok {
+			acc = &toolCallAccumulator{}
+			p.ToolCalls[index] = acc
+		}
+		if name := call.Get("function.name").String(); name != "" {
+			acc.Name = name
+		}
+		acc.Arguments += call.Get("function.arguments").String()
+	}
+
+	if reason := choice.Get("finish_reason").String(); reason != "" {
+		p.FinishReason = reason
+		out = append(out, p.flushToolCalls(originalRequestRawJSON, modelName)...)
+	}
+	if p.FinishReason != "" && p.HasUsage {
+		out = append(out, p.finish(originalRequestRawJSON, modelName)...)
+	}
+	return out
+}
+
+// flushToolCalls emits the accumulated tool calls as one Ollama line.
+func (p *convertOpenAIResponseToOllamaParams) flushToolCalls(originalRequestRawJSON []byte, modelName string) []string {
+	if p.ToolCallsSent || len(p.ToolCalls) == 0 {
+		return nil
+	}
+	p.ToolCallsSent = true
+	indexes := make([]int, 0, len(p.ToolCalls))
+	for index := range p.ToolCalls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	line := newOllamaLine(originalRequestRawJSON, modelName)
+	for _, index := range indexes {
+		acc := p.ToolCalls[index]
+		line, _ = sjson.SetRaw(line, "message.tool_calls.-1", ollamaToolCall(acc.Name, acc.Arguments))
+	}
+	return []string{line}
+}
+
+// finish emits the final "done" line with the usage counts, once per stream.
+func (p *convertOpenAIResponseToOllamaParams) finish(originalRequestRawJSON []byte, modelName string) []string {
+	if p.Done {
+		return nil
+	}
+	out := p.flushToolCalls(originalRequestRawJSON, modelName)
+	p.Done = true
+	elapsed := time.Since(p.Start).Nanoseconds()
+	line := newOllamaLine(originalRequestRawJSON, modelName)
+	line, _ = sjson.Set(line, "done", true)
+	line, _ = sjson.Set(line, "done_reason", ollamaDoneReason(p.FinishReason))
+	line, _ = sjson.Set(line, "total_duration", elapsed)
+	line, _ = sjson.Set(line, "prompt_eval_count", p.PromptTokens)
+	line, _ = sjson.Set(line, "eval_count", p.EvalTokens)
+	line, _ = sjson.Set(line, "eval_duration", elapsed)
+	return append(out, line)
+}
+
+// newOllamaLine returns an empty, not yet done, Ollama chat response for the requested model.
+func newOllamaLine(originalRequestRawJSON []byte, modelName string) string {
+	if model := gjson.GetBytes(originalRequestRawJSON, "model").String(); model != "" {
+		modelName = model
+	}
+	line := `{"model":"","created_at":"","message":{"role":"assistant","content":""},"done":false}`
+	line, _ = sjson.Set(line, "model", modelName)
+	line, _ = sjson.Set(line, "created_at", time.Now().UTC().Format(time.RFC3339Nano))
+	return line
+}
+
+// ollamaToolCall builds an Ollama tool call, whose arguments are an object rather than a string.
+func ollamaToolCall(name, arguments string) string {
+	call := `{"function":{"name":"","arguments":{}}}`
+	call, _ = sjson.Set(call, "function.name", name)
+	if args := gjson.Parse(arguments); gjson.Valid(arguments) && args.IsObject() {
+		call, _ = sjson.SetRaw(call, "function.arguments", args.Raw)
+	}
+	return call
+}
+
+// ollamaDoneReason maps an OpenAI finish reason to an Ollama done reason.
+func ollamaDoneReason(finishReason string) string {
+	if finishReason == "length" {
+		return "length"
+	}
+	return "stop"
+}
+
+// thinkingEnabled reports whether thinking should be returned; Ollama omits it when think is false.
+func thinkingEnabled(originalRequestRawJSON []byte) bool {
+	return gjson.GetBytes(originalRequestRawJSON, "think").Type != gjson.False
+}
@@ -0,0 +1,98 @@
+package usage
+
+import (
+	"sort"
+	"time"
+)
+
+// CostRow is the usage and cost of one API key, model and credential, optionally within a
+// single period.
+type CostRow struct {
+	Period   string     `json:"period,omitempty"`
+	APIKey   string     `json:"api"`
+	Model    string     `json:"model"`
+	Provider string     `json:"provider,omitempty"`
+	AuthID   string     `json:"auth_id,omitempty"`
+	Requests int64      `json:"requests"`
+	Failures int64      `json:"failures"`
+	Tokens   TokenStats `json:"tokens"`
+	Cost     float64    `json:"cost"`
+}
+
+// CostReport summarises the cost of the usage matching a filter.
+type CostReport struct {
+	Granularity   Granularity `json:"granularity,omitempty"`
+	TotalRequests int64       `json:"total_requests"`
+	TotalTokens   int64       `json:"total_tokens"`
+	TotalCost     float64     `json:"total_cost"`
+	Rows          []CostRow   `json:"rows"`
+	// RetainedFrom is the start of the oldest period the rollups used still cover.
+	RetainedFrom time.Time `json:"retained_from"`
+	// Truncated reports that the filter starts before RetainedFrom, so older usage is missing.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// CostReport aggregates the rollups matching filter into cost rows. When g is empty the
+// whole range is reported as a single period using the finest rollups that cover it;
+// otherwise rows are split into periods of width g. Usage older than the retention of the
+// rollups is gone, also from the persisted events, so such ranges are flagged as Truncated.
+func (s *RequestStatistics) CostReport(filter Filter, g Granularity) CostReport {
+	report := CostReport{Granularity: g, Rows: []CostRow{}}
+	if s == nil {
+		return report
+	}
+	now := time.Now()
+	source := g
+	s.mu.RLock()
+	if source == "" {
+		source = s.coveringGranularity(filter.From, now)
+	}
+	report.RetainedFrom = bucketStart(source, now.Add(-s.retention.of(source)))
+	s.mu.RUnlock()
+	report.Truncated = !filter.From.IsZero() && filter.From.Before(report.RetainedFrom)
+
+	type rowKey struct {
+		period   string
+		apiKey   string
+		model    string
+		provider string
+		authID   string
+	}
+	rows := make(map[rowKey]*CostRow)
+	for _, bucket := range s.Rollups(source, filter) {
+		key := rowKey{apiKey: bucket.APIKey, model: bucket.Model, provider: bucket.Provider, authID: bucket.AuthID}
+		if g != "" {
+			key.period = bucket.Start.Format(time.RFC3339)
+		}
+		row, ok := rows[key]
+		if !ok {
+			row = &CostRow{Period: key.period, APIKey: bucket.APIKey, Model: bucket.Model, Provider: bucket.Provider, AuthID: bucket.AuthID}
+			rows[key] = row
+		}
+		row.Requests += bucket.Requests
+		row.Failures += bucket.Failures
+		addTokens(&row.Tokens, bucket.Tokens)
+		row.Cost += bucket.Cost
+
+		report.TotalRequests += bucket.Requests
+		report.TotalTokens += bucket.Tokens.TotalTokens
+		report.TotalCost += bucket.Cost
+	}
+	for _, row := range rows {
+		report.Rows = append(report.Rows, *row)
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		a, b := report.Rows[i], report.Rows[j]
+		if a.Period != b.Period {
+			return a.Period < b.Period
+		}
+		if a.APIKey != b.APIKey {
+			return a.APIKey < b.APIKey
+		}
+		if a.Model != b.Model {
+			return a.Model < b.Model
+		}
+		return a.AuthID < b.AuthID
+	})
+	return report
+}
@@ -0,0 +1,310 @@
+package usage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DumpVersion is the version of the usage dump format written by this build.
+const DumpVersion = 1
+
+// ErrPersistenceInactive is returned by operations that need the usage store when
+// persistence failed to start.
+var ErrPersistenceInactive = errors.New("usage: persistence is not active")
+
+// Dump is a portable copy of the recorded usage events.
+type Dump struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Events     []Event   `json:"events"`
+}
+
+// dumpHeader is the first line of an NDJSON dump; every following line is an Event.
+type dumpHeader struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+// WriteNDJSON writes d as a header line followed by one event per line.
+func (d Dump) WriteNDJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(dumpHeader{Version: d.Version, ExportedAt: d.ExportedAt}); err != nil {
+		return err
+	}
+	for _, event := range d.Events {
+		if err := enc.Encode(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeDump reads a dump written as JSON or NDJSON. NDJSON input may omit the header line.
+func DecodeDump(r io.Reader) (Dump, error) {
+	reader := bufio.NewReader(r)
+	first, err := peekNonSpace(reader)
+	if err != nil {
+		return Dump{}, err
+	}
+	if first != '{' {
+		return Dump{}, fmt.Errorf("usage: dump must be a JSON object or NDJSON")
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return Dump{}, err
+	}
+	// A single JSON document carries an events array; anything else is read as NDJSON.
+	var dump Dump
+	if errUnmarshal := json.Unmarshal(data, &dump); errUnmarshal != nil || dump.Events == nil {
+		dump = Dump{}
+		if err = decodeNDJSON(bytes.NewReader(data), &dump); err != nil {
+			return Dump{}, err
+		}
+	}
+	return dump, checkDumpVersion(dump.Version)
+}
+
+func decodeNDJSON(r io.Reader, dump *Dump) error {
+	dec := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("usage: dump record %d: %w", line, err)
+		}
+		var probe struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(raw, &probe); err != nil {
+			return fmt.Errorf("usage: dump record %d: %w", line, err)
+		}
+		if probe.ID == "" {
+			if line != 1 {
+				return fmt.Errorf("usage: dump record %d has no id", line)
+			}
+			var header dumpHeader
+			if err := json.Unmarshal(raw, &header); err != nil {
+				return fmt.Errorf("usage: dump header: %w", err)
+			}
+			dump.Version, dump.ExportedAt = header.Version, header.ExportedAt
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(raw, &event); err != nil {
+			return fmt.Errorf("usage: dump record %d: %w", line, err)
+		}
+		dump.Events = append(dump.Events, event)
+	}
+}
+
+func peekNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return 0, fmt.Errorf("usage: dump is empty")
+			}
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b, reader.UnreadByte()
+	}
+}
+
+func checkDumpVersion(version int) error {
+	if version > DumpVersion {
+		return fmt.Errorf("usage: dump version %d is newer than supported version %d", version, DumpVersion)
+	}
+	return nil
+}
+
+// Export returns the persisted events matching filter, after writing pending events.
+func (p *Persister) Export(ctx context.Context, filter Filter) ([]Event, error) {
+	if err := p.Flush(ctx); err != nil {
+		return nil, err
+	}
+	events, _, err := p.store.LoadUsage(ctx, 0)
+	if err != nil {
+		return nil, fmt.Errorf("usage: load events: %w", err)
+	}
+	out := make([]Event, 0, len(events))
+	for _, event := range events {
+		if filter.matches(event) {
+			out = append(out, event)
+		}
+	}
+	return out, nil
+}
+
+// Import merges events into the statistics and the store. Events whose ID is already
+// stored, or repeated within events, are skipped so importing the same dump twice has no
+// effect. Events without an ID get a new one and are always imported.
+func (p *Persister) Import(ctx context.Context, events []Event) (imported, skipped int, err error) {
+	if err = p.Flush(ctx); err != nil {
+		return 0, 0, err
+	}
+	stored, _, err := p.store.LoadUsage(ctx, 0)
+	if err != nil {
+		return 0, 0, fmt.Errorf("usage: load events: %w", err)
+	}
+	seen := make(map[string]struct{}, len(stored)+len(events))
+	for _, event := range stored {
+		seen[event.ID] = struct{}{}
+	}
+	fresh := make([]Event, 0, len(events))
+	for _, event := range events {
+		if event.ID == "" {
+			event.ID = uuid.NewString()
+		}
+		if _, ok := seen[event.ID]; ok {
+			skipped++
+			continue
+		}
+		seen[event.ID] = struct{}{}
+		if event.Model == "" {
+			event.Model = "unknown"
+		}
+		if event.Detail.Timestamp.IsZero() {
+			event.Detail.Timestamp = time.Now()
+		}
+		if event.Detail.Cost == 0 {
+			event.Detail.Cost = Cost(event.Detail.Provider, event.Model, event.Detail.Tokens)
+		}
+		fresh = append(fresh, event)
+	}
+
+	p.stats.mu.Lock()
+	for _, event := range fresh {
+		p.stats.applyLocked(event)
+		p.enqueue(event)
+	}
+	p.stats.mu.Unlock()
+	return len(fresh), skipped, p.Flush(ctx)
+}
+
+// Delete removes the persisted events matching filter and rebuilds the statistics from
+// the remaining ones. Other instances sharing the store rebuild theirs on their next sync.
+func (p *Persister) Delete(ctx context.Context, filter Filter) (int64, error) {
+	p.flushMu.Lock()
+	defer p.flushMu.Unlock()
+	if err := p.flushLocked(ctx); err != nil {
+		return 0, err
+	}
+	removed, err := p.store.DeleteUsage(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("usage: delete events: %w", err)
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, p.syncLocked(ctx)
+}
+
+// rebuildLocked replaces the statistics with the persisted events and the events not
+// written yet, and records generation as seen. The events are loaded and aggregated
+// without holding the statistics lock, so requests keep being recorded meanwhile; the
+// result is swapped in at the end. Callers hold p.flushMu.
+func (p *Persister) rebuildLocked(ctx context.Context, generation int64) error {
+	events, cursor, err := p.store.LoadUsage(ctx, 0)
+	if err != nil {
+		return fmt.Errorf("usage: reload events: %w", err)
+	}
+	fresh := NewRequestStatistics()
+	fresh.retention = p.stats.Retention()
+	for _, event := range events {
+		if event.Model == "" {
+			event.Model = "unknown"
+		}
+		fresh.applyLocked(event)
+	}
+
+	p.stats.mu.Lock()
+	defer p.stats.mu.Unlock()
+	// Requests recorded but not written yet still count. Record queues events while
+	// holding the statistics lock, so none is missed between here and the swap.
+	p.mu.Lock()
+	for _, event := range p.pending {
+		fresh.applyLocked(event)
+	}
+	p.mu.Unlock()
+	p.stats.replaceLocked(fresh)
+	p.cursor = cursor
+	p.generation = generation
+	return nil
+}
+
+// replaceLocked takes over the aggregates of fresh, keeping the retention and persister.
+func (s *RequestStatistics) replaceLocked(fresh *RequestStatistics) {
+	s.totalRequests, s.totalAttempts, s.successCount, s.failureCount = fresh.totalRequests, fresh.totalAttempts, fresh.successCount, fresh.failureCount
+	s.totalTokens, s.totalCost = fresh.totalTokens, fresh.totalCost
+	s.apis, s.auths = fresh.apis, fresh.auths
+	s.requestsByDay, s.requestsByHour = fresh.requestsByDay, fresh.requestsByHour
+	s.tokensByDay, s.tokensByHour = fresh.tokensByDay, fresh.tokensByHour
+	s.rollups = fresh.rollups
+	s.details, s.detailNext = fresh.details, fresh.detailNext
+	s.lastPrune = time.Time{}
+}
+
+// resetLocked clears every aggregate while keeping the retention and persister.
+func (s *RequestStatistics) resetLocked() {
+	s.replaceLocked(NewRequestStatistics())
+}
+
+func activeUsagePersister() *Persister {
+	persistenceMu.Lock()
+	defer persistenceMu.Unlock()
+	return activePersister
+}
+
+// ExportUsage returns a dump of the recorded usage events matching filter.
+func ExportUsage(ctx context.Context, filter Filter) (Dump, error) {
+	persister := activeUsagePersister()
+	if persister == nil {
+		return Dump{}, ErrPersistenceInactive
+	}
+	events, err := persister.Export(ctx, filter)
+	if err != nil {
+		return Dump{}, err
+	}
+	return Dump{Version: DumpVersion, ExportedAt: time.Now().UTC(), Events: events}, nil
+}
+
+// ImportUsage merges the events of dump into the shared statistics.
+func ImportUsage(ctx context.Context, dump Dump) (imported, skipped int, err error) {
+	persister := activeUsagePersister()
+	if persister == nil {
+		return 0, 0, ErrPersistenceInactive
+	}
+	return persister.Import(ctx, dump.Events)
+}
+
+// DeleteUsage removes the usage matching filter from the shared statistics. Without
+// persistence only an unfiltered reset of the in-memory statistics is possible.
+func DeleteUsage(ctx context.Context, filter Filter) (int64, error) {
+	persister := activeUsagePersister()
+	if persister != nil {
+		return persister.Delete(ctx, filter)
+	}
+	if !filter.IsZero() {
+		return 0, ErrPersistenceInactive
+	}
+	stats := defaultRequestStatistics
+	stats.mu.Lock()
+	removed := stats.totalRequests
+	stats.resetLocked()
+	stats.mu.Unlock()
+	return removed, nil
+}
@@ -0,0 +1,187 @@
+package usage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// FileStore persists usage events as JSON lines in an append-only file. The cursor is the
+// byte offset after the last complete line, so a torn write at the end of the file is
+// ignored until it is completed or overwritten.
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+	// generation counts deletions made through this store. The file belongs to one
+	// instance, so it is not persisted.
+	generation int64
+}
+
+// NewFileStore creates a store writing to path, creating parent directories as needed.
+func NewFileStore(path string) (*FileStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("usage: file store path is empty")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("usage: create directory for %s: %w", path, err)
+	}
+	return &FileStore{path: path}, nil
+}
+
+// Path returns the file backing the store.
+func (s *FileStore) Path() string { return s.path }
+
+// AppendUsage implements Store.
+func (s *FileStore) AppendUsage(_ context.Context, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(buf.Bytes()); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// LoadUsage implements Store.
+func (s *FileStore) LoadUsage(_ context.Context, cursor int64) ([]Event, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := os.Open(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, 0, nil
+		}
+		return nil, cursor, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, cursor, err
+	}
+	if cursor > info.Size() {
+		// The file was replaced or truncated; start over.
+		cursor = 0
+	}
+	if _, err = file.Seek(cursor, io.SeekStart); err != nil {
+		return nil, cursor, err
+	}
+
+	start := cursor
+	var events []Event
+	reader := bufio.NewReader(file)
+	for {
+		line, errRead := reader.ReadBytes('\n')
+		if errRead != nil {
+			if errors.Is(errRead, io.EOF) {
+				break
+			}
+			return nil, start, errRead
+		}
+		cursor += int64(len(line))
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var event Event
+		if errUnmarshal := json.Unmarshal(line, &event); errUnmarshal != nil {
+			log.Warnf("usage: skipping malformed line in %s: %v", s.path, errUnmarshal)
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, cursor, nil
+}
+
+// DeleteUsage implements Store by rewriting the file without the matching events.
+func (s *FileStore) DeleteUsage(_ context.Context, filter Filter) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var kept bytes.Buffer
+	var removed int64
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		trimmed := bytes.TrimSpace(line)
+		if len(trimmed) == 0 {
+			continue
+		}
+		var event Event
+		if errUnmarshal := json.Unmarshal(trimmed, &event); errUnmarshal == nil && filter.matches(event) {
+			removed++
+			continue
+		}
+		kept.Write(line)
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err = tmp.Write(kept.Bytes()); err != nil {
+		_ = tmp.Close()
+		return 0, err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return 0, err
+	}
+	if err = tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err = os.Chmod(tmp.Name(), 0o600); err != nil {
+		return 0, err
+	}
+	if err = os.Rename(tmp.Name(), s.path); err != nil {
+		return 0, err
+	}
+	s.generation++
+	return removed, nil
+}
+
+// UsageGeneration implements Store.
+func (s *FileStore) UsageGeneration(_ context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.generation, nil
+}
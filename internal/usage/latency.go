@@ -0,0 +1,161 @@
+package usage
+
+import "time"
+
+// latencyBoundsMs are the upper bounds of the latency histogram buckets in milliseconds.
+var latencyBoundsMs = [...]int64{50, 100, 250, 500, 1000, 2500, 5000, 10000, 20000, 30000, 60000, 120000, 300000}
+
+// latencyHistogram counts latencies in fixed buckets so percentiles can be estimated and
+// merged across aggregates without keeping every sample.
+type latencyHistogram struct {
+	counts [len(latencyBoundsMs) + 1]int64
+	count  int64
+	sumMs  int64
+}
+
+// LatencySummary describes a latency distribution in milliseconds. Percentiles are
+// estimated from histogram buckets.
+type LatencySummary struct {
+	Count int64   `json:"count"`
+	AvgMs float64 `json:"avg_ms"`
+	P50Ms int64   `json:"p50_ms"`
+	P90Ms int64   `json:"p90_ms"`
+	P99Ms int64   `json:"p99_ms"`
+}
+
+func (h *latencyHistogram) observe(ms int64) {
+	if ms < 0 {
+		return
+	}
+	i := 0
+	for i < len(latencyBoundsMs) && ms > latencyBoundsMs[i] {
+		i++
+	}
+	h.counts[i]++
+	h.count++
+	h.sumMs += ms
+}
+
+func (h *latencyHistogram) merge(other latencyHistogram) {
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+	h.count += other.count
+	h.sumMs += other.sumMs
+}
+
+// quantile estimates the q-quantile by interpolating linearly inside the bucket that holds
+// it. Samples above the last bound are reported as that bound.
+func (h *latencyHistogram) quantile(q float64) int64 {
+	if h.count == 0 {
+		return 0
+	}
+	rank := q * float64(h.count)
+	var seen int64
+	for i, c := range h.counts {
+		if c == 0 || float64(seen+c) < rank {
+			seen += c
+			continue
+		}
+		if i == len(latencyBoundsMs) {
+			return latencyBoundsMs[len(latencyBoundsMs)-1]
+		}
+		var lower int64
+		if i > 0 {
+			lower = latencyBoundsMs[i-1]
+		}
+		upper := latencyBoundsMs[i]
+		fraction := (rank - float64(seen)) / float64(c)
+		return lower + int64(fraction*float64(upper-lower))
+	}
+	return latencyBoundsMs[len(latencyBoundsMs)-1]
+}
+
+// summary returns nil when no latency was observed.
+func (h *latencyHistogram) summary() *LatencySummary {
+	if h.count == 0 {
+		return nil
+	}
+	return &LatencySummary{
+		Count: h.count,
+		AvgMs: float64(h.sumMs) / float64(h.count),
+		P50Ms: h.quantile(0.5),
+		P90Ms: h.quantile(0.9),
+		P99Ms: h.quantile(0.99),
+	}
+}
+
+// outcomeStats aggregates failures and latencies of a set of requests.
+type outcomeStats struct {
+	failures    map[string]int64
+	latency     latencyHistogram
+	ttft        latencyHistogram
+	lastSuccess time.Time
+	lastFailure time.Time
+}
+
+// observe folds the outcome of detail into the aggregate. Requests recorded before
+// latencies were tracked only count towards failures.
+func (o *outcomeStats) observe(detail RequestDetail) {
+	if detail.Failed {
+		class := detail.ErrorClass
+		if class == "" {
+			class = "error"
+		}
+		if o.failures == nil {
+			o.failures = make(map[string]int64)
+		}
+		o.failures[class]++
+		if detail.Timestamp.After(o.lastFailure) {
+			o.lastFailure = detail.Timestamp
+		}
+	} else if detail.Timestamp.After(o.lastSuccess) {
+		o.lastSuccess = detail.Timestamp
+	}
+	if detail.LatencyMs > 0 {
+		o.latency.observe(detail.LatencyMs)
+	}
+	if detail.TTFTMs > 0 {
+		o.ttft.observe(detail.TTFTMs)
+	}
+}
+
+func (o *outcomeStats) merge(other outcomeStats) {
+	for class, count := range other.failures {
+		if o.failures == nil {
+			o.failures = make(map[string]int64)
+		}
+		o.failures[class] += count
+	}
+	o.latency.merge(other.latency)
+	o.ttft.merge(other.ttft)
+	if other.lastSuccess.After(o.lastSuccess) {
+		o.lastSuccess = other.lastSuccess
+	}
+	if other.lastFailure.After(o.lastFailure) {
+		o.lastFailure = other.lastFailure
+	}
+}
+
+// errorsCopy returns the failure counts per error class, or nil when nothing failed.
+func (o *outcomeStats) errorsCopy() map[string]int64 {
+	if len(o.failures) == 0 {
+		return nil
+	}
+	out := make(map[string]int64, len(o.failures))
+	for class, count := range o.failures {
+		out[class] = count
+	}
+	return out
+}
+
+func durationMs(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	ms := d.Milliseconds()
+	if ms == 0 {
+		ms = 1
+	}
+	return ms
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 )
 
@@ -60,37 +61,78 @@ type RequestStatistics struct {
 	mu sync.RWMutex
 
 	totalRequests int64
+	totalAttempts int64
 	successCount  int64
 	failureCount  int64
 	totalTokens   int64
+	totalCost     float64
 
-	apis map[string]*apiStats
+	apis  map[string]*apiStats
+	auths map[string]*credentialStats
 
 	requestsByDay  map[string]int64
 	requestsByHour map[int]int64
 	tokensByDay    map[string]int64
 	tokensByHour   map[int]int64
+
+	retention  Retention
+	rollups    map[Granularity]map[rollupKey]*Bucket
+	details    []Event
+	detailNext int
+	lastPrune  time.Time
+
+	persister *Persister
 }
 
 // apiStats holds aggregated metrics for a single API key.
 type apiStats struct {
 	TotalRequests int64
+	FailureCount  int64
 	TotalTokens   int64
+	TotalCost     float64
 	Models        map[string]*modelStats
 }
 
 // modelStats holds aggregated metrics for a specific model within an API.
 type modelStats struct {
 	TotalRequests int64
+	TotalAttempts int64
+	FailureCount  int64
 	TotalTokens   int64
-	Details       []RequestDetail
+	TotalCost     float64
+	outcomes      outcomeStats
 }
 
-// RequestDetail stores the timestamp and token usage for a single request.
+// credentialStats holds aggregated metrics for a single credential. Every attempt the
+// credential served counts, including those superseded by a retry or failover.
+type credentialStats struct {
+	Provider      string
+	TotalRequests int64
+	FailureCount  int64
+	TotalTokens   int64
+	TotalCost     float64
+	outcomes      outcomeStats
+}
+
+// RequestDetail stores the timestamp, token usage and outcome of a single request.
 type RequestDetail struct {
-	Timestamp time.Time  `json:"timestamp"`
-	Source    string     `json:"source"`
-	Tokens    TokenStats `json:"tokens"`
+	Timestamp      time.Time  `json:"timestamp"`
+	Source         string     `json:"source"`
+	RequestedModel string     `json:"requested_model,omitempty"`
+	Provider       string     `json:"provider,omitempty"`
+	AuthID         string     `json:"auth_id,omitempty"`
+	Tokens         TokenStats `json:"tokens"`
+	Cost           float64    `json:"cost"`
+	Failed         bool       `json:"failed"`
+	Status         int        `json:"status,omitempty"`
+	ErrorClass     string     `json:"error_class,omitempty"`
+	LatencyMs      int64      `json:"latency_ms,omitempty"`
+	TTFTMs         int64      `json:"ttft_ms,omitempty"`
+	Stream         bool       `json:"stream,omitempty"`
+	Attempt        int        `json:"attempt,omitempty"`
+	Superseded     bool       `json:"superseded,omitempty"`
+	SourceFormat   string     `json:"source_format,omitempty"`
+	TargetFormat   string     `json:"target_format,omitempty"`
 }
 
 // TokenStats captures the token usage breakdown for a request.
@@ -102,14 +144,19 @@ type TokenStats struct {
 	TotalTokens     int64 `json:"total_tokens"`
 }
 
-// StatisticsSnapshot represents an immutable view of the aggregated metrics.
+// StatisticsSnapshot represents an immutable view of the aggregated metrics. Requests
+// count client requests; attempts also count the retries, failovers and model fallbacks
+// a request went through.
 type StatisticsSnapshot struct {
-	TotalRequests int64 `json:"total_requests"`
-	SuccessCount  int64 `json:"success_count"`
-	FailureCount  int64 `json:"failure_count"`
-	TotalTokens   int64 `json:"total_tokens"`
+	TotalRequests int64   `json:"total_requests"`
+	TotalAttempts int64   `json:"total_attempts"`
+	SuccessCount  int64   `json:"success_count"`
+	FailureCount  int64   `json:"failure_count"`
+	TotalTokens   int64   `json:"total_tokens"`
+	TotalCost     float64 `json:"total_cost"`
 
-	APIs map[string]APISnapshot `json:"apis"`
+	APIs        map[string]APISnapshot        `json:"apis"`
+	Credentials map[string]CredentialSnapshot `json:"credentials"`
 
 	RequestsByDay  map[string]int64 `json:"requests_by_day"`
 	RequestsByHour map[string]int64 `json:"requests_by_hour"`
@@ -120,15 +167,38 @@ type StatisticsSnapshot struct {
 // APISnapshot summarises metrics for a single API key.
 type APISnapshot struct {
 	TotalRequests int64                    `json:"total_requests"`
+	FailureCount  int64                    `json:"failure_count"`
 	TotalTokens   int64                    `json:"total_tokens"`
+	TotalCost     float64                  `json:"total_cost"`
 	Models        map[string]ModelSnapshot `json:"models"`
 }
 
-// ModelSnapshot summarises metrics for a specific model.
+// ModelSnapshot summarises metrics for a specific model. Details only holds the most
+// recent requests kept by the retention limits.
 type ModelSnapshot struct {
-	TotalRequests int64           `json:"total_requests"`
-	TotalTokens   int64           `json:"total_tokens"`
-	Details       []RequestDetail `json:"details"`
+	TotalRequests    int64            `json:"total_requests"`
+	TotalAttempts    int64            `json:"total_attempts"`
+	FailureCount     int64            `json:"failure_count"`
+	TotalTokens      int64            `json:"total_tokens"`
+	TotalCost        float64          `json:"total_cost"`
+	Errors           map[string]int64 `json:"errors,omitempty"`
+	Latency          *LatencySummary  `json:"latency,omitempty"`
+	TimeToFirstToken *LatencySummary  `json:"ttft,omitempty"`
+	Details          []RequestDetail  `json:"details"`
+}
+
+// CredentialSnapshot summarises metrics for a single credential (auth ID).
+type CredentialSnapshot struct {
+	Provider         string           `json:"provider"`
+	TotalRequests    int64            `json:"total_requests"`
+	FailureCount     int64            `json:"failure_count"`
+	TotalTokens      int64            `json:"total_tokens"`
+	TotalCost        float64          `json:"total_cost"`
+	Errors           map[string]int64 `json:"errors,omitempty"`
+	Latency          *LatencySummary  `json:"latency,omitempty"`
+	TimeToFirstToken *LatencySummary  `json:"ttft,omitempty"`
+	LastSuccessAt    time.Time        `json:"last_success_at"`
+	LastFailureAt    time.Time        `json:"last_failure_at"`
 }
 
 var defaultRequestStatistics = NewRequestStatistics()
@@ -140,10 +210,17 @@ func GetRequestStatistics() *RequestStatistics { return defaultRequestStatistics
 func NewRequestStatistics() *RequestStatistics {
 	return &RequestStatistics{
 		apis:           make(map[string]*apiStats),
+		auths:          make(map[string]*credentialStats),
 		requestsByDay:  make(map[string]int64),
 		requestsByHour: make(map[int]int64),
 		tokensByDay:    make(map[string]int64),
 		tokensByHour:   make(map[int]int64),
+		retention:      DefaultRetention(),
+		rollups: map[Granularity]map[rollupKey]*Bucket{
+			GranularityMinute: make(map[rollupKey]*Bucket),
+			GranularityHour:   make(map[rollupKey]*Bucket),
+			GranularityDay:    make(map[rollupKey]*Bucket),
+		},
 	}
 }
 
@@ -160,58 +237,135 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 		timestamp = time.Now()
 	}
 	detail := normaliseDetail(record.Detail)
-	totalTokens := detail.TotalTokens
 	statsKey := record.APIKey
 	if statsKey == "" {
 		statsKey = resolveAPIIdentifier(ctx, record)
 	}
-	success := resolveSuccess(ctx)
+	// Records published by the auth manager carry the attempt outcome; older producers
+	// leave it to the status written to the client.
+	success := !record.Failed
+	if record.Attempt == 0 && !record.Failed {
+		success = resolveSuccess(ctx)
+	}
 	modelName := record.Model
 	if modelName == "" {
 		modelName = "unknown"
 	}
-	dayKey := timestamp.Format("2006-01-02")
-	hourKey := timestamp.Hour()
+	event := Event{
+		ID:     uuid.NewString(),
+		APIKey: statsKey,
+		Model:  modelName,
+		Detail: RequestDetail{
+			Timestamp:      timestamp,
+			Source:         record.Source,
+			RequestedModel: record.RequestedModel,
+			Provider:       record.Provider,
+			AuthID:         record.AuthID,
+			Tokens:         detail,
+			Cost:           Cost(record.Provider, modelName, detail),
+			Failed:         !success,
+			Status:         record.Status,
+			ErrorClass:     record.ErrorClass,
+			LatencyMs:      durationMs(record.Latency),
+			TTFTMs:         durationMs(record.TimeToFirstToken),
+			Stream:         record.Stream,
+			Attempt:        record.Attempt,
+			Superseded:     record.Superseded,
+			SourceFormat:   record.SourceFormat,
+			TargetFormat:   record.TargetFormat,
+		},
+	}
 
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.applyLocked(event)
+	s.persister.enqueue(event)
+	s.mu.Unlock()
+}
 
-	s.totalRequests++
-	if success {
-		s.successCount++
-	} else {
-		s.failureCount++
+// applyLocked folds a usage event into the aggregates. Callers must hold s.mu.
+func (s *RequestStatistics) applyLocked(event Event) {
+	timestamp := event.Detail.Timestamp
+	totalTokens := event.Detail.Tokens.TotalTokens
+	dayKey := timestamp.Format("2006-01-02")
+	hourKey := timestamp.Hour()
+
+	// Superseded attempts were followed by another attempt of the same client request;
+	// they count as attempts and consume tokens, but only the last one is a request.
+	final := !event.Detail.Superseded
+	s.totalAttempts++
+	if final {
+		s.totalRequests++
+		if event.Detail.Failed {
+			s.failureCount++
+		} else {
+			s.successCount++
+		}
 	}
 	s.totalTokens += totalTokens
+	s.totalCost += event.Detail.Cost
 
-	stats, ok := s.apis[statsKey]
+	stats, ok := s.apis[event.APIKey]
 	if !ok {
 		stats = &apiStats{Models: make(map[string]*modelStats)}
-		s.apis[statsKey] = stats
+		s.apis[event.APIKey] = stats
 	}
-	s.updateAPIStats(stats, modelName, RequestDetail{
-		Timestamp: timestamp,
-		Source:    record.Source,
-		Tokens:    detail,
-	})
+	s.updateAPIStats(stats, event.Model, event.Detail)
+	s.updateCredentialStats(event.Detail)
 
-	s.requestsByDay[dayKey]++
-	s.requestsByHour[hourKey]++
+	if final {
+		s.requestsByDay[dayKey]++
+		s.requestsByHour[hourKey]++
+	}
 	s.tokensByDay[dayKey] += totalTokens
 	s.tokensByHour[hourKey] += totalTokens
+
+	now := time.Now()
+	s.addRollupsLocked(event, now)
+	s.addDetailLocked(event)
+	s.pruneLocked(now)
 }
 
 func (s *RequestStatistics) updateAPIStats(stats *apiStats, model string, detail RequestDetail) {
-	stats.TotalRequests++
 	stats.TotalTokens += detail.Tokens.TotalTokens
+	stats.TotalCost += detail.Cost
 	modelStatsValue, ok := stats.Models[model]
 	if !ok {
 		modelStatsValue = &modelStats{}
 		stats.Models[model] = modelStatsValue
 	}
-	modelStatsValue.TotalRequests++
+	modelStatsValue.TotalAttempts++
 	modelStatsValue.TotalTokens += detail.Tokens.TotalTokens
-	modelStatsValue.Details = append(modelStatsValue.Details, detail)
+	modelStatsValue.TotalCost += detail.Cost
+	if !detail.Superseded {
+		stats.TotalRequests++
+		modelStatsValue.TotalRequests++
+		if detail.Failed {
+			stats.FailureCount++
+			modelStatsValue.FailureCount++
+		}
+	}
+	modelStatsValue.outcomes.observe(detail)
+}
+
+func (s *RequestStatistics) updateCredentialStats(detail RequestDetail) {
+	if detail.AuthID == "" {
+		return
+	}
+	stats, ok := s.auths[detail.AuthID]
+	if !ok {
+		stats = &credentialStats{}
+		s.auths[detail.AuthID] = stats
+	}
+	if detail.Provider != "" {
+		stats.Provider = detail.Provider
+	}
+	stats.TotalRequests++
+	if detail.Failed {
+		stats.FailureCount++
+	}
+	stats.TotalTokens += detail.Tokens.TotalTokens
+	stats.TotalCost += detail.Cost
+	stats.outcomes.observe(detail)
 }
 
 // Snapshot returns a copy of the aggregated metrics for external consumption.
@@ -225,28 +379,53 @@ func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
 	defer s.mu.RUnlock()
 
 	result.TotalRequests = s.totalRequests
+	result.TotalAttempts = s.totalAttempts
 	result.SuccessCount = s.successCount
 	result.FailureCount = s.failureCount
 	result.TotalTokens = s.totalTokens
+	result.TotalCost = s.totalCost
 
 	result.APIs = make(map[string]APISnapshot, len(s.apis))
 	for apiName, stats := range s.apis {
 		apiSnapshot := APISnapshot{
 			TotalRequests: stats.TotalRequests,
+			FailureCount:  stats.FailureCount,
 			TotalTokens:   stats.TotalTokens,
+			TotalCost:     stats.TotalCost,
 			Models:        make(map[string]ModelSnapshot, len(stats.Models)),
 		}
 		for modelName, modelStatsValue := range stats.Models {
-			requestDetails := make([]RequestDetail, len(modelStatsValue.Details))
-			copy(requestDetails, modelStatsValue.Details)
 			apiSnapshot.Models[modelName] = ModelSnapshot{
-				TotalRequests: modelStatsValue.TotalRequests,
-				TotalTokens:   modelStatsValue.TotalTokens,
-				Details:       requestDetails,
+				TotalRequests:    modelStatsValue.TotalRequests,
+				TotalAttempts:    modelStatsValue.TotalAttempts,
+				FailureCount:     modelStatsValue.FailureCount,
+				TotalTokens:      modelStatsValue.TotalTokens,
+				TotalCost:        modelStatsValue.TotalCost,
+				Errors:           modelStatsValue.outcomes.errorsCopy(),
+				Latency:          modelStatsValue.outcomes.latency.summary(),
+				TimeToFirstToken: modelStatsValue.outcomes.ttft.summary(),
+				Details:          []RequestDetail{},
 			}
 		}
 		result.APIs[apiName] = apiSnapshot
 	}
+	attachDetails(result.APIs, s.recentDetailsLocked(), Filter{})
+
+	result.Credentials = make(map[string]CredentialSnapshot, len(s.auths))
+	for authID, stats := range s.auths {
+		result.Credentials[authID] = CredentialSnapshot{
+			Provider:         stats.Provider,
+			TotalRequests:    stats.TotalRequests,
+			FailureCount:     stats.FailureCount,
+			TotalTokens:      stats.TotalTokens,
+			TotalCost:        stats.TotalCost,
+			Errors:           stats.outcomes.errorsCopy(),
+			Latency:          stats.outcomes.latency.summary(),
+			TimeToFirstToken: stats.outcomes.ttft.summary(),
+			LastSuccessAt:    stats.outcomes.lastSuccess,
+			LastFailureAt:    stats.outcomes.lastFailure,
+		}
+	}
 
 	result.RequestsByDay = make(map[string]int64, len(s.requestsByDay))
 	for k, v := range s.requestsByDay {
@@ -0,0 +1,305 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultFlushInterval is how often pending usage events are persisted and events written
+// by other instances sharing the store are picked up.
+const DefaultFlushInterval = 30 * time.Second
+
+// pruneInterval is how often persisted events older than the day rollup retention are removed.
+const pruneInterval = time.Hour
+
+// Event is a single request as persisted by a Store. Aggregates are rebuilt from events.
+type Event struct {
+	// ID uniquely identifies the event so repeated writes stay idempotent.
+	ID string `json:"id"`
+	// Instance identifies the process that recorded the event.
+	Instance string `json:"instance"`
+	// APIKey is the statistics bucket the request was attributed to.
+	APIKey string `json:"api"`
+	// Model is the model that served the request.
+	Model string `json:"model"`
+	// Detail holds the timestamp and token usage of the request.
+	Detail RequestDetail `json:"detail"`
+}
+
+// Store persists usage events. Implementations must tolerate concurrent writers, and
+// LoadUsage must return events in commit order so cursors never skip an event.
+type Store interface {
+	// AppendUsage durably records events. Events whose ID already exists are ignored.
+	AppendUsage(ctx context.Context, events []Event) error
+	// LoadUsage returns the events committed after cursor together with the cursor that
+	// follows the last returned event. A zero cursor loads everything.
+	LoadUsage(ctx context.Context, cursor int64) ([]Event, int64, error)
+	// DeleteUsage removes the events matching filter and returns how many were removed.
+	// Cursors handed out earlier may no longer be valid afterwards. Removing any event
+	// advances the generation.
+	DeleteUsage(ctx context.Context, filter Filter) (int64, error)
+	// UsageGeneration returns how often events were removed from the store, letting
+	// instances that share it notice deletions made by the others.
+	UsageGeneration(ctx context.Context) (int64, error)
+}
+
+// Persister keeps a RequestStatistics instance in sync with a Store: it loads persisted
+// events at startup, writes new events in batches and imports events recorded by other
+// instances that share the same store.
+type Persister struct {
+	store    Store
+	stats    *RequestStatistics
+	instance string
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending []Event
+
+	flushMu    sync.Mutex
+	cursor     int64
+	generation int64
+
+	lastPrune time.Time
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewPersister binds stats to store. A non-positive interval uses DefaultFlushInterval.
+func NewPersister(store Store, stats *RequestStatistics, interval time.Duration) *Persister {
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+	return &Persister{
+		store:    store,
+		stats:    stats,
+		instance: uuid.NewString(),
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start attaches the persister so new records are queued for writing, loads the persisted
+// events into the statistics and starts the periodic flush loop.
+func (p *Persister) Start(ctx context.Context) error {
+	if p == nil || p.store == nil || p.stats == nil {
+		return nil
+	}
+	p.stats.mu.Lock()
+	p.stats.persister = p
+	p.stats.mu.Unlock()
+	// Drop expired events before loading so startup only replays the retained history.
+	if _, err := p.store.DeleteUsage(ctx, Filter{To: p.pruneCutoff(time.Now())}); err != nil {
+		log.Warnf("usage: prune expired events: %v", err)
+	}
+	p.lastPrune = time.Now()
+	generation, err := p.store.UsageGeneration(ctx)
+	if err == nil {
+		p.generation = generation
+		err = p.sync(ctx)
+	}
+	if err != nil {
+		p.stats.mu.Lock()
+		p.stats.persister = nil
+		p.stats.mu.Unlock()
+		return err
+	}
+	go p.run()
+	return nil
+}
+
+// Stop detaches the persister, stops the flush loop and writes all pending events.
+func (p *Persister) Stop(ctx context.Context) error {
+	if p == nil || p.store == nil || p.stats == nil {
+		return nil
+	}
+	var err error
+	p.stopOnce.Do(func() {
+		p.stats.mu.Lock()
+		if p.stats.persister == p {
+			p.stats.persister = nil
+		}
+		p.stats.mu.Unlock()
+		close(p.stop)
+		<-p.done
+		err = p.Flush(ctx)
+	})
+	return err
+}
+
+// Flush writes pending events and imports events recorded by other instances.
+func (p *Persister) Flush(ctx context.Context) error {
+	if p == nil || p.store == nil {
+		return nil
+	}
+	p.flushMu.Lock()
+	defer p.flushMu.Unlock()
+	return p.flushLocked(ctx)
+}
+
+// flushLocked implements Flush. Callers hold p.flushMu, so no event is in flight between
+// the pending queue and the store while they rebuild the statistics.
+func (p *Persister) flushLocked(ctx context.Context) error {
+	p.mu.Lock()
+	batch := p.pending
+	p.pending = nil
+	p.mu.Unlock()
+
+	if len(batch) > 0 {
+		if err := p.store.AppendUsage(ctx, batch); err != nil {
+			// Keep the batch for the next attempt; event IDs make the retry idempotent.
+			p.mu.Lock()
+			p.pending = append(batch, p.pending...)
+			p.mu.Unlock()
+			return fmt.Errorf("usage: persist %d events: %w", len(batch), err)
+		}
+	}
+	return p.syncLocked(ctx)
+}
+
+// sync imports events committed after the current cursor, skipping this instance's own
+// events which are already part of the aggregates. When another instance deleted events
+// since the last sync, the statistics are rebuilt from the remaining events instead.
+func (p *Persister) sync(ctx context.Context) error {
+	p.flushMu.Lock()
+	defer p.flushMu.Unlock()
+	return p.syncLocked(ctx)
+}
+
+func (p *Persister) syncLocked(ctx context.Context) error {
+	generation, err := p.store.UsageGeneration(ctx)
+	if err != nil {
+		return fmt.Errorf("usage: load generation: %w", err)
+	}
+	if generation != p.generation {
+		return p.rebuildLocked(ctx, generation)
+	}
+	events, cursor, err := p.store.LoadUsage(ctx, p.cursor)
+	if err != nil {
+		return fmt.Errorf("usage: load events: %w", err)
+	}
+	p.cursor = cursor
+	if len(events) == 0 {
+		return nil
+	}
+	p.stats.mu.Lock()
+	defer p.stats.mu.Unlock()
+	for _, event := range events {
+		if event.Instance == p.instance {
+			continue
+		}
+		if event.Model == "" {
+			event.Model = "unknown"
+		}
+		if event.Detail.Cost == 0 {
+			// Events written before cost accounting existed are priced on import.
+			event.Detail.Cost = Cost(event.Detail.Provider, event.Model, event.Detail.Tokens)
+		}
+		p.stats.applyLocked(event)
+	}
+	return nil
+}
+
+// Prune removes persisted events older than the day rollup retention. Such events no
+// longer contribute to any rollup, so keeping them would only grow the store and the
+// history replayed at startup, import and export. All-time totals shrink accordingly.
+func (p *Persister) Prune(ctx context.Context) (int64, error) {
+	if p == nil || p.store == nil {
+		return 0, nil
+	}
+	return p.Delete(ctx, Filter{To: p.pruneCutoff(time.Now())})
+}
+
+// pruneCutoff returns the start of the oldest local day still covered by the day rollups.
+func (p *Persister) pruneCutoff(now time.Time) time.Time {
+	return bucketStart(GranularityDay, now.Add(-p.stats.Retention().Day))
+}
+
+func (p *Persister) enqueue(event Event) {
+	if p == nil {
+		return
+	}
+	event.Instance = p.instance
+	p.mu.Lock()
+	p.pending = append(p.pending, event)
+	p.mu.Unlock()
+}
+
+func (p *Persister) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), p.interval)
+			if err := p.Flush(ctx); err != nil {
+				log.Warnf("%v", err)
+			}
+			if time.Since(p.lastPrune) >= pruneInterval {
+				p.lastPrune = time.Now()
+				if _, err := p.Prune(ctx); err != nil {
+					log.Warnf("%v", err)
+				}
+			}
+			cancel()
+		}
+	}
+}
+
+var (
+	persistenceMu    sync.Mutex
+	persistenceStore Store
+	activePersister  *Persister
+)
+
+// SetPersistenceStore selects the store used by StartPersistence instead of the default
+// append-only file. It must be called before the service starts.
+func SetPersistenceStore(store Store) {
+	persistenceMu.Lock()
+	persistenceStore = store
+	persistenceMu.Unlock()
+}
+
+// StartPersistence loads persisted usage into the shared statistics and keeps them in
+// sync. The store registered through SetPersistenceStore wins; otherwise events are kept
+// in an append-only file at filePath.
+func StartPersistence(ctx context.Context, filePath string) error {
+	persistenceMu.Lock()
+	defer persistenceMu.Unlock()
+	if activePersister != nil {
+		return nil
+	}
+	store := persistenceStore
+	if store == nil {
+		fileStore, err := NewFileStore(filePath)
+		if err != nil {
+			return err
+		}
+		store = fileStore
+	}
+	persister := NewPersister(store, defaultRequestStatistics, DefaultFlushInterval)
+	if err := persister.Start(ctx); err != nil {
+		return err
+	}
+	activePersister = persister
+	return nil
+}
+
+// StopPersistence flushes pending usage events and stops the background sync.
+func StopPersistence(ctx context.Context) error {
+	persistenceMu.Lock()
+	persister := activePersister
+	activePersister = nil
+	persistenceMu.Unlock()
+	return persister.Stop(ctx)
+}
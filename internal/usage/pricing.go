@@ -0,0 +1,123 @@
+package usage
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+)
+
+// priceTable resolves model names to token prices.
+type priceTable struct {
+	exact    map[string]registry.ModelPrice
+	prefixes []pricePrefix
+}
+
+type pricePrefix struct {
+	prefix string
+	price  registry.ModelPrice
+}
+
+var (
+	pricingMu     sync.RWMutex
+	activePricing = newPriceTable(config.PricingConfig{})
+)
+
+// SetPricing replaces the price table used to compute the cost of new usage records.
+func SetPricing(cfg config.PricingConfig) {
+	table := newPriceTable(cfg)
+	pricingMu.Lock()
+	activePricing = table
+	pricingMu.Unlock()
+}
+
+func newPriceTable(cfg config.PricingConfig) *priceTable {
+	table := &priceTable{exact: make(map[string]registry.ModelPrice)}
+	if !cfg.DisableDefaults {
+		for model, price := range registry.DefaultModelPrices() {
+			table.exact[model] = price
+		}
+	}
+	for model, configured := range cfg.Models {
+		model = strings.TrimSpace(model)
+		if model == "" {
+			continue
+		}
+		price := registry.ModelPrice{Input: configured.Input, Output: configured.Output, CachedInput: configured.Input, Reasoning: configured.Output}
+		if configured.CachedInput != nil {
+			price.CachedInput = *configured.CachedInput
+		}
+		if configured.Reasoning != nil {
+			price.Reasoning = *configured.Reasoning
+		}
+		if prefix, ok := strings.CutSuffix(model, "*"); ok {
+			table.prefixes = append(table.prefixes, pricePrefix{prefix: prefix, price: price})
+			continue
+		}
+		table.exact[model] = price
+	}
+	// Longest prefixes first so the most specific pattern wins.
+	sort.Slice(table.prefixes, func(i, j int) bool { return len(table.prefixes[i].prefix) > len(table.prefixes[j].prefix) })
+	return table
+}
+
+func (t *priceTable) lookup(model string) (registry.ModelPrice, bool) {
+	if price, ok := t.exact[model]; ok {
+		return price, true
+	}
+	for _, entry := range t.prefixes {
+		if strings.HasPrefix(model, entry.prefix) {
+			return entry.price, true
+		}
+	}
+	return registry.ModelPrice{}, false
+}
+
+// ModelPrice returns the price configured for model.
+func ModelPrice(model string) (registry.ModelPrice, bool) {
+	pricingMu.RLock()
+	table := activePricing
+	pricingMu.RUnlock()
+	return table.lookup(model)
+}
+
+// Cost returns the USD cost of tokens for model served by provider, or zero when the
+// model has no price. Providers report cached and reasoning tokens differently: Claude
+// excludes cache reads from input tokens, Gemini reports thoughts apart from output
+// tokens, and OpenAI style APIs include both in the input and output counts.
+func Cost(provider, model string, tokens TokenStats) float64 {
+	price, ok := ModelPrice(model)
+	if !ok {
+		return 0
+	}
+	input := tokens.InputTokens
+	if !strings.EqualFold(provider, "claude") {
+		input -= tokens.CachedTokens
+	}
+	output := tokens.OutputTokens
+	if !reportsThoughtsSeparately(provider) {
+		output -= tokens.ReasoningTokens
+	}
+	if input < 0 {
+		input = 0
+	}
+	if output < 0 {
+		output = 0
+	}
+	total := float64(input)*price.Input +
+		float64(tokens.CachedTokens)*price.CachedInput +
+		float64(output)*price.Output +
+		float64(tokens.ReasoningTokens)*price.Reasoning
+	return total / 1_000_000
+}
+
+func reportsThoughtsSeparately(provider string) bool {
+	switch strings.ToLower(provider) {
+	case "gemini", "gemini-cli", "vertex", "aistudio":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,496 @@
+package usage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// Granularity is the width of a usage rollup bucket.
+type Granularity string
+
+const (
+	// GranularityMinute buckets usage per minute.
+	GranularityMinute Granularity = "minute"
+	// GranularityHour buckets usage per hour.
+	GranularityHour Granularity = "hour"
+	// GranularityDay buckets usage per local calendar day.
+	GranularityDay Granularity = "day"
+)
+
+var granularities = []Granularity{GranularityMinute, GranularityHour, GranularityDay}
+
+// ParseGranularity converts a query value into a Granularity.
+func ParseGranularity(value string) (Granularity, bool) {
+	for _, g := range granularities {
+		if string(g) == value {
+			return g, true
+		}
+	}
+	return "", false
+}
+
+// Retention bounds the usage history kept in memory.
+type Retention struct {
+	// Minute is how long per-minute buckets are kept.
+	Minute time.Duration
+	// Hour is how long per-hour buckets are kept.
+	Hour time.Duration
+	// Day is how long per-day buckets and daily totals are kept.
+	Day time.Duration
+	// Details is the number of most recent per-request details kept.
+	Details int
+}
+
+// DefaultRetention keeps a day of minute buckets, a month of hour buckets, a bit more than
+// a year of day buckets and the last 1000 request details.
+func DefaultRetention() Retention {
+	return Retention{
+		Minute:  24 * time.Hour,
+		Hour:    31 * 24 * time.Hour,
+		Day:     400 * 24 * time.Hour,
+		Details: 1000,
+	}
+}
+
+// RetentionFromConfig converts the usage-retention settings; unset values use the defaults.
+func RetentionFromConfig(cfg config.UsageRetention) Retention {
+	return Retention{
+		Minute:  time.Duration(cfg.MinuteRollupHours) * time.Hour,
+		Hour:    time.Duration(cfg.HourRollupDays) * 24 * time.Hour,
+		Day:     time.Duration(cfg.DayRollupDays) * 24 * time.Hour,
+		Details: cfg.MaxDetails,
+	}.withDefaults()
+}
+
+// withDefaults replaces unset limits with the defaults.
+func (r Retention) withDefaults() Retention {
+	def := DefaultRetention()
+	if r.Minute <= 0 {
+		r.Minute = def.Minute
+	}
+	if r.Hour <= 0 {
+		r.Hour = def.Hour
+	}
+	if r.Day <= 0 {
+		r.Day = def.Day
+	}
+	if r.Details <= 0 {
+		r.Details = def.Details
+	}
+	return r
+}
+
+func (r Retention) of(g Granularity) time.Duration {
+	switch g {
+	case GranularityMinute:
+		return r.Minute
+	case GranularityHour:
+		return r.Hour
+	default:
+		return r.Day
+	}
+}
+
+// Bucket aggregates the requests of one API key, model and credential within a time window.
+// Requests and Failures count client requests by their last attempt; Attempts and
+// FailedAttempts also count superseded attempts. Errors and the latency summaries are
+// filled in when buckets are returned by Rollups.
+type Bucket struct {
+	Start            time.Time        `json:"start"`
+	APIKey           string           `json:"api"`
+	Model            string           `json:"model"`
+	Provider         string           `json:"provider,omitempty"`
+	AuthID           string           `json:"auth_id,omitempty"`
+	Requests         int64            `json:"requests"`
+	Failures         int64            `json:"failures"`
+	Attempts         int64            `json:"attempts"`
+	FailedAttempts   int64            `json:"failed_attempts"`
+	Tokens           TokenStats       `json:"tokens"`
+	Cost             float64          `json:"cost"`
+	Errors           map[string]int64 `json:"errors,omitempty"`
+	Latency          *LatencySummary  `json:"latency,omitempty"`
+	TimeToFirstToken *LatencySummary  `json:"ttft,omitempty"`
+
+	outcomes outcomeStats
+}
+
+// Filter narrows a usage query. Empty fields match everything; To is exclusive.
+type Filter struct {
+	From     time.Time
+	To       time.Time
+	APIKey   string
+	Model    string
+	Provider string
+	AuthID   string
+}
+
+// IsZero reports whether the filter matches everything.
+func (f Filter) IsZero() bool {
+	return f == Filter{}
+}
+
+func (f Filter) matchesDimensions(apiKey, model, provider, authID string) bool {
+	return (f.APIKey == "" || f.APIKey == apiKey) &&
+		(f.Model == "" || f.Model == model) &&
+		(f.Provider == "" || f.Provider == provider) &&
+		(f.AuthID == "" || f.AuthID == authID)
+}
+
+func (f Filter) matchesTime(ts time.Time) bool {
+	return (f.From.IsZero() || !ts.Before(f.From)) && (f.To.IsZero() || ts.Before(f.To))
+}
+
+// matches reports whether event falls inside the filter.
+func (f Filter) matches(event Event) bool {
+	return f.matchesDimensions(event.APIKey, event.Model, event.Detail.Provider, event.Detail.AuthID) &&
+		f.matchesTime(event.Detail.Timestamp)
+}
+
+// overlaps reports whether the window [start, end) intersects the filter's time range.
+func (f Filter) overlaps(start, end time.Time) bool {
+	return (f.From.IsZero() || end.After(f.From)) && (f.To.IsZero() || start.Before(f.To))
+}
+
+type rollupKey struct {
+	start    int64
+	apiKey   string
+	model    string
+	provider string
+	authID   string
+}
+
+// bucketStart returns the start of the bucket of width g containing ts.
+func bucketStart(g Granularity, ts time.Time) time.Time {
+	switch g {
+	case GranularityMinute:
+		return ts.Truncate(time.Minute)
+	case GranularityHour:
+		return ts.Truncate(time.Hour)
+	default:
+		y, m, d := ts.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, ts.Location())
+	}
+}
+
+// bucketEnd returns the end of the bucket of width g starting at start.
+func bucketEnd(g Granularity, start time.Time) time.Time {
+	switch g {
+	case GranularityMinute:
+		return start.Add(time.Minute)
+	case GranularityHour:
+		return start.Add(time.Hour)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// addRollupsLocked adds event to the bucket of every granularity still within retention.
+func (s *RequestStatistics) addRollupsLocked(event Event, now time.Time) {
+	ts := event.Detail.Timestamp
+	for _, g := range granularities {
+		start := bucketStart(g, ts)
+		if bucketEnd(g, start).Before(now.Add(-s.retention.of(g))) {
+			continue
+		}
+		key := rollupKey{start: start.UnixNano(), apiKey: event.APIKey, model: event.Model, provider: event.Detail.Provider, authID: event.Detail.AuthID}
+		buckets := s.rollups[g]
+		bucket, ok := buckets[key]
+		if !ok {
+			bucket = &Bucket{Start: start, APIKey: event.APIKey, Model: event.Model, Provider: event.Detail.Provider, AuthID: event.Detail.AuthID}
+			buckets[key] = bucket
+		}
+		bucket.Attempts++
+		if event.Detail.Failed {
+			bucket.FailedAttempts++
+		}
+		if !event.Detail.Superseded {
+			bucket.Requests++
+			if event.Detail.Failed {
+				bucket.Failures++
+			}
+		}
+		addTokens(&bucket.Tokens, event.Detail.Tokens)
+		bucket.Cost += event.Detail.Cost
+		bucket.outcomes.observe(event.Detail)
+	}
+}
+
+// addDetailLocked stores event in the ring buffer of recent request details.
+func (s *RequestStatistics) addDetailLocked(event Event) {
+	if len(s.details) < s.retention.Details {
+		s.details = append(s.details, event)
+		return
+	}
+	s.details[s.detailNext] = event
+	s.detailNext = (s.detailNext + 1) % len(s.details)
+}
+
+// recentDetailsLocked returns the buffered details from oldest to newest.
+func (s *RequestStatistics) recentDetailsLocked() []Event {
+	out := make([]Event, 0, len(s.details))
+	out = append(out, s.details[s.detailNext:]...)
+	return append(out, s.details[:s.detailNext]...)
+}
+
+// pruneLocked drops buckets and daily totals that fell out of retention. It runs at most
+// once per minute.
+func (s *RequestStatistics) pruneLocked(now time.Time) {
+	if now.Sub(s.lastPrune) < time.Minute {
+		return
+	}
+	s.lastPrune = now
+	for _, g := range granularities {
+		cutoff := now.Add(-s.retention.of(g))
+		for key, bucket := range s.rollups[g] {
+			if bucketEnd(g, bucket.Start).Before(cutoff) {
+				delete(s.rollups[g], key)
+			}
+		}
+	}
+	dayCutoff := bucketStart(GranularityDay, now.Add(-s.retention.Day)).Format("2006-01-02")
+	for day := range s.requestsByDay {
+		if day < dayCutoff {
+			delete(s.requestsByDay, day)
+			delete(s.tokensByDay, day)
+		}
+	}
+}
+
+// SetRetention changes the retention limits, trimming history that no longer fits.
+func (s *RequestStatistics) SetRetention(retention Retention) {
+	if s == nil {
+		return
+	}
+	retention = retention.withDefaults()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if retention.Details != s.retention.Details {
+		recent := s.recentDetailsLocked()
+		if len(recent) > retention.Details {
+			recent = recent[len(recent)-retention.Details:]
+		}
+		s.details = make([]Event, 0, retention.Details)
+		s.details = append(s.details, recent...)
+		s.detailNext = 0
+	}
+	s.retention = retention
+	s.lastPrune = time.Time{}
+	s.pruneLocked(time.Now())
+}
+
+// Retention returns the active retention limits.
+func (s *RequestStatistics) Retention() Retention {
+	if s == nil {
+		return DefaultRetention()
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.retention
+}
+
+// Rollups returns the buckets of granularity g matching filter, ordered by start time.
+func (s *RequestStatistics) Rollups(g Granularity, filter Filter) []Bucket {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Bucket, 0)
+	for _, bucket := range s.rollups[g] {
+		if !filter.matchesDimensions(bucket.APIKey, bucket.Model, bucket.Provider, bucket.AuthID) {
+			continue
+		}
+		if !filter.overlaps(bucket.Start, bucketEnd(g, bucket.Start)) {
+			continue
+		}
+		copied := *bucket
+		copied.Errors = bucket.outcomes.errorsCopy()
+		copied.Latency = bucket.outcomes.latency.summary()
+		copied.TimeToFirstToken = bucket.outcomes.ttft.summary()
+		out = append(out, copied)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].Start.Equal(out[j].Start) {
+			return out[i].Start.Before(out[j].Start)
+		}
+		if out[i].APIKey != out[j].APIKey {
+			return out[i].APIKey < out[j].APIKey
+		}
+		if out[i].Model != out[j].Model {
+			return out[i].Model < out[j].Model
+		}
+		return out[i].AuthID < out[j].AuthID
+	})
+	return out
+}
+
+// TokensSince returns the tokens recorded for apiKey from the local day containing since
+// onwards. It is computed from the day rollups, so history beyond their retention is lost.
+func (s *RequestStatistics) TokensSince(apiKey string, since time.Time) int64 {
+	if s == nil {
+		return 0
+	}
+	from := bucketStart(GranularityDay, since)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var total int64
+	for _, bucket := range s.rollups[GranularityDay] {
+		if bucket.APIKey == apiKey && !bucket.Start.Before(from) {
+			total += bucket.Tokens.TotalTokens
+		}
+	}
+	return total
+}
+
+// coveringGranularity returns the finest granularity whose retention still covers from.
+func (s *RequestStatistics) coveringGranularity(from time.Time, now time.Time) Granularity {
+	if from.IsZero() {
+		return GranularityDay
+	}
+	for _, g := range granularities {
+		if !bucketStart(g, from).Before(now.Add(-s.retention.of(g))) {
+			return g
+		}
+	}
+	return GranularityDay
+}
+
+func addTokens(dst *TokenStats, src TokenStats) {
+	dst.InputTokens += src.InputTokens
+	dst.OutputTokens += src.OutputTokens
+	dst.ReasoningTokens += src.ReasoningTokens
+	dst.CachedTokens += src.CachedTokens
+	dst.TotalTokens += src.TotalTokens
+}
+
+// Query returns a snapshot restricted to filter. It is computed from the finest rollups
+// whose retention still covers filter.From, so time ranges are widened to bucket
+// boundaries. An empty filter returns the all-time Snapshot.
+func (s *RequestStatistics) Query(filter Filter) StatisticsSnapshot {
+	if filter.IsZero() {
+		return s.Snapshot()
+	}
+	result := StatisticsSnapshot{
+		APIs:           make(map[string]APISnapshot),
+		Credentials:    make(map[string]CredentialSnapshot),
+		RequestsByDay:  make(map[string]int64),
+		RequestsByHour: make(map[string]int64),
+		TokensByDay:    make(map[string]int64),
+		TokensByHour:   make(map[string]int64),
+	}
+	if s == nil {
+		return result
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type modelKey struct{ apiKey, model string }
+	modelOutcomes := make(map[modelKey]*outcomeStats)
+	credentialOutcomes := make(map[string]*outcomeStats)
+	g := s.coveringGranularity(filter.From, time.Now())
+	for _, bucket := range s.rollups[g] {
+		if !filter.matchesDimensions(bucket.APIKey, bucket.Model, bucket.Provider, bucket.AuthID) {
+			continue
+		}
+		if !filter.overlaps(bucket.Start, bucketEnd(g, bucket.Start)) {
+			continue
+		}
+		tokens := bucket.Tokens.TotalTokens
+		result.TotalRequests += bucket.Requests
+		result.TotalAttempts += bucket.Attempts
+		result.FailureCount += bucket.Failures
+		result.SuccessCount += bucket.Requests - bucket.Failures
+		result.TotalTokens += tokens
+		result.TotalCost += bucket.Cost
+
+		apiSnapshot, ok := result.APIs[bucket.APIKey]
+		if !ok {
+			apiSnapshot = APISnapshot{Models: make(map[string]ModelSnapshot)}
+		}
+		apiSnapshot.TotalRequests += bucket.Requests
+		apiSnapshot.FailureCount += bucket.Failures
+		apiSnapshot.TotalTokens += tokens
+		apiSnapshot.TotalCost += bucket.Cost
+		modelSnapshot, ok := apiSnapshot.Models[bucket.Model]
+		if !ok {
+			modelSnapshot = ModelSnapshot{Details: []RequestDetail{}}
+		}
+		modelSnapshot.TotalRequests += bucket.Requests
+		modelSnapshot.TotalAttempts += bucket.Attempts
+		modelSnapshot.FailureCount += bucket.Failures
+		modelSnapshot.TotalTokens += tokens
+		modelSnapshot.TotalCost += bucket.Cost
+		apiSnapshot.Models[bucket.Model] = modelSnapshot
+		result.APIs[bucket.APIKey] = apiSnapshot
+		mk := modelKey{apiKey: bucket.APIKey, model: bucket.Model}
+		if modelOutcomes[mk] == nil {
+			modelOutcomes[mk] = &outcomeStats{}
+		}
+		modelOutcomes[mk].merge(bucket.outcomes)
+
+		if bucket.AuthID != "" {
+			credential := result.Credentials[bucket.AuthID]
+			credential.Provider = bucket.Provider
+			credential.TotalRequests += bucket.Attempts
+			credential.FailureCount += bucket.FailedAttempts
+			credential.TotalTokens += tokens
+			credential.TotalCost += bucket.Cost
+			result.Credentials[bucket.AuthID] = credential
+			if credentialOutcomes[bucket.AuthID] == nil {
+				credentialOutcomes[bucket.AuthID] = &outcomeStats{}
+			}
+			credentialOutcomes[bucket.AuthID].merge(bucket.outcomes)
+		}
+
+		day := bucket.Start.Format("2006-01-02")
+		result.RequestsByDay[day] += bucket.Requests
+		result.TokensByDay[day] += tokens
+		if g != GranularityDay {
+			hour := formatHour(bucket.Start.Hour())
+			result.RequestsByHour[hour] += bucket.Requests
+			result.TokensByHour[hour] += tokens
+		}
+	}
+	for mk, outcomes := range modelOutcomes {
+		modelSnapshot := result.APIs[mk.apiKey].Models[mk.model]
+		modelSnapshot.Errors = outcomes.errorsCopy()
+		modelSnapshot.Latency = outcomes.latency.summary()
+		modelSnapshot.TimeToFirstToken = outcomes.ttft.summary()
+		result.APIs[mk.apiKey].Models[mk.model] = modelSnapshot
+	}
+	for authID, outcomes := range credentialOutcomes {
+		credential := result.Credentials[authID]
+		credential.Errors = outcomes.errorsCopy()
+		credential.Latency = outcomes.latency.summary()
+		credential.TimeToFirstToken = outcomes.ttft.summary()
+		credential.LastSuccessAt = outcomes.lastSuccess
+		credential.LastFailureAt = outcomes.lastFailure
+		result.Credentials[authID] = credential
+	}
+	attachDetails(result.APIs, s.recentDetailsLocked(), filter)
+	return result
+}
+
+// attachDetails adds the buffered request details matching filter to their model snapshots.
+func attachDetails(apis map[string]APISnapshot, events []Event, filter Filter) {
+	for _, event := range events {
+		if !filter.matchesDimensions(event.APIKey, event.Model, event.Detail.Provider, event.Detail.AuthID) {
+			continue
+		}
+		if !filter.matchesTime(event.Detail.Timestamp) {
+			continue
+		}
+		apiSnapshot, ok := apis[event.APIKey]
+		if !ok {
+			continue
+		}
+		modelSnapshot, ok := apiSnapshot.Models[event.Model]
+		if !ok {
+			continue
+		}
+		modelSnapshot.Details = append(modelSnapshot.Details, event.Detail)
+		apiSnapshot.Models[event.Model] = modelSnapshot
+	}
+}
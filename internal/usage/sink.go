@@ -0,0 +1,201 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	coreusage.RegisterPlugin(sinkPlugin{})
+}
+
+// sinkFields lists every field a sink can write, in documentation order.
+var sinkFields = []string{
+	"timestamp", "provider", "model", "requested_model", "api_key", "auth_id", "source",
+	"input_tokens", "output_tokens", "reasoning_tokens", "cached_tokens", "total_tokens", "cost",
+	"latency_ms", "ttft_ms", "status", "failed", "error_class", "stream", "attempt",
+	"source_format", "target_format",
+}
+
+// sink receives rendered usage rows.
+type sink interface {
+	write(row map[string]any)
+	close(ctx context.Context) error
+}
+
+// configuredSink pairs a sink with the row options of its config entry.
+type configuredSink struct {
+	sink   sink
+	fields map[string]struct{}
+	raw    bool
+}
+
+var (
+	sinksMu     sync.RWMutex
+	sinks       []configuredSink
+	sinkConfigs []config.UsageSink
+	sinkBaseDir string
+)
+
+// sinkPlugin forwards usage records to the configured sinks.
+type sinkPlugin struct{}
+
+// HandleUsage implements coreusage.Plugin.
+func (sinkPlugin) HandleUsage(_ context.Context, record coreusage.Record) {
+	sinksMu.RLock()
+	defer sinksMu.RUnlock()
+	if len(sinks) == 0 {
+		return
+	}
+	full := sinkRow(record)
+	for _, s := range sinks {
+		row := make(map[string]any, len(full))
+		for key, value := range full {
+			if s.fields != nil {
+				if _, ok := s.fields[key]; !ok {
+					continue
+				}
+			}
+			row[key] = value
+		}
+		if _, ok := row["api_key"]; ok && !s.raw {
+			row["api_key"] = util.HideAPIKey(record.APIKey)
+		}
+		s.sink.write(row)
+	}
+}
+
+// sinkRow renders every field of record.
+func sinkRow(record coreusage.Record) map[string]any {
+	tokens := normaliseDetail(record.Detail)
+	return map[string]any{
+		"timestamp":        record.RequestedAt.UTC(),
+		"provider":         record.Provider,
+		"model":            record.Model,
+		"requested_model":  record.RequestedModel,
+		"api_key":          record.APIKey,
+		"auth_id":          record.AuthID,
+		"source":           record.Source,
+		"input_tokens":     tokens.InputTokens,
+		"output_tokens":    tokens.OutputTokens,
+		"reasoning_tokens": tokens.ReasoningTokens,
+		"cached_tokens":    tokens.CachedTokens,
+		"total_tokens":     tokens.TotalTokens,
+		"cost":             Cost(record.Provider, record.Model, tokens),
+		"latency_ms":       durationMs(record.Latency),
+		"ttft_ms":          durationMs(record.TimeToFirstToken),
+		"status":           record.Status,
+		"failed":           record.Failed,
+		"error_class":      record.ErrorClass,
+		"stream":           record.Stream,
+		"attempt":          record.Attempt,
+		"superseded":       record.Superseded,
+		"source_format":    record.SourceFormat,
+		"target_format":    record.TargetFormat,
+	}
+}
+
+// ConfigureSinks replaces the usage sinks with the ones described by cfgs. Relative paths
+// resolve against baseDir. Unchanged configurations keep the running sinks.
+func ConfigureSinks(ctx context.Context, cfgs []config.UsageSink, baseDir string) {
+	sinksMu.Lock()
+	if reflect.DeepEqual(cfgs, sinkConfigs) && baseDir == sinkBaseDir {
+		sinksMu.Unlock()
+		return
+	}
+	old := sinks
+	sinks = nil
+	for i, cfg := range cfgs {
+		s, err := newSink(cfg, baseDir)
+		if err != nil {
+			log.Errorf("usage sink %d: %v", i, err)
+			continue
+		}
+		entry := configuredSink{sink: s, raw: cfg.RawAPIKeys}
+		if len(cfg.Fields) > 0 {
+			entry.fields = make(map[string]struct{}, len(cfg.Fields))
+			for _, field := range cfg.Fields {
+				field = strings.TrimSpace(field)
+				if !isSinkField(field) {
+					log.Warnf("usage sink %d: unknown field %q ignored", i, field)
+					continue
+				}
+				entry.fields[field] = struct{}{}
+			}
+		}
+		sinks = append(sinks, entry)
+	}
+	sinkConfigs = cfgs
+	sinkBaseDir = baseDir
+	sinksMu.Unlock()
+
+	closeSinks(ctx, old)
+}
+
+// CloseSinks flushes and closes all usage sinks.
+func CloseSinks(ctx context.Context) {
+	sinksMu.Lock()
+	old := sinks
+	sinks = nil
+	sinkConfigs = nil
+	sinksMu.Unlock()
+	closeSinks(ctx, old)
+}
+
+func closeSinks(ctx context.Context, list []configuredSink) {
+	for _, s := range list {
+		if err := s.sink.close(ctx); err != nil {
+			log.Warnf("usage sink: close: %v", err)
+		}
+	}
+}
+
+func newSink(cfg config.UsageSink, baseDir string) (sink, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Type)) {
+	case "file":
+		if strings.TrimSpace(cfg.Path) == "" {
+			return nil, fmt.Errorf("file sink requires a path")
+		}
+		return newFileSink(cfg, resolveSinkPath(baseDir, cfg.Path)), nil
+	case "webhook":
+		if strings.TrimSpace(cfg.URL) == "" {
+			return nil, fmt.Errorf("webhook sink requires a url")
+		}
+		spillDir := strings.TrimSpace(cfg.SpillDir)
+		if spillDir == "" {
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(cfg.URL))
+			spillDir = filepath.Join("usage-spill", fmt.Sprintf("%08x", h.Sum32()))
+		}
+		return newWebhookSink(cfg, resolveSinkPath(baseDir, spillDir))
+	default:
+		return nil, fmt.Errorf("unknown sink type %q, expected file or webhook", cfg.Type)
+	}
+}
+
+func resolveSinkPath(baseDir, path string) string {
+	path = strings.TrimSpace(path)
+	if filepath.IsAbs(path) || baseDir == "" {
+		return path
+	}
+	return filepath.Join(baseDir, path)
+}
+
+func isSinkField(field string) bool {
+	for _, known := range sinkFields {
+		if known == field {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// fileSink appends usage rows as JSON lines to a size-rotated file.
+type fileSink struct {
+	mu     sync.Mutex
+	writer *lumberjack.Logger
+}
+
+func newFileSink(cfg config.UsageSink, path string) *fileSink {
+	maxSize := cfg.MaxSizeMB
+	if maxSize <= 0 {
+		maxSize = 100
+	}
+	return &fileSink{writer: &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    maxSize,
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAgeDays,
+		Compress:   cfg.Compress,
+	}}
+}
+
+func (s *fileSink) write(row map[string]any) {
+	line, err := json.Marshal(row)
+	if err != nil {
+		log.Warnf("usage file sink: marshal record: %v", err)
+		return
+	}
+	line = append(line, '\n')
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err = s.writer.Write(line); err != nil {
+		log.Warnf("usage file sink: write %s: %v", s.writer.Filename, err)
+	}
+}
+
+func (s *fileSink) close(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writer.Close()
+}
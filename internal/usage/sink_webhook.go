@@ -0,0 +1,289 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultWebhookBatchSize     = 100
+	defaultWebhookFlushInterval = 5 * time.Second
+	defaultWebhookTimeout       = 10 * time.Second
+	defaultWebhookMaxRetries    = 3
+	// webhookMaxPendingBatches bounds the records kept in memory; older batches spill to disk.
+	webhookMaxPendingBatches = 20
+	webhookMaxBackoff        = 30 * time.Second
+)
+
+// webhookSink POSTs usage rows in batches. Batches that cannot be delivered after the
+// configured retries are written to the spill directory and resent, oldest first, once
+// the endpoint accepts requests again.
+type webhookSink struct {
+	url        string
+	headers    map[string]string
+	client     *http.Client
+	batchSize  int
+	interval   time.Duration
+	maxRetries int
+	spillDir   string
+
+	mu      sync.Mutex
+	pending []map[string]any
+	seq     atomic.Uint64
+
+	kick chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newWebhookSink(cfg config.UsageSink, spillDir string) (*webhookSink, error) {
+	if err := os.MkdirAll(spillDir, 0o700); err != nil {
+		return nil, fmt.Errorf("create spill directory %s: %w", spillDir, err)
+	}
+	s := &webhookSink{
+		url:        strings.TrimSpace(cfg.URL),
+		headers:    cfg.Headers,
+		client:     &http.Client{Timeout: defaultWebhookTimeout},
+		batchSize:  cfg.BatchSize,
+		interval:   time.Duration(cfg.FlushIntervalSeconds) * time.Second,
+		maxRetries: cfg.MaxRetries,
+		spillDir:   spillDir,
+		kick:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if cfg.TimeoutSeconds > 0 {
+		s.client.Timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	if s.batchSize <= 0 {
+		s.batchSize = defaultWebhookBatchSize
+	}
+	if s.interval <= 0 {
+		s.interval = defaultWebhookFlushInterval
+	}
+	if s.maxRetries <= 0 {
+		s.maxRetries = defaultWebhookMaxRetries
+	}
+	go s.run()
+	return s, nil
+}
+
+func (s *webhookSink) write(row map[string]any) {
+	s.mu.Lock()
+	s.pending = append(s.pending, row)
+	full := len(s.pending) >= s.batchSize
+	var overflow []map[string]any
+	if len(s.pending) > s.batchSize*webhookMaxPendingBatches {
+		overflow = s.pending[:s.batchSize]
+		s.pending = s.pending[s.batchSize:]
+	}
+	s.mu.Unlock()
+	if overflow != nil {
+		s.spill(overflow)
+	}
+	if full {
+		select {
+		case s.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *webhookSink) close(ctx context.Context) error {
+	close(s.stop)
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	// Whatever is left could not be delivered in time; keep it for the next start.
+	s.mu.Lock()
+	rest := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+	for len(rest) > 0 {
+		n := min(len(rest), s.batchSize)
+		s.spill(rest[:n])
+		rest = rest[n:]
+	}
+	return nil
+}
+
+func (s *webhookSink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			ctx, cancel := context.WithTimeout(context.Background(), s.client.Timeout)
+			s.flushPending(ctx, false)
+			cancel()
+			return
+		case <-s.kick:
+		case <-ticker.C:
+		}
+		ctx, cancel := s.stopContext()
+		if s.flushPending(ctx, true) {
+			s.resendSpilled(ctx)
+		}
+		cancel()
+	}
+}
+
+// stopContext returns a context cancelled when the sink is closed, so retries do not
+// delay shutdown.
+func (s *webhookSink) stopContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-s.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// flushPending sends pending rows batch by batch and reports whether all were delivered.
+// Undeliverable batches are spilled to disk.
+func (s *webhookSink) flushPending(ctx context.Context, retry bool) bool {
+	for {
+		s.mu.Lock()
+		n := min(len(s.pending), s.batchSize)
+		batch := s.pending[:n:n]
+		s.pending = s.pending[n:]
+		s.mu.Unlock()
+		if n == 0 {
+			return true
+		}
+		body, errMarshal := json.Marshal(batch)
+		if errMarshal != nil {
+			log.Warnf("usage webhook sink: marshal batch: %v", errMarshal)
+			continue
+		}
+		retryable, err := s.send(ctx, body, retry)
+		if err == nil {
+			continue
+		}
+		if !retryable {
+			log.Warnf("usage webhook sink: %s rejected %d records: %v", s.url, n, err)
+			continue
+		}
+		log.Warnf("usage webhook sink: deliver %d records to %s: %v", n, s.url, err)
+		s.spillBody(body)
+		return false
+	}
+}
+
+// resendSpilled delivers spilled batches oldest first, stopping at the first failure.
+func (s *webhookSink) resendSpilled(ctx context.Context) {
+	entries, err := os.ReadDir(s.spillDir)
+	if err != nil {
+		return
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(s.spillDir, name)
+		body, errRead := os.ReadFile(path)
+		if errRead != nil {
+			log.Warnf("usage webhook sink: read spilled batch %s: %v", path, errRead)
+			continue
+		}
+		if retryable, errSend := s.send(ctx, body, false); errSend != nil {
+			if retryable {
+				return
+			}
+			log.Warnf("usage webhook sink: %s rejected spilled batch %s: %v", s.url, path, errSend)
+		}
+		if errRemove := os.Remove(path); errRemove != nil {
+			log.Warnf("usage webhook sink: remove spilled batch %s: %v", path, errRemove)
+		}
+	}
+}
+
+func (s *webhookSink) spill(batch []map[string]any) {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		log.Warnf("usage webhook sink: marshal batch: %v", err)
+		return
+	}
+	s.spillBody(body)
+}
+
+func (s *webhookSink) spillBody(body []byte) {
+	name := fmt.Sprintf("%020d-%06d.json", time.Now().UnixNano(), s.seq.Add(1)%1_000_000)
+	path := filepath.Join(s.spillDir, name)
+	if err := os.WriteFile(path, body, 0o600); err != nil {
+		log.Errorf("usage webhook sink: spill batch to %s: %v", path, err)
+	}
+}
+
+// send POSTs body, retrying network errors, 429 and 5xx responses with exponential
+// backoff when retry is set. Other responses fail immediately and are not retryable.
+func (s *webhookSink) send(ctx context.Context, body []byte, retry bool) (retryable bool, err error) {
+	attempts := 1
+	if retry {
+		attempts += s.maxRetries
+	}
+	backoff := time.Second
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return true, ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, webhookMaxBackoff)
+		}
+		retryable, err = s.post(ctx, body)
+		if err == nil || !retryable {
+			return retryable, err
+		}
+	}
+	return retryable, err
+}
+
+func (s *webhookSink) post(ctx context.Context, body []byte) (retryable bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retryable = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+	return retryable, fmt.Errorf("unexpected status %d", resp.StatusCode)
+}
@@ -0,0 +1,136 @@
+package util
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// OpenAIEmbeddingsToGemini converts an OpenAI /v1/embeddings request into a Gemini
+// batchEmbedContents request for model. Token array inputs have no Gemini equivalent and
+// are rejected.
+func OpenAIEmbeddingsToGemini(model string, rawJSON []byte) ([]byte, error) {
+	input := gjson.GetBytes(rawJSON, "input")
+	var texts []string
+	switch {
+	case input.Type == gjson.String:
+		texts = []string{input.String()}
+	case input.IsArray():
+		for _, item := range input.Array() {
+			if item.Type != gjson.String {
+				return nil, fmt.Errorf("input must be a string or an array of strings; token arrays are not supported by %s", model)
+			}
+			texts = append(texts, item.String())
+		}
+	}
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("input must not be empty")
+	}
+
+	out := []byte(`{"requests":[]}`)
+	for _, text := range texts {
+		request := `{"model":"","content":{"parts":[{"text":""}]}}`
+		request, _ = sjson.Set(request, "model", "models/"+model)
+		request, _ = sjson.Set(request, "content.parts.0.text", text)
+		if dimensions := gjson.GetBytes(rawJSON, "dimensions"); dimensions.Exists() {
+			request, _ = sjson.Set(request, "outputDimensionality", dimensions.Int())
+		}
+		out, _ = sjson.SetRawBytes(out, "requests.-1", []byte(request))
+	}
+	return out, nil
+}
+
+// GeminiEmbeddingsToOpenAI converts a Gemini batchEmbedContents response into an OpenAI
+// embeddings list. Vectors are base64 encoded when the original request asked for
+// encoding_format "base64".
+func GeminiEmbeddingsToOpenAI(model string, originalRequest, data []byte, promptTokens int64) []byte {
+	encodeBase64 := gjson.GetBytes(originalRequest, "encoding_format").String() == "base64"
+	out := []byte(`{"object":"list","data":[],"model":"","usage":{"prompt_tokens":0,"total_tokens":0}}`)
+	out, _ = sjson.SetBytes(out, "model", model)
+	for i, embedding := range gjson.GetBytes(data, "embeddings").Array() {
+		item := `{"object":"embedding","index":0,"embedding":[]}`
+		item, _ = sjson.Set(item, "index", i)
+		if encodeBase64 {
+			item, _ = sjson.Set(item, "embedding", encodeEmbeddingBase64(embedding.Get("values").Array()))
+		} else if values := embedding.Get("values"); values.IsArray() {
+			item, _ = sjson.SetRaw(item, "embedding", values.Raw)
+		}
+		out, _ = sjson.SetRawBytes(out, "data.-1", []byte(item))
+	}
+	out, _ = sjson.SetBytes(out, "usage.prompt_tokens", promptTokens)
+	out, _ = sjson.SetBytes(out, "usage.total_tokens", promptTokens)
+	return out
+}
+
+// GeminiEmbeddingsRequestToOpenAI converts a Gemini batchEmbedContents request into an
+// OpenAI /v1/embeddings request. The text parts of each request become one input; the
+// output dimensionality of the first request applies to all of them.
+func GeminiEmbeddingsRequestToOpenAI(model string, rawJSON []byte) []byte {
+	out := []byte(`{"model":"","input":[]}`)
+	out, _ = sjson.SetBytes(out, "model", model)
+	requests := gjson.GetBytes(rawJSON, "requests").Array()
+	for _, request := range requests {
+		var parts []string
+		for _, part := range request.Get("content.parts").Array() {
+			if text := part.Get("text"); text.Exists() {
+				parts = append(parts, text.String())
+			}
+		}
+		out, _ = sjson.SetBytes(out, "input.-1", strings.Join(parts, "\n"))
+	}
+	if len(requests) > 0 {
+		if dimensions := requests[0].Get("outputDimensionality"); dimensions.Exists() {
+			out, _ = sjson.SetBytes(out, "dimensions", dimensions.Int())
+		}
+	}
+	return out
+}
+
+// OpenAIEmbeddingsToGeminiResponse converts an OpenAI embeddings list with float vectors
+// into a Gemini batchEmbedContents response, ordered by index.
+func OpenAIEmbeddingsToGeminiResponse(data []byte) []byte {
+	items := gjson.GetBytes(data, "data").Array()
+	ordered := make([]string, len(items))
+	for i, item := range items {
+		index := i
+		if idx := item.Get("index"); idx.Exists() && int(idx.Int()) < len(items) && idx.Int() >= 0 {
+			index = int(idx.Int())
+		}
+		ordered[index] = item.Get("embedding").Raw
+	}
+	out := []byte(`{"embeddings":[]}`)
+	for _, values := range ordered {
+		if values == "" {
+			values = "[]"
+		}
+		out, _ = sjson.SetRawBytes(out, "embeddings.-1", []byte(`{"values":`+values+`}`))
+	}
+	return out
+}
+
+// EstimateGeminiEmbeddingTokens estimates the prompt tokens of a batchEmbedContents
+// request for upstreams that do not report them, at about four characters per token.
+func EstimateGeminiEmbeddingTokens(rawJSON []byte) int64 {
+	var total int64
+	for _, request := range gjson.GetBytes(rawJSON, "requests").Array() {
+		for _, part := range request.Get("content.parts").Array() {
+			total += int64(math.Ceil(float64(len(part.Get("text").String())) / charsPerToken))
+		}
+	}
+	return total
+}
+
+// encodeEmbeddingBase64 encodes a vector the way OpenAI does for encoding_format "base64":
+// little-endian float32 values.
+func encodeEmbeddingBase64(values []gjson.Result) string {
+	buf := make([]byte, 4*len(values))
+	for i, value := range values {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(float32(value.Float())))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
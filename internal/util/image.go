@@ -3,11 +3,18 @@ package util
 import (
 	"bytes"
 	"encoding/base64"
+	"fmt"
 	"image"
 	"image/draw"
 	"image/png"
+	"math"
+	"strconv"
+	"strings"
 )
 
+// geminiAspectRatios lists the aspect ratios accepted by Gemini image models.
+var geminiAspectRatios = []string{"1:1", "2:3", "3:2", "3:4", "4:3", "4:5", "5:4", "9:16", "16:9", "21:9"}
+
 func CreateWhiteImageBase64(aspectRatio string) (string, error) {
 	width := 1024
 	height := 1024
@@ -57,3 +64,46 @@ func CreateWhiteImageBase64(aspectRatio string) (string, error) {
 	base64String := base64.StdEncoding.EncodeToString(buf.Bytes())
 	return base64String, nil
 }
+
+// GeminiAspectRatio maps an OpenAI image size such as "1536x1024" to the closest aspect
+// ratio accepted by Gemini image models. Supported ratios such as "16:9" are returned as
+// is; an empty size or "auto" returns "" to let the model choose.
+func GeminiAspectRatio(size string) (string, error) {
+	size = strings.ToLower(strings.TrimSpace(size))
+	if size == "" || size == "auto" {
+		return "", nil
+	}
+	for _, ratio := range geminiAspectRatios {
+		if size == ratio {
+			return ratio, nil
+		}
+	}
+	width, height, ok := parseImageDimensions(size, "x")
+	if !ok {
+		if width, height, ok = parseImageDimensions(size, ":"); !ok {
+			return "", fmt.Errorf("invalid size %q: expected WIDTHxHEIGHT, an aspect ratio such as 16:9, or auto", size)
+		}
+	}
+	target := math.Log(width / height)
+	best, bestDistance := "", math.Inf(1)
+	for _, ratio := range geminiAspectRatios {
+		w, h, _ := parseImageDimensions(ratio, ":")
+		if distance := math.Abs(math.Log(w/h) - target); distance < bestDistance {
+			best, bestDistance = ratio, distance
+		}
+	}
+	return best, nil
+}
+
+func parseImageDimensions(value, separator string) (float64, float64, bool) {
+	parts := strings.Split(value, separator)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	width, errWidth := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	height, errHeight := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if errWidth != nil || errHeight != nil || width <= 0 || height <= 0 {
+		return 0, 0, false
+	}
+	return width, height, true
+}
@@ -0,0 +1,351 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidateJSON checks that data is a JSON document conforming to schema. An empty schema
+// only requires valid JSON. The check covers the JSON Schema keywords used for structured
+// outputs (type, properties, required, additionalProperties, items, enum, const, numeric
+// and length bounds, pattern, anyOf/oneOf/allOf and local $ref); other keywords are
+// ignored. The returned error names the first offending location, e.g. "$.items[2].id".
+func ValidateJSON(schema, data string) error {
+	var value interface{}
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("output is not valid JSON: %w", err)
+	}
+	if decoder.More() {
+		return fmt.Errorf("output is not valid JSON: unexpected data after the top-level value")
+	}
+	if strings.TrimSpace(schema) == "" {
+		return nil
+	}
+	var root interface{}
+	if err := json.Unmarshal([]byte(schema), &root); err != nil {
+		return fmt.Errorf("invalid JSON schema: %w", err)
+	}
+	v := schemaValidator{root: root}
+	return v.validate(root, value, "$", 0)
+}
+
+type schemaValidator struct {
+	root interface{}
+}
+
+func (v *schemaValidator) validate(schema, value interface{}, path string, depth int) error {
+	if depth > 64 {
+		return nil
+	}
+	switch s := schema.(type) {
+	case bool:
+		if !s {
+			return fmt.Errorf("%s: no value is allowed here", path)
+		}
+		return nil
+	case map[string]interface{}:
+		return v.validateObjectSchema(s, value, path, depth)
+	default:
+		return nil
+	}
+}
+
+func (v *schemaValidator) validateObjectSchema(s map[string]interface{}, value interface{}, path string, depth int) error {
+	if ref, ok := s["$ref"].(string); ok {
+		target := resolveSchemaRef(v.root, ref)
+		if target == nil {
+			return fmt.Errorf("%s: unresolvable schema reference %q", path, ref)
+		}
+		if err := v.validate(target, value, path, depth+1); err != nil {
+			return err
+		}
+	}
+
+	if typ, ok := s["type"]; ok {
+		if err := checkSchemaType(typ, value, path); err != nil {
+			return err
+		}
+	}
+	if enum, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, candidate := range enum {
+			if jsonEqual(candidate, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %s is not one of the allowed values", path, describeJSON(value))
+		}
+	}
+	if constant, ok := s["const"]; ok && !jsonEqual(constant, value) {
+		return fmt.Errorf("%s: expected %s, got %s", path, describeJSON(constant), describeJSON(value))
+	}
+
+	for _, key := range []string{"allOf", "anyOf", "oneOf"} {
+		branches, ok := s[key].([]interface{})
+		if !ok {
+			continue
+		}
+		matched := 0
+		var firstErr error
+		for _, branch := range branches {
+			if err := v.validate(branch, value, path, depth+1); err != nil {
+				if key == "allOf" {
+					return err
+				}
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			matched++
+		}
+		switch {
+		case key == "anyOf" && matched == 0 && len(branches) > 0:
+			return fmt.Errorf("%s: matches none of the anyOf schemas (first mismatch: %v)", path, firstErr)
+		case key == "oneOf" && matched != 1 && len(branches) > 0:
+			return fmt.Errorf("%s: matches %d of the oneOf schemas, expected exactly one", path, matched)
+		}
+	}
+
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		return v.validateObject(s, typed, path, depth)
+	case []interface{}:
+		return v.validateArray(s, typed, path, depth)
+	case string:
+		return validateString(s, typed, path)
+	case json.Number:
+		return validateNumber(s, typed, path)
+	}
+	return nil
+}
+
+func (v *schemaValidator) validateObject(s map[string]interface{}, obj map[string]interface{}, path string, depth int) error {
+	if required, ok := s["required"].([]interface{}); ok {
+		for _, name := range required {
+			key, _ := name.(string)
+			if _, present := obj[key]; !present {
+				return fmt.Errorf("%s: missing required property %q", path, key)
+			}
+		}
+	}
+	if n, ok := schemaNumber(s["minProperties"]); ok && float64(len(obj)) < n {
+		return fmt.Errorf("%s: expected at least %v properties, got %d", path, n, len(obj))
+	}
+	if n, ok := schemaNumber(s["maxProperties"]); ok && float64(len(obj)) > n {
+		return fmt.Errorf("%s: expected at most %v properties, got %d", path, n, len(obj))
+	}
+
+	properties, _ := s["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		childPath := path + "." + key
+		if propSchema, ok := properties[key]; ok {
+			if err := v.validate(propSchema, obj[key], childPath, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+		switch additional := s["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fmt.Errorf("%s: unexpected property %q", path, key)
+			}
+		case map[string]interface{}:
+			if err := v.validate(additional, obj[key], childPath, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *schemaValidator) validateArray(s map[string]interface{}, arr []interface{}, path string, depth int) error {
+	if n, ok := schemaNumber(s["minItems"]); ok && float64(len(arr)) < n {
+		return fmt.Errorf("%s: expected at least %v items, got %d", path, n, len(arr))
+	}
+	if n, ok := schemaNumber(s["maxItems"]); ok && float64(len(arr)) > n {
+		return fmt.Errorf("%s: expected at most %v items, got %d", path, n, len(arr))
+	}
+	start := 0
+	if prefix, ok := s["prefixItems"].([]interface{}); ok {
+		for i := 0; i < len(prefix) && i < len(arr); i++ {
+			if err := v.validate(prefix[i], arr[i], fmt.Sprintf("%s[%d]", path, i), depth+1); err != nil {
+				return err
+			}
+		}
+		start = len(prefix)
+	}
+	if items, ok := s["items"]; ok {
+		for i := start; i < len(arr); i++ {
+			if err := v.validate(items, arr[i], fmt.Sprintf("%s[%d]", path, i), depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	if unique, _ := s["uniqueItems"].(bool); unique {
+		for i := 0; i < len(arr); i++ {
+			for j := i + 1; j < len(arr); j++ {
+				if jsonEqual(arr[i], arr[j]) {
+					return fmt.Errorf("%s: items %d and %d are equal", path, i, j)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func validateString(s map[string]interface{}, str, path string) error {
+	length := float64(utf8.RuneCountInString(str))
+	if n, ok := schemaNumber(s["minLength"]); ok && length < n {
+		return fmt.Errorf("%s: expected at least %v characters, got %v", path, n, length)
+	}
+	if n, ok := schemaNumber(s["maxLength"]); ok && length > n {
+		return fmt.Errorf("%s: expected at most %v characters, got %v", path, n, length)
+	}
+	if pattern, ok := s["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err == nil && !re.MatchString(str) {
+			return fmt.Errorf("%s: %q does not match pattern %q", path, str, pattern)
+		}
+	}
+	return nil
+}
+
+func validateNumber(s map[string]interface{}, num json.Number, path string) error {
+	f, err := num.Float64()
+	if err != nil {
+		return nil
+	}
+	if n, ok := schemaNumber(s["minimum"]); ok && f < n {
+		return fmt.Errorf("%s: %v is less than the minimum %v", path, num, n)
+	}
+	if n, ok := schemaNumber(s["maximum"]); ok && f > n {
+		return fmt.Errorf("%s: %v is greater than the maximum %v", path, num, n)
+	}
+	if n, ok := schemaNumber(s["exclusiveMinimum"]); ok && f <= n {
+		return fmt.Errorf("%s: %v must be greater than %v", path, num, n)
+	}
+	if n, ok := schemaNumber(s["exclusiveMaximum"]); ok && f >= n {
+		return fmt.Errorf("%s: %v must be less than %v", path, num, n)
+	}
+	if n, ok := schemaNumber(s["multipleOf"]); ok && n > 0 {
+		if q := f / n; math.Abs(q-math.Round(q)) > 1e-9 {
+			return fmt.Errorf("%s: %v is not a multiple of %v", path, num, n)
+		}
+	}
+	return nil
+}
+
+func checkSchemaType(typ, value interface{}, path string) error {
+	var allowed []string
+	switch t := typ.(type) {
+	case string:
+		allowed = []string{t}
+	case []interface{}:
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				allowed = append(allowed, s)
+			}
+		}
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+	actual := jsonType(value)
+	for _, want := range allowed {
+		if want == actual || (want == "number" && actual == "integer") {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(allowed, " or "), actual)
+}
+
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
+
+// schemaNumber reads a numeric keyword from a schema decoded without UseNumber.
+func schemaNumber(value interface{}) (float64, bool) {
+	n, ok := value.(float64)
+	return n, ok
+}
+
+// jsonEqual compares a schema value (float64 numbers) with an instance value (json.Number).
+func jsonEqual(a, b interface{}) bool {
+	return reflect.DeepEqual(normalizeJSON(a), normalizeJSON(b))
+}
+
+func normalizeJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = normalizeJSON(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = normalizeJSON(item)
+		}
+		return out
+	}
+	return value
+}
+
+func describeJSON(value interface{}) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Sprint(value)
+	}
+	out := strings.TrimSpace(buf.String())
+	if len(out) > 64 {
+		out = out[:61] + "..."
+	}
+	return out
+}
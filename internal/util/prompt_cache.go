@@ -0,0 +1,213 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// defaultCacheMinTokens is Claude's minimum cacheable prompt length for most models.
+	defaultCacheMinTokens = 1024
+	// maxClaudeCacheBreakpoints is the number of cache_control blocks Claude accepts.
+	maxClaudeCacheBreakpoints = 4
+	// charsPerToken is the rough ratio used to estimate token counts from JSON size.
+	charsPerToken = 4
+)
+
+var (
+	promptCachingMu sync.RWMutex
+	promptCaching   config.PromptCachingConfig
+)
+
+// SetPromptCaching replaces the settings used when adding cache breakpoints to requests
+// translated for Claude.
+func SetPromptCaching(cfg config.PromptCachingConfig) {
+	promptCachingMu.Lock()
+	promptCaching = cfg
+	promptCachingMu.Unlock()
+}
+
+func currentPromptCaching() config.PromptCachingConfig {
+	promptCachingMu.RLock()
+	defer promptCachingMu.RUnlock()
+	return promptCaching
+}
+
+// ApplyClaudeAutoCacheBreakpoints adds ephemeral cache breakpoints to a Claude request
+// translated from another format: one after the tool definitions and one after the first
+// systemMessages messages, which hold the system prompt the translators send as leading
+// user messages. Each part only gets a breakpoint when its estimated size reaches the
+// configured minimum. Requests that already carry cache_control blocks are left untouched.
+func ApplyClaudeAutoCacheBreakpoints(out string, systemMessages int) string {
+	settings := currentPromptCaching()
+	if settings.DisableAutoBreakpoints || len(claudeCacheBreakpoints(gjson.Parse(out))) > 0 {
+		return out
+	}
+	minTokens := settings.MinTokens
+	if minTokens <= 0 {
+		minTokens = defaultCacheMinTokens
+	}
+	minChars := minTokens * charsPerToken
+
+	if tools := gjson.Get(out, "tools"); tools.IsArray() && len(tools.Raw) >= minChars {
+		out, _ = sjson.SetRaw(out, fmt.Sprintf("tools.%d.cache_control", len(tools.Array())-1), `{"type":"ephemeral"}`)
+	}
+
+	messages := gjson.Get(out, "messages").Array()
+	if systemMessages <= 0 || systemMessages > len(messages) {
+		return out
+	}
+	size := 0
+	for _, message := range messages[:systemMessages] {
+		size += len(message.Get("content").Raw)
+	}
+	if size < minChars {
+		return out
+	}
+	last := systemMessages - 1
+	content := messages[last].Get("content")
+	if content.Type == gjson.String {
+		block := `{"type":"text","text":""}`
+		block, _ = sjson.Set(block, "text", content.String())
+		out, _ = sjson.SetRaw(out, fmt.Sprintf("messages.%d.content", last), "["+block+"]")
+		content = gjson.Get(out, fmt.Sprintf("messages.%d.content", last))
+	}
+	if blocks := content.Array(); len(blocks) > 0 {
+		out, _ = sjson.SetRaw(out, fmt.Sprintf("messages.%d.content.%d.cache_control", last, len(blocks)-1), `{"type":"ephemeral"}`)
+	}
+	return out
+}
+
+// LimitClaudeCacheBreakpoints removes the earliest cache_control blocks of a Claude request
+// beyond the four Claude accepts. Later breakpoints cover longer prefixes and are kept.
+func LimitClaudeCacheBreakpoints(body []byte) []byte {
+	paths := claudeCacheBreakpoints(gjson.ParseBytes(body))
+	for i := 0; i < len(paths)-maxClaudeCacheBreakpoints; i++ {
+		body, _ = sjson.DeleteBytes(body, paths[i])
+	}
+	return body
+}
+
+// ClaudeCachePrefixKey derives a stable key from the part of a Claude request that ends at
+// its last cache_control breakpoint, or returns "" when the request has none. Upstreams with
+// automatic prefix caching, such as the Responses API prompt_cache_key, use it to send
+// requests sharing that prefix to the same cache.
+func ClaudeCachePrefixKey(rawJSON []byte) string {
+	root := gjson.ParseBytes(rawJSON)
+	paths := claudeCacheBreakpoints(root)
+	if len(paths) == 0 {
+		return ""
+	}
+	var section string
+	var index, block int
+	if _, err := fmt.Sscanf(paths[len(paths)-1], "messages.%d.content.%d.cache_control", &index, &block); err == nil {
+		section = "messages"
+	} else if _, err = fmt.Sscanf(paths[len(paths)-1], "system.%d.cache_control", &index); err == nil {
+		section = "system"
+	} else if _, err = fmt.Sscanf(paths[len(paths)-1], "tools.%d.cache_control", &index); err == nil {
+		section = "tools"
+	} else {
+		return ""
+	}
+
+	hash := sha256.New()
+	write := func(values []gjson.Result) {
+		for _, value := range values {
+			hash.Write([]byte(value.Raw))
+			hash.Write([]byte{0})
+		}
+	}
+	tools := root.Get("tools").Array()
+	if section == "tools" {
+		write(tools[:index+1])
+	} else {
+		write(tools)
+		system := root.Get("system")
+		if section == "system" {
+			write(system.Array()[:index+1])
+		} else {
+			write([]gjson.Result{system})
+			messages := root.Get("messages").Array()
+			write(messages[:index])
+			write(messages[index].Get("content").Array()[:block+1])
+		}
+	}
+	return "cache-" + hex.EncodeToString(hash.Sum(nil))[:32]
+}
+
+// claudeCacheBreakpoints lists the paths of the cache_control blocks of a Claude request
+// in prompt order: tools, system, then messages.
+func claudeCacheBreakpoints(root gjson.Result) []string {
+	var paths []string
+	for i, tool := range root.Get("tools").Array() {
+		if tool.Get("cache_control").Exists() {
+			paths = append(paths, fmt.Sprintf("tools.%d.cache_control", i))
+		}
+	}
+	for i, block := range root.Get("system").Array() {
+		if block.IsObject() && block.Get("cache_control").Exists() {
+			paths = append(paths, fmt.Sprintf("system.%d.cache_control", i))
+		}
+	}
+	for i, message := range root.Get("messages").Array() {
+		content := message.Get("content")
+		if !content.IsArray() {
+			continue
+		}
+		for j, block := range content.Array() {
+			if block.Get("cache_control").Exists() {
+				paths = append(paths, fmt.Sprintf("messages.%d.content.%d.cache_control", i, j))
+			}
+		}
+	}
+	return paths
+}
+
+// CacheUsage holds the prompt token counts of an upstream usage block, normalised so they
+// can be reported in any dialect.
+type CacheUsage struct {
+	// PromptTokens counts every prompt token, cached or not.
+	PromptTokens int64
+	// CachedTokens counts the prompt tokens read from the cache.
+	CachedTokens int64
+}
+
+// UncachedTokens returns the prompt tokens that were not read from the cache, which is what
+// Claude reports as input_tokens.
+func (u CacheUsage) UncachedTokens() int64 {
+	if u.CachedTokens >= u.PromptTokens {
+		return 0
+	}
+	return u.PromptTokens - u.CachedTokens
+}
+
+// ClaudeCacheUsage reads a Claude usage block, whose input_tokens exclude the tokens read
+// from and written to the cache.
+func ClaudeCacheUsage(usage gjson.Result) CacheUsage {
+	read := usage.Get("cache_read_input_tokens").Int()
+	return CacheUsage{
+		PromptTokens: usage.Get("input_tokens").Int() + read + usage.Get("cache_creation_input_tokens").Int(),
+		CachedTokens: read,
+	}
+}
+
+// OpenAICacheUsage reads a Chat Completions or Responses usage block.
+func OpenAICacheUsage(usage gjson.Result) CacheUsage {
+	if usage.Get("input_tokens").Exists() {
+		return CacheUsage{PromptTokens: usage.Get("input_tokens").Int(), CachedTokens: usage.Get("input_tokens_details.cached_tokens").Int()}
+	}
+	return CacheUsage{PromptTokens: usage.Get("prompt_tokens").Int(), CachedTokens: usage.Get("prompt_tokens_details.cached_tokens").Int()}
+}
+
+// GeminiCacheUsage reads a Gemini usageMetadata block. Gemini requests rely on the implicit
+// caching of the upstream: cache_control blocks are dropped when translating to Gemini and
+// no cachedContents resources are created, so only the reported counts are carried over.
+func GeminiCacheUsage(usage gjson.Result) CacheUsage {
+	return CacheUsage{PromptTokens: usage.Get("promptTokenCount").Int(), CachedTokens: usage.Get("cachedContentTokenCount").Int()}
+}
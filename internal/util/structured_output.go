@@ -0,0 +1,262 @@
+package util
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// StructuredOutputToolName is the tool Claude is forced to call when a client asks for
+// structured output. Its input becomes the message content returned to the client.
+const StructuredOutputToolName = "structured_output"
+
+// structuredOutputWrapKey holds non-object schemas inside the object Claude tools require.
+const structuredOutputWrapKey = "value"
+
+// maxSchemaRefDepth bounds $ref inlining so recursive schemas terminate.
+const maxSchemaRefDepth = 8
+
+// StructuredOutput describes the JSON output a client requested with response_format
+// (Chat Completions) or text.format (Responses).
+type StructuredOutput struct {
+	// Name is the schema name given by the client.
+	Name string
+	// Description is the optional schema description.
+	Description string
+	// Schema is the raw JSON schema; empty for json_object, which only requires valid JSON.
+	Schema string
+	// Strict reports whether the client asked for strict schema adherence.
+	Strict bool
+}
+
+// ChatCompletionsStructuredOutput returns the structured output requested by the
+// response_format field of a Chat Completions request, or nil when plain text is requested.
+func ChatCompletionsStructuredOutput(rawJSON []byte) *StructuredOutput {
+	rf := gjson.GetBytes(rawJSON, "response_format")
+	switch rf.Get("type").String() {
+	case "json_object":
+		return &StructuredOutput{}
+	case "json_schema":
+		js := rf.Get("json_schema")
+		return &StructuredOutput{
+			Name:        js.Get("name").String(),
+			Description: js.Get("description").String(),
+			Schema:      schemaRaw(js.Get("schema")),
+			Strict:      js.Get("strict").Bool(),
+		}
+	}
+	return nil
+}
+
+// ResponsesStructuredOutput returns the structured output requested by the text.format
+// field of a Responses request, or nil when plain text is requested.
+func ResponsesStructuredOutput(rawJSON []byte) *StructuredOutput {
+	format := gjson.GetBytes(rawJSON, "text.format")
+	switch format.Get("type").String() {
+	case "json_object":
+		return &StructuredOutput{}
+	case "json_schema":
+		return &StructuredOutput{
+			Name:        format.Get("name").String(),
+			Description: format.Get("description").String(),
+			Schema:      schemaRaw(format.Get("schema")),
+			Strict:      format.Get("strict").Bool(),
+		}
+	}
+	return nil
+}
+
+func schemaRaw(schema gjson.Result) string {
+	if !schema.IsObject() {
+		return ""
+	}
+	return schema.Raw
+}
+
+// GeminiResponseSchema converts the schema into a Gemini responseSchema: local $ref
+// pointers are inlined, then the result is cleaned with SanitizeSchemaForGemini.
+func (s *StructuredOutput) GeminiResponseSchema() string {
+	if s == nil || s.Schema == "" {
+		return ""
+	}
+	cleaned, err := SanitizeSchemaForGemini(InlineSchemaRefs(s.Schema))
+	if err != nil {
+		return ""
+	}
+	return cleaned
+}
+
+// ApplyGeminiStructuredOutput sets responseMimeType and responseSchema on the
+// generationConfig found under prefix ("" for Gemini, "request." for Gemini CLI).
+func ApplyGeminiStructuredOutput(out []byte, prefix string, so *StructuredOutput) []byte {
+	if so == nil {
+		return out
+	}
+	out, _ = sjson.SetBytes(out, prefix+"generationConfig.responseMimeType", "application/json")
+	if schema := so.GeminiResponseSchema(); schema != "" {
+		out, _ = sjson.SetRawBytes(out, prefix+"generationConfig.responseSchema", []byte(schema))
+	}
+	return out
+}
+
+// ApplyClaudeStructuredOutput adds the structured output tool to a Claude request and
+// forces the model to call it. Tool choice stays with the client when it named a specific
+// tool, and the request is left alone when the client disabled tool use; extended thinking
+// is disabled because Claude rejects it with forced tool use.
+func ApplyClaudeStructuredOutput(out string, so *StructuredOutput) string {
+	if so == nil || gjson.Get(out, "tool_choice.type").String() == "none" {
+		return out
+	}
+	description := "Return the final answer by calling this tool. Its input is delivered to the user as the response"
+	if so.Name != "" {
+		description += " (" + so.Name + ")"
+	}
+	description += "."
+	if so.Description != "" {
+		description += " " + so.Description
+	}
+	tool := `{"name":"","description":"","input_schema":{"type":"object"}}`
+	tool, _ = sjson.Set(tool, "name", StructuredOutputToolName)
+	tool, _ = sjson.Set(tool, "description", description)
+	if schema := so.claudeInputSchema(); schema != "" {
+		tool, _ = sjson.SetRaw(tool, "input_schema", schema)
+	}
+	if !gjson.Get(out, "tools").IsArray() {
+		out, _ = sjson.SetRaw(out, "tools", `[]`)
+	}
+	out, _ = sjson.SetRaw(out, "tools.-1", tool)
+
+	if gjson.Get(out, "tool_choice.type").String() == "tool" {
+		return out
+	}
+	if len(gjson.Get(out, "tools").Array()) == 1 {
+		out, _ = sjson.Set(out, "tool_choice", map[string]interface{}{"type": "tool", "name": StructuredOutputToolName})
+	} else {
+		out, _ = sjson.Set(out, "tool_choice", map[string]interface{}{"type": "any"})
+	}
+	out, _ = sjson.Delete(out, "thinking")
+	return out
+}
+
+// claudeInputSchema returns the tool input schema. Claude tool inputs must be objects, so
+// other schemas are wrapped in an object with a single "value" property.
+func (s *StructuredOutput) claudeInputSchema() string {
+	if s.Schema == "" {
+		return ""
+	}
+	if s.wrapsSchema() {
+		wrapped := `{"type":"object","properties":{},"required":["` + structuredOutputWrapKey + `"]}`
+		wrapped, _ = sjson.SetRaw(wrapped, "properties."+structuredOutputWrapKey, s.Schema)
+		if defs := gjson.Get(s.Schema, "$defs"); defs.Exists() {
+			wrapped, _ = sjson.SetRaw(wrapped, "$defs", defs.Raw)
+		}
+		return wrapped
+	}
+	return s.Schema
+}
+
+func (s *StructuredOutput) wrapsSchema() bool {
+	if s == nil || s.Schema == "" {
+		return false
+	}
+	typ := gjson.Get(s.Schema, "type")
+	return typ.Exists() && typ.String() != "object"
+}
+
+// StructuredOutputContent converts the input of the structured output tool call into the
+// JSON text returned to the client.
+func (s *StructuredOutput) StructuredOutputContent(input string) string {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		input = "{}"
+	}
+	if s.wrapsSchema() {
+		if value := gjson.Get(input, structuredOutputWrapKey); value.Exists() {
+			return value.Raw
+		}
+	}
+	return input
+}
+
+// StreamsStructuredOutput reports whether tool input deltas can be forwarded as content
+// deltas unchanged, which is not the case when the schema had to be wrapped.
+func (s *StructuredOutput) StreamsStructuredOutput() bool {
+	return s != nil && !s.wrapsSchema()
+}
+
+// InlineSchemaRefs replaces local $ref pointers (#/$defs/... and #/definitions/...) with
+// the schemas they point to and drops the definitions. Recursive references are cut off
+// after a few levels and left as unconstrained schemas.
+func InlineSchemaRefs(raw string) string {
+	if !strings.Contains(raw, "$ref") {
+		return raw
+	}
+	var root interface{}
+	if err := json.Unmarshal([]byte(raw), &root); err != nil {
+		return raw
+	}
+	inlined := inlineSchemaRefs(root, root, 0)
+	if m, ok := inlined.(map[string]interface{}); ok {
+		delete(m, "$defs")
+		delete(m, "definitions")
+	}
+	out, err := json.Marshal(inlined)
+	if err != nil {
+		return raw
+	}
+	return string(out)
+}
+
+func inlineSchemaRefs(node, root interface{}, depth int) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		if ref, ok := v["$ref"].(string); ok {
+			target := resolveSchemaRef(root, ref)
+			if target == nil || depth >= maxSchemaRefDepth {
+				return map[string]interface{}{}
+			}
+			return inlineSchemaRefs(target, root, depth+1)
+		}
+		cleaned := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			if key == "$defs" || key == "definitions" {
+				cleaned[key] = val
+				continue
+			}
+			cleaned[key] = inlineSchemaRefs(val, root, depth)
+		}
+		return cleaned
+	case []interface{}:
+		arr := make([]interface{}, len(v))
+		for i, item := range v {
+			arr[i] = inlineSchemaRefs(item, root, depth)
+		}
+		return arr
+	default:
+		return node
+	}
+}
+
+// resolveSchemaRef follows a local JSON pointer such as #/$defs/Item.
+func resolveSchemaRef(root interface{}, ref string) interface{} {
+	if ref == "#" {
+		return root
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil
+	}
+	current := root
+	for _, part := range strings.Split(ref[2:], "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if current, ok = m[part]; !ok {
+			return nil
+		}
+	}
+	return current
+}
@@ -15,6 +15,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -768,6 +769,7 @@ func (w *Watcher) SnapshotCoreAuths() []*coreauth.Auth {
 			if ck.BaseURL != "" {
 				attrs["base_url"] = ck.BaseURL
 			}
+			addRoutingAttributes(attrs, ck.Priority, ck.Weight)
 			proxyURL := strings.TrimSpace(ck.ProxyURL)
 			a := &coreauth.Auth{
 				ID:         id,
@@ -796,6 +798,7 @@ func (w *Watcher) SnapshotCoreAuths() []*coreauth.Auth {
 			if ck.BaseURL != "" {
 				attrs["base_url"] = ck.BaseURL
 			}
+			addRoutingAttributes(attrs, ck.Priority, ck.Weight)
 			proxyURL := strings.TrimSpace(ck.ProxyURL)
 			a := &coreauth.Auth{
 				ID:         id,
@@ -835,6 +838,7 @@ func (w *Watcher) SnapshotCoreAuths() []*coreauth.Auth {
 					if key != "" {
 						attrs["api_key"] = key
 					}
+					addRoutingAttributes(attrs, entry.Priority, entry.Weight)
 					if hash := computeOpenAICompatModelsHash(compat.Models); hash != "" {
 						attrs["models_hash"] = hash
 					}
@@ -969,6 +973,19 @@ func (w *Watcher) SnapshotCoreAuths() []*coreauth.Auth {
 	return out
 }
 
+// addRoutingAttributes records optional weighted routing settings on synthesized auth attributes.
+func addRoutingAttributes(attrs map[string]string, priority, weight int) {
+	if attrs == nil {
+		return
+	}
+	if priority != 0 {
+		attrs["priority"] = strconv.Itoa(priority)
+	}
+	if weight > 0 {
+		attrs["weight"] = strconv.Itoa(weight)
+	}
+}
+
 // buildCombinedClientMap merges file-based clients with API key clients from the cache.
 // buildCombinedClientMap removed
 
@@ -1103,6 +1120,12 @@ func describeOpenAICompatibilityUpdate(oldEntry, newEntry config.OpenAICompatibi
 	if oldModelCount != newModelCount {
 		details = append(details, fmt.Sprintf("models %d -> %d", oldModelCount, newModelCount))
 	}
+	for i := 0; i < len(oldEntry.APIKeyEntries) && i < len(newEntry.APIKeyEntries); i++ {
+		o, n := oldEntry.APIKeyEntries[i], newEntry.APIKeyEntries[i]
+		if o.Priority != n.Priority || o.Weight != n.Weight {
+			details = append(details, fmt.Sprintf("api-key-entries[%d].priority/weight %d/%d -> %d/%d", i, o.Priority, o.Weight, n.Priority, n.Weight))
+		}
+	}
 	if len(details) == 0 {
 		return ""
 	}
@@ -1192,12 +1215,64 @@ func buildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if oldCfg.UsageStatisticsEnabled != newCfg.UsageStatisticsEnabled {
 		changes = append(changes, fmt.Sprintf("usage-statistics-enabled: %t -> %t", oldCfg.UsageStatisticsEnabled, newCfg.UsageStatisticsEnabled))
 	}
+	if oldCfg.UsageRetention != newCfg.UsageRetention {
+		o, n := oldCfg.UsageRetention, newCfg.UsageRetention
+		changes = append(changes, fmt.Sprintf("usage-retention: minute-rollup-hours=%d hour-rollup-days=%d day-rollup-days=%d max-details=%d -> minute-rollup-hours=%d hour-rollup-days=%d day-rollup-days=%d max-details=%d", o.MinuteRollupHours, o.HourRollupDays, o.DayRollupDays, o.MaxDetails, n.MinuteRollupHours, n.HourRollupDays, n.DayRollupDays, n.MaxDetails))
+	}
+	if oldCfg.Pricing.DisableDefaults != newCfg.Pricing.DisableDefaults {
+		changes = append(changes, fmt.Sprintf("pricing.disable-defaults: %t -> %t", oldCfg.Pricing.DisableDefaults, newCfg.Pricing.DisableDefaults))
+	}
+	if !reflect.DeepEqual(oldCfg.Pricing.Models, newCfg.Pricing.Models) {
+		changes = append(changes, fmt.Sprintf("pricing.models: %d -> %d entries", len(oldCfg.Pricing.Models), len(newCfg.Pricing.Models)))
+	}
+	if oldCfg.Responses.Store != newCfg.Responses.Store {
+		o, n := oldCfg.Responses.Store, newCfg.Responses.Store
+		changes = append(changes, fmt.Sprintf("responses.store: disable=%t backend=%q ttl-seconds=%d max-entries=%d max-size-mb=%d -> disable=%t backend=%q ttl-seconds=%d max-entries=%d max-size-mb=%d", o.Disable, o.Backend, o.TTLSeconds, o.MaxEntries, o.MaxSizeMB, n.Disable, n.Backend, n.TTLSeconds, n.MaxEntries, n.MaxSizeMB))
+	}
+	if oldCfg.Batches != newCfg.Batches {
+		changes = append(changes, fmt.Sprintf("batches: concurrency=%d retention-hours=%d -> concurrency=%d retention-hours=%d", oldCfg.Batches.Concurrency, oldCfg.Batches.RetentionHours, newCfg.Batches.Concurrency, newCfg.Batches.RetentionHours))
+	}
+	if !reflect.DeepEqual(oldCfg.TrustedProxies, newCfg.TrustedProxies) {
+		changes = append(changes, fmt.Sprintf("trusted-proxies: %v -> %v", oldCfg.TrustedProxies, newCfg.TrustedProxies))
+	}
+	if !reflect.DeepEqual(oldCfg.UsageSinks, newCfg.UsageSinks) {
+		changes = append(changes, fmt.Sprintf("usage-sinks: %d -> %d sinks", len(oldCfg.UsageSinks), len(newCfg.UsageSinks)))
+	}
+	if oldCfg.UsageStatisticsFile != newCfg.UsageStatisticsFile {
+		changes = append(changes, fmt.Sprintf("usage-statistics-file: %s -> %s (applies after restart)", oldCfg.UsageStatisticsFile, newCfg.UsageStatisticsFile))
+	}
 	if oldCfg.RequestLog != newCfg.RequestLog {
 		changes = append(changes, fmt.Sprintf("request-log: %t -> %t", oldCfg.RequestLog, newCfg.RequestLog))
 	}
 	if oldCfg.RequestRetry != newCfg.RequestRetry {
 		changes = append(changes, fmt.Sprintf("request-retry: %d -> %d", oldCfg.RequestRetry, newCfg.RequestRetry))
 	}
+	if oldCfg.RequestRetryBackoff != newCfg.RequestRetryBackoff {
+		ob, nb := oldCfg.RequestRetryBackoff, newCfg.RequestRetryBackoff
+		changes = append(changes, fmt.Sprintf("request-retry-backoff: initial-ms=%d max-ms=%d multiplier=%g jitter=%g -> initial-ms=%d max-ms=%d multiplier=%g jitter=%g", ob.InitialMS, ob.MaxMS, ob.Multiplier, ob.Jitter, nb.InitialMS, nb.MaxMS, nb.Multiplier, nb.Jitter))
+	}
+	if oldCfg.Cooldowns != newCfg.Cooldowns {
+		oc, nc := oldCfg.Cooldowns, newCfg.Cooldowns
+		changes = append(changes, fmt.Sprintf("cooldowns: unauthorized=%ds payment-required=%ds rate-limited=%ds transient=%ds -> unauthorized=%ds payment-required=%ds rate-limited=%ds transient=%ds", oc.UnauthorizedSeconds, oc.PaymentRequiredSeconds, oc.RateLimitedSeconds, oc.TransientSeconds, nc.UnauthorizedSeconds, nc.PaymentRequiredSeconds, nc.RateLimitedSeconds, nc.TransientSeconds))
+	}
+	if !reflect.DeepEqual(oldCfg.ModelFallbacks, newCfg.ModelFallbacks) {
+		changes = append(changes, fmt.Sprintf("model-fallbacks: %d -> %d", len(oldCfg.ModelFallbacks), len(newCfg.ModelFallbacks)))
+	}
+	if oldCfg.Metrics != newCfg.Metrics {
+		changes = append(changes, fmt.Sprintf("metrics: enable=%t require-auth=%t -> enable=%t require-auth=%t", oldCfg.Metrics.Enable, oldCfg.Metrics.RequireAuth, newCfg.Metrics.Enable, newCfg.Metrics.RequireAuth))
+	}
+	if !reflect.DeepEqual(oldCfg.Tracing, newCfg.Tracing) {
+		changes = append(changes, fmt.Sprintf("tracing: enable=%t endpoint=%s sample-ratio=%g -> enable=%t endpoint=%s sample-ratio=%g", oldCfg.Tracing.Enable, oldCfg.Tracing.Endpoint, oldCfg.Tracing.SampleRatio, newCfg.Tracing.Enable, newCfg.Tracing.Endpoint, newCfg.Tracing.SampleRatio))
+	}
+	if !reflect.DeepEqual(oldCfg.APIKeyPolicies, newCfg.APIKeyPolicies) {
+		changes = append(changes, fmt.Sprintf("api-key-policies: %d -> %d", len(oldCfg.APIKeyPolicies), len(newCfg.APIKeyPolicies)))
+	}
+	if oldCfg.Routing.Strategy != newCfg.Routing.Strategy {
+		changes = append(changes, fmt.Sprintf("routing.strategy: %s -> %s", oldCfg.Routing.Strategy, newCfg.Routing.Strategy))
+	}
+	if oldCfg.PromptCaching != newCfg.PromptCaching {
+		changes = append(changes, fmt.Sprintf("prompt-caching: disable-auto-breakpoints=%t min-tokens=%d -> disable-auto-breakpoints=%t min-tokens=%d", oldCfg.PromptCaching.DisableAutoBreakpoints, oldCfg.PromptCaching.MinTokens, newCfg.PromptCaching.DisableAutoBreakpoints, newCfg.PromptCaching.MinTokens))
+	}
 	if oldCfg.ProxyURL != newCfg.ProxyURL {
 		changes = append(changes, fmt.Sprintf("proxy-url: %s -> %s", oldCfg.ProxyURL, newCfg.ProxyURL))
 	}
@@ -1241,6 +1316,9 @@ func buildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			if strings.TrimSpace(o.APIKey) != strings.TrimSpace(n.APIKey) {
 				changes = append(changes, fmt.Sprintf("claude[%d].api-key: updated", i))
 			}
+			if o.Priority != n.Priority || o.Weight != n.Weight {
+				changes = append(changes, fmt.Sprintf("claude[%d].priority/weight: %d/%d -> %d/%d", i, o.Priority, o.Weight, n.Priority, n.Weight))
+			}
 		}
 	}
 
@@ -1263,6 +1341,9 @@ func buildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			if strings.TrimSpace(o.APIKey) != strings.TrimSpace(n.APIKey) {
 				changes = append(changes, fmt.Sprintf("codex[%d].api-key: updated", i))
 			}
+			if o.Priority != n.Priority || o.Weight != n.Weight {
+				changes = append(changes, fmt.Sprintf("codex[%d].priority/weight: %d/%d -> %d/%d", i, o.Priority, o.Weight, n.Priority, n.Weight))
+			}
 		}
 	}
 
@@ -0,0 +1,268 @@
+// Package batches provides HTTP handlers for the batch APIs. It implements Anthropic's
+// /v1/messages/batches endpoints and OpenAI's /v1/batches and /v1/files endpoints on top of
+// the batch runner in sdk/cliproxy/batches, which executes the requests of every batch in
+// the background through the auth manager and keeps their results in the batch store.
+package batches
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/access/policy"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	corebatches "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/batches"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// BatchAPIHandler contains the handlers for the Anthropic and OpenAI batch endpoints.
+type BatchAPIHandler struct {
+	*handlers.BaseAPIHandler
+	runner   *corebatches.Runner
+	policies *policy.Enforcer
+}
+
+// NewBatchAPIHandler creates the batch handlers and starts processing stored batches.
+// Requests of a batch are checked against the policy of the API key that created it
+// through policies, which may be nil.
+//
+// Parameters:
+//   - apiHandlers: The base API handlers instance
+//   - policies: The API key policy enforcer, or nil
+//
+// Returns:
+//   - *BatchAPIHandler: A new batch API handlers instance
+func NewBatchAPIHandler(apiHandlers *handlers.BaseAPIHandler, policies *policy.Enforcer) *BatchAPIHandler {
+	h := &BatchAPIHandler{
+		BaseAPIHandler: apiHandlers,
+		policies:       policies,
+	}
+	h.runner = corebatches.NewRunner(corebatches.DefaultStore(), h.execute, corebatches.Options{
+		Concurrency: func() int {
+			if h.Cfg == nil {
+				return 0
+			}
+			return h.Cfg.Batches.Concurrency
+		},
+		Retention: func() time.Duration {
+			if h.Cfg == nil {
+				return 0
+			}
+			return time.Duration(h.Cfg.Batches.RetentionHours) * time.Hour
+		},
+	})
+	h.runner.Start()
+	return h
+}
+
+// Stop stops processing batches, waiting for in-flight requests until ctx is done.
+// Unfinished batches are resumed by the next handler created on the same store.
+func (h *BatchAPIHandler) Stop(ctx context.Context) error {
+	return h.runner.Stop(ctx)
+}
+
+// execute runs one batch request through the auth manager like the matching synchronous
+// endpoint would, attributing usage to the API key that created the batch.
+func (h *BatchAPIHandler) execute(ctx context.Context, batch *corebatches.Batch, request corebatches.Request) corebatches.Response {
+	body, _ := sjson.DeleteBytes(request.Body, "stream")
+	modelName := gjson.GetBytes(body, "model").String()
+
+	apiKey := batch.APIKey
+	if apiKey == "" && batch.Owner != "" {
+		// The key is not persisted; batches resumed after a restart recover it from the
+		// owner hash so policies and usage attribution keep applying.
+		key, ok := h.resolveOwnerKey(batch.Owner)
+		if !ok {
+			return errorResponse(batch.Dialect, &interfaces.ErrorMessage{StatusCode: http.StatusUnauthorized, Error: errors.New("the API key that created this batch is no longer configured")}, 0)
+		}
+		apiKey = key
+	}
+	if apiKey != "" {
+		release, violation := h.policies.Acquire(apiKey, modelName)
+		if violation != nil {
+			return errorResponse(batch.Dialect, &interfaces.ErrorMessage{StatusCode: violation.StatusCode, Error: errors.New(violation.Message)}, violation.RetryAfter)
+		}
+		defer release()
+	}
+
+	background := backgroundContext(ctx, batch.Endpoint, apiKey)
+	if apiKey != "" {
+		background.Set(handlers.ModelFilterKey, func(model string) bool {
+			return h.policies.CheckModel(apiKey, model) == nil
+		})
+	}
+	ctx = context.WithValue(ctx, "gin", background)
+	var resp []byte
+	var errMsg *interfaces.ErrorMessage
+	switch batch.Endpoint {
+	case claudeEndpoint:
+		resp, errMsg = h.ExecuteWithAuthManager(ctx, constant.Claude, modelName, body, "")
+	case chatCompletionsEndpoint:
+		resp, errMsg = h.ExecuteWithAuthManager(ctx, constant.OpenAI, modelName, body, "")
+	case responsesEndpoint:
+		resp, errMsg = h.ExecuteWithAuthManager(ctx, constant.OpenaiResponse, modelName, body, "")
+	case embeddingsEndpoint:
+		resp, errMsg = h.ExecuteEmbedWithAuthManager(ctx, constant.OpenAI, modelName, body)
+	default:
+		errMsg = &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("unsupported batch endpoint %s", batch.Endpoint)}
+	}
+	if errMsg != nil {
+		return errorResponse(batch.Dialect, errMsg, retryAfter(errMsg))
+	}
+	return corebatches.Response{StatusCode: http.StatusOK, Body: resp}
+}
+
+// resolveOwnerKey finds the configured client API key whose hash is owner.
+func (h *BatchAPIHandler) resolveOwnerKey(owner string) (string, bool) {
+	candidates := h.policies.Keys()
+	if h.Cfg != nil {
+		candidates = append(candidates, h.Cfg.APIKeys...)
+		for _, provider := range h.Cfg.Access.Providers {
+			candidates = append(candidates, provider.APIKeys...)
+		}
+	}
+	for _, key := range candidates {
+		if key != "" && keyHash(key) == owner {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// checkModels writes a policy error and returns false when the calling client may not use
+// the model of one of the requests, so a batch is rejected up front rather than failing
+// request by request.
+func (h *BatchAPIHandler) checkModels(c *gin.Context, dialect string, requests []corebatches.Request, field func(int) string) bool {
+	apiKey := clientAPIKey(c)
+	for i, request := range requests {
+		if violation := h.policies.CheckModel(apiKey, gjson.GetBytes(request.Body, "model").String()); violation != nil {
+			writeError(c, dialect, violation.StatusCode, fmt.Sprintf("%s: %s", field(i), violation.Message))
+			return false
+		}
+	}
+	return true
+}
+
+// backgroundContext builds the Gin context the usage plugins read the client API key and
+// request path from, since batch requests run after the client request has returned.
+func backgroundContext(ctx context.Context, endpoint, apiKey string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequestWithContext(ctx, http.MethodPost, endpoint, nil)
+	if apiKey != "" {
+		c.Set("apiKey", apiKey)
+	}
+	return c
+}
+
+// errorResponse renders a failed request in the error format of the batch dialect.
+func errorResponse(dialect string, errMsg *interfaces.ErrorMessage, wait time.Duration) corebatches.Response {
+	status := errMsg.StatusCode
+	if status <= 0 {
+		status = http.StatusInternalServerError
+	}
+	return corebatches.Response{StatusCode: status, Body: handlers.BuildErrorResponseBody(dialect, errMsg), RetryAfter: wait}
+}
+
+// retryAfter returns the credential cooldown reported with errMsg, if any.
+func retryAfter(errMsg *interfaces.ErrorMessage) time.Duration {
+	if errMsg == nil || errMsg.Addon == nil {
+		return 0
+	}
+	seconds, err := strconv.Atoi(errMsg.Addon.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// lookupBatch loads the batch named by the id path parameter, writing a 404 when it is
+// unknown, was created through the other dialect or belongs to another client.
+func (h *BatchAPIHandler) lookupBatch(c *gin.Context, dialect string) (*corebatches.Batch, bool) {
+	id := strings.TrimSpace(c.Param("id"))
+	batch, err := h.runner.Get(c.Request.Context(), id)
+	if err == nil && (batch.Dialect != dialect || batch.Owner != batchOwner(c)) {
+		err = corebatches.ErrNotFound
+	}
+	if err != nil {
+		if errors.Is(err, corebatches.ErrNotFound) {
+			writeError(c, dialect, http.StatusNotFound, fmt.Sprintf("batch with id '%s' not found", id))
+		} else {
+			writeError(c, dialect, http.StatusInternalServerError, fmt.Sprintf("failed to load batch: %v", err))
+		}
+		return nil, false
+	}
+	return batch, true
+}
+
+// listBatches returns the batches of the calling client created through dialect, newest first.
+func (h *BatchAPIHandler) listBatches(c *gin.Context, dialect string) ([]*corebatches.Batch, bool) {
+	list, err := h.runner.List(c.Request.Context())
+	if err != nil {
+		writeError(c, dialect, http.StatusInternalServerError, fmt.Sprintf("failed to list batches: %v", err))
+		return nil, false
+	}
+	owner := batchOwner(c)
+	visible := list[:0]
+	for _, batch := range list {
+		if batch.Dialect == dialect && batch.Owner == owner {
+			visible = append(visible, batch)
+		}
+	}
+	return visible, true
+}
+
+// writeError writes an error body in the format of dialect.
+func writeError(c *gin.Context, dialect string, status int, message string) {
+	if dialect == constant.Claude {
+		c.Data(status, "application/json", handlers.BuildErrorResponseBody(dialect, &interfaces.ErrorMessage{StatusCode: status, Error: errors.New(message)}))
+		return
+	}
+	errType := "invalid_request_error"
+	if status >= http.StatusInternalServerError {
+		errType = "server_error"
+	}
+	c.JSON(status, handlers.ErrorResponse{Error: handlers.ErrorDetail{Message: message, Type: errType}})
+}
+
+// batchOwner identifies the authenticated client so batches and files are only visible to
+// the key that created them. The key itself is not kept.
+func batchOwner(c *gin.Context) string {
+	key := clientAPIKey(c)
+	if key == "" {
+		return ""
+	}
+	return keyHash(key)
+}
+
+func keyHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func clientAPIKey(c *gin.Context) string {
+	value, exists := c.Get("apiKey")
+	if !exists {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// pageLimit parses the limit query parameter, clamping it to [1, maxLimit].
+func pageLimit(c *gin.Context, defaultLimit, maxLimit int) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		return defaultLimit
+	}
+	return min(limit, maxLimit)
+}
@@ -0,0 +1,249 @@
+package batches
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	corebatches "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/batches"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	claudeEndpoint = "/v1/messages"
+	// maxClaudeBatchRequests is the number of requests Anthropic accepts in one batch.
+	maxClaudeBatchRequests = 100000
+)
+
+// claudeCustomID matches the custom IDs Anthropic accepts.
+var claudeCustomID = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// CreateMessageBatch handles POST /v1/messages/batches. Every request carries a custom_id
+// and the params of a /v1/messages call.
+func (h *BatchAPIHandler) CreateMessageBatch(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		writeError(c, constant.Claude, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	items := gjson.GetBytes(rawJSON, "requests")
+	if !items.IsArray() || len(items.Array()) == 0 {
+		writeError(c, constant.Claude, http.StatusBadRequest, "requests: at least one request is required")
+		return
+	}
+	if len(items.Array()) > maxClaudeBatchRequests {
+		writeError(c, constant.Claude, http.StatusBadRequest, fmt.Sprintf("requests: a batch holds at most %d requests", maxClaudeBatchRequests))
+		return
+	}
+	requests := make([]corebatches.Request, 0, len(items.Array()))
+	seen := make(map[string]bool, len(items.Array()))
+	for i, item := range items.Array() {
+		customID := item.Get("custom_id").String()
+		switch {
+		case !claudeCustomID.MatchString(customID):
+			writeError(c, constant.Claude, http.StatusBadRequest, fmt.Sprintf("requests.%d.custom_id: must be 1 to 64 letters, digits, underscores or hyphens", i))
+			return
+		case seen[customID]:
+			writeError(c, constant.Claude, http.StatusBadRequest, fmt.Sprintf("requests.%d.custom_id: duplicate custom_id %q", i, customID))
+			return
+		}
+		seen[customID] = true
+		params := item.Get("params")
+		if !params.IsObject() || params.Get("model").String() == "" {
+			writeError(c, constant.Claude, http.StatusBadRequest, fmt.Sprintf("requests.%d.params.model: field required", i))
+			return
+		}
+		requests = append(requests, corebatches.Request{CustomID: customID, Body: json.RawMessage(params.Raw)})
+	}
+
+	if !h.checkModels(c, constant.Claude, requests, func(i int) string { return fmt.Sprintf("requests.%d.params.model", i) }) {
+		return
+	}
+
+	batch := &corebatches.Batch{
+		ID:       corebatches.NewID("msgbatch_"),
+		Owner:    batchOwner(c),
+		Dialect:  constant.Claude,
+		Endpoint: claudeEndpoint,
+		APIKey:   clientAPIKey(c),
+	}
+	batch, err = h.runner.Submit(c.Request.Context(), batch, requests)
+	if err != nil {
+		writeError(c, constant.Claude, http.StatusInternalServerError, fmt.Sprintf("failed to store batch: %v", err))
+		return
+	}
+	c.JSON(http.StatusOK, h.claudeBatchObject(c, batch))
+}
+
+// ListMessageBatches handles GET /v1/messages/batches, newest first, paginated with
+// limit, before_id and after_id.
+func (h *BatchAPIHandler) ListMessageBatches(c *gin.Context) {
+	list, ok := h.listBatches(c, constant.Claude)
+	if !ok {
+		return
+	}
+	limit := pageLimit(c, 20, 1000)
+	start, end := 0, len(list)
+	if afterID := c.Query("after_id"); afterID != "" {
+		start = indexAfter(list, afterID)
+	} else if beforeID := c.Query("before_id"); beforeID != "" {
+		end = indexOf(list, beforeID)
+		start = max(end-limit, 0)
+	}
+	page := list[start:min(start+limit, end)]
+	hasMore := start+len(page) < end || (c.Query("before_id") != "" && start > 0)
+
+	data := make([]gin.H, 0, len(page))
+	for _, batch := range page {
+		data = append(data, h.claudeBatchObject(c, batch))
+	}
+	response := gin.H{"data": data, "has_more": hasMore, "first_id": nil, "last_id": nil}
+	if len(page) > 0 {
+		response["first_id"] = page[0].ID
+		response["last_id"] = page[len(page)-1].ID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetMessageBatch handles GET /v1/messages/batches/{id}.
+func (h *BatchAPIHandler) GetMessageBatch(c *gin.Context) {
+	batch, ok := h.lookupBatch(c, constant.Claude)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, h.claudeBatchObject(c, batch))
+}
+
+// CancelMessageBatch handles POST /v1/messages/batches/{id}/cancel.
+func (h *BatchAPIHandler) CancelMessageBatch(c *gin.Context) {
+	batch, ok := h.lookupBatch(c, constant.Claude)
+	if !ok {
+		return
+	}
+	batch, err := h.runner.Cancel(c.Request.Context(), batch.ID)
+	if err != nil {
+		writeError(c, constant.Claude, http.StatusInternalServerError, fmt.Sprintf("failed to cancel batch: %v", err))
+		return
+	}
+	c.JSON(http.StatusOK, h.claudeBatchObject(c, batch))
+}
+
+// DeleteMessageBatch handles DELETE /v1/messages/batches/{id}. Batches still processing
+// must be canceled first.
+func (h *BatchAPIHandler) DeleteMessageBatch(c *gin.Context) {
+	batch, ok := h.lookupBatch(c, constant.Claude)
+	if !ok {
+		return
+	}
+	if err := h.runner.Delete(c.Request.Context(), batch.ID); err != nil {
+		if errors.Is(err, corebatches.ErrNotEnded) {
+			writeError(c, constant.Claude, http.StatusBadRequest, "batch is still processing; cancel it before deleting it")
+			return
+		}
+		if !errors.Is(err, corebatches.ErrNotFound) {
+			writeError(c, constant.Claude, http.StatusInternalServerError, fmt.Sprintf("failed to delete batch: %v", err))
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"id": batch.ID, "type": "message_batch_deleted"})
+}
+
+// MessageBatchResults handles GET /v1/messages/batches/{id}/results, streaming one JSON
+// line per request in request order once the batch has ended.
+func (h *BatchAPIHandler) MessageBatchResults(c *gin.Context) {
+	batch, ok := h.lookupBatch(c, constant.Claude)
+	if !ok {
+		return
+	}
+	if !batch.Status.Ended() {
+		writeError(c, constant.Claude, http.StatusBadRequest, "batch is still processing; results are available once it has ended")
+		return
+	}
+	results, err := h.runner.Results(c.Request.Context(), batch.ID)
+	if err != nil {
+		writeError(c, constant.Claude, http.StatusInternalServerError, fmt.Sprintf("failed to load results: %v", err))
+		return
+	}
+	var buf bytes.Buffer
+	for _, result := range results {
+		var outcome gin.H
+		switch result.Outcome {
+		case corebatches.OutcomeSucceeded:
+			outcome = gin.H{"type": "succeeded", "message": json.RawMessage(result.Body)}
+		case corebatches.OutcomeErrored:
+			outcome = gin.H{"type": "errored", "error": json.RawMessage(result.Body)}
+		default:
+			outcome = gin.H{"type": string(result.Outcome)}
+		}
+		line, _ := json.Marshal(gin.H{"custom_id": result.CustomID, "result": outcome})
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	c.Data(http.StatusOK, "application/x-jsonl", buf.Bytes())
+}
+
+// claudeBatchObject renders a batch as an Anthropic message_batch object.
+func (h *BatchAPIHandler) claudeBatchObject(c *gin.Context, batch *corebatches.Batch) gin.H {
+	status := "in_progress"
+	switch {
+	case batch.Status.Ended():
+		status = "ended"
+	case batch.Status == corebatches.StatusCanceling:
+		status = "canceling"
+	}
+	object := gin.H{
+		"id":                  batch.ID,
+		"type":                "message_batch",
+		"processing_status":   status,
+		"request_counts":      claudeRequestCounts(batch.Counts),
+		"ended_at":            rfc3339(batch.EndedAt),
+		"created_at":          batch.CreatedAt.UTC().Format(time.RFC3339Nano),
+		"expires_at":          batch.ExpiresAt.UTC().Format(time.RFC3339Nano),
+		"archived_at":         nil,
+		"cancel_initiated_at": rfc3339(batch.CancelRequestedAt),
+		"results_url":         nil,
+	}
+	if batch.Status.Ended() {
+		object["results_url"] = h.ExternalBaseURL(c) + claudeEndpoint + "/batches/" + batch.ID + "/results"
+	}
+	return object
+}
+
+func claudeRequestCounts(counts corebatches.Counts) gin.H {
+	return gin.H{
+		"processing": counts.Processing(),
+		"succeeded":  counts.Succeeded,
+		"errored":    counts.Errored,
+		"canceled":   counts.Canceled,
+		"expired":    counts.Expired,
+	}
+}
+
+// rfc3339 formats an optional timestamp, rendering nil as JSON null.
+func rfc3339(at *time.Time) any {
+	if at == nil {
+		return nil
+	}
+	return at.UTC().Format(time.RFC3339Nano)
+}
+
+// indexOf returns the position of the batch with id in list, or len(list) when absent.
+func indexOf(list []*corebatches.Batch, id string) int {
+	for i, batch := range list {
+		if batch.ID == id {
+			return i
+		}
+	}
+	return len(list)
+}
+
+// indexAfter returns the position following the batch with id in list, or len(list).
+func indexAfter(list []*corebatches.Batch, id string) int {
+	return min(indexOf(list, id)+1, len(list))
+}
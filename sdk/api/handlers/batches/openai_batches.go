@@ -0,0 +1,283 @@
+package batches
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	corebatches "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/batches"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	chatCompletionsEndpoint = "/v1/chat/completions"
+	responsesEndpoint       = "/v1/responses"
+	embeddingsEndpoint      = "/v1/embeddings"
+	// maxOpenAIBatchRequests is the number of requests OpenAI accepts in one batch.
+	maxOpenAIBatchRequests = 50000
+	// maxBatchMetadata is the number of metadata pairs OpenAI accepts.
+	maxBatchMetadata = 16
+)
+
+// CreateBatch handles POST /v1/batches. The requests are read from a JSONL file uploaded
+// with purpose "batch"; every line names the batch endpoint in its url.
+func (h *BatchAPIHandler) CreateBatch(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		writeError(c, constant.OpenAI, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	root := gjson.ParseBytes(rawJSON)
+	endpoint := root.Get("endpoint").String()
+	switch endpoint {
+	case chatCompletionsEndpoint, responsesEndpoint, embeddingsEndpoint:
+	default:
+		writeError(c, constant.OpenAI, http.StatusBadRequest, fmt.Sprintf("endpoint must be one of %s, %s or %s", chatCompletionsEndpoint, responsesEndpoint, embeddingsEndpoint))
+		return
+	}
+	if window := root.Get("completion_window").String(); window != "24h" {
+		writeError(c, constant.OpenAI, http.StatusBadRequest, "completion_window must be 24h")
+		return
+	}
+	metadata := make(map[string]string)
+	root.Get("metadata").ForEach(func(key, value gjson.Result) bool {
+		metadata[key.String()] = value.String()
+		return true
+	})
+	if len(metadata) > maxBatchMetadata {
+		writeError(c, constant.OpenAI, http.StatusBadRequest, fmt.Sprintf("metadata holds at most %d pairs", maxBatchMetadata))
+		return
+	}
+
+	inputFileID := root.Get("input_file_id").String()
+	store := h.runner.Store()
+	file, err := store.GetFile(c.Request.Context(), inputFileID)
+	if err == nil && file.Owner != batchOwner(c) {
+		err = corebatches.ErrNotFound
+	}
+	if err != nil {
+		if errors.Is(err, corebatches.ErrNotFound) {
+			writeError(c, constant.OpenAI, http.StatusBadRequest, fmt.Sprintf("input file '%s' not found", inputFileID))
+		} else {
+			writeError(c, constant.OpenAI, http.StatusInternalServerError, fmt.Sprintf("failed to load input file: %v", err))
+		}
+		return
+	}
+	if file.Purpose != "batch" {
+		writeError(c, constant.OpenAI, http.StatusBadRequest, fmt.Sprintf("input file '%s' was not uploaded with purpose batch", inputFileID))
+		return
+	}
+	content, err := store.ReadFile(c.Request.Context(), inputFileID)
+	if err != nil {
+		writeError(c, constant.OpenAI, http.StatusInternalServerError, fmt.Sprintf("failed to read input file: %v", err))
+		return
+	}
+	requests, err := parseBatchInput(content, endpoint)
+	if err != nil {
+		writeError(c, constant.OpenAI, http.StatusBadRequest, fmt.Sprintf("input file '%s': %v", inputFileID, err))
+		return
+	}
+	if !h.checkModels(c, constant.OpenAI, requests, func(i int) string {
+		return fmt.Sprintf("input file '%s': request %s", inputFileID, requests[i].CustomID)
+	}) {
+		return
+	}
+
+	batch := &corebatches.Batch{
+		ID:               corebatches.NewID("batch_"),
+		Owner:            batchOwner(c),
+		Dialect:          constant.OpenAI,
+		Endpoint:         endpoint,
+		InputFileID:      inputFileID,
+		CompletionWindow: "24h",
+		APIKey:           clientAPIKey(c),
+	}
+	if len(metadata) > 0 {
+		batch.Metadata = metadata
+	}
+	batch, err = h.runner.Submit(c.Request.Context(), batch, requests)
+	if err != nil {
+		writeError(c, constant.OpenAI, http.StatusInternalServerError, fmt.Sprintf("failed to store batch: %v", err))
+		return
+	}
+	c.JSON(http.StatusOK, openAIBatchObject(batch))
+}
+
+// parseBatchInput reads the requests of an OpenAI batch input file.
+func parseBatchInput(content []byte, endpoint string) ([]corebatches.Request, error) {
+	var requests []corebatches.Request
+	seen := make(map[string]bool)
+	for number, line := range bytes.Split(content, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if !gjson.ValidBytes(line) {
+			return nil, fmt.Errorf("line %d: invalid JSON", number+1)
+		}
+		item := gjson.ParseBytes(line)
+		customID := item.Get("custom_id").String()
+		switch {
+		case customID == "":
+			return nil, fmt.Errorf("line %d: custom_id is required", number+1)
+		case seen[customID]:
+			return nil, fmt.Errorf("line %d: duplicate custom_id %q", number+1, customID)
+		case !strings.EqualFold(item.Get("method").String(), http.MethodPost):
+			return nil, fmt.Errorf("line %d: method must be POST", number+1)
+		case item.Get("url").String() != endpoint:
+			return nil, fmt.Errorf("line %d: url must match the batch endpoint %s", number+1, endpoint)
+		case !item.Get("body").IsObject() || item.Get("body.model").String() == "":
+			return nil, fmt.Errorf("line %d: body.model is required", number+1)
+		}
+		seen[customID] = true
+		requests = append(requests, corebatches.Request{CustomID: customID, Body: json.RawMessage(item.Get("body").Raw)})
+	}
+	switch {
+	case len(requests) == 0:
+		return nil, errors.New("no requests found")
+	case len(requests) > maxOpenAIBatchRequests:
+		return nil, fmt.Errorf("a batch holds at most %d requests", maxOpenAIBatchRequests)
+	}
+	return requests, nil
+}
+
+// ListBatches handles GET /v1/batches, newest first, paginated with limit and after.
+func (h *BatchAPIHandler) ListBatches(c *gin.Context) {
+	list, ok := h.listBatches(c, constant.OpenAI)
+	if !ok {
+		return
+	}
+	start := 0
+	if after := c.Query("after"); after != "" {
+		start = indexAfter(list, after)
+	}
+	page := list[start:min(start+pageLimit(c, 20, 100), len(list))]
+
+	data := make([]gin.H, 0, len(page))
+	for _, batch := range page {
+		data = append(data, openAIBatchObject(batch))
+	}
+	response := gin.H{"object": "list", "data": data, "has_more": start+len(page) < len(list), "first_id": nil, "last_id": nil}
+	if len(page) > 0 {
+		response["first_id"] = page[0].ID
+		response["last_id"] = page[len(page)-1].ID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetBatch handles GET /v1/batches/{id}.
+func (h *BatchAPIHandler) GetBatch(c *gin.Context) {
+	batch, ok := h.lookupBatch(c, constant.OpenAI)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, openAIBatchObject(batch))
+}
+
+// CancelBatch handles POST /v1/batches/{id}/cancel.
+func (h *BatchAPIHandler) CancelBatch(c *gin.Context) {
+	batch, ok := h.lookupBatch(c, constant.OpenAI)
+	if !ok {
+		return
+	}
+	batch, err := h.runner.Cancel(c.Request.Context(), batch.ID)
+	if err != nil {
+		writeError(c, constant.OpenAI, http.StatusInternalServerError, fmt.Sprintf("failed to cancel batch: %v", err))
+		return
+	}
+	c.JSON(http.StatusOK, openAIBatchObject(batch))
+}
+
+// openAIBatchObject renders a batch as an OpenAI batch object.
+func openAIBatchObject(batch *corebatches.Batch) gin.H {
+	status := string(batch.Status)
+	switch batch.Status {
+	case corebatches.StatusCanceling:
+		status = "cancelling"
+	case corebatches.StatusCanceled:
+		status = "cancelled"
+	}
+	counts := batch.Counts
+	object := gin.H{
+		"id":                batch.ID,
+		"object":            "batch",
+		"endpoint":          batch.Endpoint,
+		"errors":            nil,
+		"input_file_id":     batch.InputFileID,
+		"completion_window": batch.CompletionWindow,
+		"status":            status,
+		"output_file_id":    nil,
+		"error_file_id":     nil,
+		"created_at":        batch.CreatedAt.Unix(),
+		"in_progress_at":    batch.CreatedAt.Unix(),
+		"expires_at":        batch.ExpiresAt.Unix(),
+		"finalizing_at":     nil,
+		"completed_at":      nil,
+		"failed_at":         nil,
+		"expired_at":        nil,
+		"cancelling_at":     unixTime(batch.CancelRequestedAt),
+		"cancelled_at":      nil,
+		"request_counts": gin.H{
+			"total":     counts.Total,
+			"completed": counts.Succeeded,
+			"failed":    counts.Errored + counts.Canceled + counts.Expired,
+		},
+		"metadata": batch.Metadata,
+	}
+	if !batch.Status.Ended() {
+		return object
+	}
+	switch batch.Status {
+	case corebatches.StatusCompleted:
+		object["completed_at"] = unixTime(batch.EndedAt)
+	case corebatches.StatusCanceled:
+		object["cancelled_at"] = unixTime(batch.EndedAt)
+	case corebatches.StatusExpired:
+		object["expired_at"] = unixTime(batch.EndedAt)
+	}
+	if counts.Succeeded > 0 {
+		object["output_file_id"] = outputFileID(batch.ID)
+	}
+	if counts.Errored+counts.Canceled+counts.Expired > 0 {
+		object["error_file_id"] = errorFileID(batch.ID)
+	}
+	return object
+}
+
+// openAIResultLines renders the results of a batch as the lines of its output file, or of
+// its error file when errorsFile is set.
+func openAIResultLines(results []corebatches.Result, errorsFile bool) []byte {
+	var buf bytes.Buffer
+	for _, result := range results {
+		if (result.Outcome == corebatches.OutcomeSucceeded) == errorsFile {
+			continue
+		}
+		line := gin.H{"id": result.ID, "custom_id": result.CustomID, "response": nil, "error": nil}
+		switch result.Outcome {
+		case corebatches.OutcomeSucceeded, corebatches.OutcomeErrored:
+			line["response"] = gin.H{"status_code": result.StatusCode, "request_id": result.ID, "body": json.RawMessage(result.Body)}
+		case corebatches.OutcomeCanceled:
+			line["error"] = gin.H{"code": "batch_cancelled", "message": "This request was not executed because the batch was cancelled."}
+		case corebatches.OutcomeExpired:
+			line["error"] = gin.H{"code": "batch_expired", "message": "This request could not be executed before the completion window expired."}
+		}
+		data, _ := json.Marshal(line)
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// unixTime formats an optional timestamp as Unix seconds, rendering nil as JSON null.
+func unixTime(at *time.Time) any {
+	if at == nil {
+		return nil
+	}
+	return at.Unix()
+}
@@ -0,0 +1,230 @@
+package batches
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	corebatches "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/batches"
+)
+
+const (
+	// maxFileBytes is the largest batch input file OpenAI accepts.
+	maxFileBytes = 200 << 20
+
+	outputFileSuffix = "-output"
+	errorFileSuffix  = "-errors"
+)
+
+// The output and error files of a batch are not stored; they are rendered from its results
+// under IDs derived from the batch ID.
+
+func outputFileID(batchID string) string {
+	return "file-" + strings.TrimPrefix(batchID, "batch_") + outputFileSuffix
+}
+
+func errorFileID(batchID string) string {
+	return "file-" + strings.TrimPrefix(batchID, "batch_") + errorFileSuffix
+}
+
+// resultFileBatch returns the batch ID an output or error file ID was derived from.
+func resultFileBatch(fileID string) (batchID string, errorsFile bool, ok bool) {
+	rest, found := strings.CutPrefix(fileID, "file-")
+	if !found {
+		return "", false, false
+	}
+	if id, cut := strings.CutSuffix(rest, outputFileSuffix); cut {
+		return "batch_" + id, false, true
+	}
+	if id, cut := strings.CutSuffix(rest, errorFileSuffix); cut {
+		return "batch_" + id, true, true
+	}
+	return "", false, false
+}
+
+// UploadFile handles POST /v1/files. Only batch input files (purpose "batch") are accepted.
+func (h *BatchAPIHandler) UploadFile(c *gin.Context) {
+	purpose := c.PostForm("purpose")
+	if purpose != "batch" {
+		writeError(c, constant.OpenAI, http.StatusBadRequest, "purpose must be batch; only batch input files are supported")
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		writeError(c, constant.OpenAI, http.StatusBadRequest, "file is required")
+		return
+	}
+	if header.Size > maxFileBytes {
+		writeError(c, constant.OpenAI, http.StatusRequestEntityTooLarge, fmt.Sprintf("file exceeds the %d MB limit", maxFileBytes>>20))
+		return
+	}
+	src, err := header.Open()
+	if err != nil {
+		writeError(c, constant.OpenAI, http.StatusBadRequest, fmt.Sprintf("failed to read file: %v", err))
+		return
+	}
+	content, err := io.ReadAll(src)
+	_ = src.Close()
+	if err != nil {
+		writeError(c, constant.OpenAI, http.StatusBadRequest, fmt.Sprintf("failed to read file: %v", err))
+		return
+	}
+	if len(content) == 0 {
+		writeError(c, constant.OpenAI, http.StatusBadRequest, "file is empty")
+		return
+	}
+
+	file := &corebatches.File{
+		ID:        corebatches.NewID("file-"),
+		Owner:     batchOwner(c),
+		Filename:  header.Filename,
+		Purpose:   purpose,
+		Bytes:     int64(len(content)),
+		CreatedAt: time.Now(),
+	}
+	if err = h.runner.Store().PutFile(c.Request.Context(), file, content); err != nil {
+		writeError(c, constant.OpenAI, http.StatusInternalServerError, fmt.Sprintf("failed to store file: %v", err))
+		return
+	}
+	c.JSON(http.StatusOK, fileObject(file))
+}
+
+// ListFiles handles GET /v1/files, optionally filtered by purpose. Only uploaded files are
+// listed; output and error files are reached through their batch.
+func (h *BatchAPIHandler) ListFiles(c *gin.Context) {
+	list, err := h.runner.Store().ListFiles(c.Request.Context())
+	if err != nil {
+		writeError(c, constant.OpenAI, http.StatusInternalServerError, fmt.Sprintf("failed to list files: %v", err))
+		return
+	}
+	owner := batchOwner(c)
+	purpose := c.Query("purpose")
+	data := make([]gin.H, 0, len(list))
+	for _, file := range list {
+		if file.Owner == owner && (purpose == "" || file.Purpose == purpose) {
+			data = append(data, fileObject(file))
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": data, "has_more": false})
+}
+
+// GetFile handles GET /v1/files/{id}.
+func (h *BatchAPIHandler) GetFile(c *gin.Context) {
+	file, _, ok := h.lookupFile(c, false)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, fileObject(file))
+}
+
+// GetFileContent handles GET /v1/files/{id}/content.
+func (h *BatchAPIHandler) GetFileContent(c *gin.Context) {
+	_, content, ok := h.lookupFile(c, true)
+	if !ok {
+		return
+	}
+	c.Data(http.StatusOK, "application/x-jsonl", content)
+}
+
+// DeleteFile handles DELETE /v1/files/{id}. Output and error files go away with their batch.
+func (h *BatchAPIHandler) DeleteFile(c *gin.Context) {
+	file, _, ok := h.lookupFile(c, false)
+	if !ok {
+		return
+	}
+	if file.Purpose != "batch" {
+		writeError(c, constant.OpenAI, http.StatusBadRequest, "batch output files cannot be deleted")
+		return
+	}
+	if err := h.runner.Store().DeleteFile(c.Request.Context(), file.ID); err != nil && !errors.Is(err, corebatches.ErrNotFound) {
+		writeError(c, constant.OpenAI, http.StatusInternalServerError, fmt.Sprintf("failed to delete file: %v", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": file.ID, "object": "file", "deleted": true})
+}
+
+// lookupFile loads the file named by the id path parameter, rendering output and error files
+// from the results of their batch. The content is loaded only when withContent is set;
+// output and error files always carry it. A 404 is written when the file is unknown or
+// belongs to another client.
+func (h *BatchAPIHandler) lookupFile(c *gin.Context, withContent bool) (*corebatches.File, []byte, bool) {
+	id := strings.TrimSpace(c.Param("id"))
+	ctx := c.Request.Context()
+	store := h.runner.Store()
+	var file *corebatches.File
+	var content []byte
+	var err error
+	if batchID, errorsFile, derived := resultFileBatch(id); derived {
+		file, content, err = h.resultFile(c, id, batchID, errorsFile)
+	} else {
+		file, err = store.GetFile(ctx, id)
+		if err == nil && file.Owner != batchOwner(c) {
+			err = corebatches.ErrNotFound
+		}
+		if err == nil && withContent {
+			content, err = store.ReadFile(ctx, id)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, corebatches.ErrNotFound) {
+			writeError(c, constant.OpenAI, http.StatusNotFound, fmt.Sprintf("file with id '%s' not found", id))
+		} else {
+			writeError(c, constant.OpenAI, http.StatusInternalServerError, fmt.Sprintf("failed to load file: %v", err))
+		}
+		return nil, nil, false
+	}
+	return file, content, true
+}
+
+// resultFile renders the output or error file of an ended batch.
+func (h *BatchAPIHandler) resultFile(c *gin.Context, id, batchID string, errorsFile bool) (*corebatches.File, []byte, error) {
+	batch, err := h.runner.Get(c.Request.Context(), batchID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if batch.Dialect != constant.OpenAI || batch.Owner != batchOwner(c) || !batch.Status.Ended() {
+		return nil, nil, corebatches.ErrNotFound
+	}
+	results, err := h.runner.Results(c.Request.Context(), batch.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	content := openAIResultLines(results, errorsFile)
+	if len(content) == 0 {
+		return nil, nil, corebatches.ErrNotFound
+	}
+	name := batch.ID + "_output.jsonl"
+	if errorsFile {
+		name = batch.ID + "_errors.jsonl"
+	}
+	file := &corebatches.File{
+		ID:        id,
+		Owner:     batch.Owner,
+		Filename:  name,
+		Purpose:   "batch_output",
+		Bytes:     int64(len(content)),
+		CreatedAt: batch.CreatedAt,
+	}
+	if batch.EndedAt != nil {
+		file.CreatedAt = *batch.EndedAt
+	}
+	return file, content, nil
+}
+
+// fileObject renders a file as an OpenAI file object.
+func fileObject(file *corebatches.File) gin.H {
+	return gin.H{
+		"id":         file.ID,
+		"object":     "file",
+		"bytes":      file.Bytes,
+		"created_at": file.CreatedAt.Unix(),
+		"filename":   file.Filename,
+		"purpose":    file.Purpose,
+		"status":     "processed",
+	}
+}
@@ -9,7 +9,6 @@ package claude
 import (
 	"bufio"
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
@@ -249,9 +248,12 @@ func (h *ClaudeCodeAPIHandler) forwardClaudeStream(c *gin.Context, flusher http.
 			if !ok {
 				continue
 			}
-			if errMsg != nil {
+			if errMsg != nil && chunkIdx == 0 && writer.Buffered() == 0 && !c.Writer.Written() {
+				// Nothing was sent yet: reply with the upstream status and a regular error body
+				h.WriteErrorResponse(c, errMsg)
+			} else if errMsg != nil {
 				// An error occurred: emit as a proper SSE error event
-				errorBytes, _ := json.Marshal(h.toClaudeError(errMsg))
+				errorBytes := handlers.BuildErrorResponseBody(h.HandlerType(), errMsg)
 				_, _ = writer.WriteString("event: error\n")
 				_, _ = writer.WriteString("data: ")
 				_, _ = writer.Write(errorBytes)
@@ -267,23 +269,3 @@ func (h *ClaudeCodeAPIHandler) forwardClaudeStream(c *gin.Context, flusher http.
 		}
 	}
 }
-
-type claudeErrorDetail struct {
-	Type    string `json:"type"`
-	Message string `json:"message"`
-}
-
-type claudeErrorResponse struct {
-	Type  string            `json:"type"`
-	Error claudeErrorDetail `json:"error"`
-}
-
-func (h *ClaudeCodeAPIHandler) toClaudeError(msg *interfaces.ErrorMessage) claudeErrorResponse {
-	return claudeErrorResponse{
-		Type: "error",
-		Error: claudeErrorDetail{
-			Type:    "api_error",
-			Message: msg.Error.Error(),
-		},
-	}
-}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+// handlerTypeKey is the Gin context key holding the inbound API dialect of the request.
+const handlerTypeKey = "API_HANDLER_TYPE"
+
+// statusClientClosedRequest is the non-standard status recorded for requests the client
+// abandoned, so that disconnects are not reported as timeouts.
+const statusClientClosedRequest = 499
+
+// newErrorMessage converts an execution error into an ErrorMessage carrying the
+// upstream HTTP status and, when the auth cooldown is known, a Retry-After header.
+func newErrorMessage(err error) *interfaces.ErrorMessage {
+	msg := &interfaces.ErrorMessage{StatusCode: statusFromError(err), Error: err}
+	if wait, ok := coreauth.RetryAfterFromError(err); ok {
+		seconds := int(math.Ceil(wait.Seconds()))
+		if seconds < 1 {
+			seconds = 1
+		}
+		msg.Addon = http.Header{}
+		msg.Addon.Set("Retry-After", strconv.Itoa(seconds))
+	}
+	return msg
+}
+
+// statusFromError derives the HTTP status that best describes err.
+func statusFromError(err error) int {
+	if err == nil {
+		return http.StatusInternalServerError
+	}
+	var se coreexecutor.StatusError
+	if errors.As(err, &se) && se != nil {
+		if code := se.StatusCode(); code >= 400 && code <= 599 {
+			return code
+		}
+	}
+	var authErr *coreauth.Error
+	if errors.As(err, &authErr) && authErr != nil {
+		switch authErr.Code {
+		case "auth_unavailable":
+			return http.StatusTooManyRequests
+		case "auth_not_found", "executor_not_found":
+			return http.StatusServiceUnavailable
+		case "provider_not_found":
+			return http.StatusBadRequest
+		}
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return statusClientClosedRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// BuildErrorResponseBody renders msg as a JSON error body in the dialect of handlerType:
+// OpenAI-style for openai/openai-response, Anthropic-style for claude, Google-style for
+// gemini/gemini-cli and a plain {"error": message} object for ollama.
+func BuildErrorResponseBody(handlerType string, msg *interfaces.ErrorMessage) []byte {
+	status := http.StatusInternalServerError
+	if msg != nil && msg.StatusCode > 0 {
+		status = msg.StatusCode
+	}
+	message := http.StatusText(status)
+	if msg != nil && msg.Error != nil {
+		message = errorMessageText(msg.Error.Error())
+	}
+
+	var payload any
+	switch handlerType {
+	case constant.Claude:
+		payload = map[string]any{
+			"type": "error",
+			"error": map[string]any{
+				"type":    claudeErrorType(status),
+				"message": message,
+			},
+		}
+	case constant.Gemini, constant.GeminiCLI:
+		payload = map[string]any{
+			"error": map[string]any{
+				"code":    status,
+				"message": message,
+				"status":  geminiErrorStatus(status),
+			},
+		}
+	case constant.Ollama:
+		payload = map[string]any{"error": message}
+	default:
+		errType, code := openAIErrorType(status)
+		payload = ErrorResponse{Error: ErrorDetail{Message: message, Type: errType, Code: code}}
+	}
+	body, _ := json.Marshal(payload)
+	return body
+}
+
+// errorMessageText extracts a human readable message from an upstream error body,
+// falling back to the raw text when it is not a recognised JSON error.
+func errorMessageText(raw string) string {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" || !gjson.Valid(trimmed) {
+		return trimmed
+	}
+	root := gjson.Parse(trimmed)
+	if root.IsArray() {
+		root = root.Get("0")
+	}
+	for _, path := range []string{"error.message", "message", "error", "detail"} {
+		if value := root.Get(path); value.Type == gjson.String && strings.TrimSpace(value.String()) != "" {
+			return value.String()
+		}
+	}
+	return trimmed
+}
+
+func openAIErrorType(status int) (string, string) {
+	switch status {
+	case http.StatusUnauthorized:
+		return "authentication_error", "invalid_api_key"
+	case http.StatusForbidden:
+		return "permission_error", ""
+	case http.StatusNotFound:
+		return "invalid_request_error", "model_not_found"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error", "rate_limit_exceeded"
+	}
+	if status >= 500 {
+		return "server_error", ""
+	}
+	return "invalid_request_error", ""
+}
+
+func claudeErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusServiceUnavailable, 529:
+		return "overloaded_error"
+	}
+	if status < 500 {
+		return "invalid_request_error"
+	}
+	return "api_error"
+}
+
+func geminiErrorStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusConflict:
+		return "ABORTED"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusNotImplemented:
+		return "UNIMPLEMENTED"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return "DEADLINE_EXCEEDED"
+	case statusClientClosedRequest:
+		return "CANCELLED"
+	}
+	if status < 500 {
+		return "FAILED_PRECONDITION"
+	}
+	return "INTERNAL"
+}
+
+// handlerTypeFromContext returns the inbound API dialect recorded by GetContextWithCancel.
+func handlerTypeFromContext(c *gin.Context) string {
+	if c == nil {
+		return ""
+	}
+	if value, exists := c.Get(handlerTypeKey); exists {
+		if handlerType, ok := value.(string); ok {
+			return handlerType
+		}
+	}
+	return ""
+}
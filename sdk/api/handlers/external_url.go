@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExternalBaseURL returns the scheme and host clients used to reach the proxy, for URLs
+// returned in responses. X-Forwarded-Proto and X-Forwarded-Host are only honoured when
+// the request comes from one of the configured trusted proxies; otherwise any client could
+// make the proxy hand out links to a host of its choosing.
+func (h *BaseAPIHandler) ExternalBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	host := c.Request.Host
+	if h.trustedProxy(c.Request.RemoteAddr) {
+		if forwarded := c.GetHeader("X-Forwarded-Proto"); forwarded != "" {
+			scheme = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+		if forwarded := c.GetHeader("X-Forwarded-Host"); forwarded != "" {
+			host = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	return scheme + "://" + host
+}
+
+// trustedProxy reports whether remoteAddr matches an address or CIDR range listed in
+// trusted-proxies.
+func (h *BaseAPIHandler) trustedProxy(remoteAddr string) bool {
+	if h.Cfg == nil || len(h.Cfg.TrustedProxies) == 0 {
+		return false
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, entry := range h.Cfg.TrustedProxies {
+		entry = strings.TrimSpace(entry)
+		if _, network, errCIDR := net.ParseCIDR(entry); errCIDR == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if trusted := net.ParseIP(entry); trusted != nil && trusted.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
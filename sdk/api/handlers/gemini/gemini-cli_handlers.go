@@ -181,6 +181,24 @@ func (h *GeminiCLIAPIHandler) handleInternalGenerateContent(c *gin.Context, rawJ
 	cliCancel()
 }
 
+// writeStreamError reports an execution error on a stream. Before the first byte the
+// upstream status and a regular error body are sent; once the stream has started the
+// error body follows as the last event, or the last element when alt is set.
+func (h *GeminiCLIAPIHandler) writeStreamError(c *gin.Context, alt string, errMsg *interfaces.ErrorMessage) {
+	if !c.Writer.Written() {
+		h.WriteErrorResponse(c, errMsg)
+		return
+	}
+	body := handlers.BuildErrorResponseBody(h.HandlerType(), errMsg)
+	if alt == "" {
+		_, _ = c.Writer.Write([]byte("data: "))
+		_, _ = c.Writer.Write(body)
+		_, _ = c.Writer.Write([]byte("\n\n"))
+		return
+	}
+	_, _ = c.Writer.Write(body)
+}
+
 func (h *GeminiCLIAPIHandler) forwardCLIStream(c *gin.Context, flusher http.Flusher, alt string, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
 	for {
 		select {
@@ -212,7 +230,7 @@ func (h *GeminiCLIAPIHandler) forwardCLIStream(c *gin.Context, flusher http.Flus
 				continue
 			}
 			if errMsg != nil {
-				h.WriteErrorResponse(c, errMsg)
+				h.writeStreamError(c, alt, errMsg)
 				flusher.Flush()
 			}
 			var execErr error
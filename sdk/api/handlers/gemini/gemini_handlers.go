@@ -17,6 +17,8 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
 )
 
 // GeminiAPIHandler contains the handlers for Gemini API endpoints.
@@ -173,6 +175,10 @@ func (h *GeminiAPIHandler) GeminiHandler(c *gin.Context) {
 		h.handleStreamGenerateContent(c, action[0], rawJSON)
 	case "countTokens":
 		h.handleCountTokens(c, action[0], rawJSON)
+	case "embedContent":
+		h.handleEmbedContent(c, action[0], rawJSON)
+	case "batchEmbedContents":
+		h.handleBatchEmbedContents(c, action[0], rawJSON)
 	}
 }
 
@@ -235,6 +241,51 @@ func (h *GeminiAPIHandler) handleCountTokens(c *gin.Context, modelName string, r
 	cliCancel()
 }
 
+// handleEmbedContent handles single embedding requests for Gemini models. The request is
+// sent as a one-item batch and the single embedding of the batch response is returned.
+//
+// Parameters:
+//   - c: The Gin context for the request
+//   - modelName: The name of the embedding model
+//   - rawJSON: The raw JSON request body containing the content to embed
+func (h *GeminiAPIHandler) handleEmbedContent(c *gin.Context, modelName string, rawJSON []byte) {
+	batch, _ := sjson.SetRawBytes([]byte(`{"requests":[]}`), "requests.-1", rawJSON)
+	resp, ok := h.embed(c, modelName, batch)
+	if !ok {
+		return
+	}
+	out, _ := sjson.SetRawBytes([]byte(`{}`), "embedding", []byte(gjson.GetBytes(resp, "embeddings.0").Raw))
+	_, _ = c.Writer.Write(out)
+}
+
+// handleBatchEmbedContents handles batch embedding requests for Gemini models.
+//
+// Parameters:
+//   - c: The Gin context for the request
+//   - modelName: The name of the embedding model
+//   - rawJSON: The raw JSON request body containing the requests to embed
+func (h *GeminiAPIHandler) handleBatchEmbedContents(c *gin.Context, modelName string, rawJSON []byte) {
+	resp, ok := h.embed(c, modelName, rawJSON)
+	if !ok {
+		return
+	}
+	_, _ = c.Writer.Write(resp)
+}
+
+// embed runs a batchEmbedContents request and writes the error response when it fails.
+func (h *GeminiAPIHandler) embed(c *gin.Context, modelName string, rawJSON []byte) ([]byte, bool) {
+	c.Header("Content-Type", "application/json")
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, errMsg := h.ExecuteEmbedWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON)
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return nil, false
+	}
+	cliCancel()
+	return resp, true
+}
+
 // handleGenerateContent handles non-streaming content generation requests for Gemini models.
 // This function processes the request synchronously and returns the complete generated
 // response in a single API call. It supports various generation parameters and
@@ -258,6 +309,24 @@ func (h *GeminiAPIHandler) handleGenerateContent(c *gin.Context, modelName strin
 	cliCancel()
 }
 
+// writeStreamError reports an execution error on a stream. Before the first byte the
+// upstream status and a regular error body are sent; once the stream has started the
+// error body follows as the last event, or the last element when alt is set.
+func (h *GeminiAPIHandler) writeStreamError(c *gin.Context, alt string, errMsg *interfaces.ErrorMessage) {
+	if !c.Writer.Written() {
+		h.WriteErrorResponse(c, errMsg)
+		return
+	}
+	body := handlers.BuildErrorResponseBody(h.HandlerType(), errMsg)
+	if alt == "" {
+		_, _ = c.Writer.Write([]byte("data: "))
+		_, _ = c.Writer.Write(body)
+		_, _ = c.Writer.Write([]byte("\n\n"))
+		return
+	}
+	_, _ = c.Writer.Write(body)
+}
+
 func (h *GeminiAPIHandler) forwardGeminiStream(c *gin.Context, flusher http.Flusher, alt string, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
 	for {
 		select {
@@ -282,7 +351,7 @@ func (h *GeminiAPIHandler) forwardGeminiStream(c *gin.Context, flusher http.Flus
 				continue
 			}
 			if errMsg != nil {
-				h.WriteErrorResponse(c, errMsg)
+				h.writeStreamError(c, alt, errMsg)
 				flusher.Flush()
 			}
 			var execErr error
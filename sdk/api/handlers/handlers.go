@@ -9,6 +9,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
@@ -109,6 +110,11 @@ func (h *BaseAPIHandler) GetContextWithCancel(handler interfaces.APIHandler, c *
 	newCtx, cancel := context.WithCancel(ctx)
 	newCtx = context.WithValue(newCtx, "gin", c)
 	newCtx = context.WithValue(newCtx, "handler", handler)
+	// Handlers execute on a detached context; carry over the server span of the request.
+	newCtx = tracing.ContextWithSpan(newCtx, tracing.SpanFromContext(c.Request.Context()))
+	if handler != nil {
+		c.Set(handlerTypeKey, handler.HandlerType())
+	}
 	return newCtx, func(params ...interface{}) {
 		if h.Cfg.RequestLog {
 			if len(params) == 1 {
@@ -133,6 +139,7 @@ func (h *BaseAPIHandler) GetContextWithCancel(handler interfaces.APIHandler, c *
 // ExecuteWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
+	tracing.SpanFromContext(ctx).SetAttribute("model", modelName)
 	normalizedModel, metadata := normalizeModelMetadata(modelName)
 	providers := util.GetProviderName(normalizedModel)
 	if len(providers) == 0 {
@@ -154,9 +161,9 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 	if cloned := cloneMetadata(metadata); cloned != nil {
 		opts.Metadata = cloned
 	}
-	resp, err := h.AuthManager.Execute(ctx, providers, req, opts)
+	resp, err := h.AuthManager.Execute(withModelFallbacks(ctx), providers, req, opts)
 	if err != nil {
-		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: err}
+		return nil, newErrorMessage(err)
 	}
 	return cloneBytes(resp.Payload), nil
 }
@@ -164,6 +171,7 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 // ExecuteCountWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
+	tracing.SpanFromContext(ctx).SetAttribute("model", modelName)
 	normalizedModel, metadata := normalizeModelMetadata(modelName)
 	providers := util.GetProviderName(normalizedModel)
 	if len(providers) == 0 {
@@ -185,9 +193,32 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 	if cloned := cloneMetadata(metadata); cloned != nil {
 		opts.Metadata = cloned
 	}
-	resp, err := h.AuthManager.ExecuteCount(ctx, providers, req, opts)
+	resp, err := h.AuthManager.ExecuteCount(withModelFallbacks(ctx), providers, req, opts)
 	if err != nil {
-		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: err}
+		return nil, newErrorMessage(err)
+	}
+	return cloneBytes(resp.Payload), nil
+}
+
+// ExecuteEmbedWithAuthManager creates embeddings via the core auth manager. Only providers
+// whose executor supports embeddings are considered.
+func (h *BaseAPIHandler) ExecuteEmbedWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	tracing.SpanFromContext(ctx).SetAttribute("model", modelName)
+	providers := util.GetProviderName(modelName)
+	if len(providers) == 0 {
+		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("unknown provider for model %s", modelName)}
+	}
+	req := coreexecutor.Request{
+		Model:   modelName,
+		Payload: cloneBytes(rawJSON),
+	}
+	opts := coreexecutor.Options{
+		OriginalRequest: cloneBytes(rawJSON),
+		SourceFormat:    sdktranslator.FromString(handlerType),
+	}
+	resp, err := h.AuthManager.ExecuteEmbed(withModelFallbacks(ctx), providers, req, opts)
+	if err != nil {
+		return nil, newErrorMessage(err)
 	}
 	return cloneBytes(resp.Payload), nil
 }
@@ -195,6 +226,7 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 // ExecuteStreamWithAuthManager executes a streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+	tracing.SpanFromContext(ctx).SetAttribute("model", modelName)
 	normalizedModel, metadata := normalizeModelMetadata(modelName)
 	providers := util.GetProviderName(normalizedModel)
 	if len(providers) == 0 {
@@ -219,10 +251,10 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 	if cloned := cloneMetadata(metadata); cloned != nil {
 		opts.Metadata = cloned
 	}
-	chunks, err := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
+	chunks, err := h.AuthManager.ExecuteStream(withModelFallbacks(ctx), providers, req, opts)
 	if err != nil {
 		errChan := make(chan *interfaces.ErrorMessage, 1)
-		errChan <- &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: err}
+		errChan <- newErrorMessage(err)
 		close(errChan)
 		return nil, errChan
 	}
@@ -233,7 +265,7 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 		defer close(errChan)
 		for chunk := range chunks {
 			if chunk.Err != nil {
-				errChan <- &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: chunk.Err}
+				errChan <- newErrorMessage(chunk.Err)
 				return
 			}
 			if len(chunk.Payload) > 0 {
@@ -244,6 +276,30 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 	return dataChan, errChan
 }
 
+// modelFallbackHeader names the response header reporting the fallback model that served a request.
+const modelFallbackHeader = "X-Model-Fallback"
+
+// ModelFilterKey is the Gin context key under which middleware stores a func(model string) bool
+// restricting the models a request may fall back to.
+const ModelFilterKey = "modelFilter"
+
+// withModelFallbacks makes the auth manager report a model fallback through the response
+// header and only fall back to models allowed by the filter stored under ModelFilterKey.
+func withModelFallbacks(ctx context.Context) context.Context {
+	c, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || c == nil {
+		return ctx
+	}
+	if value, exists := c.Get(ModelFilterKey); exists {
+		if allow, okFilter := value.(func(model string) bool); okFilter {
+			ctx = coreauth.WithModelFilter(ctx, allow)
+		}
+	}
+	return coreauth.WithModelFallbackObserver(ctx, func(_, fallback string) {
+		c.Header(modelFallbackHeader, fallback)
+	})
+}
+
 func cloneBytes(src []byte) []byte {
 	if len(src) == 0 {
 		return nil
@@ -282,23 +338,32 @@ func cloneMetadata(src map[string]any) map[string]any {
 }
 
 // WriteErrorResponse writes an error message to the response writer using the HTTP status embedded in the message.
+// The body is shaped after the API dialect of the handler that created the request context, and any
+// headers carried in msg.Addon (such as Retry-After) are copied to the response.
 func (h *BaseAPIHandler) WriteErrorResponse(c *gin.Context, msg *interfaces.ErrorMessage) {
-    status := http.StatusInternalServerError
-    if msg != nil && msg.StatusCode > 0 {
-        status = msg.StatusCode
-    }
-    // Expose error payload to Gin context for verbose logging, regardless of RequestLog flag.
-    if msg != nil && msg.Error != nil {
-        // Best-effort body snapshot for log
-        c.Set("API_RESPONSE", []byte(msg.Error.Error()))
-        c.Set("API_RESPONSE_ERROR", []*interfaces.ErrorMessage{msg})
-    }
-    c.Status(status)
-    if msg != nil && msg.Error != nil {
-        _, _ = c.Writer.Write([]byte(msg.Error.Error()))
-    } else {
-        _, _ = c.Writer.Write([]byte(http.StatusText(status)))
-    }
+	status := http.StatusInternalServerError
+	if msg != nil && msg.StatusCode > 0 {
+		status = msg.StatusCode
+	}
+	body := BuildErrorResponseBody(handlerTypeFromContext(c), msg)
+	// Expose error payload to Gin context for verbose logging, regardless of RequestLog flag.
+	if msg != nil && msg.Error != nil {
+		// Best-effort body snapshot for log
+		c.Set("API_RESPONSE", body)
+		c.Set("API_RESPONSE_ERROR", []*interfaces.ErrorMessage{msg})
+	}
+	if msg != nil {
+		for key, values := range msg.Addon {
+			for _, value := range values {
+				c.Writer.Header().Add(key, value)
+			}
+		}
+	}
+	if !c.Writer.Written() {
+		c.Header("Content-Type", "application/json")
+	}
+	c.Status(status)
+	_, _ = c.Writer.Write(body)
 }
 
 func (h *BaseAPIHandler) LoggingAPIResponseError(ctx context.Context, err *interfaces.ErrorMessage) {
@@ -0,0 +1,319 @@
+// Package ollama provides HTTP handlers for Ollama API endpoints.
+// This package implements the Ollama-compatible /api/chat, /api/generate, /api/tags and
+// /api/show endpoints so that tools which only speak the Ollama API can use any model the
+// proxy serves. Chat requests are translated to the backend format by the ollama
+// translators; generate requests are converted to chat requests first. Streaming
+// responses are written as newline-delimited JSON like Ollama does.
+package ollama
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// OllamaAPIHandler contains the handlers for Ollama API endpoints.
+type OllamaAPIHandler struct {
+	*handlers.BaseAPIHandler
+}
+
+// NewOllamaAPIHandler creates a new Ollama API handlers instance.
+// It takes an BaseAPIHandler instance as input and returns an OllamaAPIHandler.
+//
+// Parameters:
+//   - apiHandlers: The base API handlers instance
+//
+// Returns:
+//   - *OllamaAPIHandler: A new Ollama API handlers instance
+func NewOllamaAPIHandler(apiHandlers *handlers.BaseAPIHandler) *OllamaAPIHandler {
+	return &OllamaAPIHandler{
+		BaseAPIHandler: apiHandlers,
+	}
+}
+
+// HandlerType returns the identifier for this handler implementation.
+func (h *OllamaAPIHandler) HandlerType() string {
+	return Ollama
+}
+
+// Models returns the Ollama-compatible model metadata supported by this handler.
+func (h *OllamaAPIHandler) Models() []map[string]any {
+	// Get dynamic models from the global registry
+	modelRegistry := registry.GetGlobalRegistry()
+	return modelRegistry.GetAvailableModels("ollama")
+}
+
+// Tags handles the /api/tags endpoint, listing the available models as local Ollama models.
+func (h *OllamaAPIHandler) Tags(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"models": h.Models(),
+	})
+}
+
+// Show handles the /api/show endpoint, describing a single model. The model is looked up
+// by "model", or by the legacy "name" field.
+func (h *OllamaAPIHandler) Show(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+	modelName := gjson.GetBytes(rawJSON, "model").String()
+	if modelName == "" {
+		modelName = gjson.GetBytes(rawJSON, "name").String()
+	}
+	for _, model := range h.Models() {
+		if model["name"] != normalizeModelName(modelName) {
+			continue
+		}
+		details, _ := model["details"].(map[string]any)
+		family, _ := details["family"].(string)
+		c.JSON(http.StatusOK, gin.H{
+			"modelfile":    "",
+			"parameters":   "",
+			"template":     "",
+			"details":      details,
+			"model_info":   gin.H{"general.architecture": family},
+			"capabilities": model["capabilities"],
+			"modified_at":  model["modified_at"],
+		})
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("model '%s' not found", modelName)})
+}
+
+// Chat handles the /api/chat endpoint. Ollama streams by default, so the response is only
+// returned as a single object when the request sets "stream" to false.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OllamaAPIHandler) Chat(c *gin.Context) {
+	rawJSON, ok := h.readRequest(c)
+	if !ok {
+		return
+	}
+	modelName := gjson.GetBytes(rawJSON, "model").String()
+	// An empty message list only loads the model in Ollama.
+	if len(gjson.GetBytes(rawJSON, "messages").Array()) == 0 {
+		c.JSON(http.StatusOK, loadResponse(modelName, "message", gin.H{"role": "assistant", "content": ""}))
+		return
+	}
+
+	if gjson.GetBytes(rawJSON, "stream").Type == gjson.False {
+		h.handleNonStreamingResponse(c, normalizeModelName(modelName), rawJSON, nil)
+	} else {
+		h.handleStreamingResponse(c, normalizeModelName(modelName), rawJSON, nil)
+	}
+}
+
+// Generate handles the /api/generate endpoint. The prompt is sent as a chat request and the
+// chat responses are converted back to generate responses.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OllamaAPIHandler) Generate(c *gin.Context) {
+	rawJSON, ok := h.readRequest(c)
+	if !ok {
+		return
+	}
+	modelName := gjson.GetBytes(rawJSON, "model").String()
+	// An empty prompt only loads the model in Ollama.
+	if gjson.GetBytes(rawJSON, "prompt").String() == "" && len(gjson.GetBytes(rawJSON, "images").Array()) == 0 {
+		c.JSON(http.StatusOK, loadResponse(modelName, "response", ""))
+		return
+	}
+
+	chatJSON := convertGenerateRequestToChat(rawJSON)
+	if gjson.GetBytes(rawJSON, "stream").Type == gjson.False {
+		h.handleNonStreamingResponse(c, normalizeModelName(modelName), chatJSON, convertChatResponseToGenerate)
+	} else {
+		h.handleStreamingResponse(c, normalizeModelName(modelName), chatJSON, convertChatResponseToGenerate)
+	}
+}
+
+// readRequest reads the request body and checks that it names a model.
+func (h *OllamaAPIHandler) readRequest(c *gin.Context) ([]byte, bool) {
+	rawJSON, err := c.GetRawData()
+	// If data retrieval fails, return a 400 Bad Request error.
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return nil, false
+	}
+	if gjson.GetBytes(rawJSON, "model").String() == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "model is required"})
+		return nil, false
+	}
+	return rawJSON, true
+}
+
+// handleNonStreamingResponse executes a chat request and writes the single Ollama response.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+//   - modelName: The requested model
+//   - rawJSON: The raw JSON bytes of the Ollama chat request
+//   - convert: An optional conversion applied to the chat response
+func (h *OllamaAPIHandler) handleNonStreamingResponse(c *gin.Context, modelName string, rawJSON []byte, convert func([]byte) []byte) {
+	c.Header("Content-Type", "application/json")
+	c.Set("API_REQUEST", append([]byte(nil), rawJSON...))
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, "")
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	if convert != nil {
+		resp = convert(resp)
+	}
+	_, _ = c.Writer.Write(resp)
+	cliCancel()
+}
+
+// handleStreamingResponse executes a chat request and streams the Ollama responses as
+// newline-delimited JSON. If the stream ends without a final "done" object, one is written
+// so clients always see the end of the response.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+//   - modelName: The requested model
+//   - rawJSON: The raw JSON bytes of the Ollama chat request
+//   - convert: An optional conversion applied to every chat response line
+func (h *OllamaAPIHandler) handleStreamingResponse(c *gin.Context, modelName string, rawJSON []byte, convert func([]byte) []byte) {
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("Access-Control-Allow-Origin", "*")
+
+	// Get the http.Flusher interface to manually flush the response.
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Streaming not supported"})
+		return
+	}
+
+	c.Set("API_REQUEST", append([]byte(nil), rawJSON...))
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	dataChan, errChan := h.ExecuteStreamWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, "")
+	h.handleStreamResult(c, flusher, modelName, convert, func(err error) { cliCancel(err) }, dataChan, errChan)
+}
+
+func (h *OllamaAPIHandler) handleStreamResult(c *gin.Context, flusher http.Flusher, modelName string, convert func([]byte) []byte, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
+	done := false
+	write := func(line []byte) {
+		if convert != nil {
+			line = convert(line)
+		}
+		_, _ = c.Writer.Write(line)
+		_, _ = c.Writer.Write([]byte("\n"))
+		flusher.Flush()
+	}
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			cancel(c.Request.Context().Err())
+			return
+		case chunk, ok := <-data:
+			if !ok {
+				if !done {
+					final := `{"model":"","created_at":"","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop"}`
+					final, _ = sjson.Set(final, "model", modelName)
+					final, _ = sjson.Set(final, "created_at", time.Now().UTC().Format(time.RFC3339Nano))
+					write([]byte(final))
+				}
+				cancel(nil)
+				return
+			}
+			done = done || gjson.GetBytes(chunk, "done").Bool()
+			write(chunk)
+		case errMsg, ok := <-errs:
+			if !ok {
+				continue
+			}
+			if errMsg != nil {
+				if c.Writer.Written() {
+					// The stream has started: end it with an error line, as Ollama does.
+					_, _ = c.Writer.Write(handlers.BuildErrorResponseBody(h.HandlerType(), errMsg))
+					_, _ = c.Writer.Write([]byte("\n"))
+				} else {
+					h.WriteErrorResponse(c, errMsg)
+				}
+				flusher.Flush()
+			}
+			var execErr error
+			if errMsg != nil {
+				execErr = errMsg.Error
+			}
+			cancel(execErr)
+			return
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// normalizeModelName strips the ":latest" tag Ollama clients add to untagged model names,
+// so "gemini-2.5-pro:latest" resolves to the served model "gemini-2.5-pro".
+func normalizeModelName(name string) string {
+	return strings.TrimSuffix(name, ":latest")
+}
+
+// loadResponse builds the response Ollama returns for requests that only load a model,
+// with the empty output of the endpoint stored under key.
+func loadResponse(modelName, key string, empty any) gin.H {
+	return gin.H{
+		"model":       modelName,
+		"created_at":  time.Now().UTC().Format(time.RFC3339Nano),
+		key:           empty,
+		"done":        true,
+		"done_reason": "load",
+	}
+}
+
+// convertGenerateRequestToChat converts an /api/generate request into an /api/chat request.
+// The system prompt and the prompt with its images become the chat messages; "suffix",
+// "template", "raw" and "context" have no chat equivalent and are dropped.
+func convertGenerateRequestToChat(rawJSON []byte) []byte {
+	root := gjson.ParseBytes(rawJSON)
+	out := `{"model":"","messages":[]}`
+	out, _ = sjson.Set(out, "model", root.Get("model").String())
+	if system := root.Get("system").String(); system != "" {
+		message, _ := sjson.Set(`{"role":"system","content":""}`, "content", system)
+		out, _ = sjson.SetRaw(out, "messages.-1", message)
+	}
+	message, _ := sjson.Set(`{"role":"user","content":""}`, "content", root.Get("prompt").String())
+	if images := root.Get("images"); images.IsArray() {
+		message, _ = sjson.SetRaw(message, "images", images.Raw)
+	}
+	out, _ = sjson.SetRaw(out, "messages.-1", message)
+	for _, key := range []string{"stream", "format", "options", "think", "keep_alive"} {
+		if value := root.Get(key); value.Exists() {
+			out, _ = sjson.SetRaw(out, key, value.Raw)
+		}
+	}
+	return []byte(out)
+}
+
+// convertChatResponseToGenerate converts an /api/chat response or stream line into an
+// /api/generate one, moving the message content to "response".
+func convertChatResponseToGenerate(rawJSON []byte) []byte {
+	message := gjson.GetBytes(rawJSON, "message")
+	if !message.Exists() {
+		return rawJSON
+	}
+	out, _ := sjson.DeleteBytes(rawJSON, "message")
+	out, _ = sjson.SetBytes(out, "response", message.Get("content").String())
+	if thinking := message.Get("thinking"); thinking.Exists() {
+		out, _ = sjson.SetBytes(out, "thinking", thinking.String())
+	}
+	return out
+}
@@ -407,6 +407,11 @@ func (h *OpenAIAPIHandler) handleNonStreamingResponse(c *gin.Context, rawJSON []
         cliCancel(errMsg.Error)
         return
     }
+    if errMsg = newChatStructuredOutputCheck(rawJSON).checkChatCompletion(resp); errMsg != nil {
+        h.WriteErrorResponse(c, errMsg)
+        cliCancel(errMsg.Error)
+        return
+    }
     _, _ = c.Writer.Write(resp)
     cliCancel()
 }
@@ -440,7 +445,7 @@ func (h *OpenAIAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON []byt
     c.Set("API_REQUEST", append([]byte(nil), rawJSON...))
     cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
     dataChan, errChan := h.ExecuteStreamWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, h.GetAlt(c))
-    h.handleStreamResult(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan)
+    h.handleStreamResult(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan, newChatStructuredOutputCheck(rawJSON))
 }
 
 // handleCompletionsNonStreamingResponse handles non-streaming completions responses.
@@ -523,7 +528,7 @@ func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, ra
 				continue
 			}
 			if errMsg != nil {
-				h.WriteErrorResponse(c, errMsg)
+				h.writeStreamError(c, errMsg)
 				flusher.Flush()
 			}
 			var execErr error
@@ -536,7 +541,19 @@ func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, ra
 		}
 	}
 }
-func (h *OpenAIAPIHandler) handleStreamResult(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
+
+// writeStreamError reports an execution error on a chat or completions stream. Before the
+// first byte the upstream status and a regular error body are sent; once the stream has
+// started the error body is sent as a data event, which is how OpenAI ends a failed stream.
+func (h *OpenAIAPIHandler) writeStreamError(c *gin.Context, errMsg *interfaces.ErrorMessage) {
+	if !c.Writer.Written() {
+		h.WriteErrorResponse(c, errMsg)
+		return
+	}
+	_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", string(handlers.BuildErrorResponseBody(h.HandlerType(), errMsg)))
+}
+
+func (h *OpenAIAPIHandler) handleStreamResult(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage, check *structuredOutputCheck) {
 	for {
 		select {
 		case <-c.Request.Context().Done():
@@ -544,11 +561,18 @@ func (h *OpenAIAPIHandler) handleStreamResult(c *gin.Context, flusher http.Flush
 			return
 		case chunk, ok := <-data:
 			if !ok {
+				// Report output that does not match the requested structured output before closing the stream.
+				var execErr error
+				if errMsg := check.checkChatStream(); errMsg != nil {
+					_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", string(handlers.BuildErrorResponseBody(h.HandlerType(), errMsg)))
+					execErr = errMsg.Error
+				}
 				_, _ = fmt.Fprintf(c.Writer, "data: [DONE]\n\n")
 				flusher.Flush()
-				cancel(nil)
+				cancel(execErr)
 				return
 			}
+			check.observeChatChunk(chunk)
 			_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", string(chunk))
 			flusher.Flush()
 		case errMsg, ok := <-errs:
@@ -556,7 +580,7 @@ func (h *OpenAIAPIHandler) handleStreamResult(c *gin.Context, flusher http.Flush
 				continue
 			}
 			if errMsg != nil {
-				h.WriteErrorResponse(c, errMsg)
+				h.writeStreamError(c, errMsg)
 				flusher.Flush()
 			}
 			var execErr error
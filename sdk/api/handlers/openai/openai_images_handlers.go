@@ -0,0 +1,327 @@
+package openai
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// defaultImageModel serves image requests that do not name a model.
+	defaultImageModel = "gemini-2.5-flash-image"
+	// maxImagesPerRequest is the largest n accepted, as in the OpenAI API.
+	maxImagesPerRequest = 10
+	// maxImageUploadBytes bounds the multipart body of /v1/images/edits.
+	maxImageUploadBytes = 32 << 20
+)
+
+// imageRequest is an OpenAI image generation or edit request.
+type imageRequest struct {
+	model          string
+	prompt         string
+	size           string
+	responseFormat string
+	n              int
+	images         []inlineImage
+	mask           *inlineImage
+}
+
+// inlineImage is an uploaded image sent to Gemini as inline data.
+type inlineImage struct {
+	mimeType string
+	data     []byte
+}
+
+// ImageGenerations handles the /v1/images/generations endpoint. Images are generated by
+// Gemini image models through generateContent and returned as b64_json (the default) or as
+// temporary URLs served by the proxy.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) ImageGenerations(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		h.writeImageRequestError(c, fmt.Errorf("Invalid request: %v", err))
+		return
+	}
+	req := imageRequest{
+		model:          gjson.GetBytes(rawJSON, "model").String(),
+		prompt:         gjson.GetBytes(rawJSON, "prompt").String(),
+		size:           gjson.GetBytes(rawJSON, "size").String(),
+		responseFormat: gjson.GetBytes(rawJSON, "response_format").String(),
+		n:              int(gjson.GetBytes(rawJSON, "n").Int()),
+	}
+	if aspectRatio := gjson.GetBytes(rawJSON, "aspect_ratio").String(); aspectRatio != "" {
+		req.size = aspectRatio
+	}
+	h.generateImages(c, req)
+}
+
+// ImageEdits handles the multipart /v1/images/edits endpoint. The uploaded images, and the
+// mask when present, are sent to a Gemini image model together with the prompt.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) ImageEdits(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImageUploadBytes)
+	form, err := c.MultipartForm()
+	if err != nil {
+		h.writeImageRequestError(c, fmt.Errorf("Invalid request: expected a multipart form: %v", err))
+		return
+	}
+	value := func(key string) string {
+		if values := form.Value[key]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	req := imageRequest{
+		model:          value("model"),
+		prompt:         value("prompt"),
+		size:           value("size"),
+		responseFormat: value("response_format"),
+	}
+	if aspectRatio := value("aspect_ratio"); aspectRatio != "" {
+		req.size = aspectRatio
+	}
+	if n := value("n"); n != "" {
+		if req.n, err = strconv.Atoi(n); err != nil {
+			h.writeImageRequestError(c, fmt.Errorf("Invalid request: n must be an integer"))
+			return
+		}
+	}
+	for _, key := range []string{"image", "image[]"} {
+		for _, file := range form.File[key] {
+			image, errRead := readUploadedImage(file)
+			if errRead != nil {
+				h.writeImageRequestError(c, errRead)
+				return
+			}
+			req.images = append(req.images, image)
+		}
+	}
+	if len(req.images) == 0 {
+		h.writeImageRequestError(c, fmt.Errorf("Invalid request: image is required"))
+		return
+	}
+	if files := form.File["mask"]; len(files) > 0 {
+		mask, errRead := readUploadedImage(files[0])
+		if errRead != nil {
+			h.writeImageRequestError(c, errRead)
+			return
+		}
+		req.mask = &mask
+	}
+	h.generateImages(c, req)
+}
+
+// ImageFile serves an image generated with response_format "url" until its URL expires.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) ImageFile(c *gin.Context) {
+	image, ok := temporaryImages.get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "Image not found or expired",
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(time.Until(image.expires).Seconds())))
+	c.Data(http.StatusOK, image.mimeType, image.data)
+}
+
+// generateImages runs one generateContent call per requested image and writes the OpenAI
+// images response. Each call is recorded as its own usage record; the response usage block
+// holds the totals. When a call fails after some images were generated, those images are
+// returned rather than discarded, so data may hold fewer than n entries.
+func (h *OpenAIAPIHandler) generateImages(c *gin.Context, req imageRequest) {
+	if strings.TrimSpace(req.prompt) == "" {
+		h.writeImageRequestError(c, fmt.Errorf("Invalid request: prompt is required"))
+		return
+	}
+	if req.n == 0 {
+		req.n = 1
+	}
+	if req.n < 1 || req.n > maxImagesPerRequest {
+		h.writeImageRequestError(c, fmt.Errorf("Invalid request: n must be between 1 and %d", maxImagesPerRequest))
+		return
+	}
+	switch req.responseFormat {
+	case "":
+		req.responseFormat = "b64_json"
+	case "b64_json", "url":
+	default:
+		h.writeImageRequestError(c, fmt.Errorf("Invalid request: response_format must be b64_json or url"))
+		return
+	}
+	aspectRatio, err := util.GeminiAspectRatio(req.size)
+	if err != nil {
+		h.writeImageRequestError(c, fmt.Errorf("Invalid request: %v", err))
+		return
+	}
+	if req.model == "" {
+		req.model = defaultImageModel
+	}
+	payload := req.geminiRequest(aspectRatio)
+
+	c.Set("API_REQUEST", append([]byte(nil), payload...))
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	out := []byte(`{"created":0,"data":[],"usage":{"input_tokens":0,"output_tokens":0,"total_tokens":0}}`)
+	out, _ = sjson.SetBytes(out, "created", time.Now().Unix())
+	var inputTokens, outputTokens, totalTokens int64
+	var failure *interfaces.ErrorMessage
+	generated := 0
+	for generated < req.n && failure == nil {
+		resp, errMsg := h.ExecuteWithAuthManager(cliCtx, constant.Gemini, req.model, payload, "")
+		if errMsg != nil {
+			failure = errMsg
+			break
+		}
+		usage := gjson.GetBytes(resp, "usageMetadata")
+		inputTokens += usage.Get("promptTokenCount").Int()
+		outputTokens += usage.Get("candidatesTokenCount").Int() + usage.Get("thoughtsTokenCount").Int()
+		totalTokens += usage.Get("totalTokenCount").Int()
+
+		images := geminiResponseImages(resp)
+		if len(images) == 0 {
+			failure = &interfaces.ErrorMessage{StatusCode: http.StatusBadGateway, Error: fmt.Errorf("model %s returned no image", req.model)}
+			break
+		}
+		for _, image := range images {
+			if generated == req.n {
+				break
+			}
+			item := `{}`
+			if req.responseFormat == "url" {
+				data, errDecode := base64.StdEncoding.DecodeString(image.Get("data").String())
+				if errDecode != nil {
+					failure = &interfaces.ErrorMessage{StatusCode: http.StatusBadGateway, Error: fmt.Errorf("model %s returned an invalid image: %v", req.model, errDecode)}
+					break
+				}
+				id := temporaryImages.put(data, imageMimeType(image))
+				item, _ = sjson.Set(item, "url", h.ExternalBaseURL(c)+"/v1/images/files/"+id)
+			} else {
+				item, _ = sjson.Set(item, "b64_json", image.Get("data").String())
+			}
+			out, _ = sjson.SetRawBytes(out, "data.-1", []byte(item))
+			generated++
+		}
+	}
+	if failure != nil {
+		if generated == 0 {
+			h.WriteErrorResponse(c, failure)
+			cliCancel(failure.Error)
+			return
+		}
+		log.Warnf("image generation: returning %d of %d images: %v", generated, req.n, failure.Error)
+	}
+	out, _ = sjson.SetBytes(out, "usage.input_tokens", inputTokens)
+	out, _ = sjson.SetBytes(out, "usage.output_tokens", outputTokens)
+	out, _ = sjson.SetBytes(out, "usage.total_tokens", totalTokens)
+
+	c.Header("Content-Type", "application/json")
+	_, _ = c.Writer.Write(out)
+	cliCancel(out)
+}
+
+// geminiRequest builds the generateContent request asking for image output.
+func (r imageRequest) geminiRequest(aspectRatio string) []byte {
+	out := []byte(`{"contents":[{"role":"user","parts":[]}],"generationConfig":{"responseModalities":["IMAGE","TEXT"]}}`)
+	addText := func(text string) {
+		part, _ := sjson.Set(`{"text":""}`, "text", text)
+		out, _ = sjson.SetRawBytes(out, "contents.0.parts.-1", []byte(part))
+	}
+	addImage := func(image inlineImage) {
+		part := `{"inlineData":{"mimeType":"","data":""}}`
+		part, _ = sjson.Set(part, "inlineData.mimeType", image.mimeType)
+		part, _ = sjson.Set(part, "inlineData.data", base64.StdEncoding.EncodeToString(image.data))
+		out, _ = sjson.SetRawBytes(out, "contents.0.parts.-1", []byte(part))
+	}
+	for _, image := range r.images {
+		addImage(image)
+	}
+	if r.mask != nil {
+		addImage(*r.mask)
+		addText("The previous image is a mask: only change the areas of the first image where the mask is transparent.")
+	}
+	addText(r.prompt)
+	if aspectRatio != "" {
+		out, _ = sjson.SetBytes(out, "generationConfig.imageConfig.aspectRatio", aspectRatio)
+	}
+	return out
+}
+
+// writeImageRequestError answers with a 400 in the OpenAI error format.
+func (h *OpenAIAPIHandler) writeImageRequestError(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+		Error: handlers.ErrorDetail{
+			Message: err.Error(),
+			Type:    "invalid_request_error",
+		},
+	})
+}
+
+// readUploadedImage reads a multipart image file.
+func readUploadedImage(file *multipart.FileHeader) (inlineImage, error) {
+	f, err := file.Open()
+	if err != nil {
+		return inlineImage{}, fmt.Errorf("Invalid request: cannot read %s: %v", file.Filename, err)
+	}
+	defer func() { _ = f.Close() }()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return inlineImage{}, fmt.Errorf("Invalid request: cannot read %s: %v", file.Filename, err)
+	}
+	mimeType := file.Header.Get("Content-Type")
+	if !strings.HasPrefix(mimeType, "image/") {
+		mimeType = http.DetectContentType(data)
+	}
+	if !strings.HasPrefix(mimeType, "image/") {
+		return inlineImage{}, fmt.Errorf("Invalid request: %s is not an image", file.Filename)
+	}
+	return inlineImage{mimeType: mimeType, data: data}, nil
+}
+
+// geminiResponseImages returns the inline data of the image parts of a Gemini response.
+func geminiResponseImages(resp []byte) []gjson.Result {
+	var images []gjson.Result
+	for _, part := range gjson.GetBytes(resp, "candidates.0.content.parts").Array() {
+		inline := part.Get("inlineData")
+		if !inline.Exists() {
+			inline = part.Get("inline_data")
+		}
+		if inline.Exists() && strings.HasPrefix(imageMimeType(inline), "image/") {
+			images = append(images, inline)
+		}
+	}
+	return images
+}
+
+func imageMimeType(inline gjson.Result) string {
+	if mimeType := inline.Get("mimeType").String(); mimeType != "" {
+		return mimeType
+	}
+	if mimeType := inline.Get("mime_type").String(); mimeType != "" {
+		return mimeType
+	}
+	return "image/png"
+}
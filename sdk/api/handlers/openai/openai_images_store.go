@@ -0,0 +1,70 @@
+package openai
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const (
+	// imageURLTTL is how long a generated image stays reachable through its temporary URL.
+	imageURLTTL = time.Hour
+	// maxStoredImages bounds the images kept for temporary URLs; the oldest are dropped first.
+	maxStoredImages = 256
+)
+
+// storedImage is a generated image served through a temporary URL.
+type storedImage struct {
+	data     []byte
+	mimeType string
+	expires  time.Time
+}
+
+// imageStore keeps generated images in memory for response_format "url".
+type imageStore struct {
+	mu     sync.Mutex
+	images map[string]*storedImage
+	order  []string
+}
+
+var temporaryImages = &imageStore{images: make(map[string]*storedImage)}
+
+// put stores an image and returns its unguessable ID.
+func (s *imageStore) put(data []byte, mimeType string) string {
+	var raw [16]byte
+	_, _ = rand.Read(raw[:])
+	id := hex.EncodeToString(raw[:])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireLocked(time.Now())
+	for len(s.order) >= maxStoredImages {
+		delete(s.images, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.images[id] = &storedImage{data: data, mimeType: mimeType, expires: time.Now().Add(imageURLTTL)}
+	s.order = append(s.order, id)
+	return id
+}
+
+// get returns an image that has not expired yet.
+func (s *imageStore) get(id string) (*storedImage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireLocked(time.Now())
+	image, ok := s.images[id]
+	return image, ok
+}
+
+// expireLocked drops expired images. Images are stored in expiry order.
+func (s *imageStore) expireLocked(now time.Time) {
+	for len(s.order) > 0 {
+		image, ok := s.images[s.order[0]]
+		if ok && now.Before(image.expires) {
+			return
+		}
+		delete(s.images, s.order[0])
+		s.order = s.order[1:]
+	}
+}
@@ -86,12 +86,19 @@ func (h *OpenAIResponsesAPIHandler) Responses(c *gin.Context) {
     // Preprocess request: apply model-suffix inference and inject defaults.
     rawJSON, _ = h.preprocessResponsesRequest(rawJSON)
 
+	// Expand previous_response_id from the response store.
+	rawJSON, state, errMsg := h.prepareResponseState(c, rawJSON)
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		return
+	}
+
     // Check if the client requested a streaming response.
     streamResult := gjson.GetBytes(rawJSON, "stream")
 	if streamResult.Type == gjson.True {
-		h.handleStreamingResponse(c, rawJSON)
+		h.handleStreamingResponse(c, rawJSON, state)
 	} else {
-    h.handleNonStreamingResponse(c, rawJSON)
+    h.handleNonStreamingResponse(c, rawJSON, state)
 	}
 
 }
@@ -103,7 +110,8 @@ func (h *OpenAIResponsesAPIHandler) Responses(c *gin.Context) {
 // Parameters:
 //   - c: The Gin context containing the HTTP request and response
 //   - rawJSON: The raw JSON bytes of the OpenAIResponses-compatible request
-func (h *OpenAIResponsesAPIHandler) handleNonStreamingResponse(c *gin.Context, rawJSON []byte) {
+//   - state: The response store state, or nil when the store is disabled
+func (h *OpenAIResponsesAPIHandler) handleNonStreamingResponse(c *gin.Context, rawJSON []byte, state *responseState) {
 	c.Header("Content-Type", "application/json")
 
 	modelName := gjson.GetBytes(rawJSON, "model").String()
@@ -117,7 +125,12 @@ func (h *OpenAIResponsesAPIHandler) handleNonStreamingResponse(c *gin.Context, r
 		h.WriteErrorResponse(c, errMsg)
 		return
 	}
+	if errMsg = newResponsesStructuredOutputCheck(rawJSON).checkResponse(resp); errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		return
+	}
 	_, _ = c.Writer.Write(resp)
+	state.saveResponse(c.Request.Context(), resp)
 	return
 
 	// no legacy fallback
@@ -131,7 +144,8 @@ func (h *OpenAIResponsesAPIHandler) handleNonStreamingResponse(c *gin.Context, r
 // Parameters:
 //   - c: The Gin context containing the HTTP request and response
 //   - rawJSON: The raw JSON bytes of the OpenAIResponses-compatible request
-func (h *OpenAIResponsesAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON []byte) {
+//   - state: The response store state, or nil when the store is disabled
+func (h *OpenAIResponsesAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON []byte, state *responseState) {
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
@@ -153,7 +167,7 @@ func (h *OpenAIResponsesAPIHandler) handleStreamingResponse(c *gin.Context, rawJ
     modelName := gjson.GetBytes(rawJSON, "model").String()
     cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
     dataChan, errChan := h.ExecuteStreamWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, "")
-    h.forwardResponsesStream(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan)
+    h.forwardResponsesStream(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan, state, newResponsesStructuredOutputCheck(rawJSON))
     return
 }
 
@@ -276,7 +290,8 @@ func modelBaseAndSuffix(model string) (string, string) {
     }
 }
 
-func (h *OpenAIResponsesAPIHandler) forwardResponsesStream(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
+func (h *OpenAIResponsesAPIHandler) forwardResponsesStream(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage, state *responseState, check *structuredOutputCheck) {
+	var sequence int64
 	for {
 		select {
 		case <-c.Request.Context().Done():
@@ -295,14 +310,36 @@ func (h *OpenAIResponsesAPIHandler) forwardResponsesStream(c *gin.Context, flush
 			}
 			_, _ = c.Writer.Write(chunk)
 			_, _ = c.Writer.Write([]byte("\n"))
+			if seq, ok := lastSequenceNumber(chunk); ok {
+				sequence = seq
+			}
+
+			// Output that does not match the requested structured output is followed by an
+			// error event and is not stored.
+			if event, errMsg := check.checkResponsesStreamChunk(chunk); errMsg != nil {
+				_, _ = c.Writer.Write([]byte("\n"))
+				_, _ = c.Writer.Write(event)
+				_, _ = c.Writer.Write([]byte("\n"))
+				flusher.Flush()
+				cancel(errMsg.Error)
+				return
+			}
 
 			flusher.Flush()
+			state.saveStreamChunk(c.Request.Context(), chunk)
 		case errMsg, ok := <-errs:
 			if !ok {
 				continue
 			}
 			if errMsg != nil {
-				h.WriteErrorResponse(c, errMsg)
+				if c.Writer.Written() {
+					// The stream has started: end it with an error event instead of a status.
+					_, _ = c.Writer.Write([]byte("\n"))
+					_, _ = c.Writer.Write(responsesErrorEvent(errMsg, sequence+1))
+					_, _ = c.Writer.Write([]byte("\n\n"))
+				} else {
+					h.WriteErrorResponse(c, errMsg)
+				}
 				flusher.Flush()
 			}
 			var execErr error
@@ -315,3 +352,36 @@ func (h *OpenAIResponsesAPIHandler) forwardResponsesStream(c *gin.Context, flush
 		}
 	}
 }
+
+// lastSequenceNumber returns the sequence number of the last event in a stream chunk.
+func lastSequenceNumber(chunk []byte) (int64, bool) {
+	var (
+		seq   int64
+		found bool
+	)
+	for _, line := range bytes.Split(chunk, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		if value := gjson.GetBytes(bytes.TrimSpace(line[len("data:"):]), "sequence_number"); value.Exists() {
+			seq, found = value.Int(), true
+		}
+	}
+	return seq, found
+}
+
+// responsesErrorEvent renders errMsg as a Responses API error event with the given
+// sequence number.
+func responsesErrorEvent(errMsg *interfaces.ErrorMessage, sequence int64) []byte {
+	body := handlers.BuildErrorResponseBody(OpenaiResponse, errMsg)
+	code := gjson.GetBytes(body, "error.code").String()
+	if code == "" {
+		code = gjson.GetBytes(body, "error.type").String()
+	}
+	event := `{"type":"error","code":"","message":"","param":null,"sequence_number":0}`
+	event, _ = sjson.Set(event, "code", code)
+	event, _ = sjson.Set(event, "message", gjson.GetBytes(body, "error.message").String())
+	event, _ = sjson.Set(event, "sequence_number", sequence)
+	return []byte("event: error\ndata: " + event)
+}
@@ -0,0 +1,223 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreresponses "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/responses"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// responseState carries what is needed to store the result of a /v1/responses call.
+type responseState struct {
+	store coreresponses.Store
+	owner string
+	input []byte
+}
+
+// responseStore returns the response store, or nil when it is disabled.
+func (h *OpenAIResponsesAPIHandler) responseStore() coreresponses.Store {
+	if h.Cfg != nil && h.Cfg.Responses.Store.Disable {
+		return nil
+	}
+	return coreresponses.DefaultStore()
+}
+
+// prepareResponseState expands previous_response_id into the input items of the stored
+// response chain and decides whether the result will be stored. It returns a nil state
+// when the store is disabled.
+func (h *OpenAIResponsesAPIHandler) prepareResponseState(c *gin.Context, body []byte) ([]byte, *responseState, *interfaces.ErrorMessage) {
+	store := h.responseStore()
+	if store == nil {
+		return body, nil, nil
+	}
+	state := &responseState{owner: responseOwner(c), input: normalizeResponsesInput(gjson.GetBytes(body, "input"))}
+	if previousID := strings.TrimSpace(gjson.GetBytes(body, "previous_response_id").String()); previousID != "" {
+		previous, err := store.Get(c.Request.Context(), previousID)
+		if err == nil && previous.Owner != state.owner {
+			err = coreresponses.ErrNotFound
+		}
+		if err != nil {
+			if errors.Is(err, coreresponses.ErrNotFound) {
+				return body, nil, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("previous response with id '%s' not found", previousID)}
+			}
+			return body, nil, &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: fmt.Errorf("load previous response: %w", err)}
+		}
+		state.input = joinItemArrays(previous.Input, replayableOutput(gjson.GetBytes(previous.Response, "output")), state.input)
+		body, _ = sjson.SetRawBytes(body, "input", state.input)
+	}
+	if stored := gjson.GetBytes(body, "store"); !stored.Exists() || stored.Bool() {
+		state.store = store
+	}
+	return body, state, nil
+}
+
+// saveResponse stores a completed response object. Failures are logged; the client
+// already has its response.
+func (s *responseState) saveResponse(ctx context.Context, response []byte) {
+	if s == nil || s.store == nil {
+		return
+	}
+	id := gjson.GetBytes(response, "id").String()
+	if id == "" || gjson.GetBytes(response, "status").String() == "failed" {
+		return
+	}
+	err := s.store.Put(context.WithoutCancel(ctx), &coreresponses.StoredResponse{
+		ID:        id,
+		Owner:     s.owner,
+		Model:     gjson.GetBytes(response, "model").String(),
+		CreatedAt: time.Now(),
+		Input:     s.input,
+		Response:  bytes.Clone(response),
+	})
+	if err != nil {
+		log.Warnf("responses store: save %s: %v", id, err)
+	}
+}
+
+// saveStreamChunk stores the response carried by a response.completed event.
+func (s *responseState) saveStreamChunk(ctx context.Context, chunk []byte) {
+	if s == nil || s.store == nil {
+		return
+	}
+	for _, line := range bytes.Split(chunk, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		payload := bytes.TrimSpace(line[len("data:"):])
+		if gjson.GetBytes(payload, "type").String() != "response.completed" {
+			continue
+		}
+		if response := gjson.GetBytes(payload, "response"); response.IsObject() {
+			s.saveResponse(ctx, []byte(response.Raw))
+		}
+	}
+}
+
+// GetResponse handles GET /v1/responses/{id}.
+func (h *OpenAIResponsesAPIHandler) GetResponse(c *gin.Context) {
+	stored, ok := h.lookupResponse(c)
+	if !ok {
+		return
+	}
+	c.Data(http.StatusOK, "application/json", stored.Response)
+}
+
+// DeleteResponse handles DELETE /v1/responses/{id}.
+func (h *OpenAIResponsesAPIHandler) DeleteResponse(c *gin.Context) {
+	stored, ok := h.lookupResponse(c)
+	if !ok {
+		return
+	}
+	if err := h.responseStore().Delete(c.Request.Context(), stored.ID); err != nil && !errors.Is(err, coreresponses.ErrNotFound) {
+		writeResponseStoreError(c, http.StatusInternalServerError, fmt.Sprintf("failed to delete response: %v", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": stored.ID, "object": "response", "deleted": true})
+}
+
+// lookupResponse loads the response named by the id path parameter, writing a 404 when it
+// is unknown, expired or owned by another client.
+func (h *OpenAIResponsesAPIHandler) lookupResponse(c *gin.Context) (*coreresponses.StoredResponse, bool) {
+	id := strings.TrimSpace(c.Param("id"))
+	notFound := fmt.Sprintf("response with id '%s' not found", id)
+	store := h.responseStore()
+	if store == nil || id == "" {
+		writeResponseStoreError(c, http.StatusNotFound, notFound)
+		return nil, false
+	}
+	stored, err := store.Get(c.Request.Context(), id)
+	if err == nil && stored.Owner != responseOwner(c) {
+		err = coreresponses.ErrNotFound
+	}
+	if err != nil {
+		if errors.Is(err, coreresponses.ErrNotFound) {
+			writeResponseStoreError(c, http.StatusNotFound, notFound)
+		} else {
+			writeResponseStoreError(c, http.StatusInternalServerError, fmt.Sprintf("failed to load response: %v", err))
+		}
+		return nil, false
+	}
+	return stored, true
+}
+
+func writeResponseStoreError(c *gin.Context, status int, message string) {
+	errType := "invalid_request_error"
+	if status >= http.StatusInternalServerError {
+		errType = "server_error"
+	}
+	c.JSON(status, handlers.ErrorResponse{Error: handlers.ErrorDetail{Message: message, Type: errType}})
+}
+
+// responseOwner identifies the authenticated client so stored responses are only visible
+// to the key that created them. The key itself is not kept.
+func responseOwner(c *gin.Context) string {
+	value, exists := c.Get("apiKey")
+	if !exists {
+		return ""
+	}
+	key := fmt.Sprint(value)
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeResponsesInput returns the input of a request as a JSON array of items.
+func normalizeResponsesInput(input gjson.Result) []byte {
+	switch {
+	case input.IsArray():
+		return []byte(input.Raw)
+	case input.Type == gjson.String:
+		item := `{"type":"message","role":"user","content":[{"type":"input_text","text":""}]}`
+		item, _ = sjson.Set(item, "content.0.text", input.String())
+		return []byte("[" + item + "]")
+	default:
+		return []byte("[]")
+	}
+}
+
+// replayableOutput converts the output items of a stored response into input items.
+// Item IDs are dropped because upstreams that do not persist items reject unknown IDs;
+// reasoning items are only kept when they carry encrypted content, which they need to be
+// replayed without server-side state.
+func replayableOutput(output gjson.Result) []byte {
+	items := make([][]byte, 0, len(output.Array()))
+	for _, item := range output.Array() {
+		raw := []byte(item.Raw)
+		if item.Get("type").String() == "reasoning" {
+			if item.Get("encrypted_content").String() == "" {
+				continue
+			}
+		} else {
+			raw, _ = sjson.DeleteBytes(raw, "id")
+		}
+		items = append(items, raw)
+	}
+	return append(append([]byte("["), bytes.Join(items, []byte(","))...), ']')
+}
+
+// joinItemArrays concatenates JSON arrays of items.
+func joinItemArrays(arrays ...[]byte) []byte {
+	var items [][]byte
+	for _, array := range arrays {
+		for _, item := range gjson.ParseBytes(array).Array() {
+			items = append(items, []byte(item.Raw))
+		}
+	}
+	return append(append([]byte("["), bytes.Join(items, []byte(","))...), ']')
+}
@@ -0,0 +1,174 @@
+package openai
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// structuredOutputCheck validates model output against the structured output requested with
+// response_format or text.format. Upstreams that have no native structured outputs only
+// receive the schema as a hint, so their output is checked before it reaches the client.
+type structuredOutputCheck struct {
+	output *util.StructuredOutput
+	field  string
+
+	// streaming state for chat completions
+	content      strings.Builder
+	finishReason string
+	toolCalls    bool
+}
+
+// newChatStructuredOutputCheck returns a check for a Chat Completions request, or nil when
+// the request does not ask for enforced structured output.
+func newChatStructuredOutputCheck(rawJSON []byte) *structuredOutputCheck {
+	if output := util.ChatCompletionsStructuredOutput(rawJSON); enforced(output) {
+		return &structuredOutputCheck{output: output, field: "response_format"}
+	}
+	return nil
+}
+
+// newResponsesStructuredOutputCheck returns a check for a Responses request, or nil when the
+// request does not ask for enforced structured output.
+func newResponsesStructuredOutputCheck(rawJSON []byte) *structuredOutputCheck {
+	if output := util.ResponsesStructuredOutput(rawJSON); enforced(output) {
+		return &structuredOutputCheck{output: output, field: "text.format"}
+	}
+	return nil
+}
+
+// enforced reports whether output is rejected when it does not conform: json_object always
+// requires valid JSON, while a JSON schema is only binding with strict set. Without strict
+// the schema stays a hint, as it is for OpenAI.
+func enforced(output *util.StructuredOutput) bool {
+	return output != nil && (output.Schema == "" || output.Strict)
+}
+
+// validate checks content and describes the mismatch; truncated marks output that was cut
+// off by the token limit.
+func (s *structuredOutputCheck) validate(content string, truncated bool) error {
+	err := util.ValidateJSON(s.output.Schema, content)
+	if err == nil {
+		return nil
+	}
+	target := "JSON object"
+	if s.output.Schema != "" {
+		target = "JSON schema"
+		if s.output.Name != "" {
+			target = fmt.Sprintf("JSON schema %q", s.output.Name)
+		}
+	}
+	reason := ""
+	if truncated {
+		reason = " (the output was truncated by the token limit)"
+	}
+	return fmt.Errorf("model output does not conform to the %s requested in %s%s: %v", target, s.field, reason, err)
+}
+
+func structuredOutputError(err error) *interfaces.ErrorMessage {
+	return &interfaces.ErrorMessage{StatusCode: http.StatusBadGateway, Error: err}
+}
+
+// checkChatCompletion validates a non-streaming chat completion. Responses that call tools
+// instead of answering are not checked.
+func (s *structuredOutputCheck) checkChatCompletion(resp []byte) *interfaces.ErrorMessage {
+	if s == nil {
+		return nil
+	}
+	choice := gjson.GetBytes(resp, "choices.0")
+	if !choice.Exists() || choice.Get("finish_reason").String() == "tool_calls" || len(choice.Get("message.tool_calls").Array()) > 0 {
+		return nil
+	}
+	if err := s.validate(choice.Get("message.content").String(), choice.Get("finish_reason").String() == "length"); err != nil {
+		return structuredOutputError(err)
+	}
+	return nil
+}
+
+// observeChatChunk records the content of a chat completion stream chunk.
+func (s *structuredOutputCheck) observeChatChunk(chunk []byte) {
+	if s == nil {
+		return
+	}
+	choice := gjson.GetBytes(chunk, "choices.0")
+	s.content.WriteString(choice.Get("delta.content").String())
+	if choice.Get("delta.tool_calls").Exists() {
+		s.toolCalls = true
+	}
+	if reason := choice.Get("finish_reason").String(); reason != "" {
+		s.finishReason = reason
+	}
+}
+
+// checkChatStream validates the content collected from a chat completion stream.
+func (s *structuredOutputCheck) checkChatStream() *interfaces.ErrorMessage {
+	if s == nil || s.toolCalls || s.finishReason == "tool_calls" {
+		return nil
+	}
+	if err := s.validate(s.content.String(), s.finishReason == "length"); err != nil {
+		return structuredOutputError(err)
+	}
+	return nil
+}
+
+// checkResponse validates the message text of a Responses object. Responses that only call
+// tools are not checked.
+func (s *structuredOutputCheck) checkResponse(response []byte) *interfaces.ErrorMessage {
+	if s == nil || gjson.GetBytes(response, "status").String() == "failed" {
+		return nil
+	}
+	var text strings.Builder
+	hasMessage := false
+	for _, item := range gjson.GetBytes(response, "output").Array() {
+		if item.Get("type").String() != "message" {
+			continue
+		}
+		hasMessage = true
+		for _, part := range item.Get("content").Array() {
+			if part.Get("type").String() == "output_text" {
+				text.WriteString(part.Get("text").String())
+			}
+		}
+	}
+	if !hasMessage {
+		return nil
+	}
+	truncated := gjson.GetBytes(response, "incomplete_details.reason").String() == "max_output_tokens"
+	if err := s.validate(text.String(), truncated); err != nil {
+		return structuredOutputError(err)
+	}
+	return nil
+}
+
+// checkResponsesStreamChunk validates the response carried by a response.completed event
+// and returns the error event to send after it when the output does not conform.
+func (s *structuredOutputCheck) checkResponsesStreamChunk(chunk []byte) ([]byte, *interfaces.ErrorMessage) {
+	if s == nil {
+		return nil, nil
+	}
+	for _, line := range bytes.Split(chunk, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		payload := bytes.TrimSpace(line[len("data:"):])
+		if gjson.GetBytes(payload, "type").String() != "response.completed" {
+			continue
+		}
+		errMsg := s.checkResponse([]byte(gjson.GetBytes(payload, "response").Raw))
+		if errMsg == nil {
+			return nil, nil
+		}
+		event := `{"type":"error","code":"invalid_structured_output","message":"","param":null,"sequence_number":0}`
+		event, _ = sjson.Set(event, "message", errMsg.Error.Error())
+		event, _ = sjson.Set(event, "sequence_number", gjson.GetBytes(payload, "sequence_number").Int()+1)
+		return []byte("event: error\ndata: " + event), errMsg
+	}
+	return nil, nil
+}
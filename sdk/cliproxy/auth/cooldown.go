@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+const (
+	defaultUnauthorizedCooldown    = 30 * time.Minute
+	defaultPaymentRequiredCooldown = 30 * time.Minute
+	defaultRateLimitedCooldown     = 30 * time.Minute
+	defaultTransientCooldown       = time.Minute
+)
+
+// CooldownPolicy holds the default cooldowns applied by MarkResult per status class
+// when the upstream did not report when the auth becomes usable again.
+type CooldownPolicy struct {
+	// Unauthorized applies to 401 responses.
+	Unauthorized time.Duration
+	// PaymentRequired applies to 402 and 403 responses.
+	PaymentRequired time.Duration
+	// RateLimited applies to 429 responses.
+	RateLimited time.Duration
+	// Transient applies to 408 and 5xx responses.
+	Transient time.Duration
+}
+
+// normalized returns a copy of the policy with defaults applied to unset fields.
+func (p CooldownPolicy) normalized() CooldownPolicy {
+	if p.Unauthorized <= 0 {
+		p.Unauthorized = defaultUnauthorizedCooldown
+	}
+	if p.PaymentRequired <= 0 {
+		p.PaymentRequired = defaultPaymentRequiredCooldown
+	}
+	if p.RateLimited <= 0 {
+		p.RateLimited = defaultRateLimitedCooldown
+	}
+	if p.Transient <= 0 {
+		p.Transient = defaultTransientCooldown
+	}
+	return p
+}
+
+// Max returns the longest cooldown of the policy after defaults are applied. Upstream
+// reset hints are capped at this value.
+func (p CooldownPolicy) Max() time.Duration {
+	p = p.normalized()
+	longest := p.Unauthorized
+	for _, d := range []time.Duration{p.PaymentRequired, p.RateLimited, p.Transient} {
+		if d > longest {
+			longest = d
+		}
+	}
+	return longest
+}
+
+// cooldownFor returns how long an auth stays blocked after a failure with statusCode.
+// An upstream supplied retryAfter takes precedence over the default for the status class.
+// The boolean is false for statuses that do not trigger a cooldown.
+func (p CooldownPolicy) cooldownFor(statusCode int, retryAfter *time.Duration) (time.Duration, bool) {
+	var fallback time.Duration
+	switch statusCode {
+	case 401:
+		fallback = p.Unauthorized
+	case 402, 403:
+		fallback = p.PaymentRequired
+	case 429:
+		fallback = p.RateLimited
+	case 408, 500, 502, 503, 504:
+		fallback = p.Transient
+	default:
+		return 0, false
+	}
+	if retryAfter != nil && *retryAfter > 0 {
+		return *retryAfter, true
+	}
+	return fallback, true
+}
+
+// SetCooldownPolicy replaces the default cooldowns used when results carry no RetryAfter.
+func (m *Manager) SetCooldownPolicy(policy CooldownPolicy) {
+	m.mu.Lock()
+	m.cooldowns = policy.normalized()
+	m.mu.Unlock()
+}
+
+// CooldownPolicy returns the currently configured default cooldowns.
+func (m *Manager) CooldownPolicy() CooldownPolicy {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cooldowns
+}
+
+// failureResult builds the Result recorded for a failed executor call, copying the
+// status code and any upstream reset hint carried by err.
+func failureResult(authID, provider, model string, err error) Result {
+	result := Result{AuthID: authID, Provider: provider, Model: model, Success: false}
+	if err == nil {
+		return result
+	}
+	result.Error = &Error{Message: err.Error()}
+	var se cliproxyexecutor.StatusError
+	if errors.As(err, &se) && se != nil {
+		result.Error.HTTPStatus = se.StatusCode()
+	}
+	var ra cliproxyexecutor.RetryAfterError
+	if errors.As(err, &ra) && ra != nil {
+		if wait := ra.RetryAfter(); wait != nil && *wait > 0 {
+			retryAfter := *wait
+			result.RetryAfter = &retryAfter
+		}
+	}
+	return result
+}
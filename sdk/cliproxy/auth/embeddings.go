@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	log "github.com/sirupsen/logrus"
+)
+
+// EmbeddingExecutor is implemented by provider executors that can create embeddings.
+// Executors without it are skipped when routing embedding requests.
+type EmbeddingExecutor interface {
+	// Embed creates embeddings for the request payload, which is in opts.SourceFormat, and
+	// returns them in the same format.
+	Embed(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error)
+}
+
+// ExecuteEmbed creates embeddings using the configured selector and the providers whose
+// executor implements EmbeddingExecutor. Providers are rotated per model like Execute.
+// Model fallbacks are not applied: vectors of another model live in a different space.
+func (m *Manager) ExecuteEmbed(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	ctx, settle := withRequestAttempts(ctx)
+	defer settle()
+	return m.executeEmbedModel(ctx, providers, req, opts)
+}
+
+func (m *Manager) executeEmbedModel(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	normalized := m.normalizeProviders(providers)
+	if len(normalized) == 0 {
+		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "no provider supplied"}
+	}
+	embedding := make([]string, 0, len(normalized))
+	for _, provider := range normalized {
+		if _, ok := m.executorFor(provider).(EmbeddingExecutor); ok {
+			embedding = append(embedding, provider)
+		}
+	}
+	if len(embedding) == 0 {
+		return cliproxyexecutor.Response{}, &Error{
+			Code:       "embeddings_not_supported",
+			Message:    fmt.Sprintf("model %s is not served by a provider that supports embeddings", req.Model),
+			HTTPStatus: http.StatusBadRequest,
+		}
+	}
+	rotated := m.rotateProviders(req.Model, embedding)
+	defer m.advanceProviderCursor(req.Model, embedding)
+
+	var lastErr error
+	for round := 0; ; round++ {
+		attemptCtx := withRetryAttempt(ctx, round)
+		for _, provider := range rotated {
+			resp, errExec := m.executeEmbedWithProvider(attemptCtx, provider, req, opts)
+			if errExec == nil {
+				return resp, nil
+			}
+			lastErr = errExec
+		}
+		if !m.waitForRetry(ctx, round, lastErr, rotated, req.Model) {
+			break
+		}
+	}
+	if lastErr != nil {
+		return cliproxyexecutor.Response{}, m.withRetryAfter(lastErr, rotated, req.Model)
+	}
+	return cliproxyexecutor.Response{}, &Error{Code: "auth_not_found", Message: "no auth available"}
+}
+
+func (m *Manager) executeEmbedWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	if provider == "" {
+		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "provider identifier is empty"}
+	}
+	tried := make(map[string]struct{})
+	var lastErr error
+	for {
+		auth, executor, release, errPick := m.pickNext(ctx, provider, req.Model, opts, tried)
+		if errPick != nil {
+			if lastErr != nil {
+				return cliproxyexecutor.Response{}, lastErr
+			}
+			return cliproxyexecutor.Response{}, errPick
+		}
+		embedder, ok := executor.(EmbeddingExecutor)
+		if !ok {
+			return cliproxyexecutor.Response{}, &Error{Code: "embeddings_not_supported", Message: "executor does not support embeddings", HTTPStatus: http.StatusBadRequest}
+		}
+
+		accountType, accountInfo := auth.AccountInfo()
+		if accountType == "api_key" {
+			log.Debugf("Use API key %s for model %s", util.HideAPIKey(accountInfo), req.Model)
+		} else if accountType == "oauth" {
+			log.Debugf("Use OAuth %s for model %s", accountInfo, req.Model)
+		}
+
+		tried[auth.ID] = struct{}{}
+		execCtx := ctx
+		if rt := m.roundTripperFor(auth); rt != nil {
+			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
+			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
+		}
+		// Embedding payloads are not translated, so hooks see them as the client sent them.
+		execCtx, hookCtx, hooks := m.beforeExecute(execCtx, nil, auth, req, opts, true)
+		tried[hookCtx.Auth.ID] = struct{}{}
+		resp, errExec := embedder.Embed(execCtx, hookCtx.Auth, hookCtx.Request, hookCtx.Options)
+		release()
+		afterExecute(execCtx, hooks, hookCtx, resp, errExec)
+		if errExec != nil {
+			m.MarkResult(execCtx, failureResult(hookCtx.Auth.ID, provider, req.Model, errExec))
+			lastErr = errExec
+			continue
+		}
+		m.MarkResult(execCtx, Result{AuthID: hookCtx.Auth.ID, Provider: provider, Model: req.Model, Success: true})
+		return resp, nil
+	}
+}
@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+// ExecutionContext encapsulates the state of a single executor call shared with execution hooks.
+// It is exposed to SDK users as pipeline.Context.
+type ExecutionContext struct {
+	// Request is the request handed to the provider executor. When the executor implements
+	// RequestFormatter, Payload holds the request translated into the provider format.
+	// Hooks may replace Payload or Metadata before the executor runs.
+	Request cliproxyexecutor.Request
+	// Options carries execution flags (streaming, headers, etc.).
+	Options cliproxyexecutor.Options
+	// Auth references the credential selected for execution.
+	Auth *Auth
+	// Translator is the pipeline that translated Request into the provider format.
+	Translator *sdktranslator.Pipeline
+	// HTTPClient, when set by a hook, replaces the outbound client built by the executor.
+	HTTPClient *http.Client
+
+	// span traces the attempt; it is ended by afterExecute.
+	span *tracing.Span
+	// usage collects the attempt's usage record; it is published by afterExecute.
+	usage *coreusage.Attempt
+	// sourcePayload is the client payload the executor translates itself; the translated
+	// Request.Payload reaches it through the context.
+	sourcePayload []byte
+}
+
+// executorRequest returns the request to hand to the executor: Request as left by the
+// hooks, with the payload still in the client format when it was translated for them.
+func (e *ExecutionContext) executorRequest() cliproxyexecutor.Request {
+	req := e.Request
+	if e.sourcePayload != nil {
+		req.Payload = e.sourcePayload
+	}
+	return req
+}
+
+// RequestFormatter is implemented by executors that translate requests into a provider
+// format before sending them upstream. When execution hooks are registered, the manager
+// translates the request the same way so hooks see, and may edit, the provider payload.
+type RequestFormatter interface {
+	// RequestFormat returns the provider format requests with opts are translated into
+	// and whether the streaming translator is used.
+	RequestFormat(opts cliproxyexecutor.Options) (format sdktranslator.Format, stream bool)
+}
+
+// ExecutionHook captures middleware callbacks around every executor call.
+// It is exposed to SDK users as pipeline.Hook.
+type ExecutionHook interface {
+	// BeforeExecute runs after an auth was selected and before the executor is invoked.
+	BeforeExecute(ctx context.Context, execCtx *ExecutionContext)
+	// AfterExecute runs once the executor returned; for streams, after the last chunk.
+	AfterExecute(ctx context.Context, execCtx *ExecutionContext, resp cliproxyexecutor.Response, err error)
+	// OnStreamChunk runs for every chunk emitted by a streaming executor.
+	OnStreamChunk(ctx context.Context, execCtx *ExecutionContext, chunk cliproxyexecutor.StreamChunk)
+}
+
+// RegisterExecutionHook appends a hook invoked around every executor call.
+func (m *Manager) RegisterExecutionHook(hook ExecutionHook) {
+	if hook == nil {
+		return
+	}
+	m.mu.Lock()
+	m.execHooks = append(m.execHooks, hook)
+	m.mu.Unlock()
+}
+
+// UnregisterExecutionHook removes a hook added with RegisterExecutionHook.
+func (m *Manager) UnregisterExecutionHook(hook ExecutionHook) {
+	if hook == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, registered := range m.execHooks {
+		if registered == hook {
+			// Copy so hook lists handed out by executionHooks stay intact.
+			m.execHooks = append(m.execHooks[:i:i], m.execHooks[i+1:]...)
+			return
+		}
+	}
+}
+
+func (m *Manager) executionHooks() []ExecutionHook {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.execHooks
+}
+
+// beforeExecute builds the execution context for an attempt, runs BeforeExecute hooks
+// and returns the context the executor should run with. Hooks see the request translated
+// into the provider format when executor implements RequestFormatter. When trackUsage is
+// set the attempt publishes a usage record once afterExecute runs.
+func (m *Manager) beforeExecute(ctx context.Context, executor any, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, trackUsage bool) (context.Context, *ExecutionContext, []ExecutionHook) {
+	hooks := m.executionHooks()
+	ctx, span := tracing.StartSpan(ctx, "auth attempt", tracing.SpanKindInternal)
+	span.SetAttribute("model", req.Model)
+	if fallback, ok := ModelFallbackFromContext(ctx); ok {
+		span.SetAttribute("model.requested", fallback.Requested)
+	}
+	span.SetAttribute("provider", auth.Provider)
+	span.SetAttribute("auth.id", auth.ID)
+	span.SetAttribute("stream", opts.Stream)
+	execCtx := &ExecutionContext{Request: req, Options: opts, Auth: auth, span: span}
+	if trackUsage {
+		execCtx.usage = newUsageAttempt(ctx, auth, req, opts)
+		ctx = coreusage.WithAttempt(ctx, execCtx.usage)
+	}
+	if len(hooks) == 0 {
+		return ctx, execCtx, nil
+	}
+	execCtx.Translator = sdktranslator.NewPipeline(nil)
+	formatter, translate := executor.(RequestFormatter)
+	var translated translatedRequest
+	if translate {
+		translated.format, translated.stream = formatter.RequestFormat(opts)
+		envelope, err := execCtx.Translator.TranslateRequest(ctx, opts.SourceFormat, translated.format, sdktranslator.RequestEnvelope{
+			Format: opts.SourceFormat,
+			Model:  req.Model,
+			Stream: translated.stream,
+			Body:   bytes.Clone(req.Payload),
+		})
+		translate = err == nil
+		if translate {
+			execCtx.sourcePayload = req.Payload
+			execCtx.Request.Payload = envelope.Body
+		}
+	}
+	for _, hook := range hooks {
+		hook.BeforeExecute(ctx, execCtx)
+	}
+	if execCtx.Auth == nil {
+		execCtx.Auth = auth
+	}
+	if translate {
+		translated.model = execCtx.Request.Model
+		translated.payload = execCtx.Request.Payload
+		ctx = context.WithValue(ctx, translatedRequestContextKey{}, translated)
+	}
+	if execCtx.HTTPClient != nil {
+		ctx = context.WithValue(ctx, httpClientContextKey{}, execCtx.HTTPClient)
+	}
+	return ctx, execCtx, hooks
+}
+
+func afterExecute(ctx context.Context, hooks []ExecutionHook, execCtx *ExecutionContext, resp cliproxyexecutor.Response, err error) {
+	for _, hook := range hooks {
+		hook.AfterExecute(ctx, execCtx, resp, err)
+	}
+	status := attemptStatus(err)
+	if span := execCtx.span; span != nil {
+		if err != nil {
+			span.SetError(err)
+		}
+		if status > 0 {
+			span.SetAttribute("status", status)
+		}
+		span.End()
+	}
+	execCtx.usage.End(status, usageErrorClass(err, status))
+	publishAttempt(ctx, execCtx.usage, err)
+}
+
+func onStreamChunk(ctx context.Context, hooks []ExecutionHook, execCtx *ExecutionContext, chunk cliproxyexecutor.StreamChunk) {
+	for _, hook := range hooks {
+		hook.OnStreamChunk(ctx, execCtx, chunk)
+	}
+}
+
+// translatedRequestContextKey is an unexported context key type to avoid collisions.
+type translatedRequestContextKey struct{}
+
+// translatedRequest is a request payload translated for execution hooks.
+type translatedRequest struct {
+	format  sdktranslator.Format
+	model   string
+	stream  bool
+	payload []byte
+}
+
+// TranslatedRequestFromContext returns the request payload translated into format for
+// execution hooks, as they left it, when it was translated for model with the same stream
+// flag. Executors send it instead of translating the client payload again; translations
+// for another model, such as provider-side fallbacks, start from the client payload.
+func TranslatedRequestFromContext(ctx context.Context, format sdktranslator.Format, model string, stream bool) ([]byte, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	translated, ok := ctx.Value(translatedRequestContextKey{}).(translatedRequest)
+	if !ok || translated.format != format || translated.model != model || translated.stream != stream {
+		return nil, false
+	}
+	return bytes.Clone(translated.payload), true
+}
+
+// httpClientContextKey is an unexported context key type to avoid collisions.
+type httpClientContextKey struct{}
+
+// HTTPClientFromContext returns the outbound HTTP client installed by an execution hook, if any.
+func HTTPClientFromContext(ctx context.Context) *http.Client {
+	if ctx == nil {
+		return nil
+	}
+	client, _ := ctx.Value(httpClientContextKey{}).(*http.Client)
+	return client
+}
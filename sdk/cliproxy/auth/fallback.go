@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// SetModelFallbacks replaces the fallback chains consulted when every credential for a
+// requested model failed. Keys are requested model names; values are ordered fallbacks.
+func (m *Manager) SetModelFallbacks(fallbacks map[string][]string) {
+	chains := make(map[string][]string, len(fallbacks))
+	for model, chain := range fallbacks {
+		key := strings.TrimSpace(model)
+		if key == "" {
+			continue
+		}
+		cleaned := make([]string, 0, len(chain))
+		for _, fallback := range chain {
+			if trimmed := strings.TrimSpace(fallback); trimmed != "" && trimmed != key {
+				cleaned = append(cleaned, trimmed)
+			}
+		}
+		if len(cleaned) > 0 {
+			chains[key] = cleaned
+		}
+	}
+	m.mu.Lock()
+	m.fallbacks = chains
+	m.mu.Unlock()
+}
+
+// ModelFallbacks returns the fallback chain configured for model.
+func (m *Manager) ModelFallbacks(model string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	chain := m.fallbacks[model]
+	if len(chain) == 0 {
+		return nil
+	}
+	return append([]string(nil), chain...)
+}
+
+// shouldFallback reports whether a failure of the requested model may be served by a fallback.
+func shouldFallback(err error) bool {
+	if err == nil {
+		return false
+	}
+	var authErr *Error
+	if errors.As(err, &authErr) && authErr != nil && authErr.Code == "auth_not_found" {
+		return true
+	}
+	return isRetryableError(err)
+}
+
+// executeWithFallbacks runs exec for the requested model and, when it fails with an error
+// a different model may recover from, walks the configured fallback chain in order.
+// The payload stays in the client's format, so translators re-target it for every fallback.
+func (m *Manager) executeWithFallbacks(ctx context.Context, providers []string, req cliproxyexecutor.Request, exec func(context.Context, []string, cliproxyexecutor.Request) error) error {
+	ctx, settle := withRequestAttempts(ctx)
+	defer settle()
+	err := exec(ctx, providers, req)
+	if err == nil || !shouldFallback(err) {
+		return err
+	}
+	requested := req.Model
+	for _, fallback := range m.ModelFallbacks(requested) {
+		if ctx.Err() != nil {
+			break
+		}
+		if !modelAllowed(ctx, fallback) {
+			log.Debugf("model fallback %s for %s skipped: not allowed for this request", fallback, requested)
+			continue
+		}
+		fallbackProviders := util.GetProviderName(fallback)
+		if len(fallbackProviders) == 0 {
+			log.Debugf("model fallback %s for %s skipped: no provider serves it", fallback, requested)
+			continue
+		}
+		log.Debugf("model %s unavailable, falling back to %s: %v", requested, fallback, err)
+		fallbackReq := req
+		fallbackReq.Model = fallback
+		if gjson.GetBytes(req.Payload, "model").Exists() {
+			if payload, errSet := sjson.SetBytes(req.Payload, "model", fallback); errSet == nil {
+				fallbackReq.Payload = payload
+			}
+		}
+		fallbackCtx := context.WithValue(ctx, modelFallbackContextKey{}, ModelFallback{Requested: requested, Model: fallback})
+		errFallback := exec(fallbackCtx, fallbackProviders, fallbackReq)
+		if errFallback == nil {
+			notifyModelFallback(ctx, requested, fallback)
+			return nil
+		}
+		if !shouldFallback(errFallback) {
+			return errFallback
+		}
+	}
+	return err
+}
+
+// ModelFallback describes a request that was served by a fallback model.
+type ModelFallback struct {
+	// Requested is the model the client asked for.
+	Requested string
+	// Model is the fallback model that actually served the request.
+	Model string
+}
+
+// modelFallbackContextKey is an unexported context key type to avoid collisions.
+type modelFallbackContextKey struct{}
+
+// ModelFallbackFromContext returns the fallback an execution runs under, if any.
+func ModelFallbackFromContext(ctx context.Context) (ModelFallback, bool) {
+	if ctx == nil {
+		return ModelFallback{}, false
+	}
+	fallback, ok := ctx.Value(modelFallbackContextKey{}).(ModelFallback)
+	return fallback, ok
+}
+
+// modelFilterContextKey is an unexported context key type to avoid collisions.
+type modelFilterContextKey struct{}
+
+// WithModelFilter returns a context whose executions only fall back to models for which
+// allow returns true, e.g. the models the policy of the client API key permits.
+func WithModelFilter(ctx context.Context, allow func(model string) bool) context.Context {
+	if allow == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, modelFilterContextKey{}, allow)
+}
+
+func modelAllowed(ctx context.Context, model string) bool {
+	if allow, ok := ctx.Value(modelFilterContextKey{}).(func(model string) bool); ok && allow != nil {
+		return allow(model)
+	}
+	return true
+}
+
+// modelFallbackObserverKey is an unexported context key type to avoid collisions.
+type modelFallbackObserverKey struct{}
+
+// WithModelFallbackObserver returns a context whose executions report, through fn, the
+// fallback model that served the request when the requested model was unavailable.
+func WithModelFallbackObserver(ctx context.Context, fn func(requested, fallback string)) context.Context {
+	if fn == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, modelFallbackObserverKey{}, fn)
+}
+
+func notifyModelFallback(ctx context.Context, requested, fallback string) {
+	if fn, ok := ctx.Value(modelFallbackObserverKey{}).(func(requested, fallback string)); ok && fn != nil {
+		fn(requested, fallback)
+	}
+}
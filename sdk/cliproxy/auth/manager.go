@@ -3,7 +3,6 @@ package auth
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"net/http"
 	"strconv"
 	"strings"
@@ -14,6 +13,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -54,6 +54,9 @@ type Result struct {
 	Success bool
 	// Error describes the failure when Success is false.
 	Error *Error
+	// RetryAfter, when set, is the upstream reported delay before the auth may be used
+	// again. It overrides the default cooldown for the failure's status class.
+	RetryAfter *time.Duration
 }
 
 // Selector chooses an auth candidate for execution.
@@ -97,6 +100,16 @@ type Manager struct {
 	// Optional HTTP RoundTripper provider injected by host.
 	rtProvider RoundTripperProvider
 
+	// retry controls how failed rounds across all candidate auths are repeated.
+	retry RetryPolicy
+	// cooldowns holds the default per-status cooldowns applied by MarkResult.
+	cooldowns CooldownPolicy
+	// fallbacks maps requested models to ordered fallback models.
+	fallbacks map[string][]string
+
+	// execHooks run around every executor call.
+	execHooks []ExecutionHook
+
 	// Auto refresh state
 	refreshCancel context.CancelFunc
 }
@@ -116,6 +129,8 @@ func NewManager(store Store, selector Selector, hook Hook) *Manager {
 		hook:            hook,
 		auths:           make(map[string]*Auth),
 		providerOffsets: make(map[string]int),
+		retry:           RetryPolicy{}.normalized(),
+		cooldowns:       CooldownPolicy{}.normalized(),
 	}
 }
 
@@ -133,6 +148,18 @@ func (m *Manager) SetRoundTripperProvider(p RoundTripperProvider) {
 	m.mu.Unlock()
 }
 
+// SetSelector swaps the auth selection strategy. Executions already in flight keep
+// the auth they picked and release it to the selector that picked it; subsequent picks
+// use the new selector.
+func (m *Manager) SetSelector(selector Selector) {
+	if selector == nil {
+		selector = &RoundRobinSelector{}
+	}
+	m.mu.Lock()
+	m.selector = selector
+	m.mu.Unlock()
+}
+
 // RegisterExecutor registers a provider executor with the manager.
 func (m *Manager) RegisterExecutor(executor ProviderExecutor) {
 	if executor == nil {
@@ -195,7 +222,19 @@ func (m *Manager) Load(ctx context.Context) error {
 
 // Execute performs a non-streaming execution using the configured selector and executor.
 // It supports multiple providers for the same model and round-robins the starting provider per model.
+// When every candidate fails with a retryable error, the whole provider list is retried per the RetryPolicy,
+// after which the model fallback chain configured for req.Model is tried in order.
 func (m *Manager) Execute(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	var resp cliproxyexecutor.Response
+	err := m.executeWithFallbacks(ctx, providers, req, func(execCtx context.Context, execProviders []string, execReq cliproxyexecutor.Request) error {
+		var errExec error
+		resp, errExec = m.executeModel(execCtx, execProviders, execReq, opts)
+		return errExec
+	})
+	return resp, err
+}
+
+func (m *Manager) executeModel(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
 	normalized := m.normalizeProviders(providers)
 	if len(normalized) == 0 {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "no provider supplied"}
@@ -204,22 +243,35 @@ func (m *Manager) Execute(ctx context.Context, providers []string, req cliproxye
 	defer m.advanceProviderCursor(req.Model, normalized)
 
 	var lastErr error
-	for _, provider := range rotated {
-		resp, errExec := m.executeWithProvider(ctx, provider, req, opts)
-		if errExec == nil {
-			return resp, nil
+	for round := 0; ; round++ {
+		attemptCtx := withRetryAttempt(ctx, round)
+		for _, provider := range rotated {
+			resp, errExec := m.executeWithProvider(attemptCtx, provider, req, opts)
+			if errExec == nil {
+				return resp, nil
+			}
+			lastErr = errExec
+		}
+		if !m.waitForRetry(ctx, round, lastErr, rotated, req.Model) {
+			break
 		}
-		lastErr = errExec
 	}
 	if lastErr != nil {
-		return cliproxyexecutor.Response{}, lastErr
+		return cliproxyexecutor.Response{}, m.withRetryAfter(lastErr, rotated, req.Model)
 	}
 	return cliproxyexecutor.Response{}, &Error{Code: "auth_not_found", Message: "no auth available"}
 }
 
 // ExecuteCount performs a non-streaming execution using the configured selector and executor.
 // It supports multiple providers for the same model and round-robins the starting provider per model.
+// Model fallbacks are not applied, since another model's tokenizer would report a wrong count.
 func (m *Manager) ExecuteCount(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	ctx, settle := withRequestAttempts(ctx)
+	defer settle()
+	return m.executeCountModel(ctx, providers, req, opts)
+}
+
+func (m *Manager) executeCountModel(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
 	normalized := m.normalizeProviders(providers)
 	if len(normalized) == 0 {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "no provider supplied"}
@@ -228,22 +280,41 @@ func (m *Manager) ExecuteCount(ctx context.Context, providers []string, req clip
 	defer m.advanceProviderCursor(req.Model, normalized)
 
 	var lastErr error
-	for _, provider := range rotated {
-		resp, errExec := m.executeCountWithProvider(ctx, provider, req, opts)
-		if errExec == nil {
-			return resp, nil
+	for round := 0; ; round++ {
+		attemptCtx := withRetryAttempt(ctx, round)
+		for _, provider := range rotated {
+			resp, errExec := m.executeCountWithProvider(attemptCtx, provider, req, opts)
+			if errExec == nil {
+				return resp, nil
+			}
+			lastErr = errExec
+		}
+		if !m.waitForRetry(ctx, round, lastErr, rotated, req.Model) {
+			break
 		}
-		lastErr = errExec
 	}
 	if lastErr != nil {
-		return cliproxyexecutor.Response{}, lastErr
+		return cliproxyexecutor.Response{}, m.withRetryAfter(lastErr, rotated, req.Model)
 	}
 	return cliproxyexecutor.Response{}, &Error{Code: "auth_not_found", Message: "no auth available"}
 }
 
 // ExecuteStream performs a streaming execution using the configured selector and executor.
 // It supports multiple providers for the same model and round-robins the starting provider per model.
+// Streams that fail before producing their first payload chunk, or whose first event is an
+// in-band error, transparently fail over to the next auth or provider; once payload has been
+// returned, errors are forwarded to the caller.
 func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	var chunks <-chan cliproxyexecutor.StreamChunk
+	err := m.executeWithFallbacks(ctx, providers, req, func(execCtx context.Context, execProviders []string, execReq cliproxyexecutor.Request) error {
+		var errStream error
+		chunks, errStream = m.executeStreamModel(execCtx, execProviders, execReq, opts)
+		return errStream
+	})
+	return chunks, err
+}
+
+func (m *Manager) executeStreamModel(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
 	normalized := m.normalizeProviders(providers)
 	if len(normalized) == 0 {
 		return nil, &Error{Code: "provider_not_found", Message: "no provider supplied"}
@@ -252,15 +323,21 @@ func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cli
 	defer m.advanceProviderCursor(req.Model, normalized)
 
 	var lastErr error
-	for _, provider := range rotated {
-		chunks, errStream := m.executeStreamWithProvider(ctx, provider, req, opts)
-		if errStream == nil {
-			return chunks, nil
+	for round := 0; ; round++ {
+		attemptCtx := withRetryAttempt(ctx, round)
+		for _, provider := range rotated {
+			chunks, errStream := m.executeStreamWithProvider(attemptCtx, provider, req, opts)
+			if errStream == nil {
+				return chunks, nil
+			}
+			lastErr = errStream
+		}
+		if !m.waitForRetry(ctx, round, lastErr, rotated, req.Model) {
+			break
 		}
-		lastErr = errStream
 	}
 	if lastErr != nil {
-		return nil, lastErr
+		return nil, m.withRetryAfter(lastErr, rotated, req.Model)
 	}
 	return nil, &Error{Code: "auth_not_found", Message: "no auth available"}
 }
@@ -272,7 +349,7 @@ func (m *Manager) executeWithProvider(ctx context.Context, provider string, req
 	tried := make(map[string]struct{})
 	var lastErr error
 	for {
-		auth, executor, errPick := m.pickNext(ctx, provider, req.Model, opts, tried)
+		auth, executor, release, errPick := m.pickNext(ctx, provider, req.Model, opts, tried)
 		if errPick != nil {
 			if lastErr != nil {
 				return cliproxyexecutor.Response{}, lastErr
@@ -293,19 +370,17 @@ func (m *Manager) executeWithProvider(ctx context.Context, provider string, req
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
 		}
-		resp, errExec := executor.Execute(execCtx, auth, req, opts)
-		result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: errExec == nil}
+		execCtx, hookCtx, hooks := m.beforeExecute(execCtx, executor, auth, req, opts, true)
+		tried[hookCtx.Auth.ID] = struct{}{}
+		resp, errExec := executor.Execute(execCtx, hookCtx.Auth, hookCtx.executorRequest(), hookCtx.Options)
+		release()
+		afterExecute(execCtx, hooks, hookCtx, resp, errExec)
 		if errExec != nil {
-			result.Error = &Error{Message: errExec.Error()}
-			var se cliproxyexecutor.StatusError
-			if errors.As(errExec, &se) && se != nil {
-				result.Error.HTTPStatus = se.StatusCode()
-			}
-			m.MarkResult(execCtx, result)
+			m.MarkResult(execCtx, failureResult(hookCtx.Auth.ID, provider, req.Model, errExec))
 			lastErr = errExec
 			continue
 		}
-		m.MarkResult(execCtx, result)
+		m.MarkResult(execCtx, Result{AuthID: hookCtx.Auth.ID, Provider: provider, Model: req.Model, Success: true})
 		return resp, nil
 	}
 }
@@ -317,7 +392,7 @@ func (m *Manager) executeCountWithProvider(ctx context.Context, provider string,
 	tried := make(map[string]struct{})
 	var lastErr error
 	for {
-		auth, executor, errPick := m.pickNext(ctx, provider, req.Model, opts, tried)
+		auth, executor, release, errPick := m.pickNext(ctx, provider, req.Model, opts, tried)
 		if errPick != nil {
 			if lastErr != nil {
 				return cliproxyexecutor.Response{}, lastErr
@@ -338,19 +413,17 @@ func (m *Manager) executeCountWithProvider(ctx context.Context, provider string,
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
 		}
-		resp, errExec := executor.CountTokens(execCtx, auth, req, opts)
-		result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: errExec == nil}
+		execCtx, hookCtx, hooks := m.beforeExecute(execCtx, executor, auth, req, opts, false)
+		tried[hookCtx.Auth.ID] = struct{}{}
+		resp, errExec := executor.CountTokens(execCtx, hookCtx.Auth, hookCtx.executorRequest(), hookCtx.Options)
+		release()
+		afterExecute(execCtx, hooks, hookCtx, resp, errExec)
 		if errExec != nil {
-			result.Error = &Error{Message: errExec.Error()}
-			var se cliproxyexecutor.StatusError
-			if errors.As(errExec, &se) && se != nil {
-				result.Error.HTTPStatus = se.StatusCode()
-			}
-			m.MarkResult(execCtx, result)
+			m.MarkResult(execCtx, failureResult(hookCtx.Auth.ID, provider, req.Model, errExec))
 			lastErr = errExec
 			continue
 		}
-		m.MarkResult(execCtx, result)
+		m.MarkResult(execCtx, Result{AuthID: hookCtx.Auth.ID, Provider: provider, Model: req.Model, Success: true})
 		return resp, nil
 	}
 }
@@ -362,7 +435,7 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 	tried := make(map[string]struct{})
 	var lastErr error
 	for {
-		auth, executor, errPick := m.pickNext(ctx, provider, req.Model, opts, tried)
+		auth, executor, release, errPick := m.pickNext(ctx, provider, req.Model, opts, tried)
 		if errPick != nil {
 			if lastErr != nil {
 				return nil, lastErr
@@ -383,42 +456,110 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
 		}
-		chunks, errStream := executor.ExecuteStream(execCtx, auth, req, opts)
+		// Every attempt gets its own context so that an abandoned upstream stream is
+		// closed instead of being read to the end.
+		execCtx, cancelAttempt := context.WithCancel(execCtx)
+		execCtx, hookCtx, hooks := m.beforeExecute(execCtx, executor, auth, req, opts, true)
+		tried[hookCtx.Auth.ID] = struct{}{}
+		chunks, errStream := executor.ExecuteStream(execCtx, hookCtx.Auth, hookCtx.executorRequest(), hookCtx.Options)
 		if errStream != nil {
-			rerr := &Error{Message: errStream.Error()}
-			var se cliproxyexecutor.StatusError
-			if errors.As(errStream, &se) && se != nil {
-				rerr.HTTPStatus = se.StatusCode()
-			}
-			result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: false, Error: rerr}
-			m.MarkResult(execCtx, result)
+			cancelAttempt()
+			release()
+			afterExecute(execCtx, hooks, hookCtx, cliproxyexecutor.Response{}, errStream)
+			m.MarkResult(execCtx, failureResult(hookCtx.Auth.ID, provider, req.Model, errStream))
 			lastErr = errStream
 			continue
 		}
+		// Hold the stream back until the first payload arrives so that errors reported
+		// before anything reached the client can fail over to the next auth.
+		head, errHead := awaitFirstStreamPayload(execCtx, opts.SourceFormat, chunks)
+		if errHead != nil {
+			cancelAttempt()
+			go drainStream(chunks)
+			release()
+			for _, chunk := range head {
+				onStreamChunk(execCtx, hooks, hookCtx, chunk)
+			}
+			afterExecute(execCtx, hooks, hookCtx, cliproxyexecutor.Response{}, errHead)
+			m.MarkResult(execCtx, failureResult(hookCtx.Auth.ID, provider, req.Model, errHead))
+			lastErr = errHead
+			if ctx.Err() != nil {
+				return nil, errHead
+			}
+			log.Debugf("stream for model %s failed before first payload on auth %s, trying next: %v", req.Model, auth.ID, errHead)
+			continue
+		}
+		if len(head) > 0 && len(head[len(head)-1].Payload) > 0 {
+			hookCtx.usage.MarkFirstToken()
+		}
+		// This stream now serves the request, so earlier failed attempts were superseded.
+		supersedeFailedAttempts(execCtx)
 		out := make(chan cliproxyexecutor.StreamChunk)
-		go func(streamCtx context.Context, streamAuth *Auth, streamProvider string, streamChunks <-chan cliproxyexecutor.StreamChunk) {
+		go func(streamCtx context.Context, release func(), streamAuth *Auth, streamProvider string, head []cliproxyexecutor.StreamChunk, streamChunks <-chan cliproxyexecutor.StreamChunk) {
 			defer close(out)
+			defer cancelAttempt()
+			defer release()
 			var failed bool
+			var streamErr error
+			for _, chunk := range head {
+				onStreamChunk(streamCtx, hooks, hookCtx, chunk)
+				out <- chunk
+			}
 			for chunk := range streamChunks {
+				onStreamChunk(streamCtx, hooks, hookCtx, chunk)
 				if chunk.Err != nil && !failed {
 					failed = true
-					rerr := &Error{Message: chunk.Err.Error()}
-					var se cliproxyexecutor.StatusError
-					if errors.As(chunk.Err, &se) && se != nil {
-						rerr.HTTPStatus = se.StatusCode()
-					}
-					m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: req.Model, Success: false, Error: rerr})
+					streamErr = chunk.Err
+					m.MarkResult(streamCtx, failureResult(streamAuth.ID, streamProvider, req.Model, chunk.Err))
 				}
 				out <- chunk
 			}
 			if !failed {
 				m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: req.Model, Success: true})
 			}
-		}(execCtx, auth.Clone(), provider, chunks)
+			afterExecute(streamCtx, hooks, hookCtx, cliproxyexecutor.Response{}, streamErr)
+			settleFailedAttempts(streamCtx)
+		}(execCtx, release, hookCtx.Auth.Clone(), provider, head, chunks)
 		return out, nil
 	}
 }
 
+// awaitFirstStreamPayload reads chunks until the first one carrying an event body in format
+// and returns everything read so far. It returns an error when the stream fails, reports an
+// in-band error as its first event, or ctx ends, before any payload was produced. A stream
+// that closes without payload or error is not a failure.
+func awaitFirstStreamPayload(ctx context.Context, format sdktranslator.Format, chunks <-chan cliproxyexecutor.StreamChunk) ([]cliproxyexecutor.StreamChunk, error) {
+	var head []cliproxyexecutor.StreamChunk
+	var errorEvent bool
+	for {
+		select {
+		case <-ctx.Done():
+			return head, ctx.Err()
+		case chunk, ok := <-chunks:
+			if !ok {
+				return head, nil
+			}
+			if chunk.Err != nil {
+				return head, chunk.Err
+			}
+			head = append(head, chunk)
+			if len(chunk.Payload) == 0 {
+				continue
+			}
+			if hasData, errEvent := streamHeadError(format, chunk.Payload, &errorEvent); hasData {
+				return head, errEvent
+			}
+		}
+	}
+}
+
+// drainStream consumes the remainder of an abandoned stream so its producer can exit once
+// the canceled attempt context has stopped it.
+func drainStream(chunks <-chan cliproxyexecutor.StreamChunk) {
+	for range chunks {
+	}
+}
+
 func (m *Manager) normalizeProviders(providers []string) []string {
 	if len(providers) == 0 {
 		return nil
@@ -520,28 +661,23 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 				}
 
 				statusCode := statusCodeFromResult(result.Error)
-				switch statusCode {
-				case 401:
-					next := now.Add(30 * time.Minute)
-					state.NextRetryAfter = next
-					suspendReason = "unauthorized"
-					shouldSuspendModel = true
-				case 402, 403:
-					next := now.Add(30 * time.Minute)
-					state.NextRetryAfter = next
-					suspendReason = "payment_required"
-					shouldSuspendModel = true
-				case 429:
-					next := now.Add(30 * time.Minute)
+				if cooldown, ok := m.cooldowns.cooldownFor(statusCode, result.RetryAfter); ok {
+					next := now.Add(cooldown)
 					state.NextRetryAfter = next
-					state.Quota = QuotaState{Exceeded: true, Reason: "quota", NextRecoverAt: next}
-					suspendReason = "quota"
-					shouldSuspendModel = true
-					setModelQuota = true
-				case 408, 500, 502, 503, 504:
-					next := now.Add(1 * time.Minute)
-					state.NextRetryAfter = next
-				default:
+					switch statusCode {
+					case 401:
+						suspendReason = "unauthorized"
+						shouldSuspendModel = true
+					case 402, 403:
+						suspendReason = "payment_required"
+						shouldSuspendModel = true
+					case 429:
+						state.Quota = QuotaState{Exceeded: true, Reason: "quota", NextRecoverAt: next}
+						suspendReason = "quota"
+						shouldSuspendModel = true
+						setModelQuota = true
+					}
+				} else {
 					state.NextRetryAfter = time.Time{}
 				}
 
@@ -549,7 +685,7 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 				auth.UpdatedAt = now
 				updateAggregatedAvailability(auth, now)
 			} else {
-				applyAuthFailureState(auth, result.Error, now)
+				applyAuthFailureState(auth, result.Error, m.cooldowns, result.RetryAfter, now)
 			}
 		}
 
@@ -709,7 +845,7 @@ func statusCodeFromResult(err *Error) int {
 	return err.StatusCode()
 }
 
-func applyAuthFailureState(auth *Auth, resultErr *Error, now time.Time) {
+func applyAuthFailureState(auth *Auth, resultErr *Error, cooldowns CooldownPolicy, retryAfter *time.Duration, now time.Time) {
 	if auth == nil {
 		return
 	}
@@ -723,22 +859,23 @@ func applyAuthFailureState(auth *Auth, resultErr *Error, now time.Time) {
 		}
 	}
 	statusCode := statusCodeFromResult(resultErr)
+	cooldown, _ := cooldowns.cooldownFor(statusCode, retryAfter)
 	switch statusCode {
 	case 401:
 		auth.StatusMessage = "unauthorized"
-		auth.NextRetryAfter = now.Add(30 * time.Minute)
+		auth.NextRetryAfter = now.Add(cooldown)
 	case 402, 403:
 		auth.StatusMessage = "payment_required"
-		auth.NextRetryAfter = now.Add(30 * time.Minute)
+		auth.NextRetryAfter = now.Add(cooldown)
 	case 429:
 		auth.StatusMessage = "quota exhausted"
 		auth.Quota.Exceeded = true
 		auth.Quota.Reason = "quota"
-		auth.Quota.NextRecoverAt = now.Add(30 * time.Minute)
+		auth.Quota.NextRecoverAt = now.Add(cooldown)
 		auth.NextRetryAfter = auth.Quota.NextRecoverAt
 	case 408, 500, 502, 503, 504:
 		auth.StatusMessage = "transient upstream error"
-		auth.NextRetryAfter = now.Add(1 * time.Minute)
+		auth.NextRetryAfter = now.Add(cooldown)
 	default:
 		if auth.StatusMessage == "" {
 			auth.StatusMessage = "request failed"
@@ -772,12 +909,14 @@ func (m *Manager) GetByID(id string) (*Auth, bool) {
 	return auth.Clone(), true
 }
 
-func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, tried map[string]struct{}) (*Auth, ProviderExecutor, error) {
+// pickNext selects the next auth of provider not in tried. The returned release function
+// must be called once the execution using the auth has completed.
+func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, tried map[string]struct{}) (*Auth, ProviderExecutor, func(), error) {
 	m.mu.RLock()
 	executor, okExecutor := m.executors[provider]
 	if !okExecutor {
 		m.mu.RUnlock()
-		return nil, nil, &Error{Code: "executor_not_found", Message: "executor not registered"}
+		return nil, nil, nil, &Error{Code: "executor_not_found", Message: "executor not registered"}
 	}
 	candidates := make([]*Auth, 0, len(m.auths))
 	for _, candidate := range m.auths {
@@ -791,20 +930,32 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 	}
 	if len(candidates) == 0 {
 		m.mu.RUnlock()
-		return nil, nil, &Error{Code: "auth_not_found", Message: "no auth available"}
+		return nil, nil, nil, &Error{Code: "auth_not_found", Message: "no auth available"}
 	}
-	selected, errPick := m.selector.Pick(ctx, provider, model, opts, candidates)
+	selector := m.selector
+	selected, errPick := selector.Pick(ctx, provider, model, opts, candidates)
 	if errPick != nil {
 		m.mu.RUnlock()
-		return nil, nil, errPick
+		return nil, nil, nil, errPick
 	}
 	if selected == nil {
 		m.mu.RUnlock()
-		return nil, nil, &Error{Code: "auth_not_found", Message: "selector returned no auth"}
+		return nil, nil, nil, &Error{Code: "auth_not_found", Message: "selector returned no auth"}
 	}
 	authCopy := selected.Clone()
 	m.mu.RUnlock()
-	return authCopy, executor, nil
+	return authCopy, executor, selectionRelease(selector, authCopy.ID), nil
+}
+
+// selectionRelease returns the function notifying selector, when it tracks outstanding
+// executions, that the execution started for authID has completed. It is bound to the
+// selector that picked the auth so a selector swapped in meanwhile is not released to.
+func selectionRelease(selector Selector, authID string) func() {
+	releaser, ok := selector.(ReleasingSelector)
+	if !ok || releaser == nil {
+		return func() {}
+	}
+	return func() { releaser.Release(authID) }
 }
 
 func (m *Manager) persist(ctx context.Context, auth *Auth) error {
@@ -945,6 +1096,71 @@ func (m *Manager) shouldRefresh(a *Auth, now time.Time) bool {
 	return true
 }
 
+// NextRefreshTime estimates when the refresh loop will next refresh a, following the same
+// rules as shouldRefresh. It returns the zero time when a is never refreshed, and now when a
+// refresh is already due. Auths with a custom RefreshEvaluator only report NextRefreshAfter.
+func NextRefreshTime(a *Auth, now time.Time) time.Time {
+	if a == nil || a.Disabled {
+		return time.Time{}
+	}
+	var next time.Time
+	if _, ok := a.Runtime.(RefreshEvaluator); ok {
+		next = a.NextRefreshAfter
+	} else {
+		next = plannedRefreshTime(a, now)
+		if next.IsZero() {
+			return next
+		}
+		if a.NextRefreshAfter.After(next) {
+			next = a.NextRefreshAfter
+		}
+	}
+	if !next.IsZero() && next.Before(now) {
+		next = now
+	}
+	return next
+}
+
+func plannedRefreshTime(a *Auth, now time.Time) time.Time {
+	lastRefresh := a.LastRefreshedAt
+	if lastRefresh.IsZero() {
+		if ts, ok := authLastRefreshTimestamp(a); ok {
+			lastRefresh = ts
+		}
+	}
+	expiry, hasExpiry := a.ExpirationTime()
+	hasExpiry = hasExpiry && !expiry.IsZero()
+
+	if interval := authPreferredInterval(a); interval > 0 {
+		if lastRefresh.IsZero() {
+			return now
+		}
+		next := lastRefresh.Add(interval)
+		if hasExpiry && expiry.Add(-interval).Before(next) {
+			next = expiry.Add(-interval)
+		}
+		return next
+	}
+
+	lead := ProviderRefreshLead(strings.ToLower(a.Provider), a.Runtime)
+	if lead == nil {
+		return time.Time{}
+	}
+	if *lead <= 0 {
+		if hasExpiry {
+			return expiry
+		}
+		return time.Time{}
+	}
+	if hasExpiry {
+		return expiry.Add(-*lead)
+	}
+	if !lastRefresh.IsZero() {
+		return lastRefresh.Add(*lead)
+	}
+	return now
+}
+
 func authPreferredInterval(a *Auth) time.Duration {
 	if a == nil {
 		return 0
@@ -0,0 +1,247 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultRetryInitialBackoff = time.Second
+	defaultRetryMaxBackoff     = 30 * time.Second
+	defaultRetryMultiplier     = 2.0
+	defaultRetryJitter         = 0.2
+)
+
+// RetryPolicy controls how many extra rounds the manager attempts after every
+// candidate auth failed, and how long it waits between those rounds.
+type RetryPolicy struct {
+	// MaxRetries is the number of additional rounds after the first one. Zero disables retries.
+	MaxRetries int
+	// InitialBackoff is the delay applied before the first retry round.
+	InitialBackoff time.Duration
+	// MaxBackoff caps a single delay, including waits for auth cooldowns to expire.
+	MaxBackoff time.Duration
+	// Multiplier grows the delay after every round.
+	Multiplier float64
+	// Jitter randomises each delay by up to the given fraction (0-1).
+	Jitter float64
+}
+
+// normalized returns a copy of the policy with defaults applied to unset fields.
+func (p RetryPolicy) normalized() RetryPolicy {
+	if p.MaxRetries < 0 {
+		p.MaxRetries = 0
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = defaultRetryInitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = defaultRetryMaxBackoff
+	}
+	if p.InitialBackoff > p.MaxBackoff {
+		p.InitialBackoff = p.MaxBackoff
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = defaultRetryMultiplier
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		p.Jitter = defaultRetryJitter
+	}
+	return p
+}
+
+// backoff computes the jittered delay for the given zero-based retry round.
+func (p RetryPolicy) backoff(round int) time.Duration {
+	delay := float64(p.InitialBackoff)
+	for i := 0; i < round; i++ {
+		delay *= p.Multiplier
+		if delay >= float64(p.MaxBackoff) {
+			delay = float64(p.MaxBackoff)
+			break
+		}
+	}
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (rand.Float64()*2 - 1)
+	}
+	if delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return time.Duration(delay)
+}
+
+// SetRetryPolicy replaces the retry policy applied by Execute, ExecuteCount and ExecuteStream.
+func (m *Manager) SetRetryPolicy(policy RetryPolicy) {
+	m.mu.Lock()
+	m.retry = policy.normalized()
+	m.mu.Unlock()
+}
+
+// RetryPolicy returns the currently configured retry policy.
+func (m *Manager) RetryPolicy() RetryPolicy {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.retry
+}
+
+// isRetryableError reports whether a failed round may succeed when repeated.
+func isRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var authErr *Error
+	if errors.As(err, &authErr) && authErr != nil && authErr.Code == "auth_unavailable" {
+		return true
+	}
+	var se cliproxyexecutor.StatusError
+	if errors.As(err, &se) && se != nil {
+		switch se.StatusCode() {
+		case 403, 408, 429, 500, 502, 503, 504:
+			return true
+		}
+	}
+	return false
+}
+
+// waitForRetry decides whether another round should run after lastErr and sleeps
+// for the backoff delay. It returns false when no further round should be attempted.
+func (m *Manager) waitForRetry(ctx context.Context, round int, lastErr error, providers []string, model string) bool {
+	policy := m.RetryPolicy()
+	if round >= policy.MaxRetries || !isRetryableError(lastErr) {
+		return false
+	}
+	delay := policy.backoff(round)
+	if cooldown, blocked := m.cooldownWait(providers, model, time.Now()); blocked {
+		if cooldown <= 0 || cooldown > policy.MaxBackoff {
+			return false
+		}
+		if cooldown > delay {
+			delay = cooldown
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+		return false
+	}
+	log.Debugf("retrying model %s in %s (retry %d/%d): %v", model, delay, round+1, policy.MaxRetries, lastErr)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// cooldownWait reports whether every auth serving the providers is blocked for model
+// and, if so, how long until the earliest of them becomes available again.
+func (m *Manager) cooldownWait(providers []string, model string, now time.Time) (time.Duration, bool) {
+	allowed := make(map[string]struct{}, len(providers))
+	for _, provider := range providers {
+		allowed[provider] = struct{}{}
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	earliest := time.Time{}
+	found := false
+	for _, candidate := range m.auths {
+		if candidate == nil || candidate.Disabled {
+			continue
+		}
+		if _, ok := allowed[candidate.Provider]; !ok {
+			continue
+		}
+		if _, ok := m.executors[candidate.Provider]; !ok {
+			continue
+		}
+		found = true
+		if !isAuthBlockedForModel(candidate, model, now) {
+			return 0, false
+		}
+		next := blockedUntil(candidate, model)
+		if next.IsZero() {
+			continue
+		}
+		if earliest.IsZero() || next.Before(earliest) {
+			earliest = next
+		}
+	}
+	if !found || earliest.IsZero() {
+		return 0, found
+	}
+	return earliest.Sub(now), true
+}
+
+func blockedUntil(auth *Auth, model string) time.Time {
+	if auth == nil {
+		return time.Time{}
+	}
+	if model != "" && len(auth.ModelStates) > 0 {
+		if state, ok := auth.ModelStates[model]; ok && state != nil {
+			return state.NextRetryAfter
+		}
+	}
+	return auth.NextRetryAfter
+}
+
+// retryAttemptContextKey is an unexported context key type to avoid collisions.
+type retryAttemptContextKey struct{}
+
+func withRetryAttempt(ctx context.Context, round int) context.Context {
+	if round <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, retryAttemptContextKey{}, round)
+}
+
+// RetryAttemptFromContext returns the retry round an execution belongs to (0 for the first round).
+func RetryAttemptFromContext(ctx context.Context) int {
+	if ctx == nil {
+		return 0
+	}
+	if round, ok := ctx.Value(retryAttemptContextKey{}).(int); ok {
+		return round
+	}
+	return 0
+}
+
+// retryAfterError decorates a final execution error with the time until a credential
+// serving the request becomes available again.
+type retryAfterError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryAfterError) Error() string { return e.err.Error() }
+
+func (e *retryAfterError) Unwrap() error { return e.err }
+
+// withRetryAfter attaches the remaining cooldown to err when every auth serving the
+// providers is blocked for model and the earliest recovery time is known.
+func (m *Manager) withRetryAfter(err error, providers []string, model string) error {
+	if err == nil {
+		return nil
+	}
+	wait, blocked := m.cooldownWait(providers, model, time.Now())
+	if !blocked || wait <= 0 {
+		return err
+	}
+	return &retryAfterError{err: err, retryAfter: wait}
+}
+
+// RetryAfterFromError returns how long a client should wait before retrying a request
+// that failed with err, when the manager knows when a credential becomes available.
+func RetryAfterFromError(err error) (time.Duration, bool) {
+	var raErr *retryAfterError
+	if errors.As(err, &raErr) && raErr != nil && raErr.retryAfter > 0 {
+		return raErr.retryAfter, true
+	}
+	return 0, false
+}
@@ -2,7 +2,10 @@ package auth
 
 import (
 	"context"
+	"fmt"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,14 +22,275 @@ type RoundRobinSelector struct {
 func (s *RoundRobinSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
 	_ = ctx
 	_ = opts
-	if len(auths) == 0 {
-		return nil, &Error{Code: "auth_not_found", Message: "no auth candidates"}
+	available, err := availableAuths(auths, model, time.Now())
+	if err != nil {
+		return nil, err
 	}
+	key := provider + ":" + model
+	s.mu.Lock()
 	if s.cursors == nil {
 		s.cursors = make(map[string]int)
 	}
-	available := make([]*Auth, 0, len(auths))
+	index := s.cursors[key]
+
+	if index >= 2_147_483_640 {
+		index = 0
+	}
+
+	s.cursors[key] = index + 1
+	s.mu.Unlock()
+	// log.Debugf("available: %d, index: %d, key: %d", len(available), index, index%len(available))
+	return available[index%len(available)], nil
+}
+
+// FillFirstSelector keeps using the first available auth (ordered by ID) until it
+// becomes unavailable, draining one credential before moving to the next.
+type FillFirstSelector struct{}
+
+// Pick selects the first available auth in stable ID order.
+func (FillFirstSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	_ = ctx
+	_ = provider
+	_ = opts
+	available, err := availableAuths(auths, model, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return available[0], nil
+}
+
+// LeastRecentlyUsedSelector picks the available auth that was selected the longest time ago.
+type LeastRecentlyUsedSelector struct {
+	mu sync.Mutex
+	// lastUsed holds the last selection time per provider and auth ID.
+	lastUsed map[string]map[string]time.Time
+}
+
+// Pick selects the least recently picked auth and records the selection time.
+func (s *LeastRecentlyUsedSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	_ = ctx
+	_ = opts
+	now := time.Now()
+	available, err := availableAuths(auths, model, now)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastUsed == nil {
+		s.lastUsed = make(map[string]map[string]time.Time)
+	}
+	lastUsed := s.lastUsed[provider]
+	if lastUsed == nil {
+		lastUsed = make(map[string]time.Time)
+		s.lastUsed[provider] = lastUsed
+	}
+	pruneAuthIDs(lastUsed, auths)
+	selected := available[0]
+	for _, candidate := range available[1:] {
+		if lastUsed[candidate.ID].Before(lastUsed[selected.ID]) {
+			selected = candidate
+		}
+	}
+	lastUsed[selected.ID] = now
+	return selected, nil
+}
+
+// WeightedSelector restricts selection to the available auths with the highest
+// "priority" and distributes requests among them proportionally to their "weight"
+// using smooth weighted round-robin. Both values are read from auth attributes,
+// falling back to metadata; missing values default to priority 0 and weight 1.
+type WeightedSelector struct {
+	mu      sync.Mutex
+	current map[string]map[string]int
+}
+
+// Pick selects an auth from the highest priority tier according to its weight.
+func (s *WeightedSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	_ = ctx
+	_ = opts
+	available, err := availableAuths(auths, model, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	topPriority := authIntValue(available[0], "priority", 0)
+	for _, candidate := range available[1:] {
+		if p := authIntValue(candidate, "priority", 0); p > topPriority {
+			topPriority = p
+		}
+	}
+	tier := make([]*Auth, 0, len(available))
+	for _, candidate := range available {
+		if authIntValue(candidate, "priority", 0) == topPriority {
+			tier = append(tier, candidate)
+		}
+	}
+
+	key := provider + ":" + model
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		s.current = make(map[string]map[string]int)
+	}
+	weights := s.current[key]
+	if weights == nil {
+		weights = make(map[string]int)
+		s.current[key] = weights
+	}
+	pruneAuthIDs(weights, auths)
+	total := 0
+	var selected *Auth
+	for _, candidate := range tier {
+		weight := authIntValue(candidate, "weight", 1)
+		if weight <= 0 {
+			weight = 1
+		}
+		total += weight
+		weights[candidate.ID] += weight
+		if selected == nil || weights[candidate.ID] > weights[selected.ID] {
+			selected = candidate
+		}
+	}
+	weights[selected.ID] -= total
+	return selected, nil
+}
+
+// LeastInFlightSelector picks the available auth with the fewest executions in
+// progress, breaking ties by least recent selection. The manager reports finished
+// executions through Release.
+type LeastInFlightSelector struct {
+	mu sync.Mutex
+	// inFlight counts outstanding executions per auth ID; entries are removed when they
+	// drop to zero.
+	inFlight map[string]int
+	// lastUsed holds the last selection time per provider and auth ID.
+	lastUsed map[string]map[string]time.Time
+}
+
+// Pick selects the least busy auth and counts the new execution against it.
+func (s *LeastInFlightSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	_ = ctx
+	_ = opts
 	now := time.Now()
+	available, err := availableAuths(auths, model, now)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inFlight == nil {
+		s.inFlight = make(map[string]int)
+	}
+	if s.lastUsed == nil {
+		s.lastUsed = make(map[string]map[string]time.Time)
+	}
+	lastUsed := s.lastUsed[provider]
+	if lastUsed == nil {
+		lastUsed = make(map[string]time.Time)
+		s.lastUsed[provider] = lastUsed
+	}
+	pruneAuthIDs(lastUsed, auths)
+	selected := available[0]
+	for _, candidate := range available[1:] {
+		load, best := s.inFlight[candidate.ID], s.inFlight[selected.ID]
+		if load < best || (load == best && lastUsed[candidate.ID].Before(lastUsed[selected.ID])) {
+			selected = candidate
+		}
+	}
+	s.inFlight[selected.ID]++
+	lastUsed[selected.ID] = now
+	return selected, nil
+}
+
+// pruneAuthIDs drops the entries of state whose auth is not among auths, the candidates
+// of the provider state belongs to, so removed credentials do not accumulate.
+func pruneAuthIDs[V any](state map[string]V, auths []*Auth) {
+	if len(state) <= len(auths) {
+		return
+	}
+	current := make(map[string]struct{}, len(auths))
+	for _, auth := range auths {
+		current[auth.ID] = struct{}{}
+	}
+	for id := range state {
+		if _, ok := current[id]; !ok {
+			delete(state, id)
+		}
+	}
+}
+
+// Release implements ReleasingSelector.
+func (s *LeastInFlightSelector) Release(authID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inFlight[authID] <= 1 {
+		delete(s.inFlight, authID)
+		return
+	}
+	s.inFlight[authID]--
+}
+
+// ReleasingSelector is implemented by selectors that track outstanding executions.
+// The manager calls Release exactly once for every auth returned by Pick, after the
+// execution using it has completed (for streams, after the last chunk).
+type ReleasingSelector interface {
+	Release(authID string)
+}
+
+// Selection strategy names accepted by NewSelector.
+const (
+	StrategyRoundRobin        = "round-robin"
+	StrategyFillFirst         = "fill-first"
+	StrategyLeastRecentlyUsed = "least-recently-used"
+	StrategyWeighted          = "weighted"
+	StrategyLeastInFlight     = "least-in-flight"
+)
+
+// NormalizeStrategy canonicalises a strategy name, resolving common aliases.
+// An empty name resolves to StrategyRoundRobin.
+func NormalizeStrategy(strategy string) string {
+	normalized := strings.ToLower(strings.TrimSpace(strategy))
+	normalized = strings.ReplaceAll(normalized, "_", "-")
+	switch normalized {
+	case "", "roundrobin", "rr":
+		return StrategyRoundRobin
+	case "fillfirst", "sequential":
+		return StrategyFillFirst
+	case "lru", "leastrecentlyused":
+		return StrategyLeastRecentlyUsed
+	case "priority", "weight":
+		return StrategyWeighted
+	case "least-inflight", "leastinflight", "least-connections":
+		return StrategyLeastInFlight
+	}
+	return normalized
+}
+
+// NewSelector constructs a selector for the named strategy.
+func NewSelector(strategy string) (Selector, error) {
+	switch NormalizeStrategy(strategy) {
+	case StrategyRoundRobin:
+		return &RoundRobinSelector{}, nil
+	case StrategyFillFirst:
+		return FillFirstSelector{}, nil
+	case StrategyLeastRecentlyUsed:
+		return &LeastRecentlyUsedSelector{}, nil
+	case StrategyWeighted:
+		return &WeightedSelector{}, nil
+	case StrategyLeastInFlight:
+		return &LeastInFlightSelector{}, nil
+	default:
+		return nil, fmt.Errorf("unknown routing strategy %q", strategy)
+	}
+}
+
+// availableAuths filters out auths blocked for model and orders the rest by ID so
+// that selection stays deterministic even if the caller's candidate order is unstable.
+func availableAuths(auths []*Auth, model string, now time.Time) ([]*Auth, error) {
+	if len(auths) == 0 {
+		return nil, &Error{Code: "auth_not_found", Message: "no auth candidates"}
+	}
+	available := make([]*Auth, 0, len(auths))
 	for i := 0; i < len(auths); i++ {
 		candidate := auths[i]
 		if isAuthBlockedForModel(candidate, model, now) {
@@ -37,22 +301,37 @@ func (s *RoundRobinSelector) Pick(ctx context.Context, provider, model string, o
 	if len(available) == 0 {
 		return nil, &Error{Code: "auth_unavailable", Message: "no auth available"}
 	}
-	// Make round-robin deterministic even if caller's candidate order is unstable.
 	if len(available) > 1 {
 		sort.Slice(available, func(i, j int) bool { return available[i].ID < available[j].ID })
 	}
-	key := provider + ":" + model
-	s.mu.Lock()
-	index := s.cursors[key]
+	return available, nil
+}
 
-	if index >= 2_147_483_640 {
-		index = 0
+// authIntValue reads an integer setting from auth attributes, falling back to metadata.
+func authIntValue(auth *Auth, key string, fallback int) int {
+	if auth == nil {
+		return fallback
 	}
-
-	s.cursors[key] = index + 1
-	s.mu.Unlock()
-	// log.Debugf("available: %d, index: %d, key: %d", len(available), index, index%len(available))
-	return available[index%len(available)], nil
+	if raw := strings.TrimSpace(auth.Attributes[key]); raw != "" {
+		if v, err := strconv.Atoi(raw); err == nil {
+			return v
+		}
+	}
+	if auth.Metadata != nil {
+		switch v := auth.Metadata[key].(type) {
+		case float64:
+			return int(v)
+		case int:
+			return v
+		case int64:
+			return int(v)
+		case string:
+			if parsed, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+				return parsed
+			}
+		}
+	}
+	return fallback
 }
 
 func isAuthBlockedForModel(auth *Auth, model string, now time.Time) bool {
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+// streamHeadError inspects a stream chunk that precedes any payload delivered to the client.
+// Upstreams sometimes accept a request with 200 and report overload or rate limiting as the
+// first event of the stream, so such an event must fail over like a non-2xx response would.
+// The chunk is in the client's source format; errorEvent carries an SSE "event: error" line
+// across chunks, since executors forwarding raw SSE emit one line per chunk. hasData reports
+// whether the chunk held an event body, as opposed to blank lines, comments or event names.
+func streamHeadError(format sdktranslator.Format, payload []byte, errorEvent *bool) (hasData bool, err error) {
+	for _, line := range bytes.Split(payload, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == ':' {
+			continue
+		}
+		if name, ok := bytes.CutPrefix(line, []byte("event:")); ok {
+			*errorEvent = string(bytes.TrimSpace(name)) == "error"
+			continue
+		}
+		data := line
+		if rest, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			data = bytes.TrimSpace(rest)
+		}
+		if string(data) == "[DONE]" {
+			return true, nil
+		}
+		return true, streamErrorEvent(format, data, *errorEvent)
+	}
+	return false, nil
+}
+
+// streamErrorEvent returns a failover error when data is an error event of format: Claude's
+// {"type":"error","error":{...}}, OpenAI's {"error":{...}} or Responses {"type":"error",...},
+// and Gemini's {"error":{"code":...,"status":...}}, possibly wrapped in a JSON array.
+func streamErrorEvent(format sdktranslator.Format, data []byte, errorEvent bool) error {
+	root := gjson.ParseBytes(data)
+	if root.IsArray() {
+		root = root.Get("0")
+	}
+	if !root.IsObject() {
+		return nil
+	}
+	detail := root.Get("error")
+	if !errorEvent && root.Get("type").String() != "error" && (!detail.Exists() || detail.Type == gjson.Null) {
+		return nil
+	}
+
+	message := detail.Get("message").String()
+	if detail.Type == gjson.String {
+		message = detail.String()
+	}
+	if message == "" {
+		message = root.Get("message").String()
+	}
+	if message == "" {
+		message = "upstream reported an error before the first stream event"
+	}
+
+	var errType string
+	status := http.StatusBadGateway
+	switch string(format) {
+	case constant.Gemini, constant.GeminiCLI:
+		errType = detail.Get("status").String()
+		if code := detail.Get("code"); code.Type == gjson.Number && code.Int() >= 400 && code.Int() < 600 {
+			status = int(code.Int())
+		} else if mapped := streamErrorStatus(errType); mapped != 0 {
+			status = mapped
+		}
+	default:
+		// Claude and the OpenAI dialects name the failure in error.type or error.code;
+		// the Responses API puts the code on the event itself.
+		for _, candidate := range []gjson.Result{detail.Get("type"), detail.Get("code"), root.Get("code")} {
+			if candidate.Type != gjson.String || candidate.String() == "" {
+				continue
+			}
+			if errType == "" {
+				errType = candidate.String()
+			}
+			if mapped := streamErrorStatus(candidate.String()); mapped != 0 {
+				status = mapped
+				break
+			}
+		}
+	}
+	if errType == "" {
+		errType = "stream_error"
+	}
+	return &Error{
+		Code:       errType,
+		Message:    message,
+		Retryable:  status == http.StatusTooManyRequests || status >= http.StatusInternalServerError,
+		HTTPStatus: status,
+	}
+}
+
+// streamErrorStatus maps the error types of the Claude, OpenAI and Gemini APIs to the HTTP
+// status the upstream would have answered with, or 0 when the type is unknown.
+func streamErrorStatus(errType string) int {
+	switch errType {
+	case "invalid_request_error", "INVALID_ARGUMENT", "FAILED_PRECONDITION":
+		return http.StatusBadRequest
+	case "authentication_error", "invalid_api_key", "UNAUTHENTICATED":
+		return http.StatusUnauthorized
+	case "permission_error", "PERMISSION_DENIED":
+		return http.StatusForbidden
+	case "not_found_error", "model_not_found", "NOT_FOUND":
+		return http.StatusNotFound
+	case "request_too_large":
+		return http.StatusRequestEntityTooLarge
+	case "rate_limit_error", "rate_limit_exceeded", "insufficient_quota", "RESOURCE_EXHAUSTED":
+		return http.StatusTooManyRequests
+	case "api_error", "server_error", "INTERNAL":
+		return http.StatusInternalServerError
+	case "overloaded_error", "UNAVAILABLE":
+		// Anthropic answers overload with 529, which is treated like 503 here so that
+		// the auth cools down and the request is retried.
+		return http.StatusServiceUnavailable
+	case "DEADLINE_EXCEEDED":
+		return http.StatusGatewayTimeout
+	}
+	return 0
+}
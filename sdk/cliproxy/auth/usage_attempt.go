@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// requestAttempts tracks the executor attempts of one client request across auths, retry
+// rounds and model fallbacks. A failed attempt is held back until it is known whether
+// another attempt follows it, so usage can tell requests from attempts.
+type requestAttempts struct {
+	count atomic.Int32
+
+	mu        sync.Mutex
+	failed    *coreusage.Attempt
+	failedCtx context.Context
+}
+
+// requestAttemptsContextKey is an unexported context key type to avoid collisions.
+type requestAttemptsContextKey struct{}
+
+// withRequestAttempts installs the attempt tracker of a client request. The returned
+// function publishes a held failed attempt as the final one and must run once the
+// request is over; it does nothing when ctx already carried a tracker.
+func withRequestAttempts(ctx context.Context) (context.Context, func()) {
+	if _, ok := ctx.Value(requestAttemptsContextKey{}).(*requestAttempts); ok {
+		return ctx, func() {}
+	}
+	attempts := &requestAttempts{}
+	return context.WithValue(ctx, requestAttemptsContextKey{}, attempts), func() { attempts.release(false) }
+}
+
+func requestAttemptsFrom(ctx context.Context) *requestAttempts {
+	attempts, _ := ctx.Value(requestAttemptsContextKey{}).(*requestAttempts)
+	return attempts
+}
+
+// nextAttempt returns the 1-based number of the attempt about to start.
+func nextAttempt(ctx context.Context) int {
+	if attempts := requestAttemptsFrom(ctx); attempts != nil {
+		return int(attempts.count.Add(1))
+	}
+	return 1
+}
+
+// publishAttempt publishes an ended attempt. A successful attempt ends its request; a
+// failed one is held until the next attempt finishes or the request is over.
+func publishAttempt(ctx context.Context, attempt *coreusage.Attempt, err error) {
+	if attempt == nil {
+		return
+	}
+	attempts := requestAttemptsFrom(ctx)
+	if attempts == nil {
+		attempt.Publish(ctx, false)
+		return
+	}
+	attempts.release(true)
+	if err == nil {
+		attempt.Publish(ctx, false)
+		return
+	}
+	attempts.mu.Lock()
+	attempts.failed, attempts.failedCtx = attempt, ctx
+	attempts.mu.Unlock()
+}
+
+// release publishes the held failed attempt, if any.
+func (a *requestAttempts) release(superseded bool) {
+	a.mu.Lock()
+	failed, ctx := a.failed, a.failedCtx
+	a.failed, a.failedCtx = nil, nil
+	a.mu.Unlock()
+	failed.Publish(ctx, superseded)
+}
+
+// supersedeFailedAttempts publishes the held failed attempt of the request in ctx as
+// superseded, once a stream that replaced it delivered its first payload.
+func supersedeFailedAttempts(ctx context.Context) {
+	if attempts := requestAttemptsFrom(ctx); attempts != nil {
+		attempts.release(true)
+	}
+}
+
+// settleFailedAttempts publishes the held failed attempt of the request in ctx as final.
+func settleFailedAttempts(ctx context.Context) {
+	if attempts := requestAttemptsFrom(ctx); attempts != nil {
+		attempts.release(false)
+	}
+}
+
+// newUsageAttempt starts the usage record of an attempt. Executors fill in token usage
+// and formats; the identity set here covers attempts that fail before reporting.
+func newUsageAttempt(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) *coreusage.Attempt {
+	_, source := auth.AccountInfo()
+	record := coreusage.Record{
+		Provider:     auth.Provider,
+		Model:        req.Model,
+		APIKey:       apiKeyFromContext(ctx),
+		AuthID:       auth.ID,
+		Source:       util.HideAPIKey(source),
+		Stream:       opts.Stream,
+		Attempt:      nextAttempt(ctx),
+		SourceFormat: opts.SourceFormat.String(),
+	}
+	if fallback, ok := ModelFallbackFromContext(ctx); ok {
+		record.RequestedModel = fallback.Requested
+	}
+	return coreusage.NewAttempt(record)
+}
+
+// apiKeyFromContext returns the client API key stored on the gin context by the access
+// middleware, without depending on gin.
+func apiKeyFromContext(ctx context.Context) string {
+	ginCtx, ok := ctx.Value("gin").(interface {
+		Get(key string) (any, bool)
+	})
+	if !ok || ginCtx == nil {
+		return ""
+	}
+	if value, exists := ginCtx.Get("apiKey"); exists {
+		if key, isString := value.(string); isString {
+			return key
+		}
+	}
+	return ""
+}
+
+// attemptStatus returns the HTTP status of a finished attempt, or zero when the upstream
+// never answered.
+func attemptStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	var se cliproxyexecutor.StatusError
+	if errors.As(err, &se) && se != nil {
+		return se.StatusCode()
+	}
+	return 0
+}
+
+// usageErrorClass groups attempt failures for usage reporting. It returns an empty string
+// for successful attempts.
+func usageErrorClass(err error, status int) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, context.Canceled) {
+		return "canceled"
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	switch {
+	case status == http.StatusTooManyRequests:
+		return "rate_limit"
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return "auth"
+	case status >= http.StatusInternalServerError:
+		return "upstream"
+	case status >= http.StatusBadRequest:
+		return "invalid_request"
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return "timeout"
+		}
+		return "network"
+	}
+	return "error"
+}
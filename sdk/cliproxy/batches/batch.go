@@ -0,0 +1,282 @@
+// Package batches runs message batches in the background. Batches are submitted through
+// the Anthropic /v1/messages/batches or OpenAI /v1/batches endpoints, persisted to a Store
+// and executed request by request, so their results survive restarts.
+package batches
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrNotFound is returned when a batch or file is unknown.
+var ErrNotFound = errors.New("batches: not found")
+
+// Status is the processing state of a batch.
+type Status string
+
+const (
+	// StatusInProgress means requests are still being executed.
+	StatusInProgress Status = "in_progress"
+	// StatusCanceling means a cancel was requested and in-flight requests are finishing.
+	StatusCanceling Status = "canceling"
+	// StatusCompleted means every request has a result.
+	StatusCompleted Status = "completed"
+	// StatusCanceled means the batch ended after a cancel; unprocessed requests were canceled.
+	StatusCanceled Status = "canceled"
+	// StatusExpired means the batch ended at its deadline; unprocessed requests expired.
+	StatusExpired Status = "expired"
+)
+
+// Ended reports whether the batch has finished processing.
+func (s Status) Ended() bool {
+	return s == StatusCompleted || s == StatusCanceled || s == StatusExpired
+}
+
+// Outcome is the result type of a single batch request.
+type Outcome string
+
+const (
+	OutcomeSucceeded Outcome = "succeeded"
+	OutcomeErrored   Outcome = "errored"
+	OutcomeCanceled  Outcome = "canceled"
+	OutcomeExpired   Outcome = "expired"
+)
+
+// Counts tallies the requests of a batch by outcome.
+type Counts struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Errored   int `json:"errored"`
+	Canceled  int `json:"canceled"`
+	Expired   int `json:"expired"`
+}
+
+// Processing returns the number of requests without a result yet.
+func (c Counts) Processing() int {
+	return c.Total - c.Succeeded - c.Errored - c.Canceled - c.Expired
+}
+
+func (c *Counts) add(outcome Outcome) {
+	switch outcome {
+	case OutcomeSucceeded:
+		c.Succeeded++
+	case OutcomeErrored:
+		c.Errored++
+	case OutcomeCanceled:
+		c.Canceled++
+	case OutcomeExpired:
+		c.Expired++
+	}
+}
+
+// Batch describes a submitted batch. Requests and results are stored separately.
+type Batch struct {
+	// ID is the batch ID returned to the client.
+	ID string `json:"id"`
+	// Owner is the SHA-256 hex of the client API key that created the batch, which alone may
+	// see it; empty when unauthenticated.
+	Owner string `json:"owner,omitempty"`
+	// Dialect is the handler type of the API the batch was created with ("claude" or "openai").
+	Dialect string `json:"dialect"`
+	// Endpoint is the API path every request of the batch is sent to, e.g. /v1/messages.
+	Endpoint string `json:"endpoint"`
+	// InputFileID is the uploaded file the requests were read from (OpenAI only).
+	InputFileID string `json:"input_file_id,omitempty"`
+	// CompletionWindow is the window requested by the client (OpenAI only).
+	CompletionWindow string `json:"completion_window,omitempty"`
+	// Metadata holds client supplied key-value pairs.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Status is the processing state.
+	Status Status `json:"status"`
+	// Counts tallies the results recorded so far.
+	Counts Counts `json:"counts"`
+	// CreatedAt is when the batch was submitted.
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt is the deadline after which unprocessed requests expire.
+	ExpiresAt time.Time `json:"expires_at"`
+	// CancelRequestedAt is when the client asked to cancel the batch.
+	CancelRequestedAt *time.Time `json:"cancel_requested_at,omitempty"`
+	// EndedAt is when processing finished.
+	EndedAt *time.Time `json:"ended_at,omitempty"`
+
+	// APIKey is the client API key that created the batch, used to attribute usage and
+	// apply key policies. It is never persisted; batches resumed after a restart recover
+	// it by matching Owner against the configured keys.
+	APIKey string `json:"-"`
+}
+
+// Clone returns a deep copy of the batch.
+func (b *Batch) Clone() *Batch {
+	if b == nil {
+		return nil
+	}
+	clone := *b
+	if b.Metadata != nil {
+		clone.Metadata = make(map[string]string, len(b.Metadata))
+		for k, v := range b.Metadata {
+			clone.Metadata[k] = v
+		}
+	}
+	if b.CancelRequestedAt != nil {
+		at := *b.CancelRequestedAt
+		clone.CancelRequestedAt = &at
+	}
+	if b.EndedAt != nil {
+		at := *b.EndedAt
+		clone.EndedAt = &at
+	}
+	return &clone
+}
+
+// Request is one request of a batch.
+type Request struct {
+	// CustomID is the client supplied ID used to match results to requests.
+	CustomID string `json:"custom_id"`
+	// Body is the request body sent to the batch endpoint.
+	Body json.RawMessage `json:"body"`
+}
+
+// Result is the outcome of one request of a batch.
+type Result struct {
+	// ID uniquely identifies the result.
+	ID string `json:"id"`
+	// Index is the position of the request in the batch.
+	Index int `json:"index"`
+	// CustomID is the custom ID of the request.
+	CustomID string `json:"custom_id"`
+	// Outcome is the result type.
+	Outcome Outcome `json:"outcome"`
+	// StatusCode is the HTTP status of the response; zero for canceled and expired requests.
+	StatusCode int `json:"status_code,omitempty"`
+	// Body is the response body, or the error body in the batch dialect for errored requests.
+	Body json.RawMessage `json:"body,omitempty"`
+	// CompletedAt is when the result was recorded.
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// File is an uploaded batch input file (OpenAI /v1/files).
+type File struct {
+	ID        string    `json:"id"`
+	Owner     string    `json:"owner,omitempty"`
+	Filename  string    `json:"filename"`
+	Purpose   string    `json:"purpose"`
+	Bytes     int64     `json:"bytes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Store persists batches, their requests and results, and uploaded input files.
+// Implementations return ErrNotFound for unknown batches and files.
+type Store interface {
+	// PutBatch creates or replaces the batch record.
+	PutBatch(ctx context.Context, batch *Batch) error
+	GetBatch(ctx context.Context, id string) (*Batch, error)
+	// ListBatches returns every batch, newest first.
+	ListBatches(ctx context.Context) ([]*Batch, error)
+	// DeleteBatch removes the batch together with its requests and results.
+	DeleteBatch(ctx context.Context, id string) error
+
+	// PutRequests stores the requests of a batch in submission order.
+	PutRequests(ctx context.Context, batchID string, requests []Request) error
+	LoadRequests(ctx context.Context, batchID string) ([]Request, error)
+	// AppendResult durably records the result of one request. Stores shared between
+	// instances keep only the first result recorded for each custom ID.
+	AppendResult(ctx context.Context, batchID string, result Result) error
+	// LoadResults returns the results recorded for a batch in the order they were appended.
+	LoadResults(ctx context.Context, batchID string) ([]Result, error)
+
+	PutFile(ctx context.Context, file *File, content []byte) error
+	GetFile(ctx context.Context, id string) (*File, error)
+	ReadFile(ctx context.Context, id string) ([]byte, error)
+	// ListFiles returns every file, newest first.
+	ListFiles(ctx context.Context) ([]*File, error)
+	DeleteFile(ctx context.Context, id string) error
+}
+
+// SharedStore is implemented by stores that several instances use at once, such as the
+// Postgres store. A batch in a shared store is processed only by the instance holding its
+// lease, and cancels requested through other instances reach it through the store:
+// PutBatch must keep a cancel request already stored for the batch, so that the holder
+// writing its progress cannot drop it.
+type SharedStore interface {
+	Store
+	// ClaimBatch takes or renews the lease of owner on a batch for ttl. It returns false
+	// while another owner holds an unexpired lease.
+	ClaimBatch(ctx context.Context, id, owner string, ttl time.Duration) (bool, error)
+	// ReleaseBatch gives up the lease of owner on a batch.
+	ReleaseBatch(ctx context.Context, id, owner string) error
+}
+
+var (
+	storeMu      sync.RWMutex
+	defaultStore Store
+	configured   = true
+	configuredAt string
+)
+
+// DefaultStore returns the store used by the batch handlers. Until a store is configured
+// batches are kept in a temporary directory.
+func DefaultStore() Store {
+	storeMu.RLock()
+	store := defaultStore
+	storeMu.RUnlock()
+	if store != nil {
+		return store
+	}
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	if defaultStore == nil {
+		defaultStore = openFileStore("")
+	}
+	return defaultStore
+}
+
+// SetStore installs a custom store, such as the Postgres store. ConfigureStore leaves it
+// in place afterwards.
+func SetStore(store Store) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	defaultStore = store
+	configured = false
+}
+
+// ConfigureStore keeps batches in dir, normally the batches directory inside the auth
+// directory. Stores installed with SetStore are left untouched.
+func ConfigureStore(dir string) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	if !configured || (defaultStore != nil && dir == configuredAt) {
+		return
+	}
+	defaultStore = openFileStore(dir)
+	configuredAt = dir
+}
+
+// openFileStore opens a file store in dir, falling back to a temporary directory when dir
+// is empty or unusable. It returns nil only when no directory can be used.
+func openFileStore(dir string) Store {
+	if dir != "" {
+		store, err := NewFileStore(dir)
+		if err == nil {
+			log.Infof("batches store: keeping batches in %s", dir)
+			return store
+		}
+		log.Errorf("batches store: %v; falling back to a temporary directory", err)
+	}
+	tmp, err := os.MkdirTemp("", "cliproxy-batches-")
+	if err != nil {
+		log.Errorf("batches store: create temporary directory: %v", err)
+		return nil
+	}
+	store, err := NewFileStore(tmp)
+	if err != nil {
+		log.Errorf("batches store: %v", err)
+		return nil
+	}
+	return store
+}
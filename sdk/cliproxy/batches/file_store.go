@@ -0,0 +1,331 @@
+package batches
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// File names inside a FileStore. Nothing is named *.json so that the auth directory
+// watcher and the token stores, which pick up every .json file, ignore batch data.
+const (
+	batchFileName    = "batch.meta"
+	requestsFileName = "requests.jsonl"
+	resultsFileName  = "results.jsonl"
+	filesDirName     = "files"
+	fileMetaSuffix   = ".meta"
+	fileDataSuffix   = ".jsonl"
+)
+
+// FileStore keeps every batch in its own directory: the batch record, the requests as
+// JSON lines and an append-only results log. Uploaded files live in a files directory.
+type FileStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileStore opens or creates dir.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(filepath.Join(dir, filesDirName), 0o700); err != nil {
+		return nil, fmt.Errorf("create batch store directory %s: %w", dir, err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// PutBatch implements Store.
+func (s *FileStore) PutBatch(_ context.Context, batch *Batch) error {
+	if batch == nil || batch.ID == "" {
+		return fmt.Errorf("batches store: batch id is required")
+	}
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	dir := s.batchDir(batch.ID)
+	if err = os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("create batch directory %s: %w", dir, err)
+	}
+	return writeFileAtomic(dir, batchFileName, data)
+}
+
+// GetBatch implements Store.
+func (s *FileStore) GetBatch(_ context.Context, id string) (*Batch, error) {
+	data, err := os.ReadFile(filepath.Join(s.batchDir(id), batchFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	var batch Batch
+	if err = json.Unmarshal(data, &batch); err != nil {
+		return nil, fmt.Errorf("decode batch %s: %w", id, err)
+	}
+	if batch.ID != id {
+		return nil, ErrNotFound
+	}
+	return &batch, nil
+}
+
+// ListBatches implements Store.
+func (s *FileStore) ListBatches(_ context.Context) ([]*Batch, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read batch store directory %s: %w", s.dir, err)
+	}
+	list := make([]*Batch, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == filesDirName {
+			continue
+		}
+		data, errRead := os.ReadFile(filepath.Join(s.dir, entry.Name(), batchFileName))
+		if errRead != nil {
+			continue
+		}
+		var batch Batch
+		if errDecode := json.Unmarshal(data, &batch); errDecode != nil || batch.ID == "" {
+			continue
+		}
+		list = append(list, &batch)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list, nil
+}
+
+// DeleteBatch implements Store.
+func (s *FileStore) DeleteBatch(_ context.Context, id string) error {
+	dir := s.batchDir(id)
+	if _, err := os.Stat(dir); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// PutRequests implements Store.
+func (s *FileStore) PutRequests(_ context.Context, batchID string, requests []Request) error {
+	var buf bytes.Buffer
+	for i := range requests {
+		line, err := json.Marshal(&requests[i])
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	dir := s.batchDir(batchID)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("create batch directory %s: %w", dir, err)
+	}
+	return writeFileAtomic(dir, requestsFileName, buf.Bytes())
+}
+
+// LoadRequests implements Store.
+func (s *FileStore) LoadRequests(_ context.Context, batchID string) ([]Request, error) {
+	var requests []Request
+	err := readLines(filepath.Join(s.batchDir(batchID), requestsFileName), func(line []byte) error {
+		var request Request
+		if err := json.Unmarshal(line, &request); err != nil {
+			return err
+		}
+		requests = append(requests, request)
+		return nil
+	})
+	return requests, err
+}
+
+// AppendResult implements Store.
+func (s *FileStore) AppendResult(_ context.Context, batchID string, result Result) error {
+	line, err := json.Marshal(&result)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := os.OpenFile(filepath.Join(s.batchDir(batchID), resultsFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	_, errWrite := file.Write(append(line, '\n'))
+	errSync := file.Sync()
+	errClose := file.Close()
+	return errors.Join(errWrite, errSync, errClose)
+}
+
+// LoadResults implements Store. A line torn by a crash while it was appended is skipped.
+func (s *FileStore) LoadResults(_ context.Context, batchID string) ([]Result, error) {
+	var results []Result
+	err := readLines(filepath.Join(s.batchDir(batchID), resultsFileName), func(line []byte) error {
+		var result Result
+		if errDecode := json.Unmarshal(line, &result); errDecode == nil {
+			results = append(results, result)
+		}
+		return nil
+	})
+	return results, err
+}
+
+// PutFile implements Store.
+func (s *FileStore) PutFile(_ context.Context, file *File, content []byte) error {
+	if file == nil || file.ID == "" {
+		return fmt.Errorf("batches store: file id is required")
+	}
+	meta, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(s.dir, filesDirName)
+	key := safeName(file.ID)
+	if err = writeFileAtomic(dir, key+fileDataSuffix, content); err != nil {
+		return err
+	}
+	return writeFileAtomic(dir, key+fileMetaSuffix, meta)
+}
+
+// GetFile implements Store.
+func (s *FileStore) GetFile(_ context.Context, id string) (*File, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, filesDirName, safeName(id)+fileMetaSuffix))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	var file File
+	if err = json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("decode file %s: %w", id, err)
+	}
+	if file.ID != id {
+		return nil, ErrNotFound
+	}
+	return &file, nil
+}
+
+// ReadFile implements Store.
+func (s *FileStore) ReadFile(_ context.Context, id string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, filesDirName, safeName(id)+fileDataSuffix))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// ListFiles implements Store.
+func (s *FileStore) ListFiles(_ context.Context) ([]*File, error) {
+	dir := filepath.Join(s.dir, filesDirName)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read batch files directory %s: %w", dir, err)
+	}
+	list := make([]*File, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != fileMetaSuffix {
+			continue
+		}
+		data, errRead := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if errRead != nil {
+			continue
+		}
+		var file File
+		if errDecode := json.Unmarshal(data, &file); errDecode != nil || file.ID == "" {
+			continue
+		}
+		list = append(list, &file)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list, nil
+}
+
+// DeleteFile implements Store.
+func (s *FileStore) DeleteFile(_ context.Context, id string) error {
+	dir := filepath.Join(s.dir, filesDirName)
+	key := safeName(id)
+	errMeta := os.Remove(filepath.Join(dir, key+fileMetaSuffix))
+	errData := os.Remove(filepath.Join(dir, key+fileDataSuffix))
+	if errors.Is(errMeta, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if errData != nil && !errors.Is(errData, os.ErrNotExist) {
+		return errData
+	}
+	return errMeta
+}
+
+func (s *FileStore) batchDir(id string) string {
+	return filepath.Join(s.dir, safeName(id))
+}
+
+// writeFileAtomic replaces dir/name with data through a temporary file.
+func writeFileAtomic(dir, name string, data []byte) error {
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, errWrite := tmp.Write(data)
+	errClose := tmp.Close()
+	if err = errors.Join(errWrite, errClose); err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(dir, name))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write %s: %w", filepath.Join(dir, name), err)
+	}
+	return nil
+}
+
+// readLines calls fn for every non-empty line of path. A missing file has no lines.
+func readLines(path string, fn func(line []byte) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	reader := bufio.NewReader(file)
+	for {
+		line, errRead := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if errLine := fn(line); errLine != nil {
+				return fmt.Errorf("%s: %w", path, errLine)
+			}
+		}
+		if errRead != nil {
+			if errors.Is(errRead, io.EOF) {
+				return nil
+			}
+			return errRead
+		}
+	}
+}
+
+// safeName maps an ID to a safe file name, hashing IDs that are not plain tokens.
+func safeName(id string) string {
+	if len(id) > 0 && len(id) <= 128 && id != filesDirName {
+		plain := true
+		for _, r := range id {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+				plain = false
+				break
+			}
+		}
+		if plain {
+			return id
+		}
+	}
+	sum := sha256.Sum256([]byte(id))
+	return "h-" + hex.EncodeToString(sum[:])
+}
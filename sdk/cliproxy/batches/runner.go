@@ -0,0 +1,755 @@
+package batches
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// DefaultConcurrency is how many batch requests run at once when unconfigured.
+	DefaultConcurrency = 4
+	// DefaultRetention is how long ended batches and their results are kept when
+	// unconfigured, matching the 29 days Anthropic keeps batch results.
+	DefaultRetention = 29 * 24 * time.Hour
+	// CompletionWindow is how long a batch may take before unprocessed requests expire.
+	CompletionWindow = 24 * time.Hour
+
+	initialRetryDelay = 5 * time.Second
+	maxRetryDelay     = 5 * time.Minute
+	sweepInterval     = time.Hour
+
+	// leaseDuration is how long a lease on a batch in a shared store lasts unless renewed;
+	// leases are renewed every leaseRenewInterval and unleased batches are adopted every
+	// adoptInterval.
+	leaseDuration      = time.Minute
+	leaseRenewInterval = 20 * time.Second
+	adoptInterval      = time.Minute
+)
+
+// ErrNotEnded is returned when deleting a batch that is still processing.
+var ErrNotEnded = errors.New("batches: batch is still processing")
+
+// Response is the outcome of executing one batch request.
+type Response struct {
+	// StatusCode is the HTTP status of the response; 2xx counts as succeeded.
+	StatusCode int
+	// Body is the response body, or the error body in the batch dialect.
+	Body []byte
+	// RetryAfter, when positive, is how long until a credential serving the model becomes
+	// available again.
+	RetryAfter time.Duration
+}
+
+// ExecuteFunc executes one request of a batch. ctx is canceled when the runner stops or
+// loses the lease on the batch.
+type ExecuteFunc func(ctx context.Context, batch *Batch, request Request) Response
+
+// Options tunes a Runner. Both functions are read whenever they are needed, so they can
+// follow configuration reloads.
+type Options struct {
+	// Concurrency returns the maximum number of requests executed at once across all
+	// batches. Non-positive values use DefaultConcurrency.
+	Concurrency func() int
+	// Retention returns how long ended batches are kept. Non-positive values use
+	// DefaultRetention.
+	Retention func() time.Duration
+}
+
+// Runner executes batches in the background. Requests are dispatched in order with a
+// concurrency limit shared by all batches. Requests rejected because every credential is
+// cooling down or rate limited (429, 503, 529) are retried once the cooldown ends, and
+// other requests for the same model wait meanwhile; requests still pending when the batch
+// expires are recorded as expired. With a SharedStore every batch is processed by the
+// one instance holding its lease; batches left by an instance that stopped or died are
+// adopted by the others once the lease expires.
+type Runner struct {
+	store   Store
+	shared  SharedStore
+	owner   string
+	execute ExecuteFunc
+	opts    Options
+
+	mu     sync.Mutex
+	active int
+	wake   chan struct{}
+	paused map[string]time.Time
+	runs   map[string]*run
+
+	ctx  context.Context
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+// run is the in-memory state of a batch being processed. batch, done and version are
+// guarded by Runner.mu. ctx is canceled when the runner stops or the lease is lost.
+type run struct {
+	batch    *Batch
+	done     map[int]bool
+	version  int64
+	ctx      context.Context
+	abandon  context.CancelFunc
+	dispatch context.CancelFunc
+
+	persistMu sync.Mutex
+	persisted int64
+}
+
+// NewRunner creates a runner that keeps batches in store and executes their requests
+// with execute. Call Start to begin processing.
+func NewRunner(store Store, execute ExecuteFunc, opts Options) *Runner {
+	ctx, stop := context.WithCancel(context.Background())
+	shared, _ := store.(SharedStore)
+	return &Runner{
+		store:   store,
+		shared:  shared,
+		owner:   NewID("runner_"),
+		execute: execute,
+		opts:    opts,
+		wake:    make(chan struct{}),
+		paused:  make(map[string]time.Time),
+		runs:    make(map[string]*run),
+		ctx:     ctx,
+		stop:    stop,
+	}
+}
+
+// Store returns the store the runner keeps batches in.
+func (r *Runner) Store() Store {
+	return r.store
+}
+
+// Start resumes the batches left unfinished by a previous run and starts removing ended
+// batches once their retention has passed. With a shared store it also keeps adopting
+// batches whose lease expired.
+func (r *Runner) Start() {
+	r.resume()
+	r.wg.Add(1)
+	go r.sweep()
+	if r.shared != nil {
+		r.wg.Add(1)
+		go r.adopt()
+	}
+}
+
+// resume launches the unfinished batches not running here. With a shared store only the
+// batches whose lease this runner obtains are launched.
+func (r *Runner) resume() {
+	list, err := r.store.ListBatches(r.ctx)
+	if err != nil {
+		log.Errorf("batches: list stored batches: %v", err)
+	}
+	resumed := 0
+	for _, batch := range list {
+		if batch.Status.Ended() || r.running(batch.ID) {
+			continue
+		}
+		if r.shared != nil {
+			claimed, errClaim := r.shared.ClaimBatch(r.ctx, batch.ID, r.owner, leaseDuration)
+			if errClaim != nil {
+				log.Warnf("batches: claim batch %s: %v", batch.ID, errClaim)
+				continue
+			}
+			if !claimed {
+				continue
+			}
+			// The list may predate the previous holder ending the batch.
+			fresh, errGet := r.store.GetBatch(r.ctx, batch.ID)
+			if errGet != nil || fresh.Status.Ended() {
+				r.releaseLease(batch.ID)
+				continue
+			}
+			batch = fresh
+		}
+		if r.launch(batch) {
+			resumed++
+		}
+	}
+	if resumed > 0 {
+		log.Infof("batches: resumed %d unfinished batches", resumed)
+	}
+}
+
+// adopt periodically resumes batches of a shared store whose holder stopped renewing its
+// lease.
+func (r *Runner) adopt() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(adoptInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		r.resume()
+	}
+}
+
+func (r *Runner) running(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.runs[id]
+	return ok
+}
+
+// Stop stops dispatching requests and waits for in-flight requests until ctx is done.
+// Requests without a result are executed again when the batch is resumed.
+func (r *Runner) Stop(ctx context.Context) error {
+	r.stop()
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Submit stores a new batch with its requests and starts processing it. The status,
+// counts and expiry of batch are set by the runner.
+func (r *Runner) Submit(ctx context.Context, batch *Batch, requests []Request) (*Batch, error) {
+	now := time.Now()
+	batch.Status = StatusInProgress
+	batch.Counts = Counts{Total: len(requests)}
+	batch.CreatedAt = now
+	batch.ExpiresAt = now.Add(CompletionWindow)
+	if err := r.store.PutRequests(ctx, batch.ID, requests); err != nil {
+		return nil, err
+	}
+	if err := r.store.PutBatch(ctx, batch); err != nil {
+		_ = r.store.DeleteBatch(context.WithoutCancel(ctx), batch.ID)
+		return nil, err
+	}
+	snapshot := batch.Clone()
+	if r.shared != nil {
+		claimed, err := r.shared.ClaimBatch(ctx, batch.ID, r.owner, leaseDuration)
+		if err != nil {
+			log.Warnf("batches: claim new batch %s: %v", batch.ID, err)
+		}
+		if !claimed {
+			// An instance adopting unleased batches processes it instead.
+			return snapshot, nil
+		}
+	}
+	r.launch(batch)
+	return snapshot, nil
+}
+
+// Get returns the current state of a batch.
+func (r *Runner) Get(ctx context.Context, id string) (*Batch, error) {
+	r.mu.Lock()
+	if current, ok := r.runs[id]; ok {
+		snapshot := current.batch.Clone()
+		r.mu.Unlock()
+		return snapshot, nil
+	}
+	r.mu.Unlock()
+	return r.store.GetBatch(ctx, id)
+}
+
+// List returns every batch, newest first.
+func (r *Runner) List(ctx context.Context) ([]*Batch, error) {
+	list, err := r.store.ListBatches(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, batch := range list {
+		if current, ok := r.runs[batch.ID]; ok {
+			list[i] = current.batch.Clone()
+		}
+	}
+	return list, nil
+}
+
+// Cancel stops dispatching the requests of a batch. In-flight requests finish; the others
+// are recorded as canceled. Ended batches are returned unchanged. A batch processed by
+// another instance is canceled through the store.
+func (r *Runner) Cancel(ctx context.Context, id string) (*Batch, error) {
+	r.mu.Lock()
+	current, ok := r.runs[id]
+	if !ok {
+		r.mu.Unlock()
+		return r.cancelStored(ctx, id)
+	}
+	if current.batch.CancelRequestedAt == nil {
+		now := time.Now()
+		current.batch.CancelRequestedAt = &now
+		current.batch.Status = StatusCanceling
+	}
+	snapshot, version := r.snapshotLocked(current)
+	r.mu.Unlock()
+	r.persist(current, snapshot, version)
+	current.dispatch()
+	return snapshot.Clone(), nil
+}
+
+// cancelStored records a cancel request on a batch that is not running here. The instance
+// processing it picks the request up from the store.
+func (r *Runner) cancelStored(ctx context.Context, id string) (*Batch, error) {
+	batch, err := r.store.GetBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if batch.Status.Ended() || batch.CancelRequestedAt != nil {
+		return batch, nil
+	}
+	now := time.Now()
+	batch.CancelRequestedAt = &now
+	batch.Status = StatusCanceling
+	if err = r.store.PutBatch(ctx, batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// Delete removes an ended batch and its results.
+func (r *Runner) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	_, running := r.runs[id]
+	r.mu.Unlock()
+	if running {
+		return ErrNotEnded
+	}
+	batch, err := r.store.GetBatch(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !batch.Status.Ended() {
+		return ErrNotEnded
+	}
+	return r.store.DeleteBatch(ctx, id)
+}
+
+// Results returns the results of a batch ordered like its requests.
+func (r *Runner) Results(ctx context.Context, id string) ([]Result, error) {
+	results, err := r.store.LoadResults(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return orderResults(results), nil
+}
+
+// orderResults sorts results by request index, keeping the first result of each request.
+func orderResults(results []Result) []Result {
+	seen := make(map[int]bool, len(results))
+	ordered := results[:0]
+	for _, result := range results {
+		if seen[result.Index] {
+			continue
+		}
+		seen[result.Index] = true
+		ordered = append(ordered, result)
+	}
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Index < ordered[j].Index })
+	return ordered
+}
+
+// launch starts processing a batch unless it is already running here.
+func (r *Runner) launch(batch *Batch) bool {
+	runCtx, abandon := context.WithCancel(r.ctx)
+	dispatchCtx, dispatch := context.WithDeadline(runCtx, batch.ExpiresAt)
+	if batch.CancelRequestedAt != nil {
+		dispatch()
+	}
+	current := &run{batch: batch, done: make(map[int]bool), ctx: runCtx, abandon: abandon, dispatch: dispatch}
+	r.mu.Lock()
+	if _, ok := r.runs[batch.ID]; ok {
+		r.mu.Unlock()
+		dispatch()
+		abandon()
+		return false
+	}
+	r.runs[batch.ID] = current
+	r.mu.Unlock()
+	r.wg.Add(1)
+	go r.process(dispatchCtx, current)
+	return true
+}
+
+// process executes the requests of a batch that have no result yet and ends the batch.
+// dispatchCtx is canceled when the batch is canceled or expires, when the runner stops
+// and when the lease is lost.
+func (r *Runner) process(dispatchCtx context.Context, current *run) {
+	defer r.wg.Done()
+	id := current.batch.ID
+	if r.shared != nil {
+		kept := make(chan struct{})
+		go func() {
+			defer close(kept)
+			r.keepLease(current)
+		}()
+		defer func() {
+			current.abandon()
+			<-kept
+			r.releaseLease(id)
+		}()
+	}
+	defer current.abandon()
+	defer current.dispatch()
+	defer func() {
+		r.mu.Lock()
+		delete(r.runs, id)
+		r.mu.Unlock()
+	}()
+
+	requests, err := r.store.LoadRequests(current.ctx, id)
+	if err != nil {
+		log.Errorf("batches: load requests of %s: %v", id, err)
+		return
+	}
+	results, err := r.store.LoadResults(current.ctx, id)
+	if err != nil {
+		log.Errorf("batches: load results of %s: %v", id, err)
+		return
+	}
+	r.mu.Lock()
+	current.batch.Counts = Counts{Total: len(requests)}
+	for _, result := range orderResults(results) {
+		if result.Index >= 0 && result.Index < len(requests) {
+			current.done[result.Index] = true
+			current.batch.Counts.add(result.Outcome)
+		}
+	}
+	r.mu.Unlock()
+
+	var inflight sync.WaitGroup
+	for index, request := range requests {
+		r.mu.Lock()
+		done := current.done[index]
+		r.mu.Unlock()
+		if done {
+			continue
+		}
+		if r.shared != nil {
+			r.syncCancel(current)
+		}
+		model := gjson.GetBytes(request.Body, "model").String()
+		if !r.acquire(dispatchCtx, model) {
+			break
+		}
+		inflight.Add(1)
+		go func(index int, request Request) {
+			defer inflight.Done()
+			r.executeRequest(dispatchCtx, current, index, request, model)
+		}(index, request)
+	}
+	inflight.Wait()
+	if current.ctx.Err() != nil {
+		return
+	}
+	r.finish(current, requests)
+}
+
+// keepLease renews the lease on a batch until its run ends and picks up cancels requested
+// through other instances. The run is abandoned when the lease is lost, leaving the
+// remaining requests to the new holder.
+func (r *Runner) keepLease(current *run) {
+	id := current.batch.ID
+	ticker := time.NewTicker(leaseRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-current.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		claimed, err := r.shared.ClaimBatch(current.ctx, id, r.owner, leaseDuration)
+		if err != nil {
+			if current.ctx.Err() == nil {
+				log.Warnf("batches: renew lease on %s: %v", id, err)
+			}
+			continue
+		}
+		if !claimed {
+			log.Warnf("batches: lease on %s was taken over by another instance", id)
+			current.abandon()
+			return
+		}
+		r.syncCancel(current)
+	}
+}
+
+// releaseLease gives up the lease on a batch so another instance can adopt it at once.
+func (r *Runner) releaseLease(id string) {
+	if err := r.shared.ReleaseBatch(context.Background(), id, r.owner); err != nil {
+		log.Warnf("batches: release lease on %s: %v", id, err)
+	}
+}
+
+// syncCancel stops dispatching the requests of a batch whose cancel was stored through
+// another instance.
+func (r *Runner) syncCancel(current *run) {
+	r.mu.Lock()
+	canceled := current.batch.CancelRequestedAt != nil
+	r.mu.Unlock()
+	if canceled {
+		return
+	}
+	stored, err := r.store.GetBatch(current.ctx, current.batch.ID)
+	if err != nil || stored.CancelRequestedAt == nil {
+		return
+	}
+	r.mu.Lock()
+	if current.batch.CancelRequestedAt == nil {
+		at := *stored.CancelRequestedAt
+		current.batch.CancelRequestedAt = &at
+		current.batch.Status = StatusCanceling
+	}
+	r.mu.Unlock()
+	current.dispatch()
+}
+
+// executeRequest runs one request, holding a slot acquired by the caller, and records
+// its result. Requests refused for lack of available credentials are retried after the
+// cooldown; they are left without a result when the batch is canceled or expires first.
+func (r *Runner) executeRequest(dispatchCtx context.Context, current *run, index int, request Request, model string) {
+	delay := initialRetryDelay
+	for {
+		r.mu.Lock()
+		batch := current.batch.Clone()
+		r.mu.Unlock()
+		resp := r.execute(current.ctx, batch, request)
+		r.release()
+		if current.ctx.Err() != nil {
+			return
+		}
+		if !retryable(resp.StatusCode) {
+			r.record(current, index, request.CustomID, resp)
+			return
+		}
+		wait := resp.RetryAfter
+		if wait <= 0 {
+			wait = delay
+			delay = min(delay*2, maxRetryDelay)
+		}
+		log.Debugf("batches: %s request %s refused with status %d, retrying in %s", batch.ID, request.CustomID, resp.StatusCode, wait)
+		r.pause(model, wait)
+		if !r.acquire(dispatchCtx, model) {
+			return
+		}
+	}
+}
+
+// retryable reports whether a status means the request should wait for a credential
+// instead of failing.
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable || status == 529
+}
+
+// acquire waits until model is not paused and a slot is free, and takes the slot. It
+// returns false when ctx is done first.
+func (r *Runner) acquire(ctx context.Context, model string) bool {
+	for {
+		r.mu.Lock()
+		now := time.Now()
+		if until := r.paused[model]; until.After(now) {
+			r.mu.Unlock()
+			timer := time.NewTimer(until.Sub(now))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return false
+			case <-timer.C:
+			}
+			continue
+		}
+		delete(r.paused, model)
+		if ctx.Err() != nil {
+			r.mu.Unlock()
+			return false
+		}
+		if r.active < r.concurrency() {
+			r.active++
+			r.mu.Unlock()
+			return true
+		}
+		wake := r.wake
+		r.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return false
+		case <-wake:
+		}
+	}
+}
+
+func (r *Runner) release() {
+	r.mu.Lock()
+	r.active--
+	close(r.wake)
+	r.wake = make(chan struct{})
+	r.mu.Unlock()
+}
+
+// pause holds back requests for model for wait, extending an existing pause.
+func (r *Runner) pause(model string, wait time.Duration) {
+	until := time.Now().Add(wait)
+	r.mu.Lock()
+	if until.After(r.paused[model]) {
+		r.paused[model] = until
+	}
+	r.mu.Unlock()
+}
+
+func (r *Runner) concurrency() int {
+	if r.opts.Concurrency != nil {
+		if n := r.opts.Concurrency(); n > 0 {
+			return n
+		}
+	}
+	return DefaultConcurrency
+}
+
+func (r *Runner) retention() time.Duration {
+	if r.opts.Retention != nil {
+		if d := r.opts.Retention(); d > 0 {
+			return d
+		}
+	}
+	return DefaultRetention
+}
+
+// record stores the result of a request and updates the batch counts. A result that
+// cannot be stored is dropped, so the request runs again when the batch is resumed.
+func (r *Runner) record(current *run, index int, customID string, resp Response) {
+	outcome := OutcomeErrored
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		outcome = OutcomeSucceeded
+	}
+	result := Result{
+		ID:          NewID("batch_req_"),
+		Index:       index,
+		CustomID:    customID,
+		Outcome:     outcome,
+		StatusCode:  resp.StatusCode,
+		Body:        resp.Body,
+		CompletedAt: time.Now(),
+	}
+	if err := r.store.AppendResult(context.Background(), current.batch.ID, result); err != nil {
+		log.Errorf("batches: store result of %s request %s: %v", current.batch.ID, customID, err)
+		return
+	}
+	r.mu.Lock()
+	current.done[index] = true
+	current.batch.Counts.add(outcome)
+	snapshot, version := r.snapshotLocked(current)
+	r.mu.Unlock()
+	r.persist(current, snapshot, version)
+}
+
+// finish records the requests left without a result as canceled or expired and ends
+// the batch.
+func (r *Runner) finish(current *run, requests []Request) {
+	r.mu.Lock()
+	outcome := OutcomeExpired
+	if current.batch.CancelRequestedAt != nil {
+		outcome = OutcomeCanceled
+	}
+	var pending []int
+	for index := range requests {
+		if !current.done[index] {
+			pending = append(pending, index)
+		}
+	}
+	r.mu.Unlock()
+
+	now := time.Now()
+	for _, index := range pending {
+		result := Result{ID: NewID("batch_req_"), Index: index, CustomID: requests[index].CustomID, Outcome: outcome, CompletedAt: now}
+		if err := r.store.AppendResult(context.Background(), current.batch.ID, result); err != nil {
+			log.Errorf("batches: store result of %s request %s: %v", current.batch.ID, result.CustomID, err)
+		}
+	}
+
+	r.mu.Lock()
+	for range pending {
+		current.batch.Counts.add(outcome)
+	}
+	switch {
+	case current.batch.CancelRequestedAt != nil:
+		current.batch.Status = StatusCanceled
+	case len(pending) > 0:
+		current.batch.Status = StatusExpired
+	default:
+		current.batch.Status = StatusCompleted
+	}
+	current.batch.EndedAt = &now
+	snapshot, version := r.snapshotLocked(current)
+	r.mu.Unlock()
+	r.persist(current, snapshot, version)
+	log.Debugf("batches: %s ended as %s (%d succeeded, %d errored, %d canceled, %d expired)", snapshot.ID, snapshot.Status, snapshot.Counts.Succeeded, snapshot.Counts.Errored, snapshot.Counts.Canceled, snapshot.Counts.Expired)
+}
+
+// snapshotLocked copies the batch for persisting. Callers hold r.mu.
+func (r *Runner) snapshotLocked(current *run) (*Batch, int64) {
+	current.version++
+	return current.batch.Clone(), current.version
+}
+
+// persist writes a batch snapshot unless a newer one was already written.
+func (r *Runner) persist(current *run, snapshot *Batch, version int64) {
+	current.persistMu.Lock()
+	defer current.persistMu.Unlock()
+	if version <= current.persisted {
+		return
+	}
+	if err := r.store.PutBatch(context.Background(), snapshot); err != nil {
+		log.Errorf("batches: store batch %s: %v", snapshot.ID, err)
+		return
+	}
+	current.persisted = version
+}
+
+// sweep periodically deletes ended batches older than the retention.
+func (r *Runner) sweep() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	for {
+		r.removeExpired()
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Runner) removeExpired() {
+	list, err := r.store.ListBatches(r.ctx)
+	if err != nil {
+		log.Warnf("batches: list stored batches: %v", err)
+		return
+	}
+	cutoff := time.Now().Add(-r.retention())
+	for _, batch := range list {
+		if !batch.Status.Ended() || batch.EndedAt == nil || batch.EndedAt.After(cutoff) {
+			continue
+		}
+		if errDelete := r.store.DeleteBatch(r.ctx, batch.ID); errDelete != nil && !errors.Is(errDelete, ErrNotFound) {
+			log.Warnf("batches: remove batch %s: %v", batch.ID, errDelete)
+		}
+	}
+}
+
+// NewID returns a random ID with the given prefix, e.g. msgbatch_ or file-.
+func NewID(prefix string) string {
+	var buf [12]byte
+	_, _ = rand.Read(buf[:])
+	return prefix + hex.EncodeToString(buf[:])
+}
@@ -11,6 +11,7 @@ import (
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/pipeline"
 )
 
 // Builder constructs a Service instance with customizable providers.
@@ -46,6 +47,9 @@ type Builder struct {
 
 	// serverOptions contains additional server configuration options.
 	serverOptions []api.ServerOption
+
+	// pipelineHooks run around every executor call made by the core manager.
+	pipelineHooks []pipeline.Hook
 }
 
 // Hooks allows callers to plug into service lifecycle stages.
@@ -142,6 +146,18 @@ func (b *Builder) WithServerOptions(opts ...api.ServerOption) *Builder {
 	return b
 }
 
+// WithPipelineHooks registers hooks invoked around every provider executor call.
+// Hooks observe the selected auth, the request and each stream chunk, and may rewrite
+// the request payload or supply a custom HTTP client before execution.
+func (b *Builder) WithPipelineHooks(hooks ...pipeline.Hook) *Builder {
+	for _, hook := range hooks {
+		if hook != nil {
+			b.pipelineHooks = append(b.pipelineHooks, hook)
+		}
+	}
+	return b
+}
+
 // WithLocalManagementPassword configures a password that is only accepted from localhost management requests.
 func (b *Builder) WithLocalManagementPassword(password string) *Builder {
 	if password == "" {
@@ -201,6 +217,9 @@ func (b *Builder) Build() (*Service, error) {
 	}
 	// Attach a default RoundTripper provider so providers can opt-in per-auth transports.
 	coreManager.SetRoundTripperProvider(newDefaultRoundTripperProvider())
+	for _, hook := range b.pipelineHooks {
+		coreManager.RegisterExecutionHook(hook)
+	}
 
 	service := &Service{
 		cfg:            b.cfg,
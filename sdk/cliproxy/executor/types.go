@@ -3,6 +3,7 @@ package executor
 import (
 	"net/http"
 	"net/url"
+	"time"
 
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 )
@@ -60,3 +61,11 @@ type StatusError interface {
 	error
 	StatusCode() int
 }
+
+// RetryAfterError represents an error that knows when the upstream accepts the next request,
+// e.g. from a Retry-After header or a provider specific rate-limit reset hint.
+// RetryAfter returns nil when no hint was provided.
+type RetryAfterError interface {
+	error
+	RetryAfter() *time.Duration
+}
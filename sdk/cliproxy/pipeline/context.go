@@ -6,29 +6,16 @@ import (
 
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
-	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 )
 
 // Context encapsulates execution state shared across middleware, translators, and executors.
-type Context struct {
-	// Request encapsulates the provider facing request payload.
-	Request cliproxyexecutor.Request
-	// Options carries execution flags (streaming, headers, etc.).
-	Options cliproxyexecutor.Options
-	// Auth references the credential selected for execution.
-	Auth *cliproxyauth.Auth
-	// Translator represents the pipeline responsible for schema adaptation.
-	Translator *sdktranslator.Pipeline
-	// HTTPClient allows middleware to customise the outbound transport per request.
-	HTTPClient *http.Client
-}
+// It aliases the auth manager type so hooks registered through cliproxy.Builder observe
+// and mutate the exact state the manager hands to provider executors.
+type Context = cliproxyauth.ExecutionContext
 
 // Hook captures middleware callbacks around execution.
-type Hook interface {
-	BeforeExecute(ctx context.Context, execCtx *Context)
-	AfterExecute(ctx context.Context, execCtx *Context, resp cliproxyexecutor.Response, err error)
-	OnStreamChunk(ctx context.Context, execCtx *Context, chunk cliproxyexecutor.StreamChunk)
-}
+// BeforeExecute may rewrite Context.Request or set Context.HTTPClient before the executor runs.
+type Hook = cliproxyauth.ExecutionHook
 
 // HookFunc aggregates optional hook implementations.
 type HookFunc struct {
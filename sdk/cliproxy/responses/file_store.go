@@ -0,0 +1,178 @@
+package responses
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// FileStore keeps one JSON file per response in a directory so responses survive
+// restarts. Entry ages come from the file modification times.
+type FileStore struct {
+	dir   string
+	mu    sync.Mutex
+	index *lru
+}
+
+// NewFileStore opens or creates dir and indexes the responses already stored in it.
+func NewFileStore(dir string, limits Limits) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create response store directory %s: %w", dir, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read response store directory %s: %w", dir, err)
+	}
+	type existing struct {
+		key     string
+		size    int64
+		modTime time.Time
+	}
+	found := make([]existing, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		info, errInfo := entry.Info()
+		if errInfo != nil {
+			continue
+		}
+		found = append(found, existing{key: strings.TrimSuffix(name, ".json"), size: info.Size(), modTime: info.ModTime()})
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].modTime.After(found[j].modTime) })
+
+	s := &FileStore{dir: dir, index: newLRU(limits)}
+	for _, entry := range found {
+		s.index.addOldest(entry.key, entry.size, entry.modTime)
+	}
+	s.removeFiles(s.index.evict(time.Now(), ""))
+	return s, nil
+}
+
+// Get implements Store.
+func (s *FileStore) Get(_ context.Context, id string) (*StoredResponse, error) {
+	key := fileKey(id)
+	s.mu.Lock()
+	present := s.index.touch(key, time.Now())
+	s.mu.Unlock()
+	if !present {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(s.path(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	var resp StoredResponse
+	if err = json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("decode stored response %s: %w", id, err)
+	}
+	if resp.ID != id {
+		return nil, ErrNotFound
+	}
+	return &resp, nil
+}
+
+// Put implements Store.
+func (s *FileStore) Put(_ context.Context, resp *StoredResponse) error {
+	if resp == nil || resp.ID == "" {
+		return nil
+	}
+	stored := *resp
+	if stored.CreatedAt.IsZero() {
+		stored.CreatedAt = time.Now()
+	}
+	data, err := json.Marshal(&stored)
+	if err != nil {
+		return err
+	}
+	key := fileKey(stored.ID)
+	path := s.path(key)
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, errWrite := tmp.Write(data)
+	errClose := tmp.Close()
+	if err = errors.Join(errWrite, errClose); err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write stored response %s: %w", stored.ID, err)
+	}
+
+	s.mu.Lock()
+	evicted := s.index.add(key, int64(len(data)), stored.CreatedAt)
+	s.mu.Unlock()
+	s.removeFiles(evicted)
+	return nil
+}
+
+// Delete implements Store.
+func (s *FileStore) Delete(_ context.Context, id string) error {
+	key := fileKey(id)
+	s.mu.Lock()
+	present := s.index.touch(key, time.Now())
+	s.index.remove(key)
+	s.mu.Unlock()
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if !present {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetLimits changes the limits, removing entries that no longer fit.
+func (s *FileStore) SetLimits(limits Limits) {
+	s.mu.Lock()
+	evicted := s.index.setLimits(limits)
+	s.mu.Unlock()
+	s.removeFiles(evicted)
+}
+
+func (s *FileStore) removeFiles(keys []string) {
+	for _, key := range keys {
+		if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warnf("responses store: remove %s: %v", s.path(key), err)
+		}
+	}
+}
+
+func (s *FileStore) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
+
+// fileKey maps a response ID to a safe file name, hashing IDs that are not plain tokens.
+func fileKey(id string) string {
+	if len(id) > 0 && len(id) <= 128 {
+		plain := true
+		for _, r := range id {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+				plain = false
+				break
+			}
+		}
+		if plain {
+			return id
+		}
+	}
+	sum := sha256.Sum256([]byte(id))
+	return "h-" + hex.EncodeToString(sum[:])
+}
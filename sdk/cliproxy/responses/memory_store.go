@@ -0,0 +1,70 @@
+package responses
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps responses in process memory; they are lost on restart.
+type MemoryStore struct {
+	mu    sync.Mutex
+	index *lru
+	data  map[string]*StoredResponse
+}
+
+// NewMemoryStore creates an empty in-memory store bounded by limits.
+func NewMemoryStore(limits Limits) *MemoryStore {
+	return &MemoryStore{index: newLRU(limits), data: make(map[string]*StoredResponse)}
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(_ context.Context, id string) (*StoredResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.index.touch(id, time.Now()) {
+		return nil, ErrNotFound
+	}
+	resp := *s.data[id]
+	return &resp, nil
+}
+
+// Put implements Store.
+func (s *MemoryStore) Put(_ context.Context, resp *StoredResponse) error {
+	if resp == nil || resp.ID == "" {
+		return nil
+	}
+	stored := *resp
+	if stored.CreatedAt.IsZero() {
+		stored.CreatedAt = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[stored.ID] = &stored
+	for _, key := range s.index.add(stored.ID, stored.size(), stored.CreatedAt) {
+		delete(s.data, key)
+	}
+	return nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	present := s.index.touch(id, time.Now())
+	s.index.remove(id)
+	delete(s.data, id)
+	if !present {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetLimits changes the limits, evicting entries that no longer fit.
+func (s *MemoryStore) SetLimits(limits Limits) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range s.index.setLimits(limits) {
+		delete(s.data, key)
+	}
+}
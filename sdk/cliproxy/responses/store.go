@@ -0,0 +1,261 @@
+// Package responses keeps the results of /v1/responses calls so that later requests can
+// continue a conversation with previous_response_id instead of resending its history.
+package responses
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultTTL        = 24 * time.Hour
+	defaultMaxEntries = 1000
+	defaultMaxBytes   = 128 << 20
+	defaultFileDir    = "responses"
+)
+
+// ErrNotFound is returned when a response is unknown or has expired.
+var ErrNotFound = errors.New("responses: response not found")
+
+// StoredResponse is a completed response together with the full input that produced it.
+type StoredResponse struct {
+	// ID is the response ID returned to the client.
+	ID string `json:"id"`
+	// Owner identifies the client allowed to read the response; empty when unauthenticated.
+	Owner string `json:"owner,omitempty"`
+	// Model is the model that produced the response.
+	Model string `json:"model,omitempty"`
+	// CreatedAt is when the response was stored.
+	CreatedAt time.Time `json:"created_at"`
+	// Input holds every input item of the call as a JSON array, including the items
+	// expanded from previous_response_id.
+	Input json.RawMessage `json:"input"`
+	// Response is the response object returned to the client.
+	Response json.RawMessage `json:"response"`
+}
+
+func (r *StoredResponse) size() int64 {
+	return int64(len(r.ID) + len(r.Owner) + len(r.Model) + len(r.Input) + len(r.Response))
+}
+
+// Store persists responses. Implementations enforce their own expiry and size limits
+// and return ErrNotFound for unknown or expired IDs.
+type Store interface {
+	Get(ctx context.Context, id string) (*StoredResponse, error)
+	Put(ctx context.Context, resp *StoredResponse) error
+	Delete(ctx context.Context, id string) error
+}
+
+// Limits bounds a store. Zero fields use the defaults: 24 hours, 1000 entries and 128 MB.
+type Limits struct {
+	TTL        time.Duration
+	MaxEntries int
+	MaxBytes   int64
+}
+
+func (l Limits) withDefaults() Limits {
+	if l.TTL <= 0 {
+		l.TTL = defaultTTL
+	}
+	if l.MaxEntries <= 0 {
+		l.MaxEntries = defaultMaxEntries
+	}
+	if l.MaxBytes <= 0 {
+		l.MaxBytes = defaultMaxBytes
+	}
+	return l
+}
+
+// LimitsFromConfig converts the store settings of the config file into Limits.
+func LimitsFromConfig(cfg config.ResponsesStoreConfig) Limits {
+	return Limits{
+		TTL:        time.Duration(cfg.TTLSeconds) * time.Second,
+		MaxEntries: cfg.MaxEntries,
+		MaxBytes:   int64(cfg.MaxSizeMB) << 20,
+	}
+}
+
+var (
+	storeMu       sync.RWMutex
+	defaultStore  Store = NewMemoryStore(Limits{})
+	configured          = true
+	configuredCfg config.ResponsesStoreConfig
+	configuredDir string
+)
+
+// DefaultStore returns the store used by the /v1/responses handlers.
+func DefaultStore() Store {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	return defaultStore
+}
+
+// SetStore installs a custom store. ConfigureStore leaves it in place afterwards.
+func SetStore(store Store) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	defaultStore = store
+	configured = false
+}
+
+// ConfigureStore applies the store settings of the config file, resolving a relative file
+// directory against baseDir. Limit changes keep the stored responses; switching backend or
+// directory starts a new store. Stores installed with SetStore are left untouched.
+func ConfigureStore(cfg config.ResponsesStoreConfig, baseDir string) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	if !configured {
+		return
+	}
+	backend := storeBackend(cfg)
+	dir := ""
+	if backend == "file" {
+		dir = strings.TrimSpace(cfg.Dir)
+		if dir == "" {
+			dir = defaultFileDir
+		}
+		if !filepath.IsAbs(dir) && baseDir != "" {
+			dir = filepath.Join(baseDir, dir)
+		}
+	}
+	limits := LimitsFromConfig(cfg)
+	if backend == storeBackend(configuredCfg) && dir == configuredDir {
+		if limited, ok := defaultStore.(interface{ SetLimits(Limits) }); ok {
+			limited.SetLimits(limits)
+		}
+		configuredCfg = cfg
+		return
+	}
+
+	var store Store
+	switch backend {
+	case "memory":
+		store = NewMemoryStore(limits)
+	case "file":
+		fileStore, err := NewFileStore(dir, limits)
+		if err != nil {
+			log.Errorf("responses store: %v; falling back to memory", err)
+			store = NewMemoryStore(limits)
+			break
+		}
+		log.Infof("responses store: keeping responses in %s", dir)
+		store = fileStore
+	default:
+		log.Errorf("responses store: unknown backend %q, expected memory or file; falling back to memory", cfg.Backend)
+		store = NewMemoryStore(limits)
+	}
+	defaultStore = store
+	configuredCfg = cfg
+	configuredDir = dir
+}
+
+func storeBackend(cfg config.ResponsesStoreConfig) string {
+	backend := strings.ToLower(strings.TrimSpace(cfg.Backend))
+	if backend == "" {
+		return "memory"
+	}
+	return backend
+}
+
+// lru tracks entry sizes and ages so stores can expire and evict entries. The front of
+// order is the most recently used entry.
+type lru struct {
+	limits Limits
+	order  *list.List
+	items  map[string]*list.Element
+	bytes  int64
+}
+
+type lruEntry struct {
+	key     string
+	size    int64
+	created time.Time
+}
+
+func newLRU(limits Limits) *lru {
+	return &lru{limits: limits.withDefaults(), order: list.New(), items: make(map[string]*list.Element)}
+}
+
+// add records key as the most recently used entry and returns the keys evicted to stay
+// within the limits, never including key itself.
+func (l *lru) add(key string, size int64, created time.Time) []string {
+	l.remove(key)
+	l.items[key] = l.order.PushFront(&lruEntry{key: key, size: size, created: created})
+	l.bytes += size
+	return l.evict(time.Now(), key)
+}
+
+// addOldest records key as the least recently used entry, used when loading existing
+// entries in age order.
+func (l *lru) addOldest(key string, size int64, created time.Time) {
+	l.remove(key)
+	l.items[key] = l.order.PushBack(&lruEntry{key: key, size: size, created: created})
+	l.bytes += size
+}
+
+// touch marks key as used and reports whether it is present and not expired.
+func (l *lru) touch(key string, now time.Time) bool {
+	elem, ok := l.items[key]
+	if !ok {
+		return false
+	}
+	if l.expired(elem.Value.(*lruEntry), now) {
+		return false
+	}
+	l.order.MoveToFront(elem)
+	return true
+}
+
+func (l *lru) remove(key string) bool {
+	elem, ok := l.items[key]
+	if !ok {
+		return false
+	}
+	l.bytes -= elem.Value.(*lruEntry).size
+	l.order.Remove(elem)
+	delete(l.items, key)
+	return true
+}
+
+func (l *lru) expired(entry *lruEntry, now time.Time) bool {
+	return now.Sub(entry.created) > l.limits.TTL
+}
+
+// evict drops expired entries and then the least recently used ones until the limits
+// hold. keep is never evicted.
+func (l *lru) evict(now time.Time, keep string) []string {
+	var evicted []string
+	for elem := l.order.Back(); elem != nil; {
+		prev := elem.Prev()
+		entry := elem.Value.(*lruEntry)
+		if entry.key != keep && l.expired(entry, now) {
+			l.remove(entry.key)
+			evicted = append(evicted, entry.key)
+		}
+		elem = prev
+	}
+	for elem := l.order.Back(); elem != nil && (len(l.items) > l.limits.MaxEntries || l.bytes > l.limits.MaxBytes); {
+		prev := elem.Prev()
+		entry := elem.Value.(*lruEntry)
+		if entry.key != keep {
+			l.remove(entry.key)
+			evicted = append(evicted, entry.key)
+		}
+		elem = prev
+	}
+	return evicted
+}
+
+func (l *lru) setLimits(limits Limits) []string {
+	l.limits = limits.withDefaults()
+	return l.evict(time.Now(), "")
+}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -16,11 +17,15 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
-	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
+	usagestats "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/watcher"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/batches"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/responses"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	log "github.com/sirupsen/logrus"
 )
@@ -80,6 +85,12 @@ type Service struct {
 	// coreManager handles core authentication and execution.
 	coreManager *coreauth.Manager
 
+	// routingMu serializes routing strategy changes, which run from config reloads.
+	routingMu sync.Mutex
+
+	// routingStrategy records the selection strategy currently applied to coreManager.
+	routingStrategy string
+
 	// shutdownOnce ensures shutdown is called only once.
 	shutdownOnce sync.Once
 }
@@ -93,6 +104,16 @@ func (s *Service) RegisterUsagePlugin(plugin usage.Plugin) {
 	usage.RegisterPlugin(plugin)
 }
 
+// SetResponseStore replaces the store behind previous_response_id and
+// GET/DELETE /v1/responses/{id}. The responses.store settings of the config file then
+// no longer apply, except for responses.store.disable.
+//
+// Parameters:
+//   - store: The response store to use
+func (s *Service) SetResponseStore(store responses.Store) {
+	responses.SetStore(store)
+}
+
 // newDefaultAuthManager creates a default authentication manager with all supported providers.
 func newDefaultAuthManager() *sdkAuth.Manager {
 	return sdkAuth.NewManager(
@@ -280,6 +301,109 @@ func (s *Service) rebindExecutors() {
 	}
 }
 
+// applyRetryPolicy propagates the request retry configuration to the core auth manager.
+func (s *Service) applyRetryPolicy(cfg *config.Config) {
+	if s == nil || s.coreManager == nil || cfg == nil {
+		return
+	}
+	backoff := cfg.RequestRetryBackoff
+	s.coreManager.SetRetryPolicy(coreauth.RetryPolicy{
+		MaxRetries:     cfg.RequestRetry,
+		InitialBackoff: time.Duration(backoff.InitialMS) * time.Millisecond,
+		MaxBackoff:     time.Duration(backoff.MaxMS) * time.Millisecond,
+		Multiplier:     backoff.Multiplier,
+		Jitter:         backoff.Jitter,
+	})
+}
+
+// applyCooldownPolicy propagates the default credential cooldowns to the core auth manager.
+func (s *Service) applyCooldownPolicy(cfg *config.Config) {
+	if s == nil || s.coreManager == nil || cfg == nil {
+		return
+	}
+	cooldowns := cfg.Cooldowns
+	s.coreManager.SetCooldownPolicy(coreauth.CooldownPolicy{
+		Unauthorized:    time.Duration(cooldowns.UnauthorizedSeconds) * time.Second,
+		PaymentRequired: time.Duration(cooldowns.PaymentRequiredSeconds) * time.Second,
+		RateLimited:     time.Duration(cooldowns.RateLimitedSeconds) * time.Second,
+		Transient:       time.Duration(cooldowns.TransientSeconds) * time.Second,
+	})
+}
+
+// applyModelFallbacks propagates the configured model fallback chains to the core auth manager.
+func (s *Service) applyModelFallbacks(cfg *config.Config) {
+	if s == nil || s.coreManager == nil || cfg == nil {
+		return
+	}
+	s.coreManager.SetModelFallbacks(cfg.ModelFallbacks)
+}
+
+// applyTracing (re)configures span export from the tracing settings.
+func (s *Service) applyTracing(cfg *config.Config) {
+	if s == nil || cfg == nil {
+		return
+	}
+	tracing.Configure(cfg.Tracing)
+}
+
+// applyUsageSinks (re)starts the usage sinks, resolving relative paths against the
+// config file directory.
+func (s *Service) applyUsageSinks(cfg *config.Config) {
+	if s == nil || cfg == nil {
+		return
+	}
+	usagestats.ConfigureSinks(context.Background(), cfg.UsageSinks, s.configDir())
+}
+
+// applyResponseStore applies the responses.store settings to the response store.
+func (s *Service) applyResponseStore(cfg *config.Config) {
+	if s == nil || cfg == nil {
+		return
+	}
+	responses.ConfigureStore(cfg.Responses.Store, s.configDir())
+}
+
+// applyBatchStore keeps message batches in the batches directory of the auth directory,
+// unless a store backend was installed with batches.SetStore.
+func (s *Service) applyBatchStore(cfg *config.Config) {
+	if s == nil || cfg == nil {
+		return
+	}
+	authDir, err := util.ResolveAuthDir(cfg.AuthDir)
+	if err != nil || authDir == "" {
+		log.Warnf("batches store: auth directory unavailable, keeping batches in a temporary directory: %v", err)
+		return
+	}
+	batches.ConfigureStore(filepath.Join(authDir, "batches"))
+}
+
+// applyRoutingStrategy swaps the core manager selector when the configured routing
+// strategy changes. An unset strategy leaves the manager's selector untouched so that
+// selectors injected through a custom core manager are preserved.
+func (s *Service) applyRoutingStrategy(cfg *config.Config) {
+	if s == nil || s.coreManager == nil || cfg == nil {
+		return
+	}
+	s.routingMu.Lock()
+	defer s.routingMu.Unlock()
+	raw := strings.TrimSpace(cfg.Routing.Strategy)
+	if raw == "" && s.routingStrategy == "" {
+		return
+	}
+	strategy := coreauth.NormalizeStrategy(raw)
+	if strategy == s.routingStrategy {
+		return
+	}
+	selector, err := coreauth.NewSelector(strategy)
+	if err != nil {
+		log.Warnf("ignoring routing strategy: %v", err)
+		return
+	}
+	s.coreManager.SetSelector(selector)
+	s.routingStrategy = strategy
+	log.Infof("routing strategy set to %s", strategy)
+}
+
 // Run starts the service and blocks until the context is cancelled or the server stops.
 // It initializes all components including authentication, file watching, HTTP server,
 // and starts processing requests. The method blocks until the context is cancelled.
@@ -298,6 +422,9 @@ func (s *Service) Run(ctx context.Context) error {
 	}
 
 	usage.StartDefault(ctx)
+	if errPersist := usagestats.StartPersistence(ctx, s.usageStatisticsFile()); errPersist != nil {
+		log.Errorf("failed to load persisted usage statistics: %v", errPersist)
+	}
 
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer shutdownCancel()
@@ -316,6 +443,14 @@ func (s *Service) Run(ctx context.Context) error {
 			log.Warnf("failed to load auth store: %v", errLoad)
 		}
 	}
+	s.applyRetryPolicy(s.cfg)
+	s.applyCooldownPolicy(s.cfg)
+	s.applyModelFallbacks(s.cfg)
+	s.applyTracing(s.cfg)
+	s.applyUsageSinks(s.cfg)
+	s.applyResponseStore(s.cfg)
+	s.applyBatchStore(s.cfg)
+	s.applyRoutingStrategy(s.cfg)
 
 	tokenResult, err := s.tokenProvider.Load(ctx, s.cfg)
 	if err != nil && !errors.Is(err, context.Canceled) {
@@ -379,6 +514,13 @@ func (s *Service) Run(ctx context.Context) error {
 		s.cfg = newCfg
 		s.cfgMu.Unlock()
 		s.rebindExecutors()
+		s.applyRetryPolicy(newCfg)
+		s.applyCooldownPolicy(newCfg)
+		s.applyModelFallbacks(newCfg)
+		s.applyTracing(newCfg)
+		s.applyUsageSinks(newCfg)
+		s.applyResponseStore(newCfg)
+		s.applyRoutingStrategy(newCfg)
 
 	}
 
@@ -468,10 +610,30 @@ func (s *Service) Shutdown(ctx context.Context) error {
 		}
 
 		usage.StopDefault()
+		if err := usagestats.StopPersistence(ctx); err != nil {
+			log.Errorf("failed to persist usage statistics: %v", err)
+		}
+		usagestats.CloseSinks(ctx)
+		tracing.Shutdown(ctx)
 	})
 	return shutdownErr
 }
 
+// defaultUsageStatisticsFile is the usage file name used when usage-statistics-file is unset.
+const defaultUsageStatisticsFile = "usage-statistics.jsonl"
+
+// usageStatisticsFile resolves the append-only usage file, relative to the config file directory.
+func (s *Service) usageStatisticsFile() string {
+	path := defaultUsageStatisticsFile
+	if s.cfg != nil && strings.TrimSpace(s.cfg.UsageStatisticsFile) != "" {
+		path = strings.TrimSpace(s.cfg.UsageStatisticsFile)
+	}
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(s.configDir(), path)
+}
+
 func (s *Service) ensureAuthDir() error {
 	info, err := os.Stat(s.cfg.AuthDir)
 	if err != nil {
@@ -511,7 +673,7 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 	var models []*ModelInfo
 	switch provider {
 	case "gemini":
-		models = registry.GetGeminiModels()
+		models = append(registry.GetGeminiModels(), registry.GetGeminiEmbeddingModels()...)
 	case "gemini-cli":
 		models = registry.GetGeminiCLIModels()
 	case "claude":
@@ -611,3 +773,11 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 		GlobalModelRegistry().RegisterClient(a.ID, key, models)
 	}
 }
+
+// configDir returns the directory relative config paths resolve against.
+func (s *Service) configDir() string {
+	if s.configPath == "" {
+		return "."
+	}
+	return filepath.Dir(s.configPath)
+}
@@ -0,0 +1,147 @@
+package usage
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Attempt collects the usage record of a single executor attempt. The auth manager attaches
+// one to the execution context, executors report token usage and the translation formats
+// into it, and the manager publishes the record once the attempt finished, whether it
+// succeeded or not.
+type Attempt struct {
+	mu         sync.Mutex
+	record     Record
+	start      time.Time
+	firstToken time.Time
+	ended      bool
+	published  bool
+}
+
+// NewAttempt starts timing an attempt. base carries the identity known before the executor
+// runs; its RequestedAt is replaced by the current time when unset.
+func NewAttempt(base Record) *Attempt {
+	now := time.Now()
+	if base.RequestedAt.IsZero() {
+		base.RequestedAt = now
+	}
+	return &Attempt{record: base, start: now}
+}
+
+type attemptContextKey struct{}
+
+// WithAttempt returns a context carrying attempt.
+func WithAttempt(ctx context.Context, attempt *Attempt) context.Context {
+	if attempt == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, attemptContextKey{}, attempt)
+}
+
+// AttemptFromContext returns the attempt attached to ctx, or nil.
+func AttemptFromContext(ctx context.Context) *Attempt {
+	if ctx == nil {
+		return nil
+	}
+	attempt, _ := ctx.Value(attemptContextKey{}).(*Attempt)
+	return attempt
+}
+
+// Report merges the record built by an executor into the attempt. Non-empty identity
+// fields and the token detail replace the values known so far.
+func (a *Attempt) Report(record Record) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if record.Provider != "" {
+		a.record.Provider = record.Provider
+	}
+	if record.Model != "" {
+		a.record.Model = record.Model
+	}
+	if record.RequestedModel != "" {
+		a.record.RequestedModel = record.RequestedModel
+	}
+	if record.APIKey != "" {
+		a.record.APIKey = record.APIKey
+	}
+	if record.AuthID != "" {
+		a.record.AuthID = record.AuthID
+	}
+	if record.Source != "" {
+		a.record.Source = record.Source
+	}
+	a.record.Detail = record.Detail
+}
+
+// SetFormats records the client and upstream API formats of the attempt.
+func (a *Attempt) SetFormats(source, target string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	a.record.SourceFormat = source
+	a.record.TargetFormat = target
+	a.mu.Unlock()
+}
+
+// MarkFirstToken records the arrival of the first streamed payload. Later calls are ignored.
+func (a *Attempt) MarkFirstToken() {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	if a.firstToken.IsZero() {
+		a.firstToken = time.Now()
+	}
+	a.mu.Unlock()
+}
+
+// Finish completes the attempt with the final HTTP status and, for failures, a non-empty
+// error class, then publishes the record. Only the first call publishes.
+func (a *Attempt) Finish(ctx context.Context, status int, errorClass string) {
+	a.End(status, errorClass)
+	a.Publish(ctx, false)
+}
+
+// End completes the attempt with the final HTTP status and, for failures, a non-empty
+// error class without publishing it yet. Only the first call is recorded.
+func (a *Attempt) End(status int, errorClass string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.ended {
+		return
+	}
+	a.ended = true
+	a.record.Latency = time.Since(a.start)
+	if !a.firstToken.IsZero() {
+		a.record.TimeToFirstToken = a.firstToken.Sub(a.start)
+	}
+	a.record.Status = status
+	a.record.ErrorClass = errorClass
+	a.record.Failed = errorClass != ""
+}
+
+// Publish hands the ended attempt to the usage plugins. superseded marks an attempt that
+// was followed by another attempt of the same client request. Only the first call publishes.
+func (a *Attempt) Publish(ctx context.Context, superseded bool) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	if !a.ended || a.published {
+		a.mu.Unlock()
+		return
+	}
+	a.published = true
+	record := a.record
+	record.Superseded = superseded
+	a.mu.Unlock()
+	PublishRecord(ctx, record)
+}
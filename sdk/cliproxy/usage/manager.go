@@ -10,13 +10,29 @@ import (
 
 // Record contains the usage statistics captured for a single provider request.
 type Record struct {
-	Provider    string
-	Model       string
-	APIKey      string
-	AuthID      string
-	Source      string
-	RequestedAt time.Time
-	Detail      Detail
+	Provider       string
+	Model          string
+	RequestedModel string
+	APIKey         string
+	AuthID         string
+	Source         string
+	RequestedAt    time.Time
+	Detail         Detail
+
+	// Outcome of the executor attempt. Failed attempts are published too, usually
+	// without token usage.
+	Latency          time.Duration
+	TimeToFirstToken time.Duration
+	Status           int
+	Failed           bool
+	ErrorClass       string
+	Stream           bool
+	Attempt          int
+	// Superseded marks an attempt followed by another attempt of the same client request
+	// (a retry, failover or model fallback). The last attempt decides the request outcome.
+	Superseded   bool
+	SourceFormat string
+	TargetFormat string
 }
 
 // Detail holds the token usage breakdown.
@@ -101,6 +117,21 @@ func (m *Manager) Register(plugin Plugin) {
 	m.pluginsMu.Unlock()
 }
 
+// Unregister removes a plugin from the delivery list.
+func (m *Manager) Unregister(plugin Plugin) {
+	if m == nil || plugin == nil {
+		return
+	}
+	m.pluginsMu.Lock()
+	defer m.pluginsMu.Unlock()
+	for i, registered := range m.plugins {
+		if registered == plugin {
+			m.plugins = append(m.plugins[:i:i], m.plugins[i+1:]...)
+			return
+		}
+	}
+}
+
 // Publish enqueues a usage record for processing. If no plugin is registered
 // the record will be discarded downstream.
 func (m *Manager) Publish(ctx context.Context, record Record) {
@@ -169,6 +200,9 @@ func DefaultManager() *Manager { return defaultManager }
 // RegisterPlugin registers a plugin on the default manager.
 func RegisterPlugin(plugin Plugin) { DefaultManager().Register(plugin) }
 
+// UnregisterPlugin removes a plugin from the default manager.
+func UnregisterPlugin(plugin Plugin) { DefaultManager().Unregister(plugin) }
+
 // PublishRecord publishes a record using the default manager.
 func PublishRecord(ctx context.Context, record Record) { DefaultManager().Publish(ctx, record) }
 
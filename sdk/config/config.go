@@ -20,6 +20,14 @@ type SDKConfig struct {
 
     // Responses configures behavior specific to the OpenAI /v1/responses endpoint.
     Responses ResponsesConfig `yaml:"responses,omitempty" json:"responses,omitempty"`
+
+    // Batches configures the background runner behind /v1/messages/batches and /v1/batches.
+    Batches BatchesConfig `yaml:"batches,omitempty" json:"batches,omitempty"`
+
+    // TrustedProxies lists the addresses or CIDR ranges of reverse proxies whose
+    // X-Forwarded-Proto and X-Forwarded-Host headers are honoured when building URLs
+    // returned to clients. Forwarded headers sent by other peers are ignored.
+    TrustedProxies []string `yaml:"trusted-proxies,omitempty" json:"trusted-proxies,omitempty"`
 }
 
 // AccessConfig groups request authentication providers.
@@ -93,6 +101,33 @@ type ResponsesConfig struct {
     // When true, and the model family is supported, the suffix is converted into
     // reasoning.effort unless the client already set reasoning.effort explicitly.
     InferEffortFromModelSuffix bool `yaml:"infer-effort-from-model-suffix,omitempty" json:"infer-effort-from-model-suffix,omitempty"`
+    // Store keeps responses so later requests can continue them with previous_response_id.
+    Store ResponsesStoreConfig `yaml:"store,omitempty" json:"store,omitempty"`
+}
+
+// ResponsesStoreConfig configures the local store behind previous_response_id and
+// GET/DELETE /v1/responses/{id}.
+type ResponsesStoreConfig struct {
+    // Disable turns the store off; previous_response_id is then passed through untouched.
+    Disable bool `yaml:"disable,omitempty" json:"disable,omitempty"`
+    // Backend selects where responses are kept: memory (default) or file.
+    Backend string `yaml:"backend,omitempty" json:"backend,omitempty"`
+    // Dir is the directory used by the file backend, relative to the config file (default "responses").
+    Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+    // TTLSeconds is how long a response can be continued or retrieved (default 86400).
+    TTLSeconds int `yaml:"ttl-seconds,omitempty" json:"ttl-seconds,omitempty"`
+    // MaxEntries caps the number of stored responses; least recently used ones are evicted (default 1000).
+    MaxEntries int `yaml:"max-entries,omitempty" json:"max-entries,omitempty"`
+    // MaxSizeMB caps the total size of stored responses (default 128).
+    MaxSizeMB int `yaml:"max-size-mb,omitempty" json:"max-size-mb,omitempty"`
+}
+
+// BatchesConfig tunes how message batches are executed and kept.
+type BatchesConfig struct {
+    // Concurrency caps the batch requests executed at once across all batches (default 4).
+    Concurrency int `yaml:"concurrency,omitempty" json:"concurrency,omitempty"`
+    // RetentionHours is how long ended batches and their results are kept (default 696, i.e. 29 days).
+    RetentionHours int `yaml:"retention-hours,omitempty" json:"retention-hours,omitempty"`
 }
 
 // ResponsesDefaults defines injectable defaults for /v1/responses.